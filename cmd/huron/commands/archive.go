@@ -0,0 +1,259 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/abassian/huron/src/common"
+	hg "github.com/abassian/huron/src/hashgraph"
+	"github.com/abassian/huron/src/huron"
+	"github.com/abassian/huron/src/peers"
+	aproxy "github.com/abassian/huron/src/proxy/socket/app"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+/*******************************************************************************
+Block archives
+
+export writes the Blocks of a database to an archive, and import rebuilds a
+database from an archive. The imported database only contains Blocks and
+validator-sets; it is meant to be queried with the db commands, or replayed to
+an app, not to run a node.
+*******************************************************************************/
+
+var (
+	archiveOut           string
+	archiveFormat        string
+	archiveSegmentBlocks int
+	archiveFrom          int
+	archiveTo            int
+	archiveReplay        bool
+	archiveClientAddr    string
+	archiveTimeout       time.Duration
+	archiveGenesis       string
+)
+
+//NewExportCmd returns the command which writes the Blocks of the database to an
+//archive
+func NewExportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export blocks to an archive",
+		Long: `Export the blocks of the database, with their signatures and validator-sets,
+to an archive directory. The blocks are written to gzip-compressed segment files,
+as JSON lines or binary records, listed in a checksummed index. The database is
+opened read-only, so the node must not be running.`,
+		Args: cobra.NoArgs,
+		RunE: exportArchive,
+	}
+
+	AddDBFlags(cmd)
+	cmd.Flags().StringVarP(&archiveOut, "out", "o", "huron-archive", "Directory where the archive will be written")
+	cmd.Flags().StringVar(&archiveFormat, "format", hg.ArchiveJSON, "Format of the segments: jsonl or binary")
+	cmd.Flags().IntVar(&archiveSegmentBlocks, "segment-blocks", hg.DefaultArchiveSegmentBlocks, "Maximum number of blocks per segment")
+	cmd.Flags().IntVar(&archiveFrom, "from", 0, "Index of the first block to export")
+	cmd.Flags().IntVar(&archiveTo, "to", -1, "Index of the last block to export (-1 for the last block)")
+
+	return cmd
+}
+
+//NewImportCmd returns the command which rebuilds a database from an archive
+func NewImportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import [archive]",
+		Short: "Import blocks from an archive",
+		Long: `Rebuild a database from an archive. The archive's checksums, the PeersHash and
+the signatures of every block are verified against the archived validator-sets.
+Each validator-set must result from the previous one and the validator changes
+accepted by the blocks it signed. The first validator-set is trusted as is,
+unless --genesis gives the genesis validator-set, in a peers.json file, which
+it must then match. The database must not exist; it only contains blocks and validator-sets, and
+can be queried read-only with the db commands, eg:
+
+  huron db blocks --db <path>
+
+With --replay, the blocks of the database are then committed, in order, to the
+app listening on --client-connect, and the state hashes returned by the app are
+compared with the archived ones. Without an archive argument, --replay replays
+an existing database.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: importArchive,
+	}
+
+	AddDBFlags(cmd)
+	cmd.Flags().StringVar(&archiveGenesis, "genesis", "", "JSON file of the genesis validator-set, eg. peers.genesis.json")
+	cmd.Flags().BoolVar(&archiveReplay, "replay", false, "Commit the blocks to the app")
+	cmd.Flags().StringVar(&archiveClientAddr, "client-connect", config.ClientAddr, "IP:Port of the app to replay the blocks to")
+	cmd.Flags().DurationVar(&archiveTimeout, "timeout", 10*time.Second, "Timeout of the calls to the app")
+	cmd.Flags().IntVar(&archiveFrom, "from", 0, "Index of the first block to replay")
+
+	return cmd
+}
+
+func exportArchive(cmd *cobra.Command, args []string) error {
+	path, err := dbDir()
+	if err != nil {
+		return err
+	}
+
+	store, err := openReadOnlyStore(path)
+	if err != nil {
+		return fmt.Errorf("Opening %s: %v", path, err)
+	}
+	defer store.Close()
+
+	w, err := hg.NewArchiveWriter(archiveOut, archiveFormat, archiveSegmentBlocks)
+	if err != nil {
+		return err
+	}
+
+	exported, err := store.ExportArchive(w, archiveFrom, archiveTo)
+	if err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Exported %d blocks from %s to %s\n", exported, path, archiveOut)
+
+	return nil
+}
+
+func importArchive(cmd *cobra.Command, args []string) error {
+	if len(args) == 0 && !archiveReplay {
+		return fmt.Errorf("Nothing to do: give an archive to import, or --replay")
+	}
+
+	path, err := dbTarget()
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 1 {
+		if err := importBlocks(args[0], path); err != nil {
+			return err
+		}
+	}
+
+	if archiveReplay {
+		return replayBlocks(path)
+	}
+
+	return nil
+}
+
+//importBlocks creates the database in path from the archive in dir
+func importBlocks(dir string, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+
+	r, err := hg.OpenArchive(dir)
+	if err != nil {
+		return err
+	}
+
+	var genesis *peers.PeerSet
+	if archiveGenesis != "" {
+		data, err := ioutil.ReadFile(archiveGenesis)
+		if err != nil {
+			return err
+		}
+		if genesis, err = peers.NewPeerSetFromPeerSliceBytes(data); err != nil {
+			return fmt.Errorf("Reading the genesis validator-set: %v", err)
+		}
+	}
+
+	key, err := dbKey()
+	if err != nil {
+		return err
+	}
+
+	var store *hg.DBStore
+	if dbBackend == huron.BoltBackend {
+		boltStore, err := hg.NewEncryptedBoltStore(0, path, key)
+		if err != nil {
+			return err
+		}
+		store = boltStore.DBStore
+	} else {
+		badgerStore, err := hg.NewEncryptedBadgerStore(0, path, key)
+		if err != nil {
+			return err
+		}
+		store = badgerStore.DBStore
+	}
+
+	imported, err := store.ImportArchive(r, genesis)
+
+	if cerr := store.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		os.RemoveAll(path)
+		return err
+	}
+
+	fmt.Printf("Imported %d blocks from %s to %s\n", imported, dir, path)
+	if genesis == nil {
+		fmt.Println("The first validator-set of the archive was not verified; use --genesis to anchor it")
+	}
+
+	return nil
+}
+
+//replayBlocks commits the Blocks of the database in path to the app
+func replayBlocks(path string) error {
+	store, err := openReadOnlyStore(path)
+	if err != nil {
+		return fmt.Errorf("Opening %s: %v", path, err)
+	}
+	defer store.Close()
+
+	indexes, err := store.DBBlockIndexes()
+	if err != nil {
+		return err
+	}
+
+	logger := logrus.New()
+	logger.Level = logrus.InfoLevel
+
+	client := aproxy.NewSocketAppProxyClient(archiveClientAddr, archiveTimeout, logger)
+
+	replayed := 0
+	for _, i := range indexes {
+		if i < archiveFrom {
+			continue
+		}
+
+		block, err := store.DBGetBlock(i)
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.CommitBlock(*block)
+		if err != nil {
+			return fmt.Errorf("Committing Block %d: %v", i, err)
+		}
+
+		if len(block.StateHash()) > 0 && !bytes.Equal(resp.StateHash, block.StateHash()) {
+			return fmt.Errorf("State hash of Block %d is %s instead of %s",
+				i,
+				common.EncodeToString(resp.StateHash),
+				common.EncodeToString(block.StateHash()))
+		}
+
+		replayed++
+	}
+
+	fmt.Printf("Replayed %d blocks to %s\n", replayed, archiveClientAddr)
+
+	return nil
+}
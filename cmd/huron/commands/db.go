@@ -0,0 +1,127 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/abassian/huron/src/crypto/encryption"
+	hg "github.com/abassian/huron/src/hashgraph"
+	"github.com/abassian/huron/src/huron"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dbDataDir    string
+	dbPath       string
+	dbBackend    string
+	dbKeyFile    string
+	dbPassphrase string
+)
+
+//NewDBCmd returns the command group which migrates, inspects, checks, backs up
+//and restores the node's database
+func NewDBCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "db",
+		Short: "Manage the node database",
+	}
+
+	AddDBFlags(cmd)
+
+	cmd.AddCommand(
+		newDBMigrateCmd(),
+		newDBRekeyCmd(),
+		newDBGenKeyCmd(),
+		newDBBackupCmd(),
+		newDBRestoreCmd(),
+	)
+	addDBInspectCmds(cmd)
+
+	return cmd
+}
+
+//AddDBFlags adds the flags shared by the db commands
+func AddDBFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&dbDataDir, "datadir", config.Huron.DataDir, "Top-level directory for configuration and data")
+	cmd.PersistentFlags().StringVar(&dbPath, "db", "", "Database path (defaults to badger_db or bolt.db in datadir, depending on the backend)")
+	cmd.PersistentFlags().StringVar(&dbBackend, "backend", huron.BadgerBackend, "Store backend of the database: badger or bolt")
+	cmd.PersistentFlags().StringVar(&dbKeyFile, "encryption-key-file", "", "File containing the encryption key of the database")
+	cmd.PersistentFlags().StringVar(&dbPassphrase, "encryption-passphrase", "", "Passphrase of the encryption key of the database (or HURON_ENCRYPTION_PASSPHRASE)")
+}
+
+func newDBMigrateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "migrate",
+		Short: "Upgrade the database to the current schema version",
+		Long: `Upgrade the database to the current schema version. The database is
+backed up next to the original before each migration.`,
+		RunE: migrateDB,
+	}
+}
+
+func migrateDB(cmd *cobra.Command, args []string) error {
+	path, err := dbDir()
+	if err != nil {
+		return err
+	}
+
+	logger := logrus.New()
+	logger.Level = logrus.InfoLevel
+
+	var from int
+	if dbBackend == huron.BoltBackend {
+		from, err = hg.MigrateBoltStore(path, logger.WithField("path", path))
+	} else {
+		from, err = hg.MigrateBadgerStore(path, logger.WithField("path", path))
+	}
+	if err != nil {
+		return err
+	}
+
+	if from == hg.SchemaVersion {
+		fmt.Printf("Database %s is up to date (schema version %d)\n", path, hg.SchemaVersion)
+		return nil
+	}
+
+	fmt.Printf("Database %s migrated from schema version %d to %d\n", path, from, hg.SchemaVersion)
+	fmt.Printf("Backup of the original database: %s\n", hg.SchemaBackupPath(path, from))
+
+	return nil
+}
+
+//dbDir returns the database path selected by the flags, which must exist
+func dbDir() (string, error) {
+	path, err := dbTarget()
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("No database in %s: %v", path, err)
+	}
+
+	return path, nil
+}
+
+//dbTarget returns the database path selected by the flags
+func dbTarget() (string, error) {
+	if dbBackend != huron.BadgerBackend && dbBackend != huron.BoltBackend {
+		return "", fmt.Errorf("Unknown backend %q", dbBackend)
+	}
+
+	if dbPath != "" {
+		return dbPath, nil
+	}
+
+	config.Huron.DataDir = dbDataDir
+	config.Huron.StoreBackend = dbBackend
+	return config.Huron.StorePath(), nil
+}
+
+//dbKey returns the encryption key of the database selected by the flags, or nil
+//if it is not encrypted
+func dbKey() (*encryption.Key, error) {
+	config.Huron.DataDir = dbDataDir
+	return huron.LoadEncryptionKey(dbKeyFile, dbPassphrase, config.Huron.SaltFile())
+}
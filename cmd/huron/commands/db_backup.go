@@ -0,0 +1,194 @@
+package commands
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	hg "github.com/abassian/huron/src/hashgraph"
+	"github.com/abassian/huron/src/huron"
+	"github.com/abassian/huron/src/service"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	backupFile  string
+	backupURL   string
+	backupToken string
+	backupCA    string
+)
+
+func newDBBackupCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: "Back up the database",
+		Long: `Back up the database to a file. With --url, the backup is streamed from the
+admin endpoint of a running node's HTTP service, and verified against the
+status and checksum which the node sends after it; an incomplete backup is
+deleted. Otherwise the database is read directly, and the node must not be
+running.`,
+		Args: cobra.NoArgs,
+		RunE: backupDB,
+	}
+
+	cmd.Flags().StringVarP(&backupFile, "out", "o", "huron.backup", "File where the backup will be written")
+	cmd.Flags().StringVar(&backupURL, "url", "", "URL of a running node's HTTP service, eg. http://127.0.0.1:8000")
+	cmd.Flags().StringVar(&backupToken, "token", "", "Bearer token granting the admin role on the HTTP service")
+	cmd.Flags().StringVar(&backupCA, "ca", "", "CA bundle used to verify the HTTP service certificate")
+
+	return cmd
+}
+
+func newDBRestoreCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "restore [file]",
+		Short: "Replace the database with a backup",
+		Long: `Replace the database of the data directory with a backup. The backup must have
+the current schema version and the genesis peers of the data directory. The
+replaced database is kept next to the new one. The node must not be running.`,
+		Args: cobra.ExactArgs(1),
+		RunE: restoreDB,
+	}
+}
+
+func backupDB(cmd *cobra.Command, args []string) error {
+	if _, err := os.Stat(backupFile); err == nil {
+		return fmt.Errorf("%s already exists", backupFile)
+	}
+
+	out, err := os.OpenFile(backupFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	if backupURL != "" {
+		err = fetchBackup(out)
+	} else {
+		err = readBackup(out)
+	}
+
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		os.Remove(backupFile)
+		return err
+	}
+
+	fmt.Printf("Backup written to %s\n", backupFile)
+
+	return nil
+}
+
+//readBackup backs up the database of the data directory
+func readBackup(w io.Writer) error {
+	path, err := dbDir()
+	if err != nil {
+		return err
+	}
+
+	key, err := dbKey()
+	if err != nil {
+		return err
+	}
+
+	if dbBackend == huron.BoltBackend {
+		store, err := hg.NewReadOnlyBoltStore(path, key)
+		if err != nil {
+			return fmt.Errorf("Opening %s: %v", path, err)
+		}
+		defer store.Close()
+
+		return store.Backup(w)
+	}
+
+	store, err := hg.NewReadOnlyBadgerStore(path, key)
+	if err != nil {
+		return fmt.Errorf("Opening %s: %v", path, err)
+	}
+	defer store.Close()
+
+	return store.Backup(w)
+}
+
+//fetchBackup downloads a backup from the admin endpoint of a running node, and
+//fails if the node did not mark it complete with a matching checksum, in which
+//case backupDB removes the file
+func fetchBackup(w io.Writer) error {
+	client := &http.Client{}
+
+	if backupCA != "" {
+		pem, err := ioutil.ReadFile(backupCA)
+		if err != nil {
+			return err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("No certificate found in %s", backupCA)
+		}
+
+		client.Transport = &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		}
+	}
+
+	req, err := http.NewRequest("POST", strings.TrimSuffix(backupURL, "/")+"/admin/backup", nil)
+	if err != nil {
+		return err
+	}
+
+	if backupToken != "" {
+		req.Header.Set("Authorization", "Bearer "+backupToken)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("Backup failed: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return service.ReadBackup(resp, w)
+}
+
+func restoreDB(cmd *cobra.Command, args []string) error {
+	if dbPath != "" {
+		return fmt.Errorf("restore replaces the database of --datadir; --db is not supported")
+	}
+
+	in, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	config.Huron.DataDir = dbDataDir
+	config.Huron.StoreBackend = dbBackend
+	config.Huron.Logger.Level = logrus.InfoLevel
+	config.Huron.EncryptionKeyFile = dbKeyFile
+	config.Huron.EncryptionPassphrase = dbPassphrase
+
+	replaced, err := huron.RestoreStore(&config.Huron, in)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Database %s restored from %s\n", config.Huron.StorePath(), args[0])
+	if replaced != "" {
+		fmt.Printf("Previous database moved to %s\n", replaced)
+	}
+
+	return nil
+}
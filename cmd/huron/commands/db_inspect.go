@@ -0,0 +1,660 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/abassian/huron/src/common"
+	hg "github.com/abassian/huron/src/hashgraph"
+	"github.com/abassian/huron/src/huron"
+	"github.com/abassian/huron/src/peers"
+	"github.com/spf13/cobra"
+)
+
+/*******************************************************************************
+Inspection commands
+
+These commands open the database read-only, so the node must not be running.
+Every command prints a human-readable table, or JSON with --json.
+*******************************************************************************/
+
+var dbJSON bool
+
+func addDBInspectCmds(cmd *cobra.Command) {
+	cmd.PersistentFlags().BoolVar(&dbJSON, "json", false, "Output JSON instead of text")
+
+	cmd.AddCommand(
+		newDBInspectCmd("blocks", "List blocks", cobra.NoArgs, listBlocks),
+		newDBInspectCmd("block [index]", "Show a block", cobra.ExactArgs(1), showBlock),
+		newDBInspectCmd("events", "List events in topological order", cobra.NoArgs, listEvents),
+		newDBInspectCmd("event [hash]", "Show an event", cobra.ExactArgs(1), showEvent),
+		newDBInspectCmd("rounds", "List rounds", cobra.NoArgs, listRounds),
+		newDBInspectCmd("round [index]", "Show a round", cobra.ExactArgs(1), showRound),
+		newDBInspectCmd("frames", "List frames", cobra.NoArgs, listFrames),
+		newDBInspectCmd("frame [round]", "Show a frame", cobra.ExactArgs(1), showFrame),
+		newDBInspectCmd("roots", "List the roots of all participants", cobra.NoArgs, listRoots),
+		newDBInspectCmd("root [pubkey]", "Show a participant's root", cobra.ExactArgs(1), showRoot),
+		newDBInspectCmd("peersets", "List peer-sets", cobra.NoArgs, listPeerSets),
+		newDBInspectCmd("peerset [round]", "Show a peer-set", cobra.ExactArgs(1), showPeerSet),
+		newDBInspectCmd("check", "Verify the consistency of the whole database", cobra.NoArgs, checkDB),
+	)
+}
+
+type dbInspectFunc func(store *hg.DBStore, args []string) error
+
+func newDBInspectCmd(use, short string, args cobra.PositionalArgs, f dbInspectFunc) *cobra.Command {
+	return &cobra.Command{
+		Use:   use,
+		Short: short,
+		Args:  args,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := dbDir()
+			if err != nil {
+				return err
+			}
+
+			store, err := openReadOnlyStore(path)
+			if err != nil {
+				return fmt.Errorf("Opening %s: %v", path, err)
+			}
+			defer store.Close()
+
+			return f(store, args)
+		},
+	}
+}
+
+//openReadOnlyStore opens the database of the selected backend in read-only mode
+func openReadOnlyStore(path string) (*hg.DBStore, error) {
+	key, err := dbKey()
+	if err != nil {
+		return nil, err
+	}
+
+	if dbBackend == huron.BoltBackend {
+		store, err := hg.NewReadOnlyBoltStore(path, key)
+		if err != nil {
+			return nil, err
+		}
+		return store.DBStore, nil
+	}
+
+	store, err := hg.NewReadOnlyBadgerStore(path, key)
+	if err != nil {
+		return nil, err
+	}
+	return store.DBStore, nil
+}
+
+/*******************************************************************************
+Blocks
+*******************************************************************************/
+
+type blockSummary struct {
+	Index         int
+	RoundReceived int
+	Transactions  int
+	Signatures    int
+	StateHash     string
+	FrameHash     string
+}
+
+func listBlocks(store *hg.DBStore, args []string) error {
+	indexes, err := store.DBBlockIndexes()
+	if err != nil {
+		return err
+	}
+
+	res := []blockSummary{}
+	for _, i := range indexes {
+		block, err := store.DBGetBlock(i)
+		if err != nil {
+			return err
+		}
+		res = append(res, blockSummary{
+			Index:         block.Index(),
+			RoundReceived: block.RoundReceived(),
+			Transactions:  len(block.Transactions()),
+			Signatures:    len(block.GetSignatures()),
+			StateHash:     common.EncodeToString(block.StateHash()),
+			FrameHash:     common.EncodeToString(block.FrameHash()),
+		})
+	}
+
+	if dbJSON {
+		return printJSON(res)
+	}
+
+	rows := [][]string{}
+	for _, b := range res {
+		rows = append(rows, []string{
+			strconv.Itoa(b.Index),
+			strconv.Itoa(b.RoundReceived),
+			strconv.Itoa(b.Transactions),
+			strconv.Itoa(b.Signatures),
+			b.StateHash,
+		})
+	}
+	return printTable([]string{"INDEX", "ROUND RECEIVED", "TXS", "SIGNATURES", "STATE HASH"}, rows)
+}
+
+func showBlock(store *hg.DBStore, args []string) error {
+	index, err := strconv.Atoi(args[0])
+	if err != nil {
+		return err
+	}
+
+	block, err := store.DBGetBlock(index)
+	if err != nil {
+		return err
+	}
+
+	if dbJSON {
+		return printJSON(block)
+	}
+
+	if err := printFields([][]string{
+		{"Index", strconv.Itoa(block.Index())},
+		{"Hash", block.Hex()},
+		{"Round Received", strconv.Itoa(block.RoundReceived())},
+		{"State Hash", common.EncodeToString(block.StateHash())},
+		{"Frame Hash", common.EncodeToString(block.FrameHash())},
+		{"Peers Hash", common.EncodeToString(block.PeersHash())},
+		{"Transactions", strconv.Itoa(len(block.Transactions()))},
+		{"Internal Transactions", strconv.Itoa(len(block.InternalTransactions()))},
+	}); err != nil {
+		return err
+	}
+
+	fmt.Println()
+
+	rows := [][]string{}
+	for _, sig := range block.GetSignatures() {
+		valid, _ := block.Verify(sig)
+		rows = append(rows, []string{sig.ValidatorHex(), strconv.FormatBool(valid)})
+	}
+	return printTable([]string{"VALIDATOR", "VALID SIGNATURE"}, rows)
+}
+
+/*******************************************************************************
+Events
+*******************************************************************************/
+
+type eventSummary struct {
+	Hash         string
+	Creator      string
+	Index        int
+	SelfParent   string
+	OtherParent  string
+	Transactions int
+}
+
+func listEvents(store *hg.DBStore, args []string) error {
+	events, err := store.DBTopologicalEvents()
+	if err != nil {
+		return err
+	}
+
+	repertoire, err := store.DBRepertoire()
+	if err != nil {
+		return err
+	}
+
+	res := []eventSummary{}
+	for _, e := range events {
+		res = append(res, eventSummary{
+			Hash:         e.Hex(),
+			Creator:      e.Creator(),
+			Index:        e.Index(),
+			SelfParent:   e.SelfParent(),
+			OtherParent:  e.OtherParent(),
+			Transactions: len(e.Transactions()),
+		})
+	}
+
+	if dbJSON {
+		return printJSON(res)
+	}
+
+	rows := [][]string{}
+	for _, e := range res {
+		rows = append(rows, []string{
+			e.Hash,
+			peerName(repertoire, e.Creator),
+			strconv.Itoa(e.Index),
+			strconv.Itoa(e.Transactions),
+		})
+	}
+	return printTable([]string{"HASH", "CREATOR", "INDEX", "TXS"}, rows)
+}
+
+func showEvent(store *hg.DBStore, args []string) error {
+	event, err := store.DBGetEvent(strings.ToUpper(args[0]))
+	if err != nil {
+		return err
+	}
+
+	if dbJSON {
+		return printJSON(event)
+	}
+
+	repertoire, err := store.DBRepertoire()
+	if err != nil {
+		return err
+	}
+
+	valid, _ := event.Verify()
+
+	return printFields([][]string{
+		{"Hash", event.Hex()},
+		{"Creator", event.Creator()},
+		{"Moniker", peerName(repertoire, event.Creator())},
+		{"Index", strconv.Itoa(event.Index())},
+		{"Self-Parent", event.SelfParent()},
+		{"Other-Parent", event.OtherParent()},
+		{"Transactions", strconv.Itoa(len(event.Transactions()))},
+		{"Internal Transactions", strconv.Itoa(len(event.InternalTransactions()))},
+		{"Block Signatures", strconv.Itoa(len(event.BlockSignatures()))},
+		{"Valid Signature", strconv.FormatBool(valid)},
+	})
+}
+
+/*******************************************************************************
+Rounds
+*******************************************************************************/
+
+type roundSummary struct {
+	Index          int
+	CreatedEvents  int
+	Witnesses      int
+	ReceivedEvents int
+}
+
+func listRounds(store *hg.DBStore, args []string) error {
+	indexes, err := store.DBRoundIndexes()
+	if err != nil {
+		return err
+	}
+
+	res := []roundSummary{}
+	for _, i := range indexes {
+		round, err := store.DBGetRound(i)
+		if err != nil {
+			return err
+		}
+		res = append(res, roundSummary{
+			Index:          i,
+			CreatedEvents:  len(round.CreatedEvents),
+			Witnesses:      len(round.Witnesses()),
+			ReceivedEvents: len(round.ReceivedEvents),
+		})
+	}
+
+	if dbJSON {
+		return printJSON(res)
+	}
+
+	rows := [][]string{}
+	for _, r := range res {
+		rows = append(rows, []string{
+			strconv.Itoa(r.Index),
+			strconv.Itoa(r.CreatedEvents),
+			strconv.Itoa(r.Witnesses),
+			strconv.Itoa(r.ReceivedEvents),
+		})
+	}
+	return printTable([]string{"ROUND", "CREATED", "WITNESSES", "RECEIVED"}, rows)
+}
+
+func showRound(store *hg.DBStore, args []string) error {
+	index, err := strconv.Atoi(args[0])
+	if err != nil {
+		return err
+	}
+
+	round, err := store.DBGetRound(index)
+	if err != nil {
+		return err
+	}
+
+	if dbJSON {
+		return printJSON(round)
+	}
+
+	created := []string{}
+	for x := range round.CreatedEvents {
+		created = append(created, x)
+	}
+	sort.Strings(created)
+
+	rows := [][]string{}
+	for _, x := range created {
+		re := round.CreatedEvents[x]
+		famous := ""
+		if re.Witness {
+			famous = re.Famous.String()
+		}
+		rows = append(rows, []string{x, strconv.FormatBool(re.Witness), famous})
+	}
+
+	fmt.Printf("Round %d: %d created events, %d received events\n\n", index, len(round.CreatedEvents), len(round.ReceivedEvents))
+
+	return printTable([]string{"CREATED EVENT", "WITNESS", "FAMOUS"}, rows)
+}
+
+/*******************************************************************************
+Frames
+*******************************************************************************/
+
+type frameSummary struct {
+	Round      int
+	Hash       string
+	Peers      int
+	RootEvents int
+	Events     int
+}
+
+func summariseFrame(frame *hg.Frame) (frameSummary, error) {
+	hash, err := frame.Hash()
+	if err != nil {
+		return frameSummary{}, err
+	}
+
+	rootEvents := 0
+	for _, r := range frame.Roots {
+		rootEvents += len(r.Events)
+	}
+
+	return frameSummary{
+		Round:      frame.Round,
+		Hash:       common.EncodeToString(hash),
+		Peers:      len(frame.Peers),
+		RootEvents: rootEvents,
+		Events:     len(frame.Events),
+	}, nil
+}
+
+func listFrames(store *hg.DBStore, args []string) error {
+	indexes, err := store.DBFrameIndexes()
+	if err != nil {
+		return err
+	}
+
+	res := []frameSummary{}
+	for _, i := range indexes {
+		frame, err := store.DBGetFrame(i)
+		if err != nil {
+			return err
+		}
+		summary, err := summariseFrame(frame)
+		if err != nil {
+			return err
+		}
+		res = append(res, summary)
+	}
+
+	if dbJSON {
+		return printJSON(res)
+	}
+
+	rows := [][]string{}
+	for _, f := range res {
+		rows = append(rows, []string{
+			strconv.Itoa(f.Round),
+			strconv.Itoa(f.Peers),
+			strconv.Itoa(f.RootEvents),
+			strconv.Itoa(f.Events),
+			f.Hash,
+		})
+	}
+	return printTable([]string{"ROUND", "PEERS", "ROOT EVENTS", "EVENTS", "HASH"}, rows)
+}
+
+func showFrame(store *hg.DBStore, args []string) error {
+	round, err := strconv.Atoi(args[0])
+	if err != nil {
+		return err
+	}
+
+	frame, err := store.DBGetFrame(round)
+	if err != nil {
+		return err
+	}
+
+	if dbJSON {
+		return printJSON(frame)
+	}
+
+	summary, err := summariseFrame(frame)
+	if err != nil {
+		return err
+	}
+
+	if err := printFields([][]string{
+		{"Round", strconv.Itoa(summary.Round)},
+		{"Hash", summary.Hash},
+		{"Peers", strconv.Itoa(summary.Peers)},
+		{"Root Events", strconv.Itoa(summary.RootEvents)},
+		{"Events", strconv.Itoa(summary.Events)},
+	}); err != nil {
+		return err
+	}
+
+	fmt.Println()
+
+	return printFrameEvents(frame.SortedFrameEvents())
+}
+
+/*******************************************************************************
+Roots
+*******************************************************************************/
+
+type rootSummary struct {
+	Participant string
+	Moniker     string
+	Events      int
+	Head        string
+}
+
+func listRoots(store *hg.DBStore, args []string) error {
+	repertoire, err := store.DBRepertoire()
+	if err != nil {
+		return err
+	}
+
+	participants := []string{}
+	for p := range repertoire {
+		participants = append(participants, p)
+	}
+	sort.Strings(participants)
+
+	res := []rootSummary{}
+	for _, p := range participants {
+		root, err := store.DBGetRoot(p)
+		if err != nil {
+			return err
+		}
+
+		head := ""
+		if len(root.Events) > 0 {
+			head = root.Events[len(root.Events)-1].Core.Hex()
+		}
+
+		res = append(res, rootSummary{
+			Participant: p,
+			Moniker:     repertoire[p].Moniker,
+			Events:      len(root.Events),
+			Head:        head,
+		})
+	}
+
+	if dbJSON {
+		return printJSON(res)
+	}
+
+	rows := [][]string{}
+	for _, r := range res {
+		rows = append(rows, []string{r.Participant, r.Moniker, strconv.Itoa(r.Events), r.Head})
+	}
+	return printTable([]string{"PARTICIPANT", "MONIKER", "EVENTS", "HEAD"}, rows)
+}
+
+func showRoot(store *hg.DBStore, args []string) error {
+	root, err := store.DBGetRoot(strings.ToUpper(args[0]))
+	if err != nil {
+		return err
+	}
+
+	if dbJSON {
+		return printJSON(root)
+	}
+
+	return printFrameEvents(root.Events)
+}
+
+func printFrameEvents(frameEvents []*hg.FrameEvent) error {
+	rows := [][]string{}
+	for _, fe := range frameEvents {
+		rows = append(rows, []string{
+			fe.Core.Hex(),
+			fe.Core.Creator(),
+			strconv.Itoa(fe.Core.Index()),
+			strconv.Itoa(fe.Round),
+			strconv.Itoa(fe.LamportTimestamp),
+			strconv.FormatBool(fe.Witness),
+		})
+	}
+	return printTable([]string{"HASH", "CREATOR", "INDEX", "ROUND", "LAMPORT", "WITNESS"}, rows)
+}
+
+/*******************************************************************************
+PeerSets
+*******************************************************************************/
+
+type peerSetSummary struct {
+	Round int
+	Peers int
+	Hash  string
+}
+
+func listPeerSets(store *hg.DBStore, args []string) error {
+	rounds, err := store.DBPeerSetRounds()
+	if err != nil {
+		return err
+	}
+
+	res := []peerSetSummary{}
+	for _, r := range rounds {
+		peerSet, err := store.DBGetPeerSet(r)
+		if err != nil {
+			return err
+		}
+		res = append(res, peerSetSummary{
+			Round: r,
+			Peers: peerSet.Len(),
+			Hash:  peerSet.Hex(),
+		})
+	}
+
+	if dbJSON {
+		return printJSON(res)
+	}
+
+	rows := [][]string{}
+	for _, p := range res {
+		rows = append(rows, []string{strconv.Itoa(p.Round), strconv.Itoa(p.Peers), p.Hash})
+	}
+	return printTable([]string{"ROUND", "PEERS", "HASH"}, rows)
+}
+
+func showPeerSet(store *hg.DBStore, args []string) error {
+	round, err := strconv.Atoi(args[0])
+	if err != nil {
+		return err
+	}
+
+	peerSet, err := store.DBGetPeerSet(round)
+	if err != nil {
+		return err
+	}
+
+	if dbJSON {
+		return printJSON(peerSet.Peers)
+	}
+
+	rows := [][]string{}
+	for _, p := range peerSet.Peers {
+		rows = append(rows, []string{fmt.Sprint(p.ID()), p.Moniker, p.NetAddr, p.PubKeyString()})
+	}
+	return printTable([]string{"ID", "MONIKER", "ADDRESS", "PUBKEY"}, rows)
+}
+
+/*******************************************************************************
+Check
+*******************************************************************************/
+
+func checkDB(store *hg.DBStore, args []string) error {
+	report, err := store.Check()
+	if err != nil {
+		return err
+	}
+
+	if dbJSON {
+		if err := printJSON(report); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("Checked %d events, %d rounds, %d frames and %d blocks\n",
+			report.Events, report.Rounds, report.Frames, report.Blocks)
+		for _, e := range report.Errors {
+			fmt.Println(e)
+		}
+	}
+
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("Found %d inconsistencies", len(report.Errors))
+	}
+
+	return nil
+}
+
+/*******************************************************************************
+Output
+*******************************************************************************/
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func printTable(header []string, rows [][]string) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, r := range rows {
+		fmt.Fprintln(w, strings.Join(r, "\t"))
+	}
+	return w.Flush()
+}
+
+func printFields(fields [][]string) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, f := range fields {
+		fmt.Fprintf(w, "%s:\t%s\n", f[0], f[1])
+	}
+	return w.Flush()
+}
+
+//peerName returns the moniker of a participant, or its public key if it has
+//none
+func peerName(repertoire map[string]*peers.Peer, pubKey string) string {
+	if p, ok := repertoire[pubKey]; ok && p.Moniker != "" {
+		return p.Moniker
+	}
+	return pubKey
+}
@@ -0,0 +1,135 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/abassian/huron/src/crypto/encryption"
+	hg "github.com/abassian/huron/src/hashgraph"
+	"github.com/abassian/huron/src/huron"
+	"github.com/abassian/huron/src/node"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rekeyNewKeyFile    string
+	rekeyNewPassphrase string
+	rekeyDecrypt       bool
+)
+
+func newDBRekeyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rekey",
+		Short: "Encrypt the database and snapshots with a new key",
+		Long: `Re-encrypt the database, and the snapshots of the data directory, with the key
+given by --new-key-file or --new-passphrase. The current key is given by
+--encryption-key-file or --encryption-passphrase, and is omitted to encrypt an
+unencrypted database. With --decrypt, the database and snapshots are decrypted
+instead. An interrupted rekey can be resumed by running the same command again.
+The node must not be running.`,
+		Args: cobra.NoArgs,
+		RunE: rekeyDB,
+	}
+
+	cmd.Flags().StringVar(&rekeyNewKeyFile, "new-key-file", "", "File containing the new encryption key")
+	cmd.Flags().StringVar(&rekeyNewPassphrase, "new-passphrase", "", "Passphrase from which the new encryption key is derived")
+	cmd.Flags().BoolVar(&rekeyDecrypt, "decrypt", false, "Decrypt the database and snapshots")
+
+	return cmd
+}
+
+func newDBGenKeyCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "genkey [file]",
+		Short: "Write a new random encryption key to a file",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			secret, err := encryption.GenerateSecret()
+			if err != nil {
+				return err
+			}
+
+			if err := encryption.WriteKeyFile(args[0], secret); err != nil {
+				return err
+			}
+
+			key, err := encryption.NewKey(secret)
+			if err != nil {
+				return err
+			}
+
+			fmt.Printf("Encryption key %s written to %s\n", key.ID(), args[0])
+
+			return nil
+		},
+	}
+}
+
+func rekeyDB(cmd *cobra.Command, args []string) error {
+	path, err := dbDir()
+	if err != nil {
+		return err
+	}
+
+	oldKey, err := dbKey()
+	if err != nil {
+		return err
+	}
+
+	newKey, err := rekeyNewKey()
+	if err != nil {
+		return err
+	}
+
+	if encryption.Equal(oldKey, newKey) {
+		return fmt.Errorf("The new key is the current key")
+	}
+
+	var count int
+	if dbBackend == huron.BoltBackend {
+		count, err = hg.RekeyBoltStore(path, oldKey, newKey)
+	} else {
+		count, err = hg.RekeyBadgerStore(path, oldKey, newKey)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Database %s: %d values re-encrypted\n", path, count)
+
+	//the snapshots belong to the database of the data directory
+	if dbPath == "" {
+		dir := config.Huron.SnapshotDir()
+
+		count, err := node.RekeySnapshots(dir, oldKey, newKey)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Snapshots %s: %d files re-encrypted\n", dir, count)
+	}
+
+	if newKey == nil {
+		fmt.Println("The database is no longer encrypted")
+	} else {
+		fmt.Printf("The database is encrypted with key %s\n", newKey.ID())
+	}
+
+	return nil
+}
+
+//rekeyNewKey returns the key selected by the --new-* flags, or nil with
+//--decrypt. Unlike the current key, it is never read from the environment.
+func rekeyNewKey() (*encryption.Key, error) {
+	if rekeyDecrypt {
+		if rekeyNewKeyFile != "" || rekeyNewPassphrase != "" {
+			return nil, fmt.Errorf("--decrypt cannot be used with a new key")
+		}
+		return nil, nil
+	}
+
+	if rekeyNewKeyFile == "" && rekeyNewPassphrase == "" {
+		return nil, fmt.Errorf("A new key is required: --new-key-file, --new-passphrase, or --decrypt")
+	}
+
+	return huron.LoadEncryptionKey(rekeyNewKeyFile, rekeyNewPassphrase, config.Huron.SaltFile())
+}
@@ -1,6 +1,11 @@
 package commands
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"github.com/abassian/huron/src/huron"
 	"github.com/abassian/huron/src/proxy/dummy"
 	aproxy "github.com/abassian/huron/src/proxy/socket/app"
@@ -53,9 +58,25 @@ func runHuron(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	engine.Run()
+	//Politely leave the network on SIGINT or SIGTERM. Signal handling is done
+	//here rather than in the node so that Huron can be embedded in other
+	//programs.
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	go func() {
+		select {
+		case <-sigCh:
+			config.Huron.Logger.Debug("Reacting to signal - LEAVE")
+			if err := engine.Leave(context.Background()); err != nil {
+				config.Huron.Logger.WithError(err).Error("Leaving")
+			}
+		case <-engine.Done():
+		}
+	}()
 
-	return nil
+	return engine.Run(context.Background())
 }
 
 /*******************************************************************************
@@ -82,16 +103,40 @@ func AddRunFlags(cmd *cobra.Command) {
 
 	// Service
 	cmd.Flags().StringP("service-listen", "s", config.Huron.ServiceAddr, "Listen IP:Port for HTTP service")
+	cmd.Flags().String("service-token", config.Huron.ServiceToken, "Bearer token granting the admin role on the HTTP service (submitting transactions)")
+	cmd.Flags().String("service-read-token", config.Huron.ServiceReadToken, "Bearer token granting the read-only role on the HTTP service")
+	cmd.Flags().String("service-cert", config.Huron.ServiceCert, "TLS certificate file for the HTTP service")
+	cmd.Flags().String("service-key", config.Huron.ServiceKey, "TLS private key file for the HTTP service")
+	cmd.Flags().String("service-client-ca", config.Huron.ServiceClientCA, "CA bundle used to verify HTTP service client certificates")
+	cmd.Flags().StringSlice("service-admin-clients", config.Huron.ServiceAdminClients, "Common names of the client certificates granted the admin role")
+	cmd.Flags().StringSlice("service-allowed-origins", config.Huron.ServiceAllowedOrigins, "Origins allowed to make cross-origin requests to the HTTP service (* for any)")
 
 	// Store
+	cmd.Flags().String("store-backend", config.Huron.StoreBackend, "Store backend: inmem, hybrid, badger or bolt")
 	cmd.Flags().Bool("store", config.Huron.Store, "Use badgerDB instead of in-mem DB")
+	cmd.Flags().MarkDeprecated("store", "use --store-backend badger instead")
 	cmd.Flags().Bool("bootstrap", config.Huron.NodeConfig.Bootstrap, "Load from database")
 	cmd.Flags().Int("cache-size", config.Huron.NodeConfig.CacheSize, "Number of items in LRU caches")
+	cmd.Flags().Int("cache-memory", config.Huron.NodeConfig.CacheMemory, "Memory budget of the LRU caches in MB, overriding cache-size (0 sizes caches by items)")
+	cmd.Flags().String("cache-shares", config.Huron.NodeConfig.CacheShares, "Relative shares of the cache memory, eg. store.event=40,hashgraph.ancestor=5")
+	cmd.Flags().String("encryption-key-file", config.Huron.EncryptionKeyFile, "File containing the key which encrypts the store and snapshots at rest")
+	cmd.Flags().String("encryption-passphrase", config.Huron.EncryptionPassphrase, "Passphrase from which the at-rest encryption key is derived (or HURON_ENCRYPTION_PASSPHRASE)")
 
 	// Node configuration
 	cmd.Flags().Duration("heartbeat", config.Huron.NodeConfig.HeartbeatTimeout, "Time between gossips")
 	cmd.Flags().Int("sync-limit", config.Huron.NodeConfig.SyncLimit, "Max number of events for sync")
 	cmd.Flags().Bool("fast-sync", config.Huron.NodeConfig.EnableFastSync, "Enable FastSync")
+	cmd.Flags().String("peer-selector", config.Huron.NodeConfig.PeerSelector, "Peer selection strategy: random, latency, unknown-events or round-robin")
+	cmd.Flags().Int("fan-out", config.Huron.NodeConfig.FanOut, "Number of peers to push new events to eagerly (0 disables fan-out)")
+	cmd.Flags().Bool("observer", config.Huron.NodeConfig.Observer, "Follow consensus without joining the validator-set")
+	cmd.Flags().Int("snapshot-interval", config.Huron.NodeConfig.SnapshotInterval, "Number of blocks between app snapshots (0 disables periodic snapshots)")
+	cmd.Flags().Int("snapshot-retention", config.Huron.NodeConfig.SnapshotRetention, "Max number of app snapshots kept on disk (0 for no limit)")
+	cmd.Flags().Duration("ready-max-round-age", config.Huron.NodeConfig.ReadyMaxRoundAge, "Max time since the last consensus round, with pending work, for the node to be ready (0 disables the check)")
+	cmd.Flags().Duration("stall-timeout", config.Huron.NodeConfig.StallTimeout, "Time without a new consensus round, with pending work, before logging a diagnostic (0 disables the watchdog)")
+	cmd.Flags().Duration("ready-max-peer-silence", config.Huron.NodeConfig.ReadyMaxPeerSilence, "Max time since the last sync with a validator for it to count towards readiness (0 disables the check)")
+	cmd.Flags().Int("prune-depth", config.Huron.NodeConfig.PruneDepth, "Number of rounds kept behind the fast-forward base when pruning the store (0 disables pruning)")
+	cmd.Flags().Duration("prune-interval", config.Huron.NodeConfig.PruneInterval, "Time between two prunings of the store")
+	cmd.Flags().String("bootstrap-mode", config.Huron.NodeConfig.BootstrapMode, "Bootstrap mode: fast (from the last suitable snapshot, which needs snapshot-interval or prune-depth) or full (replay the whole store)")
 }
 
 func loadConfig(cmd *cobra.Command, args []string) error {
@@ -110,23 +155,41 @@ func loadConfig(cmd *cobra.Command, args []string) error {
 	config.Huron.NodeConfig.Logger = config.Huron.Logger
 
 	config.Huron.Logger.WithFields(logrus.Fields{
-		"huron.DataDir":               config.Huron.DataDir,
-		"huron.BindAddr":              config.Huron.BindAddr,
-		"huron.ServiceAddr":           config.Huron.ServiceAddr,
-		"huron.MaxPool":               config.Huron.MaxPool,
-		"huron.Store":                 config.Huron.Store,
-		"huron.LoadPeers":             config.Huron.LoadPeers,
-		"huron.LogLevel":              config.Huron.LogLevel,
-		"huron.Moniker":               config.Huron.Moniker,
-		"huron.Node.HeartbeatTimeout": config.Huron.NodeConfig.HeartbeatTimeout,
-		"huron.Node.TCPTimeout":       config.Huron.NodeConfig.TCPTimeout,
-		"huron.Node.JoinTimeout":      config.Huron.NodeConfig.JoinTimeout,
-		"huron.Node.CacheSize":        config.Huron.NodeConfig.CacheSize,
-		"huron.Node.SyncLimit":        config.Huron.NodeConfig.SyncLimit,
-		"huron.Node.EnableFastSync":   config.Huron.NodeConfig.EnableFastSync,
-		"ProxyAddr":                    config.ProxyAddr,
-		"ClientAddr":                   config.ClientAddr,
-		"Standalone":                   config.Standalone,
+		"huron.DataDir":                  config.Huron.DataDir,
+		"huron.BindAddr":                 config.Huron.BindAddr,
+		"huron.ServiceAddr":              config.Huron.ServiceAddr,
+		"huron.ServiceCert":              config.Huron.ServiceCert,
+		"huron.ServiceClientCA":          config.Huron.ServiceClientCA,
+		"huron.ServiceAdminClients":      config.Huron.ServiceAdminClients,
+		"huron.ServiceAllowedOrigins":    config.Huron.ServiceAllowedOrigins,
+		"huron.MaxPool":                  config.Huron.MaxPool,
+		"huron.StoreBackend":             config.Huron.Backend(),
+		"huron.EncryptionKeyFile":        config.Huron.EncryptionKeyFile,
+		"huron.LoadPeers":                config.Huron.LoadPeers,
+		"huron.LogLevel":                 config.Huron.LogLevel,
+		"huron.Moniker":                  config.Huron.Moniker,
+		"huron.Node.HeartbeatTimeout":    config.Huron.NodeConfig.HeartbeatTimeout,
+		"huron.Node.TCPTimeout":          config.Huron.NodeConfig.TCPTimeout,
+		"huron.Node.JoinTimeout":         config.Huron.NodeConfig.JoinTimeout,
+		"huron.Node.CacheSize":           config.Huron.NodeConfig.CacheSize,
+		"huron.Node.CacheMemory":         config.Huron.NodeConfig.CacheMemory,
+		"huron.Node.CacheShares":         config.Huron.NodeConfig.CacheShares,
+		"huron.Node.SyncLimit":           config.Huron.NodeConfig.SyncLimit,
+		"huron.Node.EnableFastSync":      config.Huron.NodeConfig.EnableFastSync,
+		"huron.Node.Observer":            config.Huron.NodeConfig.Observer,
+		"huron.Node.PeerSelector":        config.Huron.NodeConfig.PeerSelector,
+		"huron.Node.FanOut":              config.Huron.NodeConfig.FanOut,
+		"huron.Node.SnapshotInterval":    config.Huron.NodeConfig.SnapshotInterval,
+		"huron.Node.SnapshotRetention":   config.Huron.NodeConfig.SnapshotRetention,
+		"huron.Node.ReadyMaxRoundAge":    config.Huron.NodeConfig.ReadyMaxRoundAge,
+		"huron.Node.ReadyMaxPeerSilence": config.Huron.NodeConfig.ReadyMaxPeerSilence,
+		"huron.Node.StallTimeout":        config.Huron.NodeConfig.StallTimeout,
+		"huron.Node.PruneDepth":          config.Huron.NodeConfig.PruneDepth,
+		"huron.Node.PruneInterval":       config.Huron.NodeConfig.PruneInterval,
+		"huron.Node.BootstrapMode":       config.Huron.NodeConfig.BootstrapMode,
+		"ProxyAddr":                      config.ProxyAddr,
+		"ClientAddr":                     config.ClientAddr,
+		"Standalone":                     config.Standalone,
 	}).Debug("RUN")
 
 	return nil
@@ -13,7 +13,10 @@ func main() {
 	rootCmd.AddCommand(
 		cmd.VersionCmd,
 		cmd.NewKeygenCmd(),
-		cmd.NewRunCmd())
+		cmd.NewRunCmd(),
+		cmd.NewDBCmd(),
+		cmd.NewExportCmd(),
+		cmd.NewImportCmd())
 
 	//Do not print usage when error occurs
 	rootCmd.SilenceUsage = true
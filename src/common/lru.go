@@ -7,18 +7,40 @@ import "container/list"
 // EvictCallback is used to get a callback when a cache entry is evicted
 type EvictCallback func(key interface{}, value interface{})
 
-// LRU implements a non-thread safe fixed size LRU cache
+// Sizer estimates the number of bytes used by a cache entry
+type Sizer func(key interface{}, value interface{}) int64
+
+// LRUStats are the counters of an LRU cache
+type LRUStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Len       int
+	Bytes     int64
+	MaxBytes  int64
+}
+
+// LRU implements a non-thread safe LRU cache, bounded by a number of items or,
+// when it has a Sizer, by the estimated size of its items in bytes
 type LRU struct {
 	size      int
+	maxBytes  int64
+	bytes     int64
+	sizer     Sizer
 	evictList *list.List
 	items     map[interface{}]*list.Element
 	onEvict   EvictCallback
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
 }
 
 // entry is used to hold a value in the evictList
 type entry struct {
 	key   interface{}
 	value interface{}
+	size  int64
 }
 
 // NewLRU constructs an LRU of the given size
@@ -32,6 +54,16 @@ func NewLRU(size int, onEvict EvictCallback) *LRU {
 	return c
 }
 
+// NewSizedLRU constructs an LRU whose items are bounded by maxBytes, as
+// estimated by sizer. The most recent item is always kept, even if it is
+// larger than maxBytes.
+func NewSizedLRU(maxBytes int64, sizer Sizer, onEvict EvictCallback) *LRU {
+	c := NewLRU(0, onEvict)
+	c.maxBytes = maxBytes
+	c.sizer = sizer
+	return c
+}
+
 // Purge is used to completely clear the cache
 func (c *LRU) Purge() {
 	for k, v := range c.items {
@@ -41,6 +73,7 @@ func (c *LRU) Purge() {
 		delete(c.items, k)
 	}
 	c.evictList.Init()
+	c.bytes = 0
 }
 
 // Add adds a value to the cache.  Returns true if an eviction occurred.
@@ -48,29 +81,32 @@ func (c *LRU) Add(key, value interface{}) bool {
 	// Check for existing item
 	if ent, ok := c.items[key]; ok {
 		c.evictList.MoveToFront(ent)
-		ent.Value.(*entry).value = value
-		return false
+		e := ent.Value.(*entry)
+		e.value = value
+		c.bytes -= e.size
+		e.size = c.sizeOf(key, value)
+		c.bytes += e.size
+		return c.evict() > 0
 	}
 
 	// Add new item
-	ent := &entry{key, value}
+	ent := &entry{key, value, c.sizeOf(key, value)}
 	entry := c.evictList.PushFront(ent)
 	c.items[key] = entry
+	c.bytes += ent.size
 
-	evict := c.evictList.Len() > c.size
 	// Verify size not exceeded
-	if evict {
-		c.removeOldest()
-	}
-	return evict
+	return c.evict() > 0
 }
 
 // Get looks up a key's value from the cache.
 func (c *LRU) Get(key interface{}) (value interface{}, ok bool) {
 	if ent, ok := c.items[key]; ok {
 		c.evictList.MoveToFront(ent)
+		c.hits++
 		return ent.Value.(*entry).value, true
 	}
+	c.misses++
 	return
 }
 
@@ -137,6 +173,81 @@ func (c *LRU) Len() int {
 	return c.evictList.Len()
 }
 
+// Bytes returns the estimated size of the items in the cache, or 0 if the
+// cache has no Sizer.
+func (c *LRU) Bytes() int64 {
+	return c.bytes
+}
+
+// Resize changes the maximum number of items of the cache, and returns the
+// number of evicted items. The cache no longer has a Sizer.
+func (c *LRU) Resize(size int) int {
+	return c.resize(size, 0, nil)
+}
+
+// ResizeBytes changes the maximum size of the cache in bytes, as estimated by
+// sizer, and returns the number of evicted items. The cache is no longer
+// bounded by a number of items.
+func (c *LRU) ResizeBytes(maxBytes int64, sizer Sizer) int {
+	return c.resize(0, maxBytes, sizer)
+}
+
+// Stats returns the hit, miss and eviction counters of the cache, with its
+// current length, size, and maximum size.
+func (c *LRU) Stats() LRUStats {
+	return LRUStats{
+		Hits:      c.hits,
+		Misses:    c.misses,
+		Evictions: c.evictions,
+		Len:       c.Len(),
+		Bytes:     c.bytes,
+		MaxBytes:  c.maxBytes,
+	}
+}
+
+func (c *LRU) resize(size int, maxBytes int64, sizer Sizer) int {
+	c.size = size
+	c.maxBytes = maxBytes
+	c.sizer = sizer
+
+	c.bytes = 0
+	for ent := c.evictList.Front(); ent != nil; ent = ent.Next() {
+		e := ent.Value.(*entry)
+		e.size = c.sizeOf(e.key, e.value)
+		c.bytes += e.size
+	}
+
+	return c.evict()
+}
+
+// sizeOf returns the estimated size of an entry, or 0 if the cache has no Sizer
+func (c *LRU) sizeOf(key, value interface{}) int64 {
+	if c.sizer == nil {
+		return 0
+	}
+	return c.sizer(key, value)
+}
+
+// evict removes the oldest items until the cache fits its bounds, and returns
+// the number of evicted items.
+func (c *LRU) evict() int {
+	evicted := 0
+	for c.overflows() {
+		c.removeOldest()
+		c.evictions++
+		evicted++
+	}
+	return evicted
+}
+
+func (c *LRU) overflows() bool {
+	n := c.evictList.Len()
+	if c.sizer != nil {
+		return n > 1 && c.bytes > c.maxBytes
+	}
+	return n > c.size
+}
+
 // removeOldest removes the oldest item from the cache.
 func (c *LRU) removeOldest() {
 	ent := c.evictList.Back()
@@ -150,6 +261,7 @@ func (c *LRU) removeElement(e *list.Element) {
 	c.evictList.Remove(e)
 	kv := e.Value.(*entry)
 	delete(c.items, kv.key)
+	c.bytes -= kv.size
 	if c.onEvict != nil {
 		c.onEvict(kv.key, kv.value)
 	}
@@ -151,3 +151,83 @@ func TestLRU_Peek(t *testing.T) {
 		t.Errorf("should not have updated recent-ness of 1")
 	}
 }
+
+// Test that a sized LRU is bounded by the size of its items
+func TestSizedLRU(t *testing.T) {
+	sizer := func(k, v interface{}) int64 {
+		return int64(len(v.(string)))
+	}
+	l := NewSizedLRU(10, sizer, nil)
+
+	l.Add(1, "aaaa")
+	l.Add(2, "bbbb")
+	if l.Len() != 2 || l.Bytes() != 8 {
+		t.Fatalf("bad len or bytes: %v, %v", l.Len(), l.Bytes())
+	}
+
+	if !l.Add(3, "cccc") {
+		t.Errorf("should have an eviction")
+	}
+	if l.Contains(1) || l.Bytes() != 8 {
+		t.Errorf("1 should have been evicted: %v", l.Bytes())
+	}
+
+	// updating a value updates the size
+	l.Add(2, "bb")
+	if l.Bytes() != 6 {
+		t.Errorf("bad bytes after update: %v", l.Bytes())
+	}
+
+	// the newest item is kept even if it exceeds the budget
+	l.Add(4, "dddddddddddd")
+	if l.Len() != 1 || !l.Contains(4) || l.Bytes() != 12 {
+		t.Errorf("only the newest item should be kept: %v", l.Keys())
+	}
+
+	l.Remove(4)
+	if l.Bytes() != 0 {
+		t.Errorf("bad bytes after remove: %v", l.Bytes())
+	}
+}
+
+// Test that Resize and ResizeBytes evict the oldest items
+func TestLRU_Resize(t *testing.T) {
+	l := NewLRU(10, nil)
+	for i := 0; i < 10; i++ {
+		l.Add(i, "aa")
+	}
+
+	if evicted := l.Resize(5); evicted != 5 || l.Len() != 5 {
+		t.Fatalf("Resize should evict 5 items: %v, %v", evicted, l.Len())
+	}
+
+	sizer := func(k, v interface{}) int64 {
+		return int64(len(v.(string)))
+	}
+	if evicted := l.ResizeBytes(6, sizer); evicted != 2 || l.Bytes() != 6 {
+		t.Fatalf("ResizeBytes should evict 2 items: %v, %v", evicted, l.Bytes())
+	}
+	if !l.Contains(9) || l.Contains(6) {
+		t.Errorf("the newest items should be kept: %v", l.Keys())
+	}
+}
+
+// Test the hit, miss and eviction counters
+func TestLRU_Stats(t *testing.T) {
+	l := NewLRU(2, nil)
+
+	l.Add(1, 1)
+	l.Add(2, 2)
+	l.Add(3, 3)
+	l.Get(1)
+	l.Get(2)
+	l.Get(3)
+	l.Peek(3)
+	l.Remove(2)
+
+	stats := l.Stats()
+	expected := LRUStats{Hits: 2, Misses: 1, Evictions: 1, Len: 1}
+	if stats != expected {
+		t.Errorf("Stats should be %+v, not %+v", expected, stats)
+	}
+}
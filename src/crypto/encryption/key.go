@@ -0,0 +1,218 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	//KeySize is the size of the secret of a Key
+	KeySize = 32
+	//IDSize is the size of the ID of a Key
+	IDSize = 8
+	//SaltSize is the size of the salt used to derive a Key from a passphrase
+	SaltSize = 16
+
+	version    byte = 1
+	headerSize      = 1 + IDSize
+)
+
+//scrypt parameters recommended for interactive logins
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+//Key encrypts data at rest with AES-256-GCM. Sealed data starts with a version
+//byte and the ID of the Key, so that the Key which sealed it can be recognized,
+//eg. while the data is re-encrypted with a new Key.
+type Key struct {
+	id   []byte
+	aead cipher.AEAD
+}
+
+//NewKey creates a Key from a secret of KeySize bytes
+func NewKey(secret []byte) (*Key, error) {
+	if len(secret) != KeySize {
+		return nil, fmt.Errorf("Encryption key must be %d bytes, not %d", KeySize, len(secret))
+	}
+
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	//the ID identifies the Key without revealing the secret
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("huron encryption key id"))
+
+	key := &Key{
+		id:   mac.Sum(nil)[:IDSize],
+		aead: aead,
+	}
+	return key, nil
+}
+
+//KeyFromPassphrase derives a Key from a passphrase and a salt with scrypt
+func KeyFromPassphrase(passphrase string, salt []byte) (*Key, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("Empty passphrase")
+	}
+
+	secret, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, KeySize)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKey(secret)
+}
+
+//GenerateSecret returns a random secret for NewKey
+func GenerateSecret() ([]byte, error) {
+	secret := make([]byte, KeySize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+//ReadKeyFile reads a Key from a file which contains the hex dump of its secret,
+//as written by WriteKeyFile
+func ReadKeyFile(file string) (*Key, error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := hex.DecodeString(strings.TrimSpace(string(buf)))
+	if err != nil {
+		return nil, fmt.Errorf("Reading encryption key %s: %v", file, err)
+	}
+
+	return NewKey(secret)
+}
+
+//WriteKeyFile writes the hex dump of a secret to a new file, readable by its
+//owner only
+func WriteKeyFile(file string, secret []byte) error {
+	if err := os.MkdirAll(path.Dir(file), 0700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write([]byte(hex.EncodeToString(secret)))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+//ReadOrCreateSalt reads the salt used to derive Keys from passphrases, and
+//creates it if the file does not exist. The salt is not secret.
+func ReadOrCreateSalt(file string) ([]byte, error) {
+	salt, err := ioutil.ReadFile(file)
+	if err == nil {
+		if len(salt) != SaltSize {
+			return nil, fmt.Errorf("Salt %s should be %d bytes, not %d", file, SaltSize, len(salt))
+		}
+		return salt, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	salt = make([]byte, SaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(path.Dir(file), 0700); err != nil {
+		return nil, err
+	}
+
+	if err := ioutil.WriteFile(file, salt, 0600); err != nil {
+		return nil, err
+	}
+
+	return salt, nil
+}
+
+//ID returns the hex encoded ID of the Key
+func (k *Key) ID() string {
+	return hex.EncodeToString(k.id)
+}
+
+//Seal encrypts and authenticates plaintext
+func (k *Key) Seal(plaintext []byte) ([]byte, error) {
+	nonceSize := k.aead.NonceSize()
+
+	res := make([]byte, headerSize+nonceSize, headerSize+nonceSize+len(plaintext)+k.aead.Overhead())
+	res[0] = version
+	copy(res[1:headerSize], k.id)
+
+	nonce := res[headerSize:]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	//the header is authenticated with the ciphertext
+	return k.aead.Seal(res, nonce, plaintext, res[:headerSize]), nil
+}
+
+//Open decrypts data sealed by the Key
+func (k *Key) Open(data []byte) ([]byte, error) {
+	id, ok := SealedBy(data)
+	if !ok {
+		return nil, fmt.Errorf("Data is not encrypted")
+	}
+	if id != k.ID() {
+		return nil, fmt.Errorf("Data is encrypted with key %s, not %s", id, k.ID())
+	}
+
+	nonceSize := k.aead.NonceSize()
+	if len(data) < headerSize+nonceSize {
+		return nil, fmt.Errorf("Encrypted data is truncated")
+	}
+
+	nonce := data[headerSize : headerSize+nonceSize]
+	return k.aead.Open(nil, nonce, data[headerSize+nonceSize:], data[:headerSize])
+}
+
+//SealedBy returns the ID of the Key which sealed data. ok is false if data does
+//not start with the header of sealed data.
+func SealedBy(data []byte) (id string, ok bool) {
+	if len(data) < headerSize || data[0] != version {
+		return "", false
+	}
+	return hex.EncodeToString(data[1:headerSize]), true
+}
+
+//Equal returns true if both Keys have the same ID. Either may be nil.
+func Equal(a, b *Key) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return bytes.Equal(a.id, b.id)
+}
@@ -0,0 +1,116 @@
+package encryption
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := NewKey(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext := []byte("the quick brown fox")
+
+	sealed, err := key.Seal(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(sealed, plaintext) {
+		t.Fatalf("Sealed data should not contain the plaintext")
+	}
+
+	if id, ok := SealedBy(sealed); !ok || id != key.ID() {
+		t.Fatalf("Sealed data should carry key ID %s, not %s", key.ID(), id)
+	}
+
+	opened, err := key.Open(sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plaintext, opened) {
+		t.Fatalf("Opened data should be %s, not %s", plaintext, opened)
+	}
+
+	//tampered data is rejected
+	sealed[len(sealed)-1] ^= 1
+	if _, err := key.Open(sealed); err == nil {
+		t.Fatalf("Opening tampered data should fail")
+	}
+
+	//data sealed by another key is rejected
+	otherSecret, _ := GenerateSecret()
+	other, _ := NewKey(otherSecret)
+	sealed, _ = other.Seal(plaintext)
+	if _, err := key.Open(sealed); err == nil {
+		t.Fatalf("Opening data sealed by another key should fail")
+	}
+
+	if _, ok := SealedBy(plaintext); ok {
+		t.Fatalf("Plaintext should not look sealed")
+	}
+}
+
+func TestKeySources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "huron-encryption")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	secret, _ := GenerateSecret()
+	keyFile := filepath.Join(dir, "key")
+
+	if err := WriteKeyFile(keyFile, secret); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteKeyFile(keyFile, secret); err == nil {
+		t.Fatalf("WriteKeyFile should not overwrite an existing key")
+	}
+
+	key, err := ReadKeyFile(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := NewKey(secret)
+	if !Equal(key, expected) {
+		t.Fatalf("Key read from file should have ID %s, not %s", expected.ID(), key.ID())
+	}
+
+	saltFile := filepath.Join(dir, "salt")
+	salt, err := ReadOrCreateSalt(saltFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	salt2, err := ReadOrCreateSalt(saltFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(salt, salt2) {
+		t.Fatalf("Salt should be reused")
+	}
+
+	k1, err := KeyFromPassphrase("passphrase", salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	k2, _ := KeyFromPassphrase("passphrase", salt)
+	k3, _ := KeyFromPassphrase("another passphrase", salt)
+
+	if !Equal(k1, k2) {
+		t.Fatalf("The same passphrase should derive the same key")
+	}
+	if Equal(k1, k3) {
+		t.Fatalf("Different passphrases should derive different keys")
+	}
+}
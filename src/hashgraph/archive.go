@@ -0,0 +1,736 @@
+package hashgraph
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/abassian/huron/src/peers"
+	"github.com/ugorji/go/codec"
+)
+
+/*******************************************************************************
+Block archives
+
+An archive is a directory which holds the committed Blocks of a node, with their
+signatures, outside of its database. The Blocks are written in increasing order
+to gzip-compressed segment files, with a fixed maximum of Blocks each, either
+as JSON lines or as length-prefixed msgpack records. Each record carries the
+validator-set of its Block when it differs from the previous record's, so that
+the signatures can be verified from the archive alone.
+
+The index file lists the segments with the range of Blocks they hold and the
+SHA256 checksum of their content. The index is itself checksummed, and is
+written last, so that an archive without an index is incomplete.
+*******************************************************************************/
+
+//Formats of the archive segments
+const (
+	ArchiveJSON   = "jsonl"
+	ArchiveBinary = "binary"
+)
+
+//ArchiveVersion is the version of the archive layout
+const ArchiveVersion = 1
+
+//ArchiveIndexFile is the name of the index file in the archive directory
+const ArchiveIndexFile = "index.json"
+
+//DefaultArchiveSegmentBlocks is the default number of Blocks per segment
+const DefaultArchiveSegmentBlocks = 10000
+
+//ArchiveRecord is a Block of the archive. PeerSet is the validator-set of the
+//Block, whose hash is the Block's PeersHash. It is only set on the first record
+//and on the records where the validator-set changes.
+type ArchiveRecord struct {
+	Block   *Block
+	PeerSet []*peers.Peer `json:",omitempty"`
+}
+
+//ArchiveSegment describes a segment file of the archive
+type ArchiveSegment struct {
+	File   string
+	First  int //Index of the first Block
+	Last   int //Index of the last Block
+	Blocks int
+	Size   int64  //Size of the compressed file
+	SHA256 string //Checksum of the compressed file
+}
+
+//ArchiveIndex lists the segments of an archive
+type ArchiveIndex struct {
+	Version  int
+	Format   string
+	Segments []ArchiveSegment
+	//Checksum is the SHA256 of the JSON encoding of the index with an empty
+	//Checksum
+	Checksum string
+}
+
+//Blocks returns the number of Blocks in the archive
+func (i *ArchiveIndex) Blocks() int {
+	n := 0
+	for _, s := range i.Segments {
+		n += s.Blocks
+	}
+	return n
+}
+
+func (i *ArchiveIndex) checksum() (string, error) {
+	c := *i
+	c.Checksum = ""
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func archiveHandle() *codec.MsgpackHandle {
+	mh := new(codec.MsgpackHandle)
+	mh.WriteExt = true
+	return mh
+}
+
+/*******************************************************************************
+Writer
+*******************************************************************************/
+
+//ArchiveWriter writes an archive to a new directory
+type ArchiveWriter struct {
+	dir           string
+	format        string
+	segmentBlocks int
+	index         ArchiveIndex
+	segment       *archiveSegmentWriter
+	last          int
+}
+
+//NewArchiveWriter creates the directory of an archive. An existing directory
+//must not contain an archive already.
+func NewArchiveWriter(dir string, format string, segmentBlocks int) (*ArchiveWriter, error) {
+	if format != ArchiveJSON && format != ArchiveBinary {
+		return nil, fmt.Errorf("Unknown archive format %q. Use %s or %s", format, ArchiveJSON, ArchiveBinary)
+	}
+	if segmentBlocks <= 0 {
+		return nil, fmt.Errorf("Segments should hold at least one Block, not %d", segmentBlocks)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, ArchiveIndexFile)); err == nil {
+		return nil, fmt.Errorf("%s already contains an archive", dir)
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	writer := &ArchiveWriter{
+		dir:           dir,
+		format:        format,
+		segmentBlocks: segmentBlocks,
+		index: ArchiveIndex{
+			Version:  ArchiveVersion,
+			Format:   format,
+			Segments: []ArchiveSegment{},
+		},
+		last: -1,
+	}
+	return writer, nil
+}
+
+//Write appends a record to the archive. Blocks must be written in increasing
+//order.
+func (w *ArchiveWriter) Write(record *ArchiveRecord) error {
+	index := record.Block.Index()
+	if index <= w.last {
+		return fmt.Errorf("Block %d written after Block %d", index, w.last)
+	}
+
+	if w.segment != nil && w.segment.info.Blocks >= w.segmentBlocks {
+		if err := w.closeSegment(); err != nil {
+			return err
+		}
+	}
+
+	if w.segment == nil {
+		segment, err := createArchiveSegment(w.dir, w.format, index)
+		if err != nil {
+			return err
+		}
+		w.segment = segment
+	}
+
+	if err := w.segment.write(record); err != nil {
+		return err
+	}
+	w.last = index
+
+	return nil
+}
+
+//Close finishes the last segment and writes the index
+func (w *ArchiveWriter) Close() error {
+	if w.segment != nil {
+		if err := w.closeSegment(); err != nil {
+			return err
+		}
+	}
+
+	checksum, err := w.index.checksum()
+	if err != nil {
+		return err
+	}
+	w.index.Checksum = checksum
+
+	data, err := json.MarshalIndent(w.index, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	//write the index atomically; a partial index would hide the segments
+	tmp := filepath.Join(w.dir, ArchiveIndexFile+".tmp")
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(w.dir, ArchiveIndexFile))
+}
+
+func (w *ArchiveWriter) closeSegment() error {
+	info, err := w.segment.close()
+	if err != nil {
+		return err
+	}
+	w.index.Segments = append(w.index.Segments, info)
+	w.segment = nil
+	return nil
+}
+
+//archiveSegmentWriter compresses records to a segment file, and computes the
+//checksum and size of the compressed data
+type archiveSegmentWriter struct {
+	file   *os.File
+	hash   hash.Hash
+	count  *countingWriter
+	buf    *bufio.Writer
+	gz     *gzip.Writer
+	format string
+	info   ArchiveSegment
+}
+
+func createArchiveSegment(dir string, format string, first int) (*archiveSegmentWriter, error) {
+	ext := "jsonl"
+	if format == ArchiveBinary {
+		ext = "bin"
+	}
+	name := fmt.Sprintf("blocks-%09d.%s.gz", first, ext)
+
+	file, err := os.OpenFile(filepath.Join(dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &archiveSegmentWriter{
+		file:   file,
+		hash:   sha256.New(),
+		format: format,
+		info: ArchiveSegment{
+			File:  name,
+			First: first,
+		},
+	}
+	s.count = &countingWriter{w: io.MultiWriter(file, s.hash)}
+	s.buf = bufio.NewWriter(s.count)
+	s.gz = gzip.NewWriter(s.buf)
+
+	return s, nil
+}
+
+func (s *archiveSegmentWriter) write(record *ArchiveRecord) error {
+	var err error
+	if s.format == ArchiveJSON {
+		err = json.NewEncoder(s.gz).Encode(record)
+	} else {
+		err = writeBinaryRecord(s.gz, record)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.info.Last = record.Block.Index()
+	s.info.Blocks++
+
+	return nil
+}
+
+func (s *archiveSegmentWriter) close() (ArchiveSegment, error) {
+	if err := s.gz.Close(); err != nil {
+		s.file.Close()
+		return s.info, err
+	}
+	if err := s.buf.Flush(); err != nil {
+		s.file.Close()
+		return s.info, err
+	}
+	if err := s.file.Sync(); err != nil {
+		s.file.Close()
+		return s.info, err
+	}
+	if err := s.file.Close(); err != nil {
+		return s.info, err
+	}
+
+	s.info.Size = s.count.n
+	s.info.SHA256 = hex.EncodeToString(s.hash.Sum(nil))
+
+	return s.info, nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+//writeBinaryRecord writes the 4-byte big-endian length of the msgpack encoding
+//of the record, followed by the encoding
+func writeBinaryRecord(w io.Writer, record *ArchiveRecord) error {
+	var data []byte
+	if err := codec.NewEncoderBytes(&data, archiveHandle()).Encode(record); err != nil {
+		return err
+	}
+
+	header := make([]byte, 4)
+	binary.BigEndian.PutUint32(header, uint32(len(data)))
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+func readBinaryRecord(r io.Reader) (*ArchiveRecord, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, binary.BigEndian.Uint32(header))
+	if _, err := io.ReadFull(r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	record := new(ArchiveRecord)
+	if err := codec.NewDecoderBytes(data, archiveHandle()).Decode(record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+/*******************************************************************************
+Reader
+*******************************************************************************/
+
+//ArchiveReader reads the records of an archive
+type ArchiveReader struct {
+	dir   string
+	index ArchiveIndex
+}
+
+//OpenArchive reads and verifies the index of an archive
+func OpenArchive(dir string) (*ArchiveReader, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, ArchiveIndexFile))
+	if err != nil {
+		return nil, fmt.Errorf("Reading the archive index: %v", err)
+	}
+
+	var index ArchiveIndex
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("Decoding the archive index: %v", err)
+	}
+
+	checksum, err := index.checksum()
+	if err != nil {
+		return nil, err
+	}
+	if checksum != index.Checksum {
+		return nil, fmt.Errorf("Archive index checksum mismatch: %s != %s", checksum, index.Checksum)
+	}
+
+	if index.Version != ArchiveVersion {
+		return nil, fmt.Errorf("Unsupported archive version %d", index.Version)
+	}
+	if index.Format != ArchiveJSON && index.Format != ArchiveBinary {
+		return nil, fmt.Errorf("Unknown archive format %q", index.Format)
+	}
+
+	reader := &ArchiveReader{
+		dir:   dir,
+		index: index,
+	}
+	return reader, nil
+}
+
+//Index returns the index of the archive
+func (r *ArchiveReader) Index() ArchiveIndex {
+	return r.index
+}
+
+//Verify verifies the size and checksum of every segment
+func (r *ArchiveReader) Verify() error {
+	for _, segment := range r.index.Segments {
+		if err := r.verifySegment(segment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Read calls fn with every record of the archive, in order. Each segment is
+//verified before its records are read.
+func (r *ArchiveReader) Read(fn func(*ArchiveRecord) error) error {
+	last := -1
+	for _, segment := range r.index.Segments {
+		if segment.First <= last {
+			return fmt.Errorf("Segment %s overlaps the previous segment", segment.File)
+		}
+		if err := r.verifySegment(segment); err != nil {
+			return err
+		}
+		if err := r.readSegment(segment, fn); err != nil {
+			return fmt.Errorf("Segment %s: %v", segment.File, err)
+		}
+		last = segment.Last
+	}
+	return nil
+}
+
+func (r *ArchiveReader) verifySegment(segment ArchiveSegment) error {
+	file, err := os.Open(filepath.Join(r.dir, segment.File))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return err
+	}
+
+	if size != segment.Size {
+		return fmt.Errorf("Segment %s has %d bytes instead of %d", segment.File, size, segment.Size)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != segment.SHA256 {
+		return fmt.Errorf("Segment %s checksum mismatch: %s != %s", segment.File, sum, segment.SHA256)
+	}
+
+	return nil
+}
+
+func (r *ArchiveReader) readSegment(segment ArchiveSegment, fn func(*ArchiveRecord) error) error {
+	file, err := os.Open(filepath.Join(r.dir, segment.File))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(bufio.NewReader(file))
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	var next func() (*ArchiveRecord, error)
+	if r.index.Format == ArchiveJSON {
+		dec := json.NewDecoder(gz)
+		next = func() (*ArchiveRecord, error) {
+			record := new(ArchiveRecord)
+			err := dec.Decode(record)
+			return record, err
+		}
+	} else {
+		next = func() (*ArchiveRecord, error) {
+			return readBinaryRecord(gz)
+		}
+	}
+
+	blocks := 0
+	last := segment.First - 1
+	for {
+		record, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if record.Block == nil {
+			return fmt.Errorf("Record %d has no Block", blocks)
+		}
+		index := record.Block.Index()
+		if index <= last || index > segment.Last {
+			return fmt.Errorf("Block %d is out of order", index)
+		}
+		last = index
+		blocks++
+
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+
+	if blocks != segment.Blocks {
+		return fmt.Errorf("%d Blocks instead of %d", blocks, segment.Blocks)
+	}
+
+	return nil
+}
+
+/*******************************************************************************
+Export and import
+*******************************************************************************/
+
+//ExportArchive writes the Blocks of the DB, with indexes between from and to
+//included, to an archive. A negative to exports up to the last Block. It
+//returns the number of exported Blocks.
+func (s *DBStore) ExportArchive(w *ArchiveWriter, from, to int) (int, error) {
+	indexes, err := s.dbIndexes(blockPrefix)
+	if err != nil {
+		return 0, err
+	}
+
+	var peersHash []byte
+	exported := 0
+	for _, i := range indexes {
+		if i < from || (to >= 0 && i > to) {
+			continue
+		}
+
+		block, err := s.dbGetBlock(i)
+		if err != nil {
+			return exported, fmt.Errorf("Block %d: %v", i, err)
+		}
+
+		record := &ArchiveRecord{Block: block}
+
+		if !bytes.Equal(block.PeersHash(), peersHash) {
+			peerSet, err := s.blockPeerSet(block)
+			if err != nil {
+				return exported, err
+			}
+			record.PeerSet = peerSet.Peers
+			peersHash = block.PeersHash()
+		}
+
+		if err := w.Write(record); err != nil {
+			return exported, err
+		}
+		exported++
+	}
+
+	return exported, nil
+}
+
+//blockPeerSet returns the validator-set of a Block, from its Frame or, if the
+//Frame is not in the DB, from the last PeerSet recorded before its round
+func (s *DBStore) blockPeerSet(block *Block) (*peers.PeerSet, error) {
+	var peerSet *peers.PeerSet
+
+	if frame, err := s.dbGetFrame(block.RoundReceived()); err == nil {
+		peerSet = peers.NewPeerSet(frame.Peers)
+	} else {
+		rounds, err := s.dbIndexes(peerSetPrefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rounds {
+			if r > block.RoundReceived() {
+				break
+			}
+			if peerSet, err = s.dbGetPeerSet(r); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if peerSet == nil {
+		return nil, fmt.Errorf("No PeerSet found for Block %d", block.Index())
+	}
+
+	hash, err := peerSet.Hash()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(hash, block.PeersHash()) {
+		return nil, fmt.Errorf("No PeerSet matches the PeersHash of Block %d", block.Index())
+	}
+
+	return peerSet, nil
+}
+
+//ImportArchive writes the Blocks and validator-sets of an archive to the DB,
+//which is typically new. Each Block's PeersHash must match its validator-set,
+//and, as in CheckBlock, it must carry more than TrustCount valid signatures
+//from members of the validator-set.
+//
+//The validator-sets are anchored: the first one must be the genesis set, if
+//one is given, and every following one must result from the previous one and
+//the InternalTransactions accepted by the Blocks which it signed, as
+//ProcessAcceptedInternalTransactions applies them. Without a genesis set, the
+//first validator-set of the archive is trusted as is. It returns the number of
+//imported Blocks.
+func (s *DBStore) ImportArchive(r *ArchiveReader, genesis *peers.PeerSet) (int, error) {
+	var peerSet *peers.PeerSet
+	var peersHash []byte
+
+	//pending are the validator-sets which result from the accepted
+	//InternalTransactions since the current validator-set, in order
+	var pending []*peers.PeerSet
+
+	imported := 0
+	err := r.Read(func(record *ArchiveRecord) error {
+		block := record.Block
+
+		if record.PeerSet != nil {
+			newPeerSet := peers.NewPeerSet(record.PeerSet)
+
+			hash, err := newPeerSet.Hash()
+			if err != nil {
+				return err
+			}
+
+			if peerSet == nil {
+				if err := checkGenesisPeerSet(hash, genesis); err != nil {
+					return fmt.Errorf("Block %d: %v", block.Index(), err)
+				}
+			} else {
+				next, err := nextPeerSet(hash, pending)
+				if err != nil {
+					return fmt.Errorf("Block %d: %v", block.Index(), err)
+				}
+				pending = pending[next+1:]
+			}
+
+			peerSet = newPeerSet
+			peersHash = hash
+
+			if err := s.dbSetPeerSet(block.RoundReceived(), peerSet); err != nil {
+				return err
+			}
+			for _, p := range peerSet.Peers {
+				if err := s.addParticipant(p); err != nil {
+					return err
+				}
+			}
+		}
+
+		if peerSet == nil {
+			return fmt.Errorf("Block %d has no validator-set", block.Index())
+		}
+		if !bytes.Equal(block.PeersHash(), peersHash) {
+			return fmt.Errorf("PeersHash of Block %d does not match its validator-set", block.Index())
+		}
+
+		validSignatures := 0
+		for _, sig := range block.GetSignatures() {
+			if _, ok := peerSet.ByPubKey[sig.ValidatorHex()]; !ok {
+				return fmt.Errorf("Block %d is signed by %s, which is not a validator", block.Index(), sig.ValidatorHex())
+			}
+			if ok, err := block.Verify(sig); !ok {
+				return fmt.Errorf("Block %d has an invalid signature from %s: %v", block.Index(), sig.ValidatorHex(), err)
+			}
+			validSignatures++
+		}
+		if validSignatures <= peerSet.TrustCount() {
+			return fmt.Errorf("Block %d has not enough valid signatures: got %d, need more than %d",
+				block.Index(), validSignatures, peerSet.TrustCount())
+		}
+
+		//the changes accepted by a Block signed by the current validator-set
+		//are the only way to the next one
+		last := peerSet
+		if len(pending) > 0 {
+			last = pending[len(pending)-1]
+		}
+		for _, receipt := range block.InternalTransactionReceipts() {
+			if !receipt.Accepted {
+				continue
+			}
+			body := receipt.InternalTransaction.Body
+			switch body.Type {
+			case PEER_ADD:
+				last = last.WithNewPeer(&body.Peer)
+			case PEER_REMOVE:
+				last = last.WithRemovedPeer(&body.Peer)
+			default:
+				continue
+			}
+			pending = append(pending, last)
+		}
+
+		if err := s.dbSetBlock(block); err != nil {
+			return err
+		}
+		imported++
+
+		return nil
+	})
+
+	return imported, err
+}
+
+//checkGenesisPeerSet verifies that the hash of the first validator-set of an
+//archive is the hash of the genesis set, if there is one
+func checkGenesisPeerSet(hash []byte, genesis *peers.PeerSet) error {
+	if genesis == nil {
+		return nil
+	}
+
+	genesisHash, err := genesis.Hash()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(hash, genesisHash) {
+		return fmt.Errorf("The first validator-set is not the genesis validator-set")
+	}
+
+	return nil
+}
+
+//nextPeerSet returns the position in pending of the validator-set with the
+//given hash
+func nextPeerSet(hash []byte, pending []*peers.PeerSet) (int, error) {
+	for i, ps := range pending {
+		psh, err := ps.Hash()
+		if err != nil {
+			return 0, err
+		}
+		if bytes.Equal(hash, psh) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("The validator-set does not result from the changes accepted by the previous validator-set")
+}
@@ -0,0 +1,450 @@
+package hashgraph
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/abassian/huron/src/peers"
+)
+
+//createArchiveDB creates a bolt DB with n signed Blocks. Block n/2-1 accepts
+//the replacement of the last validator by a new one, and the validator-set
+//changes at Block n/2.
+func createArchiveDB(n int, t *testing.T) (*BoltStore, []*Block) {
+	dir, err := ioutil.TempDir("", "huron-archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewBoltStore(10, filepath.Join(dir, "bolt.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peerSet, participants := initPeers(3)
+	if err := store.SetPeerSet(0, peerSet); err != nil {
+		t.Fatal(err)
+	}
+
+	newcomerSet, newcomers := initPeers(1)
+	newcomer := newcomerSet.Peers[0]
+	receipts := []InternalTransactionReceipt{
+		{InternalTransaction: NewInternalTransactionLeave(*peers.NewPeer(peerSet.Peers[2].PubKeyHex, "", "")), Accepted: true},
+		{InternalTransaction: NewInternalTransactionJoin(*peers.NewPeer(newcomer.PubKeyHex, "", "")), Accepted: true},
+	}
+
+	newPeerSet := peerSet.WithRemovedPeer(peerSet.Peers[2]).WithNewPeer(newcomer)
+	newParticipants := []participant{participants[0], participants[1], newcomers[0]}
+	if err := store.SetPeerSet(n/2, newPeerSet); err != nil {
+		t.Fatal(err)
+	}
+
+	blocks := []*Block{}
+	for i := 0; i < n; i++ {
+		ps, ps2 := peerSet, participants
+		if i >= n/2 {
+			ps, ps2 = newPeerSet, newParticipants
+		}
+
+		block := NewBlock(i, i,
+			[]byte(fmt.Sprintf("frame%d", i)),
+			ps.Peers,
+			[][]byte{[]byte(fmt.Sprintf("tx%d", i))},
+			[]InternalTransaction{})
+		block.Body.StateHash = []byte(fmt.Sprintf("state%d", i))
+		if i == n/2-1 {
+			block.Body.InternalTransactionReceipts = receipts
+		}
+
+		for _, p := range ps2 {
+			sig, err := block.Sign(p.privKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			block.SetSignature(sig)
+		}
+
+		if err := store.SetBlock(block); err != nil {
+			t.Fatal(err)
+		}
+		blocks = append(blocks, block)
+	}
+
+	return store, blocks
+}
+
+//writeArchive writes the records to a new JSON archive in dir
+func writeArchive(dir string, records []*ArchiveRecord, t *testing.T) *ArchiveReader {
+	w, err := NewArchiveWriter(dir, ArchiveJSON, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range records {
+		if err := w.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := OpenArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestArchiveExportImport(t *testing.T) {
+	for _, format := range []string{ArchiveJSON, ArchiveBinary} {
+		t.Run(format, func(t *testing.T) {
+			store, blocks := createArchiveDB(10, t)
+			defer os.RemoveAll(filepath.Dir(store.StorePath()))
+			defer store.Close()
+
+			dir, err := ioutil.TempDir("", "huron-archive")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			w, err := NewArchiveWriter(dir, format, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			exported, err := store.ExportArchive(w, 0, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if exported != len(blocks) {
+				t.Fatalf("Exported Blocks should be %d, not %d", len(blocks), exported)
+			}
+
+			r, err := OpenArchive(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			index := r.Index()
+			if len(index.Segments) != 4 || index.Blocks() != len(blocks) {
+				t.Fatalf("Archive should have 4 segments and %d Blocks, not %d and %d",
+					len(blocks), len(index.Segments), index.Blocks())
+			}
+
+			//the validator-set is recorded when it changes
+			peerSets := 0
+			if err := r.Read(func(record *ArchiveRecord) error {
+				if record.PeerSet != nil {
+					peerSets++
+				}
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if peerSets != 2 {
+				t.Fatalf("Archive should record 2 validator-sets, not %d", peerSets)
+			}
+
+			importDir, err := ioutil.TempDir("", "huron-archive")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(importDir)
+
+			imported, err := NewBoltStore(10, filepath.Join(importDir, "bolt.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			genesis, err := store.GetPeerSet(0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			n, err := imported.ImportArchive(r, genesis)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != len(blocks) {
+				t.Fatalf("Imported Blocks should be %d, not %d", len(blocks), n)
+			}
+			imported.Close()
+
+			readOnly, err := NewReadOnlyBoltStore(filepath.Join(importDir, "bolt.db"), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer readOnly.Close()
+
+			for _, b := range blocks {
+				ib, err := readOnly.DBGetBlock(b.Index())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(b.Body, ib.Body) || !reflect.DeepEqual(b.Signatures, ib.Signatures) {
+					t.Fatalf("Block %d should be %#v, not %#v", b.Index(), b, ib)
+				}
+			}
+
+			rounds, err := readOnly.DBPeerSetRounds()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(rounds, []int{0, 5}) {
+				t.Fatalf("PeerSets should be recorded at rounds [0 5], not %v", rounds)
+			}
+		})
+	}
+}
+
+func TestArchiveRange(t *testing.T) {
+	store, _ := createArchiveDB(10, t)
+	defer os.RemoveAll(filepath.Dir(store.StorePath()))
+	defer store.Close()
+
+	dir, err := ioutil.TempDir("", "huron-archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w, err := NewArchiveWriter(dir, ArchiveJSON, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.ExportArchive(w, 6, 8); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := OpenArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	indexes := []int{}
+	if err := r.Read(func(record *ArchiveRecord) error {
+		if record.PeerSet == nil && len(indexes) == 0 {
+			t.Fatalf("The first record should carry the validator-set")
+		}
+		indexes = append(indexes, record.Block.Index())
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(indexes, []int{6, 7, 8}) {
+		t.Fatalf("Archive should hold Blocks [6 7 8], not %v", indexes)
+	}
+
+	if _, err := NewArchiveWriter(dir, ArchiveJSON, 100); err == nil {
+		t.Fatalf("An existing archive should not be overwritten")
+	}
+}
+
+func TestArchiveCorruption(t *testing.T) {
+	store, _ := createArchiveDB(4, t)
+	defer os.RemoveAll(filepath.Dir(store.StorePath()))
+	defer store.Close()
+
+	dir, err := ioutil.TempDir("", "huron-archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	w, err := NewArchiveWriter(dir, ArchiveBinary, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.ExportArchive(w, 0, -1); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := OpenArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Verify(); err != nil {
+		t.Fatal(err)
+	}
+
+	//a corrupted segment is detected before its records are read
+	segment := filepath.Join(dir, r.Index().Segments[1].File)
+	data, err := ioutil.ReadFile(segment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)/2] ^= 0xff
+	if err := ioutil.WriteFile(segment, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	read := 0
+	err = r.Read(func(record *ArchiveRecord) error {
+		read++
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("Reading a corrupted segment should fail with a checksum mismatch, not %v", err)
+	}
+	if read != 2 {
+		t.Fatalf("Only the Blocks of the first segment should be read, not %d", read)
+	}
+
+	//a modified index is detected
+	indexFile := filepath.Join(dir, ArchiveIndexFile)
+	index, err := ioutil.ReadFile(indexFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index = []byte(strings.Replace(string(index), `"Blocks": 2`, `"Blocks": 3`, 1))
+	if err := ioutil.WriteFile(indexFile, index, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenArchive(dir); err == nil {
+		t.Fatalf("Opening an archive with a modified index should fail")
+	}
+}
+
+//importArchive imports the archive to a new bolt DB in dir
+func importArchive(dir string, r *ArchiveReader, genesis *peers.PeerSet, t *testing.T) (int, error) {
+	store, err := NewBoltStore(10, filepath.Join(dir, "bolt.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(filepath.Join(dir, "bolt.db"))
+	defer store.Close()
+
+	return store.ImportArchive(r, genesis)
+}
+
+//signedBlock returns a Block of the peer-set, signed by the participants
+func signedBlock(index int, peerSet *peers.PeerSet, signers []participant, t *testing.T) *Block {
+	block := NewBlock(index, index, []byte("frame"), peerSet.Peers, [][]byte{}, []InternalTransaction{})
+	for _, p := range signers {
+		sig, err := block.Sign(p.privKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block.SetSignature(sig)
+	}
+	return block
+}
+
+func TestArchiveImportVerifiesSignatures(t *testing.T) {
+	dir, err := ioutil.TempDir("", "huron-archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	peerSet, participants := initPeers(3)
+	_, outsiders := initPeers(1)
+
+	//a Block signed by a non-validator is refused
+	block := signedBlock(0, peerSet, []participant{participants[0], participants[1], outsiders[0]}, t)
+	r := writeArchive(filepath.Join(dir, "outsider"), []*ArchiveRecord{{Block: block, PeerSet: peerSet.Peers}}, t)
+	if _, err := importArchive(dir, r, nil, t); err == nil || !strings.Contains(err.Error(), "not a validator") {
+		t.Fatalf("A Block signed by a non-validator should be refused, not %v", err)
+	}
+
+	//a validator-set which does not match the PeersHash is refused
+	other, _ := initPeers(3)
+	block = signedBlock(0, peerSet, participants, t)
+	r = writeArchive(filepath.Join(dir, "peershash"), []*ArchiveRecord{{Block: block, PeerSet: other.Peers}}, t)
+	if _, err := importArchive(dir, r, nil, t); err == nil || !strings.Contains(err.Error(), "PeersHash") {
+		t.Fatalf("A validator-set which does not match the PeersHash should be refused, not %v", err)
+	}
+
+	//a Block needs more than TrustCount valid signatures, like in CheckBlock
+	for _, signers := range [][]participant{{}, participants[:peerSet.TrustCount()]} {
+		block = signedBlock(0, peerSet, signers, t)
+		r = writeArchive(filepath.Join(dir, fmt.Sprintf("signers%d", len(signers))), []*ArchiveRecord{{Block: block, PeerSet: peerSet.Peers}}, t)
+		if _, err := importArchive(dir, r, nil, t); err == nil || !strings.Contains(err.Error(), "not enough valid signatures") {
+			t.Fatalf("A Block with %d signatures should be refused, not %v", len(signers), err)
+		}
+	}
+
+	block = signedBlock(0, peerSet, participants[:peerSet.TrustCount()+1], t)
+	r = writeArchive(filepath.Join(dir, "trusted"), []*ArchiveRecord{{Block: block, PeerSet: peerSet.Peers}}, t)
+	if _, err := importArchive(dir, r, nil, t); err != nil {
+		t.Fatalf("A Block with more than TrustCount signatures should be imported, not %v", err)
+	}
+}
+
+func TestArchiveImportAnchorsValidatorSets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "huron-archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	peerSet, participants := initPeers(3)
+	otherSet, others := initPeers(3)
+
+	//a validator-set which does not follow from the changes accepted by the
+	//previous one is refused, even if it signs its Blocks
+	r := writeArchive(filepath.Join(dir, "unanchored"), []*ArchiveRecord{
+		{Block: signedBlock(0, peerSet, participants, t), PeerSet: peerSet.Peers},
+		{Block: signedBlock(1, otherSet, others, t), PeerSet: otherSet.Peers},
+	}, t)
+	if _, err := importArchive(dir, r, nil, t); err == nil || !strings.Contains(err.Error(), "does not result") {
+		t.Fatalf("An unanchored validator-set should be refused, not %v", err)
+	}
+
+	//the first validator-set must be the genesis set
+	r = writeArchive(filepath.Join(dir, "genesis"), []*ArchiveRecord{
+		{Block: signedBlock(0, otherSet, others, t), PeerSet: otherSet.Peers},
+	}, t)
+	if _, err := importArchive(dir, r, peerSet, t); err == nil || !strings.Contains(err.Error(), "genesis") {
+		t.Fatalf("A validator-set other than the genesis set should be refused, not %v", err)
+	}
+	if _, err := importArchive(dir, r, otherSet, t); err != nil {
+		t.Fatalf("The genesis validator-set should be accepted, not %v", err)
+	}
+
+	//a validator-set change accepted by the previous validator-set is
+	//followed, even after further accepted changes
+	store, blocks := createArchiveDB(10, t)
+	defer os.RemoveAll(filepath.Dir(store.StorePath()))
+	defer store.Close()
+
+	genesis, err := store.GetPeerSet(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := NewArchiveWriter(filepath.Join(dir, "archive"), ArchiveBinary, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.ExportArchive(w, 0, -1); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err = OpenArchive(filepath.Join(dir, "archive"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := importArchive(dir, r, genesis, t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(blocks) {
+		t.Fatalf("Imported Blocks should be %d, not %d", len(blocks), n)
+	}
+}
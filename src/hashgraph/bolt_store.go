@@ -0,0 +1,232 @@
+package hashgraph
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/abassian/huron/src/crypto/encryption"
+	bolt "go.etcd.io/bbolt"
+)
+
+//boltBucket is the bucket which contains all the keys of a BoltStore. The keys
+//are the same as those of a BadgerStore.
+var boltBucket = []byte("huron")
+
+//boltLockTimeout is how long opening a BoltStore waits for the lock held by
+//another process before failing
+const boltLockTimeout = time.Second
+
+//BoltStore is a DBStore which persists values in a single bbolt file. bbolt is a
+//pure-Go B+tree, which uses less memory and fewer file handles than badger.
+type BoltStore struct {
+	*DBStore
+	boltDB *bolt.DB
+}
+
+//NewBoltStore opens an existing database file or creates a new one if nothing
+//is found in path. It returns a SchemaError if the existing database does not
+//have the current SchemaVersion.
+func NewBoltStore(cacheSize int, path string) (*BoltStore, error) {
+	return NewEncryptedBoltStore(cacheSize, path, nil)
+}
+
+//NewEncryptedBoltStore opens or creates a database file whose values are
+//encrypted with key, like NewEncryptedBadgerStore
+func NewEncryptedBoltStore(cacheSize int, path string, key *encryption.Key) (*BoltStore, error) {
+	handle, err := openBoltDB(path, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return newBoltStore(cacheSize, handle, path, key)
+}
+
+//NewReadOnlyBoltStore opens an existing database file in read-only mode, to
+//inspect it with the DB methods of the DBStore. key is the encryption key of
+//the database, or nil if it is not encrypted.
+func NewReadOnlyBoltStore(path string, key *encryption.Key) (*BoltStore, error) {
+	handle, err := openBoltDB(path, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return newBoltStore(0, handle, path, key)
+}
+
+func newBoltStore(cacheSize int, handle *bolt.DB, path string, key *encryption.Key) (*BoltStore, error) {
+	dbStore, err := newDBStore(cacheSize, &boltKV{handle}, path, key)
+	if err != nil {
+		return nil, err
+	}
+
+	store := &BoltStore{
+		DBStore: dbStore,
+		boltDB:  handle,
+	}
+	return store, nil
+}
+
+//RestoreBoltStore creates a new database file in path from a backup written by
+//BoltStore.Backup. It returns a SchemaError if the restored database does not
+//have the current SchemaVersion.
+func RestoreBoltStore(r io.Reader, path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("%s already exists", path)
+		}
+		return err
+	}
+
+	_, err = io.Copy(file, r)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	handle, err := openBoltDB(path, true)
+	if err != nil {
+		return err
+	}
+	defer handle.Close()
+
+	version, err := dbGetSchemaVersion(&boltKV{handle})
+	if err != nil {
+		return err
+	}
+	if version != SchemaVersion {
+		return SchemaError{Path: path, Version: version}
+	}
+
+	return nil
+}
+
+func openBoltDB(path string, readOnly bool) (*bolt.DB, error) {
+	handle, err := bolt.Open(path, 0600, &bolt.Options{
+		Timeout:  boltLockTimeout,
+		ReadOnly: readOnly,
+		NoSync:   true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !readOnly {
+		err = handle.Update(func(tx *bolt.Tx) error {
+			_, err := tx.CreateBucketIfNotExists(boltBucket)
+			return err
+		})
+		if err != nil {
+			handle.Close()
+			return nil, err
+		}
+	}
+
+	return handle, nil
+}
+
+//Backup implements Backuper. It writes a copy of the database file from a read
+//transaction, so the Store remains in use. The backup is restored by
+//RestoreBoltStore.
+func (s *BoltStore) Backup(w io.Writer) error {
+	return s.boltDB.View(func(tx *bolt.Tx) error {
+		_, err := tx.WriteTo(w)
+		return err
+	})
+}
+
+/*******************************************************************************
+bbolt engine
+*******************************************************************************/
+
+//boltKV implements kvDB with a bbolt DB
+type boltKV struct {
+	db *bolt.DB
+}
+
+func (b *boltKV) View(fn func(txn kvTxn) error) error {
+	return b.db.View(func(tx *bolt.Tx) error {
+		return fn(&boltTxn{tx.Bucket(boltBucket)})
+	})
+}
+
+func (b *boltKV) Update(fn func(txn kvTxn) error) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return fn(&boltTxn{tx.Bucket(boltBucket)})
+	})
+}
+
+func (b *boltKV) Close() error {
+	return b.db.Close()
+}
+
+//boltTxn is a transaction on the boltBucket. The bucket is nil if a read-only
+//DB was never written to.
+type boltTxn struct {
+	bucket *bolt.Bucket
+}
+
+func (t *boltTxn) Get(key []byte) ([]byte, error) {
+	if t.bucket == nil {
+		return nil, errKVKeyNotFound
+	}
+
+	value := t.bucket.Get(key)
+	if value == nil {
+		return nil, errKVKeyNotFound
+	}
+
+	//bbolt values are only valid for the life of the transaction
+	return append([]byte{}, value...), nil
+}
+
+func (t *boltTxn) Set(key, value []byte) error {
+	return t.bucket.Put(key, value)
+}
+
+func (t *boltTxn) Delete(key []byte) error {
+	return t.bucket.Delete(key)
+}
+
+func (t *boltTxn) Iterate(prefix []byte, fn func(key, value []byte) error) error {
+	if t.bucket == nil {
+		return nil
+	}
+
+	c := t.bucket.Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		if err := fn(k, v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *boltTxn) IterateReverse(prefix []byte, fn func(key, value []byte) error) error {
+	if t.bucket == nil {
+		return nil
+	}
+
+	//Seek the first key after the prefix, and step back
+	c := t.bucket.Cursor()
+	k, v := c.Seek(append(append([]byte{}, prefix...), 0xff))
+	if k == nil {
+		k, v = c.Last()
+	} else {
+		k, v = c.Prev()
+	}
+
+	for ; k != nil && bytes.HasPrefix(k, prefix); k, v = c.Prev() {
+		if err := fn(k, v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,327 @@
+package hashgraph
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	cm "github.com/abassian/huron/src/common"
+)
+
+/*******************************************************************************
+Cache budget
+
+The LRU caches of a Hashgraph and of its InmemStore are bounded either by the
+number of items given by CacheSize, or by a global memory budget which is
+shared between the caches in proportion to their shares. The size of the items
+is estimated from their content, so that large transactions and large peer-sets
+are accounted for.
+*******************************************************************************/
+
+//Names of the LRU caches
+const (
+	AncestorCache     = "hashgraph.ancestor"
+	SelfAncestorCache = "hashgraph.self-ancestor"
+	StronglySeeCache  = "hashgraph.strongly-see"
+	RoundCache        = "hashgraph.round"
+	TimestampCache    = "hashgraph.timestamp"
+	WitnessCache      = "hashgraph.witness"
+	EventStoreCache   = "store.event"
+	RoundStoreCache   = "store.round"
+	BlockStoreCache   = "store.block"
+	FrameStoreCache   = "store.frame"
+)
+
+//DefaultCacheShares are the relative shares of the memory budget of the caches.
+//Events dominate the memory usage, and stronglySee is the most expensive
+//computation to repeat.
+var DefaultCacheShares = map[string]float64{
+	AncestorCache:     10,
+	SelfAncestorCache: 5,
+	StronglySeeCache:  15,
+	RoundCache:        5,
+	TimestampCache:    5,
+	WitnessCache:      5,
+	EventStoreCache:   30,
+	RoundStoreCache:   5,
+	BlockStoreCache:   10,
+	FrameStoreCache:   10,
+}
+
+var cacheSizers = map[string]cm.Sizer{
+	AncestorCache:     keySize,
+	SelfAncestorCache: keySize,
+	StronglySeeCache:  keySize,
+	RoundCache:        keySize,
+	TimestampCache:    keySize,
+	WitnessCache:      keySize,
+	EventStoreCache:   func(k, v interface{}) int64 { return keySize(k, v) + eventSize(v.(*Event)) },
+	RoundStoreCache:   func(k, v interface{}) int64 { return keySize(k, v) + roundSize(v.(*RoundInfo)) },
+	BlockStoreCache:   func(k, v interface{}) int64 { return keySize(k, v) + blockSize(v.(*Block)) },
+	FrameStoreCache:   func(k, v interface{}) int64 { return keySize(k, v) + frameSize(v.(*Frame)) },
+}
+
+//CacheConfig sets the memory budget of the caches
+type CacheConfig struct {
+	//Budget is the number of bytes shared by all the caches. 0 bounds each
+	//cache by the number of items given by CacheSize instead.
+	Budget int64
+	//Shares overrides the DefaultCacheShares of some caches
+	Shares map[string]float64
+}
+
+//ParseCacheShares parses a list of shares in the form
+//"store.event=4,hashgraph.ancestor=1"
+func ParseCacheShares(s string) (map[string]float64, error) {
+	shares := make(map[string]float64)
+
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("Cache share %q should be of the form name=share", item)
+		}
+
+		share, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("Cache share %q: %v", item, err)
+		}
+
+		shares[strings.TrimSpace(kv[0])] = share
+	}
+
+	return shares, nil
+}
+
+//Caches creates the LRU caches of a Hashgraph and its Store, and keeps track of
+//them to report their statistics. Like the LRUs, it is not thread safe.
+type Caches struct {
+	size   int
+	config CacheConfig
+	lrus   map[string]*cm.LRU
+}
+
+//NewCaches creates Caches bounded by size items until a budget is configured
+func NewCaches(size int) *Caches {
+	return &Caches{
+		size: size,
+		lrus: make(map[string]*cm.LRU),
+	}
+}
+
+//Configure validates the config and applies it to the existing caches, and to
+//those created afterwards
+func (c *Caches) Configure(config CacheConfig) error {
+	if config.Budget < 0 {
+		return fmt.Errorf("Negative cache budget %d", config.Budget)
+	}
+
+	for name, share := range config.Shares {
+		if _, ok := DefaultCacheShares[name]; !ok {
+			return fmt.Errorf("Unknown cache %q. Known caches: %s", name, strings.Join(CacheNames(), ", "))
+		}
+		if share < 0 {
+			return fmt.Errorf("Negative share %v of cache %s", share, name)
+		}
+	}
+
+	c.config = config
+
+	for name, lru := range c.lrus {
+		c.resize(name, lru)
+	}
+
+	return nil
+}
+
+//Budget returns the number of bytes allocated to a cache, or 0 if the caches
+//are bounded by a number of items
+func (c *Caches) Budget(name string) int64 {
+	if c.config.Budget == 0 {
+		return 0
+	}
+
+	total := 0.0
+	for n := range DefaultCacheShares {
+		total += c.share(n)
+	}
+	if total == 0 {
+		return 0
+	}
+
+	return int64(float64(c.config.Budget) * c.share(name) / total)
+}
+
+//Stats returns the statistics of each cache by name
+func (c *Caches) Stats() map[string]cm.LRUStats {
+	stats := make(map[string]cm.LRUStats, len(c.lrus))
+	for name, lru := range c.lrus {
+		stats[name] = lru.Stats()
+	}
+	return stats
+}
+
+//lru creates a cache, which replaces any previous cache of the same name in
+//the statistics
+func (c *Caches) lru(name string, onEvict cm.EvictCallback) *cm.LRU {
+	lru := cm.NewLRU(c.size, onEvict)
+	c.resize(name, lru)
+	c.lrus[name] = lru
+	return lru
+}
+
+func (c *Caches) resize(name string, lru *cm.LRU) {
+	if c.config.Budget == 0 {
+		lru.Resize(c.size)
+		return
+	}
+	lru.ResizeBytes(c.Budget(name), cacheSizers[name])
+}
+
+func (c *Caches) share(name string) float64 {
+	if share, ok := c.config.Shares[name]; ok {
+		return share
+	}
+	return DefaultCacheShares[name]
+}
+
+//CacheNames returns the sorted names of the caches
+func CacheNames() []string {
+	names := make([]string, 0, len(DefaultCacheShares))
+	for name := range DefaultCacheShares {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/*******************************************************************************
+Size estimates
+
+The estimates include the main allocations of the items, and fixed overheads
+for the list element, map bucket, and interfaces of each cache entry. They are
+not exact, but grow with the content of the items.
+*******************************************************************************/
+
+const (
+	entryOverhead      = 128
+	sliceOverhead      = 24
+	coordinateSize     = 120
+	signatureSize      = 150
+	peerSize           = 250
+	internalTxSize     = 350
+	roundEventSize     = 100
+	frameEventOverhead = 64
+)
+
+func keySize(key, _ interface{}) int64 {
+	switch k := key.(type) {
+	case string:
+		return entryOverhead + int64(len(k))
+	case Key:
+		return entryOverhead + int64(len(k.x)+len(k.y))
+	case TreKey:
+		return entryOverhead + int64(len(k.x)+len(k.y)+len(k.z))
+	default:
+		return entryOverhead
+	}
+}
+
+func bytesSize(b [][]byte) int64 {
+	size := int64(sliceOverhead)
+	for _, t := range b {
+		size += sliceOverhead + int64(len(t))
+	}
+	return size
+}
+
+func eventSize(e *Event) int64 {
+	if e == nil {
+		return 0
+	}
+
+	size := int64(400)
+	size += int64(len(e.Signature) + len(e.creator) + len(e.hex) + len(e.hash))
+	size += int64(len(e.Body.Creator))
+	size += bytesSize(e.Body.Transactions)
+	size += int64(len(e.Body.InternalTransactions)) * internalTxSize
+
+	for _, p := range e.Body.Parents {
+		size += 16 + int64(len(p))
+	}
+
+	for _, bs := range e.Body.BlockSignatures {
+		size += signatureSize + int64(len(bs.Signature)+len(bs.Validator))
+	}
+
+	size += int64(len(e.lastAncestors)+len(e.firstDescendants)) * coordinateSize
+
+	return size
+}
+
+func roundSize(r *RoundInfo) int64 {
+	if r == nil {
+		return 0
+	}
+
+	size := int64(100)
+	for hash := range r.CreatedEvents {
+		size += roundEventSize + int64(len(hash))
+	}
+	for _, hash := range r.ReceivedEvents {
+		size += 16 + int64(len(hash))
+	}
+
+	return size
+}
+
+func blockSize(b *Block) int64 {
+	if b == nil {
+		return 0
+	}
+
+	size := int64(300)
+	size += int64(len(b.Body.StateHash) + len(b.Body.FrameHash) + len(b.Body.PeersHash))
+	size += int64(len(b.hash) + len(b.hex))
+	size += bytesSize(b.Body.Transactions)
+	size += int64(len(b.Body.InternalTransactions)+len(b.Body.InternalTransactionReceipts)) * internalTxSize
+
+	for validator, sig := range b.Signatures {
+		size += signatureSize + int64(len(validator)+len(sig))
+	}
+
+	return size
+}
+
+func frameSize(f *Frame) int64 {
+	if f == nil {
+		return 0
+	}
+
+	size := int64(200)
+	size += int64(len(f.Peers)) * peerSize
+
+	for _, fe := range f.Events {
+		size += frameEventOverhead + eventSize(fe.Core)
+	}
+
+	for _, root := range f.Roots {
+		if root == nil {
+			continue
+		}
+		for _, fe := range root.Events {
+			size += frameEventOverhead + eventSize(fe.Core)
+		}
+	}
+
+	for _, ps := range f.PeerSets {
+		size += sliceOverhead + int64(len(ps))*peerSize
+	}
+
+	return size
+}
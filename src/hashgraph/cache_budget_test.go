@@ -0,0 +1,82 @@
+package hashgraph
+
+import (
+	"testing"
+)
+
+func TestParseCacheShares(t *testing.T) {
+	shares, err := ParseCacheShares(" store.event=40, hashgraph.ancestor=2.5,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shares) != 2 || shares[EventStoreCache] != 40 || shares[AncestorCache] != 2.5 {
+		t.Fatalf("Wrong shares %v", shares)
+	}
+
+	for _, s := range []string{"store.event", "store.event=x"} {
+		if _, err := ParseCacheShares(s); err == nil {
+			t.Fatalf("Parsing %q should fail", s)
+		}
+	}
+}
+
+func TestCachesBudget(t *testing.T) {
+	store := NewInmemStore(cacheSize)
+	h := NewHashgraph(store, nil, nil)
+	caches := store.Caches()
+
+	if b := caches.Budget(EventStoreCache); b != 0 {
+		t.Fatalf("Caches without budget should have no budget, not %d", b)
+	}
+	if h.ancestorCache.Stats().MaxBytes != 0 {
+		t.Fatalf("Caches without budget should be sized by items")
+	}
+
+	if err := caches.Configure(CacheConfig{Budget: 1000, Shares: map[string]float64{"unknown": 1}}); err == nil {
+		t.Fatalf("Configuring an unknown cache should fail")
+	}
+
+	//only two caches share the budget
+	shares := map[string]float64{}
+	for _, name := range CacheNames() {
+		shares[name] = 0
+	}
+	shares[EventStoreCache] = 3
+	shares[AncestorCache] = 1
+
+	if err := caches.Configure(CacheConfig{Budget: 100000, Shares: shares}); err != nil {
+		t.Fatal(err)
+	}
+
+	if b := caches.Budget(EventStoreCache); b != 75000 {
+		t.Fatalf("Event cache budget should be 75000, not %d", b)
+	}
+	if b := caches.Budget(WitnessCache); b != 0 {
+		t.Fatalf("Witness cache budget should be 0, not %d", b)
+	}
+
+	//the existing caches are resized
+	if m := h.ancestorCache.Stats().MaxBytes; m != 25000 {
+		t.Fatalf("Ancestor cache should be resized to 25000 bytes, not %d", m)
+	}
+
+	//events are bounded by their size
+	tx := make([]byte, 10000)
+	for i := 0; i < 20; i++ {
+		event := NewEvent([][]byte{tx}, nil, nil, []string{"", ""}, []byte("creator"), i)
+		store.eventCache.Add(event.Hex(), event)
+	}
+
+	stats := caches.Stats()[EventStoreCache]
+	if stats.Bytes > 75000 || stats.Len >= 20 || stats.Evictions == 0 {
+		t.Fatalf("Event cache should be bounded by its budget: %+v", stats)
+	}
+
+	//the caches of a reset Store keep the budget
+	if err := store.Reset(&Frame{Roots: map[string]*Root{}}); err != nil {
+		t.Fatal(err)
+	}
+	if m := caches.Stats()[EventStoreCache].MaxBytes; m != 75000 {
+		t.Fatalf("Reset event cache should have a budget of 75000, not %d", m)
+	}
+}
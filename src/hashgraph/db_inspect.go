@@ -0,0 +1,437 @@
+package hashgraph
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/abassian/huron/src/peers"
+)
+
+/*******************************************************************************
+Inspection
+
+The following methods read directly from the DB, bypassing the InmemStore, so
+that a database can be inspected offline, typically with a store opened by
+NewReadOnlyBadgerStore or NewReadOnlyBoltStore.
+*******************************************************************************/
+
+//DBRepertoire returns all the Peers recorded in the DB, by public key
+func (s *DBStore) DBRepertoire() (map[string]*peers.Peer, error) {
+	return s.dbGetRepertoire()
+}
+
+//DBPeerSetRounds returns the rounds at which PeerSets were recorded
+func (s *DBStore) DBPeerSetRounds() ([]int, error) {
+	return s.dbIndexes(peerSetPrefix)
+}
+
+//DBGetPeerSet returns the PeerSet recorded at a given round
+func (s *DBStore) DBGetPeerSet(round int) (*peers.PeerSet, error) {
+	peerSet, err := s.dbGetPeerSet(round)
+	return peerSet, mapError(err, "PeerSet", string(peerSetKey(round)))
+}
+
+//DBGetEvent returns an Event from the DB
+func (s *DBStore) DBGetEvent(key string) (*Event, error) {
+	event, err := s.dbGetEvent(key)
+	return event, mapError(err, "Event", key)
+}
+
+//DBTopologicalEvents returns all the Events of the DB in topological order
+func (s *DBStore) DBTopologicalEvents() ([]*Event, error) {
+	return s.dbTopologicalEvents()
+}
+
+//DBGetRoot returns a participant's Root from the DB
+func (s *DBStore) DBGetRoot(participant string) (*Root, error) {
+	root, err := s.dbGetRoot(participant)
+	return root, mapError(err, "Root", string(participantRootKey(participant)))
+}
+
+//DBRoundIndexes returns the indexes of the RoundInfos in the DB
+func (s *DBStore) DBRoundIndexes() ([]int, error) {
+	return s.dbIndexes(roundPrefix)
+}
+
+//DBGetRound returns a RoundInfo from the DB
+func (s *DBStore) DBGetRound(index int) (*RoundInfo, error) {
+	round, err := s.dbGetRound(index)
+	return round, mapError(err, "Round", string(roundKey(index)))
+}
+
+//DBBlockIndexes returns the indexes of the Blocks in the DB
+func (s *DBStore) DBBlockIndexes() ([]int, error) {
+	return s.dbIndexes(blockPrefix)
+}
+
+//DBGetBlock returns a Block from the DB
+func (s *DBStore) DBGetBlock(index int) (*Block, error) {
+	block, err := s.dbGetBlock(index)
+	return block, mapError(err, "Block", string(blockKey(index)))
+}
+
+//DBFrameIndexes returns the rounds of the Frames in the DB
+func (s *DBStore) DBFrameIndexes() ([]int, error) {
+	return s.dbIndexes(framePrefix)
+}
+
+//DBGetFrame returns a Frame from the DB
+func (s *DBStore) DBGetFrame(round int) (*Frame, error) {
+	frame, err := s.dbGetFrame(round)
+	return frame, mapError(err, "Frame", string(frameKey(round)))
+}
+
+//dbIndexes returns, in increasing order, the indexes of the keys formatted as
+//prefix_index
+func (s *DBStore) dbIndexes(prefix string) ([]int, error) {
+	res := []int{}
+	err := s.db.View(func(txn kvTxn) error {
+		p := []byte(prefix + "_")
+		return txn.Iterate(p, func(key, value []byte) error {
+			index, err := strconv.Atoi(strings.TrimPrefix(string(key), string(p)))
+			if err != nil {
+				//eg. the prefix of another key-space
+				return nil
+			}
+			res = append(res, index)
+			return nil
+		})
+	})
+	return res, err
+}
+
+/*******************************************************************************
+Consistency Check
+*******************************************************************************/
+
+//CheckReport summarises the consistency check of a database
+type CheckReport struct {
+	Events int
+	Blocks int
+	Frames int
+	Rounds int
+	Errors []string
+}
+
+func (r *CheckReport) errorf(format string, args ...interface{}) {
+	r.Errors = append(r.Errors, fmt.Sprintf(format, args...))
+}
+
+//Check verifies the consistency of the whole database: the signatures of Events
+//and Blocks, the parents of Events, the continuity of the participants' Event
+//indexes, and the hashes of the Frames and PeerSets referenced by Blocks.
+//Inconsistencies are collected in the report; an error is only returned if the
+//DB cannot be read.
+func (s *DBStore) Check() (*CheckReport, error) {
+	report := &CheckReport{Errors: []string{}}
+
+	events, err := s.checkEvents(report)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.checkParticipantEvents(events, report); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkRounds(events, report); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkBlocks(report); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+//checkEvents verifies the signatures and parents of the Events, and returns
+//them by hash
+func (s *DBStore) checkEvents(report *CheckReport) (map[string]*Event, error) {
+	topologicalEvents, err := s.checkTopologicalEvents(report)
+	if err != nil {
+		return nil, err
+	}
+	report.Events = len(topologicalEvents)
+
+	//Parents may also be found among the FrameEvents of the Roots and of the
+	//PruneBase, whose Events may have been pruned
+	frameEvents := make(map[string]*FrameEvent)
+
+	repertoire, err := s.dbGetRepertoire()
+	if err != nil {
+		return nil, err
+	}
+	for p := range repertoire {
+		root, err := s.dbGetRoot(p)
+		if err != nil {
+			if isDBKeyNotFound(err) {
+				report.errorf("Participant %s has no Root", p)
+				continue
+			}
+			return nil, err
+		}
+		for _, fe := range root.Events {
+			frameEvents[fe.Core.Hex()] = fe
+		}
+	}
+
+	pruneBase, err := s.dbGetPruneBase()
+	if err != nil && !isDBKeyNotFound(err) {
+		return nil, err
+	}
+
+	//The parents of the Events covered by the PruneBase Frame may have been
+	//pruned
+	covered := make(map[string]int)
+	if pruneBase != nil {
+		for x, fe := range pruneBase.frameEvents() {
+			frameEvents[x] = fe
+		}
+
+		frame, err := s.dbGetFrame(pruneBase.Round)
+		if err != nil {
+			return nil, err
+		}
+		for _, fe := range frame.SortedFrameEvents() {
+			if fe.Core.Index() > covered[fe.Core.Creator()] {
+				covered[fe.Core.Creator()] = fe.Core.Index()
+			}
+		}
+	}
+
+	events := make(map[string]*Event)
+
+	for _, e := range topologicalEvents {
+		x := e.Hex()
+
+		if ok, err := e.Verify(); !ok {
+			report.errorf("Event %s has an invalid signature: %v", x, err)
+		}
+
+		if _, ok := repertoire[e.Creator()]; !ok {
+			report.errorf("Creator of Event %s is not in the repertoire", x)
+		}
+
+		if last, ok := covered[e.Creator()]; ok && e.Index() <= last {
+			events[x] = e
+			continue
+		}
+
+		if sp := e.SelfParent(); sp != "" {
+			parent, ok := events[sp]
+			if !ok {
+				if fe, ok := frameEvents[sp]; ok {
+					parent = fe.Core
+				}
+			}
+
+			switch {
+			case parent == nil:
+				report.errorf("Self-parent %s of Event %s is unknown or does not precede it", sp, x)
+			case parent.Creator() != e.Creator():
+				report.errorf("Self-parent %s of Event %s has another creator", sp, x)
+			case parent.Index() != e.Index()-1:
+				report.errorf("Self-parent %s of Event %s has index %d instead of %d", sp, x, parent.Index(), e.Index()-1)
+			}
+		}
+
+		if op := e.OtherParent(); op != "" {
+			_, ok := events[op]
+			_, inFrame := frameEvents[op]
+			if !ok && !inFrame {
+				report.errorf("Other-parent %s of Event %s is unknown or does not precede it", op, x)
+			}
+		}
+
+		events[x] = e
+	}
+
+	return events, nil
+}
+
+//checkTopologicalEvents returns the Events in topological order, like
+//dbTopologicalEvents, but reports the indexes which point to missing or
+//unreadable Events instead of failing.
+func (s *DBStore) checkTopologicalEvents(report *CheckReport) ([]*Event, error) {
+	res := []*Event{}
+	err := s.db.View(func(txn kvTxn) error {
+		prefix := []byte(topoPrefix + "_")
+		return txn.Iterate(prefix, func(key, v []byte) error {
+			eventBytes, err := txn.Get(v)
+			if err != nil {
+				if !isDBKeyNotFound(err) {
+					return err
+				}
+				report.errorf("%s points to Event %s, which is not in the DB", key, v)
+				return nil
+			}
+
+			event := new(Event)
+			if err := event.Unmarshal(eventBytes); err != nil {
+				report.errorf("Event %s cannot be decoded: %v", v, err)
+				return nil
+			}
+
+			if event.Hex() != string(v) {
+				report.errorf("Event %s is stored under %s", event.Hex(), v)
+			}
+
+			res = append(res, event)
+			return nil
+		})
+	})
+
+	return res, err
+}
+
+//checkParticipantEvents verifies that the indexes of each participant's Events
+//are contiguous and point to the right Events
+func (s *DBStore) checkParticipantEvents(events map[string]*Event, report *CheckReport) error {
+	indexed := make(map[string]bool)
+
+	repertoire, err := s.dbGetRepertoire()
+	if err != nil {
+		return err
+	}
+
+	for p := range repertoire {
+		prefix := []byte(fmt.Sprintf("%s__event_", p))
+
+		err := s.db.View(func(txn kvTxn) error {
+			previous := -1
+			return txn.Iterate(prefix, func(key, v []byte) error {
+				index, err := strconv.Atoi(strings.TrimPrefix(string(key), string(prefix)))
+				if err != nil {
+					return err
+				}
+
+				if previous >= 0 && index != previous+1 {
+					report.errorf("Events %d to %d of participant %s are missing", previous+1, index-1, p)
+				}
+				previous = index
+
+				e, ok := events[string(v)]
+				if !ok {
+					report.errorf("Event %d of participant %s, %s, is not in the DB", index, p, string(v))
+					return nil
+				}
+				if e.Creator() != p || e.Index() != index {
+					report.errorf("Event %d of participant %s points to Event %s", index, p, string(v))
+				}
+				indexed[string(v)] = true
+				return nil
+			})
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	for x := range events {
+		if !indexed[x] {
+			report.errorf("Event %s is missing from the participant indexes", x)
+		}
+	}
+
+	return nil
+}
+
+//checkRounds verifies that the Events referenced by the RoundInfos are in the DB
+func (s *DBStore) checkRounds(events map[string]*Event, report *CheckReport) error {
+	indexes, err := s.dbIndexes(roundPrefix)
+	if err != nil {
+		return err
+	}
+	report.Rounds = len(indexes)
+
+	for _, r := range indexes {
+		round, err := s.dbGetRound(r)
+		if err != nil {
+			report.errorf("Round %d cannot be read: %v", r, err)
+			continue
+		}
+
+		for x := range round.CreatedEvents {
+			if _, ok := events[x]; !ok {
+				report.errorf("Event %s created in Round %d is not in the DB", x, r)
+			}
+		}
+
+		for _, x := range round.ReceivedEvents {
+			if _, ok := events[x]; !ok {
+				report.errorf("Event %s received in Round %d is not in the DB", x, r)
+			}
+		}
+	}
+
+	return nil
+}
+
+//checkBlocks verifies that Block indexes are contiguous, that Block signatures
+//are valid, and that each Block's FrameHash and PeersHash match the stored Frame
+func (s *DBStore) checkBlocks(report *CheckReport) error {
+	frames, err := s.dbIndexes(framePrefix)
+	if err != nil {
+		return err
+	}
+	report.Frames = len(frames)
+
+	indexes, err := s.dbIndexes(blockPrefix)
+	if err != nil {
+		return err
+	}
+	report.Blocks = len(indexes)
+
+	sort.Ints(indexes)
+
+	for i, b := range indexes {
+		if i > 0 && b != indexes[i-1]+1 {
+			report.errorf("Blocks %d to %d are missing", indexes[i-1]+1, b-1)
+		}
+
+		block, err := s.dbGetBlock(b)
+		if err != nil {
+			report.errorf("Block %d cannot be read: %v", b, err)
+			continue
+		}
+
+		if block.Index() != b {
+			report.errorf("Block %d has index %d", b, block.Index())
+		}
+
+		for _, sig := range block.GetSignatures() {
+			if ok, err := block.Verify(sig); !ok {
+				report.errorf("Block %d has an invalid signature from %s: %v", b, sig.ValidatorHex(), err)
+			}
+		}
+
+		frame, err := s.dbGetFrame(block.RoundReceived())
+		if err != nil {
+			report.errorf("Frame %d of Block %d cannot be read: %v", block.RoundReceived(), b, err)
+			continue
+		}
+
+		frameHash, err := frame.Hash()
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(frameHash, block.FrameHash()) {
+			report.errorf("FrameHash of Block %d does not match Frame %d", b, frame.Round)
+		}
+
+		peersHash, err := peers.NewPeerSet(frame.Peers).Hash()
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(peersHash, block.PeersHash()) {
+			report.errorf("PeersHash of Block %d does not match the Peers of Frame %d", b, frame.Round)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package hashgraph
+
+import (
+	"os"
+	"testing"
+)
+
+func TestBadgerStoreCheck(t *testing.T) {
+	h, index := initConsensusHashgraph(true, t)
+	defer os.RemoveAll(badgerDir)
+
+	h.DivideRounds()
+	h.DecideFame()
+	h.DecideRoundReceived()
+	if err := h.ProcessDecidedRounds(); err != nil {
+		t.Fatal(err)
+	}
+	h.Store.Close()
+
+	store, err := NewReadOnlyBadgerStore(badgerDir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := store.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Errors) != 0 {
+		t.Fatalf("Check should not report errors: %v", report.Errors)
+	}
+	if report.Events != len(index) {
+		t.Fatalf("Check should have verified %d Events, not %d", len(index), report.Events)
+	}
+	if report.Blocks == 0 || report.Frames == 0 || report.Rounds == 0 {
+		t.Fatalf("Check should have verified Blocks, Frames and Rounds: %#v", report)
+	}
+	store.Close()
+
+	//Corrupt the DB by deleting an Event and altering a Block
+	store, err = NewBadgerStore(cacheSize, badgerDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.dbDelete([][]byte{[]byte(index["e10"])}); err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := store.dbGetBlock(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block.Body.FrameHash = []byte("corrupt")
+	if err := store.dbSetBlock(block); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err = store.Check()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	expected := map[string]bool{
+		"Other-parent " + index["e10"] + " of Event " + index["e21"] + " is unknown or does not precede it": false,
+		"FrameHash of Block 0 does not match Frame 1":                                                       false,
+	}
+	for _, e := range report.Errors {
+		if _, ok := expected[e]; ok {
+			expected[e] = true
+		}
+	}
+	for e, found := range expected {
+		if !found {
+			t.Fatalf("Check should report %q, not %v", e, report.Errors)
+		}
+	}
+}
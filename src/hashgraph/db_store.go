@@ -0,0 +1,863 @@
+package hashgraph
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	cm "github.com/abassian/huron/src/common"
+	"github.com/abassian/huron/src/crypto/encryption"
+	"github.com/abassian/huron/src/peers"
+)
+
+const (
+	repertoirePrefix = "rep"
+	peerSetPrefix    = "peerset"
+	rootSuffix       = "root"
+	roundPrefix      = "round"
+	topoPrefix       = "topo"
+	blockPrefix      = "block"
+	framePrefix      = "frame"
+	pruneBaseKey     = "prunebase"
+)
+
+//DBStore is an implementation of the Store interface which persists values in a
+//key-value engine, and uses an InmemStore as a cache. It is created by
+//NewBadgerStore or NewBoltStore, which wrap it with the corresponding engine.
+type DBStore struct {
+	inmemStore *InmemStore
+	db         kvDB
+	path       string
+}
+
+//newDBStore verifies the encryption and schema version of db and wraps it in a
+//DBStore. db is closed if it cannot be used.
+func newDBStore(cacheSize int, db kvDB, path string, key *encryption.Key) (*DBStore, error) {
+	encryptedDB, err := openEncryptedKV(db, key, path)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	db = encryptedDB
+
+	if err := checkSchemaVersion(db, path); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	store := &DBStore{
+		inmemStore: NewInmemStore(cacheSize),
+		db:         db,
+		path:       path,
+	}
+	return store, nil
+}
+
+//dbStore gives access to the DBStore of the Stores which embed it
+func (s *DBStore) dbStore() *DBStore {
+	return s
+}
+
+//persistentStore is implemented by the Stores which embed a DBStore
+type persistentStore interface {
+	dbStore() *DBStore
+}
+
+//getDBStore returns the DBStore of a Store, if it has one
+func getDBStore(store Store) (*DBStore, bool) {
+	ps, ok := store.(persistentStore)
+	if !ok {
+		return nil, false
+	}
+	return ps.dbStore(), true
+}
+
+/*******************************************************************************
+Keys
+*******************************************************************************/
+
+func repertoireKey(pub string) []byte {
+	return []byte(fmt.Sprintf("%s_%s", repertoirePrefix, pub))
+}
+
+func peerSetKey(round int) []byte {
+	return []byte(fmt.Sprintf("%s_%09d", peerSetPrefix, round))
+}
+
+func topologicalEventKey(index int) []byte {
+	return []byte(fmt.Sprintf("%s_%09d", topoPrefix, index))
+}
+
+func participantEventKey(participant string, index int) []byte {
+	return []byte(fmt.Sprintf("%s__event_%09d", participant, index))
+}
+
+func participantRootKey(participant string) []byte {
+	return []byte(fmt.Sprintf("%s_%s", participant, rootSuffix))
+}
+
+func roundKey(index int) []byte {
+	return []byte(fmt.Sprintf("%s_%09d", roundPrefix, index))
+}
+
+func blockKey(index int) []byte {
+	return []byte(fmt.Sprintf("%s_%09d", blockPrefix, index))
+}
+
+func frameKey(index int) []byte {
+	return []byte(fmt.Sprintf("%s_%09d", framePrefix, index))
+}
+
+/*******************************************************************************
+Implement the Store interface
+
+DBStore is an implementation of the Store interface that uses an InmemStore
+for caching and a key-value engine to persist values on disk.
+
+*******************************************************************************/
+
+/*******************************************************************************
+Cache Only
+
+Certain objects are not meant to be retrieved directly from disk; they need to
+be processed by the hashgraph methods first, and added to the InmemStore, before
+they can be used. This is usually done by the Bootstrap method when a node is
+started; it retrieves Events one by one from the disk, in topological order, and
+inserts them in the hashgraph (thereby populating the InmemStore) before running
+the consensus methods.
+
+*******************************************************************************/
+
+//CacheSize sets the inmem cache size
+func (s *DBStore) CacheSize() int {
+	return s.inmemStore.CacheSize()
+}
+
+//Caches returns the Caches of the inmem store
+func (s *DBStore) Caches() *Caches {
+	return s.inmemStore.Caches()
+}
+
+//GetEvent returns the event for the given key
+func (s *DBStore) GetEvent(key string) (*Event, error) {
+	return s.inmemStore.GetEvent(key)
+}
+
+//ParticipantEvents returns that participant's Events from InMem
+func (s *DBStore) ParticipantEvents(participant string, skip int) ([]string, error) {
+	return s.inmemStore.ParticipantEvents(participant, skip)
+}
+
+//ParticipantEvent returns a given event from the given participant from InMem
+func (s *DBStore) ParticipantEvent(participant string, index int) (string, error) {
+	return s.inmemStore.ParticipantEvent(participant, index)
+}
+
+//GetRound returns the round from InMem
+func (s *DBStore) GetRound(r int) (*RoundInfo, error) {
+	return s.inmemStore.GetRound(r)
+}
+
+// RoundWitnesses ...
+func (s *DBStore) RoundWitnesses(r int) []string {
+	round, err := s.GetRound(r)
+	if err != nil {
+		return []string{}
+	}
+	return round.Witnesses()
+}
+
+// RoundEvents ...
+func (s *DBStore) RoundEvents(r int) int {
+	round, err := s.GetRound(r)
+	if err != nil {
+		return 0
+	}
+	return len(round.CreatedEvents)
+}
+
+// GetFrame ...
+func (s *DBStore) GetFrame(rr int) (*Frame, error) {
+	return s.inmemStore.GetFrame(rr)
+}
+
+// GetPeerSet ...
+func (s *DBStore) GetPeerSet(round int) (peerSet *peers.PeerSet, err error) {
+	return s.inmemStore.GetPeerSet(round)
+}
+
+// GetAllPeerSets ...
+func (s *DBStore) GetAllPeerSets() (map[int][]*peers.Peer, error) {
+	return s.inmemStore.GetAllPeerSets()
+}
+
+// FirstRound ...
+func (s *DBStore) FirstRound(id uint32) (int, bool) {
+	return s.inmemStore.FirstRound(id)
+}
+
+// RepertoireByPubKey ...
+func (s *DBStore) RepertoireByPubKey() map[string]*peers.Peer {
+	return s.inmemStore.RepertoireByPubKey()
+}
+
+// RepertoireByID ...
+func (s *DBStore) RepertoireByID() map[uint32]*peers.Peer {
+	return s.inmemStore.RepertoireByID()
+}
+
+// LastEventFrom ...
+func (s *DBStore) LastEventFrom(participant string) (last string, err error) {
+	return s.inmemStore.LastEventFrom(participant)
+}
+
+// LastConsensusEventFrom ...
+func (s *DBStore) LastConsensusEventFrom(participant string) (last string, err error) {
+	return s.inmemStore.LastConsensusEventFrom(participant)
+}
+
+// KnownEvents ...
+func (s *DBStore) KnownEvents() map[uint32]int {
+	return s.inmemStore.KnownEvents()
+}
+
+// ConsensusEvents ...
+func (s *DBStore) ConsensusEvents() []string {
+	return s.inmemStore.ConsensusEvents()
+}
+
+// ConsensusEventsCount ...
+func (s *DBStore) ConsensusEventsCount() int {
+	return s.inmemStore.ConsensusEventsCount()
+}
+
+// AddConsensusEvent ...
+func (s *DBStore) AddConsensusEvent(event *Event) error {
+	return s.inmemStore.AddConsensusEvent(event)
+}
+
+// LastRound ...
+func (s *DBStore) LastRound() int {
+	return s.inmemStore.LastRound()
+}
+
+// LastBlockIndex ...
+func (s *DBStore) LastBlockIndex() int {
+	return s.inmemStore.LastBlockIndex()
+}
+
+/*******************************************************************************
+Cache + DB
+
+The following methods use the InmemStore as a cache. When reading, values are
+first fetched from the cache, and only if they are not found will they be
+fetched from the DB. When writing, the value is written both to the cache
+and to the DB.
+
+*******************************************************************************/
+
+// SetPeerSet ...
+func (s *DBStore) SetPeerSet(round int, peerSet *peers.PeerSet) error {
+	//Update the cache
+	if err := s.inmemStore.SetPeerSet(round, peerSet); err != nil {
+		return err
+	}
+
+	//update the db
+	if err := s.dbSetPeerSet(round, peerSet); err != nil {
+		return err
+	}
+
+	//Extend Repertoire and Roots
+	for _, p := range peerSet.Peers {
+		err := s.addParticipant(p)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *DBStore) addParticipant(p *peers.Peer) error {
+	if err := s.dbSetRepertoire(p); err != nil {
+		return err
+	}
+
+	_, err := s.dbGetRoot(p.PubKeyString())
+	if err != nil {
+		root := NewRoot()
+		if err := s.dbSetRoot(p.PubKeyString(), root); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetEvent ...
+func (s *DBStore) SetEvent(event *Event) error {
+	//try to add it to the cache
+	if err := s.inmemStore.SetEvent(event); err != nil {
+		return err
+	}
+	//try to add it to the db
+	return s.dbSetEvents([]*Event{event})
+}
+
+// SetRound ...
+func (s *DBStore) SetRound(r int, round *RoundInfo) error {
+	if err := s.inmemStore.SetRound(r, round); err != nil {
+		return err
+	}
+	return s.dbSetRound(r, round)
+}
+
+// GetRoot ...
+func (s *DBStore) GetRoot(participant string) (*Root, error) {
+	root, err := s.inmemStore.GetRoot(participant)
+	if err != nil {
+		root, err = s.dbGetRoot(participant)
+	}
+	return root, mapError(err, "Root", string(participantRootKey(participant)))
+}
+
+// GetBlock ...
+func (s *DBStore) GetBlock(rr int) (*Block, error) {
+	res, err := s.inmemStore.GetBlock(rr)
+	if err != nil {
+		res, err = s.dbGetBlock(rr)
+	}
+	return res, mapError(err, "Block", string(blockKey(rr)))
+}
+
+// SetBlock ...
+func (s *DBStore) SetBlock(block *Block) error {
+	if err := s.inmemStore.SetBlock(block); err != nil {
+		return err
+	}
+	return s.dbSetBlock(block)
+}
+
+// SetFrame ...
+func (s *DBStore) SetFrame(frame *Frame) error {
+	if err := s.inmemStore.SetFrame(frame); err != nil {
+		return err
+	}
+	return s.dbSetFrame(frame)
+}
+
+// Reset ...
+func (s *DBStore) Reset(frame *Frame) error {
+	//Reset InmemStore
+	if err := s.inmemStore.Reset(frame); err != nil {
+		return err
+	}
+
+	//Set Frame, Roots, and PeerSet
+	if err := s.dbSetFrame(frame); err != nil {
+		return err
+	}
+
+	for p, root := range frame.Roots {
+		if err := s.dbSetRoot(p, root); err != nil {
+			return err
+		}
+	}
+
+	peerSet := peers.NewPeerSet(frame.Peers)
+	if err := s.dbSetPeerSet(frame.Round, peerSet); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Close ...
+func (s *DBStore) Close() error {
+	if err := s.inmemStore.Close(); err != nil {
+		return err
+	}
+	return s.db.Close()
+}
+
+// StorePath ...
+func (s *DBStore) StorePath() string {
+	return s.path
+}
+
+// Prune implements Pruner. The PruneBase is written first so that, should the
+// process stop in the middle of the deletions, the DB can still be
+// bootstrapped. The Events of the PruneBase Roots are not needed to bootstrap
+// from the PruneBase Frame, so they are only kept in the cache.
+func (s *DBStore) Prune(base *PruneBase) (int, error) {
+	if _, err := s.inmemStore.Prune(base); err != nil {
+		return 0, err
+	}
+
+	if err := s.dbSetPruneBase(base); err != nil {
+		return 0, err
+	}
+
+	return s.dbPrune(base.Round)
+}
+
+// GetPruneBase implements Pruner
+func (s *DBStore) GetPruneBase() (*PruneBase, error) {
+	base, err := s.inmemStore.GetPruneBase()
+	if err != nil {
+		base, err = s.dbGetPruneBase()
+	}
+	return base, mapError(err, "PruneBase", pruneBaseKey)
+}
+
+/*******************************************************************************
+DB Methods
+*******************************************************************************/
+
+func (s *DBStore) dbGetRepertoire() (map[string]*peers.Peer, error) {
+	repertoire := make(map[string]*peers.Peer)
+	err := s.db.View(func(txn kvTxn) error {
+		return txn.Iterate([]byte(repertoirePrefix), func(key, peerBytes []byte) error {
+			peer := &peers.Peer{}
+			if err := peer.Unmarshal(peerBytes); err != nil {
+				return err
+			}
+
+			repertoire[peer.PubKeyString()] = peer
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return repertoire, nil
+}
+
+func (s *DBStore) dbSetRepertoire(peer *peers.Peer) error {
+	key := repertoireKey(peer.PubKeyString())
+	val, err := peer.Marshal()
+	if err != nil {
+		return err
+	}
+
+	//insert [pub] => [Peer]
+	return kvSet(s.db, key, val)
+}
+
+func (s *DBStore) dbGetPeerSet(round int) (*peers.PeerSet, error) {
+	peerSliceBytes, err := kvGet(s.db, peerSetKey(round))
+	if err != nil {
+		return nil, err
+	}
+
+	return peers.NewPeerSetFromPeerSliceBytes(peerSliceBytes)
+}
+
+func (s *DBStore) dbSetPeerSet(round int, peerSet *peers.PeerSet) error {
+	key := peerSetKey(round)
+	val, err := peerSet.Marshal()
+	if err != nil {
+		return err
+	}
+
+	//insert [round_index] => [PeerSet bytes]
+	return kvSet(s.db, key, val)
+}
+
+func (s *DBStore) dbGetEvent(key string) (*Event, error) {
+	eventBytes, err := kvGet(s.db, []byte(key))
+	if err != nil {
+		return nil, err
+	}
+
+	event := new(Event)
+	if err := event.Unmarshal(eventBytes); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
+
+func (s *DBStore) dbSetEvents(events []*Event) error {
+	return s.db.Update(func(txn kvTxn) error {
+		for _, event := range events {
+			eventHex := event.Hex()
+			val, err := event.Marshal()
+			if err != nil {
+				return err
+			}
+			//check if it already exists
+			new := false
+			_, err = txn.Get([]byte(eventHex))
+			if err != nil && isDBKeyNotFound(err) {
+				new = true
+			}
+			//insert [event hash] => [event bytes]
+			if err := txn.Set([]byte(eventHex), val); err != nil {
+				return err
+			}
+
+			if new {
+				//insert [topo_index] => [event hash]
+				topoKey := topologicalEventKey(event.topologicalIndex)
+				if err := txn.Set(topoKey, []byte(eventHex)); err != nil {
+					return err
+				}
+				//insert [participant_index] => [event hash]
+				peKey := participantEventKey(event.Creator(), event.Index())
+				if err := txn.Set(peKey, []byte(eventHex)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func (s *DBStore) dbParticipantEvents(participant string, skip int) ([]string, error) {
+	res := []string{}
+	err := s.db.View(func(txn kvTxn) error {
+		i := skip + 1
+		v, errr := txn.Get(participantEventKey(participant, i))
+		for errr == nil {
+			res = append(res, string(v))
+
+			i++
+			v, errr = txn.Get(participantEventKey(participant, i))
+		}
+
+		if !isDBKeyNotFound(errr) {
+			return errr
+		}
+
+		return nil
+	})
+	return res, err
+}
+
+func (s *DBStore) dbParticipantEvent(participant string, index int) (string, error) {
+	data, err := kvGet(s.db, participantEventKey(participant, index))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+//dbTopologicalEvents returns the Events in topological order. The topological
+//indexes do not necessarily start at 0 because the oldest Events may have been
+//pruned.
+func (s *DBStore) dbTopologicalEvents() ([]*Event, error) {
+	res := []*Event{}
+	err := s.db.View(func(txn kvTxn) error {
+		prefix := []byte(topoPrefix + "_")
+		return txn.Iterate(prefix, func(key, v []byte) error {
+			t, err := strconv.Atoi(strings.TrimPrefix(string(key), string(prefix)))
+			if err != nil {
+				return err
+			}
+
+			eventBytes, err := txn.Get(v)
+			if err != nil {
+				return err
+			}
+
+			event := new(Event)
+			if err := event.Unmarshal(eventBytes); err != nil {
+				return err
+			}
+			event.topologicalIndex = t
+			res = append(res, event)
+			return nil
+		})
+	})
+
+	return res, err
+}
+
+//dbLastTopologicalEvents returns, in topological order, the last Events of the
+//topological order, going back until stop returns true for one of them, which
+//is included.
+func (s *DBStore) dbLastTopologicalEvents(stop func(*Event) bool) ([]*Event, error) {
+	res := []*Event{}
+	err := s.db.View(func(txn kvTxn) error {
+		prefix := []byte(topoPrefix + "_")
+		return txn.IterateReverse(prefix, func(key, v []byte) error {
+			t, err := strconv.Atoi(strings.TrimPrefix(string(key), string(prefix)))
+			if err != nil {
+				return err
+			}
+
+			eventBytes, err := txn.Get(v)
+			if err != nil {
+				return err
+			}
+
+			event := new(Event)
+			if err := event.Unmarshal(eventBytes); err != nil {
+				return err
+			}
+			event.topologicalIndex = t
+			res = append(res, event)
+
+			if stop(event) {
+				return errStopIteration
+			}
+			return nil
+		})
+	})
+
+	if err == errStopIteration {
+		err = nil
+	}
+
+	for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+		res[i], res[j] = res[j], res[i]
+	}
+
+	return res, err
+}
+
+func (s *DBStore) dbSetRoot(participant string, root *Root) error {
+	key := participantRootKey(participant)
+
+	val, err := root.Marshal()
+	if err != nil {
+		return err
+	}
+
+	//insert [participant_root] => [root bytes]
+	return kvSet(s.db, key, val)
+}
+
+func (s *DBStore) dbGetRoot(participant string) (*Root, error) {
+	rootBytes, err := kvGet(s.db, participantRootKey(participant))
+	if err != nil {
+		return nil, err
+	}
+
+	root := new(Root)
+	if err := root.Unmarshal(rootBytes); err != nil {
+		return nil, err
+	}
+
+	return root, nil
+}
+
+func (s *DBStore) dbGetRound(index int) (*RoundInfo, error) {
+	roundBytes, err := kvGet(s.db, roundKey(index))
+	if err != nil {
+		return nil, err
+	}
+
+	roundInfo := new(RoundInfo)
+	if err := roundInfo.Unmarshal(roundBytes); err != nil {
+		return nil, err
+	}
+
+	return roundInfo, nil
+}
+
+func (s *DBStore) dbSetRound(index int, round *RoundInfo) error {
+	key := roundKey(index)
+	val, err := round.Marshal()
+	if err != nil {
+		return err
+	}
+
+	//insert [round_index] => [round bytes]
+	return kvSet(s.db, key, val)
+}
+
+func (s *DBStore) dbGetBlock(index int) (*Block, error) {
+	blockBytes, err := kvGet(s.db, blockKey(index))
+	if err != nil {
+		return nil, err
+	}
+
+	block := new(Block)
+	if err := block.Unmarshal(blockBytes); err != nil {
+		return nil, err
+	}
+
+	return block, nil
+}
+
+func (s *DBStore) dbSetBlock(block *Block) error {
+	key := blockKey(block.Index())
+	val, err := block.Marshal()
+	if err != nil {
+		return err
+	}
+
+	//insert [index] => [block bytes]
+	return kvSet(s.db, key, val)
+}
+
+func (s *DBStore) dbGetFrame(index int) (*Frame, error) {
+	frameBytes, err := kvGet(s.db, frameKey(index))
+	if err != nil {
+		return nil, err
+	}
+
+	frame := new(Frame)
+	if err := frame.Unmarshal(frameBytes); err != nil {
+		return nil, err
+	}
+
+	return frame, nil
+}
+
+func (s *DBStore) dbSetFrame(frame *Frame) error {
+	key := frameKey(frame.Round)
+	val, err := frame.Marshal()
+	if err != nil {
+		return err
+	}
+
+	//insert [round] => [frame bytes]
+	return kvSet(s.db, key, val)
+}
+
+func (s *DBStore) dbGetPruneBase() (*PruneBase, error) {
+	baseBytes, err := kvGet(s.db, []byte(pruneBaseKey))
+	if err != nil {
+		return nil, err
+	}
+
+	base := new(PruneBase)
+	if err := base.Unmarshal(baseBytes); err != nil {
+		return nil, err
+	}
+
+	return base, nil
+}
+
+func (s *DBStore) dbSetPruneBase(base *PruneBase) error {
+	val, err := base.Marshal()
+	if err != nil {
+		return err
+	}
+
+	//insert [prunebase] => [PruneBase bytes]
+	return kvSet(s.db, []byte(pruneBaseKey), val)
+}
+
+//dbPrune deletes the RoundInfos below round, the Events they received, and the
+//participant and topological indexes of these Events. It returns the number of
+//deleted Events.
+func (s *DBStore) dbPrune(round int) (int, error) {
+	keys := [][]byte{}
+	events := make(map[string]bool)
+
+	err := s.db.View(func(txn kvTxn) error {
+		//RoundInfos come out in increasing order
+		prefix := []byte(roundPrefix + "_")
+		err := txn.Iterate(prefix, func(key, roundBytes []byte) error {
+			index, err := strconv.Atoi(strings.TrimPrefix(string(key), string(prefix)))
+			if err != nil {
+				return err
+			}
+			if index >= round {
+				return errStopIteration
+			}
+
+			roundInfo := new(RoundInfo)
+			if err := roundInfo.Unmarshal(roundBytes); err != nil {
+				return err
+			}
+
+			for _, e := range roundInfo.ReceivedEvents {
+				events[e] = true
+			}
+
+			keys = append(keys, roundKey(index))
+			return nil
+		})
+		if err != nil && err != errStopIteration {
+			return err
+		}
+
+		for e := range events {
+			eventBytes, err := txn.Get([]byte(e))
+			if err != nil {
+				if isDBKeyNotFound(err) {
+					delete(events, e)
+					continue
+				}
+				return err
+			}
+
+			event := new(Event)
+			if err := event.Unmarshal(eventBytes); err != nil {
+				return err
+			}
+
+			keys = append(keys, []byte(e), participantEventKey(event.Creator(), event.Index()))
+		}
+
+		//Topological indexes point to the Events' hashes
+		return txn.Iterate([]byte(topoPrefix+"_"), func(key, v []byte) error {
+			if events[string(v)] {
+				keys = append(keys, append([]byte{}, key...))
+			}
+			return nil
+		})
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	if err := s.dbDelete(keys); err != nil {
+		return 0, err
+	}
+
+	return len(events), nil
+}
+
+//dbDeleteBatchSize is the number of keys deleted per transaction by dbDelete,
+//so as not to exceed the size limit of badger transactions
+const dbDeleteBatchSize = 1000
+
+//dbDelete deletes keys, using as many transactions as necessary
+func (s *DBStore) dbDelete(keys [][]byte) error {
+	for len(keys) > 0 {
+		batch := keys
+		if len(batch) > dbDeleteBatchSize {
+			batch = batch[:dbDeleteBatchSize]
+		}
+		keys = keys[len(batch):]
+
+		err := s.db.Update(func(txn kvTxn) error {
+			for _, k := range batch {
+				if err := txn.Delete(k); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+
+func isDBKeyNotFound(err error) bool {
+	return err == errKVKeyNotFound
+}
+
+func mapError(err error, name, key string) error {
+	if err != nil {
+		if isDBKeyNotFound(err) {
+			return cm.NewStoreErr(name, cm.KeyNotFound, key)
+		}
+	}
+	return err
+}
@@ -1,9 +1,12 @@
 package hashgraph
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
@@ -27,6 +30,41 @@ func initBadgerStore(cacheSize int, t *testing.T) *BadgerStore {
 }
 
 func removeBadgerStore(store *BadgerStore, t *testing.T) {
+	removeDBStore(store.DBStore, t)
+}
+
+func initBoltStore(cacheSize int, t *testing.T) *BoltStore {
+	os.RemoveAll("test_data")
+	os.Mkdir("test_data", os.ModeDir|0777)
+	dir, err := ioutil.TempDir("test_data", "bolt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewBoltStore(cacheSize, filepath.Join(dir, "bolt.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store
+}
+
+//storeBackends are the key-value engines of the DBStore
+var storeBackends = []string{"badger", "bolt"}
+
+func initDBStore(backend string, cacheSize int, t *testing.T) *DBStore {
+	switch backend {
+	case "badger":
+		return initBadgerStore(cacheSize, t).DBStore
+	case "bolt":
+		return initBoltStore(cacheSize, t).DBStore
+	}
+
+	t.Fatalf("Unknown backend %s", backend)
+	return nil
+}
+
+func removeDBStore(store *DBStore, t *testing.T) {
 	if err := store.Close(); err != nil {
 		t.Fatal(err)
 	}
@@ -35,12 +73,25 @@ func removeBadgerStore(store *BadgerStore, t *testing.T) {
 	}
 }
 
+//forEachBackend runs a store test against every backend
+func forEachBackend(t *testing.T, test func(t *testing.T, backend string)) {
+	for _, backend := range storeBackends {
+		t.Run(backend, func(t *testing.T) {
+			test(t, backend)
+		})
+	}
+}
+
 /*******************************************************************************
-Test creating, loading, and closing a BadgerStore
+Test creating, loading, and closing a DBStore
 *******************************************************************************/
 
-func TestNewBadgerStore(t *testing.T) {
-	store := initBadgerStore(1000, t)
+func TestNewDBStore(t *testing.T) {
+	forEachBackend(t, testNewDBStore)
+}
+
+func testNewDBStore(t *testing.T, backend string) {
+	store := initDBStore(backend, 1000, t)
 
 	if _, err := os.Stat(store.path); err != nil {
 		t.Fatalf("err: %s", err)
@@ -56,10 +107,14 @@ Call DB methods directly
 *******************************************************************************/
 
 func TestDBRepertoireMethods(t *testing.T) {
+	forEachBackend(t, testDBRepertoireMethods)
+}
+
+func testDBRepertoireMethods(t *testing.T, backend string) {
 	cacheSize := 0
 
-	store := initBadgerStore(cacheSize, t)
-	defer removeBadgerStore(store, t)
+	store := initDBStore(backend, cacheSize, t)
+	defer removeDBStore(store, t)
 
 	peerSet, _ := initPeers(3)
 
@@ -86,10 +141,14 @@ func TestDBRepertoireMethods(t *testing.T) {
 }
 
 func TestDBPeerSetMethods(t *testing.T) {
+	forEachBackend(t, testDBPeerSetMethods)
+}
+
+func testDBPeerSetMethods(t *testing.T, backend string) {
 	cacheSize := 0
 
-	store := initBadgerStore(cacheSize, t)
-	defer removeBadgerStore(store, t)
+	store := initDBStore(backend, cacheSize, t)
+	defer removeDBStore(store, t)
 
 	peerSet, _ := initPeers(3)
 
@@ -109,11 +168,15 @@ func TestDBPeerSetMethods(t *testing.T) {
 }
 
 func TestDBEventMethods(t *testing.T) {
+	forEachBackend(t, testDBEventMethods)
+}
+
+func testDBEventMethods(t *testing.T, backend string) {
 	cacheSize := 0
 	testSize := 100
 
-	store := initBadgerStore(cacheSize, t)
-	defer removeBadgerStore(store, t)
+	store := initDBStore(backend, cacheSize, t)
+	defer removeDBStore(store, t)
 
 	_, participants := initPeers(3)
 
@@ -196,13 +259,37 @@ func TestDBEventMethods(t *testing.T) {
 			t.Fatalf("failed to verify signature. err: %s", err)
 		}
 	}
+
+	//read the end of the topological order backwards
+	tail := 10
+	stop := topologicalEvents[len(topologicalEvents)-tail].Hex()
+	lastEvents, err := store.dbLastTopologicalEvents(func(e *Event) bool {
+		return e.Hex() == stop
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lastEvents) != tail {
+		t.Fatalf("Length of lastEvents should be %d, not %d", tail, len(lastEvents))
+	}
+	for i, e := range lastEvents {
+		te := topologicalEvents[len(topologicalEvents)-tail+i]
+		if e.Hex() != te.Hex() || e.topologicalIndex != te.topologicalIndex {
+			t.Fatalf("lastEvents[%d] should be %s (%d), not %s (%d)", i,
+				te.Hex(), te.topologicalIndex, e.Hex(), e.topologicalIndex)
+		}
+	}
 }
 
 func TestDBRoundMethods(t *testing.T) {
+	forEachBackend(t, testDBRoundMethods)
+}
+
+func testDBRoundMethods(t *testing.T, backend string) {
 	cacheSize := 0
 
-	store := initBadgerStore(cacheSize, t)
-	defer removeBadgerStore(store, t)
+	store := initDBStore(backend, cacheSize, t)
+	defer removeDBStore(store, t)
 
 	_, participants := initPeers(3)
 
@@ -234,10 +321,14 @@ func TestDBRoundMethods(t *testing.T) {
 }
 
 func TestDBBlockMethods(t *testing.T) {
+	forEachBackend(t, testDBBlockMethods)
+}
+
+func testDBBlockMethods(t *testing.T, backend string) {
 	cacheSize := 0
 
-	store := initBadgerStore(cacheSize, t)
-	defer removeBadgerStore(store, t)
+	store := initDBStore(backend, cacheSize, t)
+	defer removeDBStore(store, t)
 
 	peerSet, participants := initPeers(3)
 
@@ -321,10 +412,14 @@ func TestDBBlockMethods(t *testing.T) {
 }
 
 func TestDBFrameMethods(t *testing.T) {
+	forEachBackend(t, testDBFrameMethods)
+}
+
+func testDBFrameMethods(t *testing.T, backend string) {
 	cacheSize := 0
 
-	store := initBadgerStore(cacheSize, t)
-	defer removeBadgerStore(store, t)
+	store := initDBStore(backend, cacheSize, t)
+	defer removeDBStore(store, t)
 
 	peerSet, participants := initPeers(3)
 
@@ -383,11 +478,15 @@ Test wrapper methods work. These methods use the inmemStore as a cache on top of
 the DB.
 *******************************************************************************/
 
-func TestBadgerPeerSets(t *testing.T) {
+func TestDBStorePeerSets(t *testing.T) {
+	forEachBackend(t, testDBStorePeerSets)
+}
+
+func testDBStorePeerSets(t *testing.T, backend string) {
 	cacheSize := 1000
 
-	store := initBadgerStore(cacheSize, t)
-	defer removeBadgerStore(store, t)
+	store := initDBStore(backend, cacheSize, t)
+	defer removeDBStore(store, t)
 
 	peerSet, _ := initPeers(3)
 
@@ -437,13 +536,17 @@ func TestBadgerPeerSets(t *testing.T) {
 	}
 }
 
-func TestBadgerEvents(t *testing.T) {
+func TestDBStoreEvents(t *testing.T) {
+	forEachBackend(t, testDBStoreEvents)
+}
+
+func testDBStoreEvents(t *testing.T, backend string) {
 	//Insert more events than can fit in cache to test retrieving from db.
 	cacheSize := 10
 	testSize := 100
 
-	store := initBadgerStore(cacheSize, t)
-	defer removeBadgerStore(store, t)
+	store := initDBStore(backend, cacheSize, t)
+	defer removeDBStore(store, t)
 
 	peerSet, participants := initPeers(3)
 
@@ -548,11 +651,15 @@ func TestBadgerEvents(t *testing.T) {
 	}
 }
 
-func TestBadgerRounds(t *testing.T) {
+func TestDBStoreRounds(t *testing.T) {
+	forEachBackend(t, testDBStoreRounds)
+}
+
+func testDBStoreRounds(t *testing.T, backend string) {
 	cacheSize := 0
 
-	store := initBadgerStore(cacheSize, t)
-	defer removeBadgerStore(store, t)
+	store := initDBStore(backend, cacheSize, t)
+	defer removeDBStore(store, t)
 
 	peerSet, participants := initPeers(3)
 
@@ -594,11 +701,15 @@ func TestBadgerRounds(t *testing.T) {
 	}
 }
 
-func TestBadgerBlocks(t *testing.T) {
+func TestDBStoreBlocks(t *testing.T) {
+	forEachBackend(t, testDBStoreBlocks)
+}
+
+func testDBStoreBlocks(t *testing.T, backend string) {
 	cacheSize := 0
 
-	store := initBadgerStore(cacheSize, t)
-	defer removeBadgerStore(store, t)
+	store := initDBStore(backend, cacheSize, t)
+	defer removeDBStore(store, t)
 
 	peerSet, participants := initPeers(3)
 
@@ -684,11 +795,15 @@ func TestBadgerBlocks(t *testing.T) {
 	})
 }
 
-func TestBadgerFrames(t *testing.T) {
+func TestDBStoreFrames(t *testing.T) {
+	forEachBackend(t, testDBStoreFrames)
+}
+
+func testDBStoreFrames(t *testing.T, backend string) {
 	cacheSize := 0
 
-	store := initBadgerStore(cacheSize, t)
-	defer removeBadgerStore(store, t)
+	store := initDBStore(backend, cacheSize, t)
+	defer removeDBStore(store, t)
 
 	peerSet, participants := initPeers(3)
 
@@ -739,3 +854,92 @@ func TestBadgerFrames(t *testing.T) {
 		}
 	})
 }
+
+/*******************************************************************************
+Test backing up and restoring a DBStore
+*******************************************************************************/
+
+func TestBackup(t *testing.T) {
+	for _, backend := range storeBackends {
+		t.Run(backend, func(t *testing.T) {
+			var (
+				store   *DBStore
+				backup  func(w io.Writer) error
+				restore func(r io.Reader, path string) error
+				open    func(path string) (*DBStore, error)
+			)
+
+			switch backend {
+			case "badger":
+				badgerStore := initBadgerStore(100, t)
+				store, backup, restore = badgerStore.DBStore, badgerStore.Backup, RestoreBadgerStore
+				open = func(path string) (*DBStore, error) {
+					s, err := NewBadgerStore(100, path)
+					if err != nil {
+						return nil, err
+					}
+					return s.DBStore, nil
+				}
+			case "bolt":
+				boltStore := initBoltStore(100, t)
+				store, backup, restore = boltStore.DBStore, boltStore.Backup, RestoreBoltStore
+				open = func(path string) (*DBStore, error) {
+					s, err := NewBoltStore(100, path)
+					if err != nil {
+						return nil, err
+					}
+					return s.DBStore, nil
+				}
+			}
+			defer removeDBStore(store, t)
+
+			peerSet, _ := initPeers(3)
+			if err := store.SetPeerSet(0, peerSet); err != nil {
+				t.Fatal(err)
+			}
+
+			block := NewBlock(0, 1, []byte("framehash"), peerSet.Peers, [][]byte{[]byte("tx")}, []InternalTransaction{})
+			if err := store.SetBlock(block); err != nil {
+				t.Fatal(err)
+			}
+
+			var buf bytes.Buffer
+			if err := backup(&buf); err != nil {
+				t.Fatal(err)
+			}
+
+			restorePath := store.path + "-restored"
+			defer os.RemoveAll(restorePath)
+
+			if err := restore(bytes.NewReader(buf.Bytes()), restorePath); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := restore(bytes.NewReader(buf.Bytes()), restorePath); err == nil {
+				t.Fatalf("Restoring into an existing database should fail")
+			}
+
+			restored, err := open(restorePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer restored.Close()
+
+			restoredBlock, err := restored.DBGetBlock(0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(block.Body, restoredBlock.Body) {
+				t.Fatalf("Restored Block should be %#v, not %#v", block.Body, restoredBlock.Body)
+			}
+
+			restoredPeerSet, err := restored.DBGetPeerSet(0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if restoredPeerSet.Hex() != peerSet.Hex() {
+				t.Fatalf("Restored PeerSet should be %s, not %s", peerSet.Hex(), restoredPeerSet.Hex())
+			}
+		})
+	}
+}
@@ -0,0 +1,313 @@
+package hashgraph
+
+import (
+	"fmt"
+
+	"github.com/abassian/huron/src/crypto/encryption"
+)
+
+/*******************************************************************************
+Encryption at rest
+
+When a DBStore is opened with an encryption Key, the values of the DB are
+sealed with the Key. The keys of the DB, and the metadata needed to open it (the
+schema version and the ID of the Key), remain in plaintext. The ID of the Key is
+recorded so that a DB is never opened with the wrong Key, or without a Key when
+it is encrypted. The Key is changed by RekeyBadgerStore or RekeyBoltStore.
+*******************************************************************************/
+
+const encryptionKeyIDKey = "encryption_key_id"
+
+//rekeyBatchSize is the number of values re-encrypted per transaction. Unlike
+//deleted keys, the values are written back, so batches must remain small.
+const rekeyBatchSize = 100
+
+//EncryptionError is returned when a DBStore is opened with a Key which does not
+//match the encryption of the DB
+type EncryptionError struct {
+	Path string
+	//KeyID is the ID of the Key which encrypts the DB, or an empty string if
+	//the DB is not encrypted
+	KeyID string
+}
+
+func (e EncryptionError) Error() string {
+	if e.KeyID == "" {
+		return fmt.Sprintf("Database %s is not encrypted. Run 'huron db rekey' to encrypt it", e.Path)
+	}
+	return fmt.Sprintf("Database %s is encrypted with key %s", e.Path, e.KeyID)
+}
+
+func isDBMetadata(key []byte) bool {
+	k := string(key)
+	return k == schemaVersionKey || k == encryptionKeyIDKey
+}
+
+//openEncryptedKV verifies that key is the Key of db, and returns db wrapped in
+//an encryptedKV. A nil key opens an unencrypted db. A new db is encrypted with
+//key.
+func openEncryptedKV(db kvDB, key *encryption.Key, path string) (kvDB, error) {
+	id, err := dbGetEncryptionKeyID(db)
+	if err != nil {
+		return nil, err
+	}
+
+	if id == "" && key != nil {
+		empty, err := dbIsEmpty(db)
+		if err != nil {
+			return nil, err
+		}
+		if !empty {
+			return nil, EncryptionError{Path: path}
+		}
+
+		id = key.ID()
+		if err := kvSet(db, []byte(encryptionKeyIDKey), []byte(id)); err != nil {
+			return nil, err
+		}
+	}
+
+	if key == nil {
+		if id != "" {
+			return nil, EncryptionError{Path: path, KeyID: id}
+		}
+		return db, nil
+	}
+
+	if id != key.ID() {
+		return nil, EncryptionError{Path: path, KeyID: id}
+	}
+
+	return &encryptedKV{kvDB: db, keys: []*encryption.Key{key}}, nil
+}
+
+func dbGetEncryptionKeyID(db kvDB) (string, error) {
+	id, err := kvGet(db, []byte(encryptionKeyIDKey))
+	if err != nil {
+		if isDBKeyNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return string(id), nil
+}
+
+//encryptedKV seals the values of a kvDB with the first of its keys, and opens
+//them with any of its keys
+type encryptedKV struct {
+	kvDB
+	keys []*encryption.Key
+	//plaintext values are accepted while a DB is being encrypted or decrypted
+	plaintext bool
+}
+
+func (e *encryptedKV) View(fn func(txn kvTxn) error) error {
+	return e.kvDB.View(func(txn kvTxn) error {
+		return fn(&encryptedTxn{txn, e})
+	})
+}
+
+func (e *encryptedKV) Update(fn func(txn kvTxn) error) error {
+	return e.kvDB.Update(func(txn kvTxn) error {
+		return fn(&encryptedTxn{txn, e})
+	})
+}
+
+func (e *encryptedKV) seal(value []byte) ([]byte, error) {
+	if len(e.keys) == 0 || e.keys[0] == nil {
+		return value, nil
+	}
+	return e.keys[0].Seal(value)
+}
+
+func (e *encryptedKV) open(value []byte) ([]byte, error) {
+	if id, ok := encryption.SealedBy(value); ok {
+		for _, k := range e.keys {
+			if k != nil && k.ID() == id {
+				return k.Open(value)
+			}
+		}
+		if !e.plaintext {
+			return nil, fmt.Errorf("Value is encrypted with unknown key %s", id)
+		}
+	}
+
+	if !e.plaintext {
+		return nil, fmt.Errorf("Value is not encrypted")
+	}
+
+	return value, nil
+}
+
+type encryptedTxn struct {
+	kvTxn
+	kv *encryptedKV
+}
+
+func (t *encryptedTxn) Get(key []byte) ([]byte, error) {
+	value, err := t.kvTxn.Get(key)
+	if err != nil || isDBMetadata(key) {
+		return value, err
+	}
+	return t.kv.open(value)
+}
+
+func (t *encryptedTxn) Set(key, value []byte) error {
+	if !isDBMetadata(key) {
+		var err error
+		if value, err = t.kv.seal(value); err != nil {
+			return err
+		}
+	}
+	return t.kvTxn.Set(key, value)
+}
+
+func (t *encryptedTxn) Iterate(prefix []byte, fn func(key, value []byte) error) error {
+	return t.kvTxn.Iterate(prefix, t.opener(fn))
+}
+
+func (t *encryptedTxn) IterateReverse(prefix []byte, fn func(key, value []byte) error) error {
+	return t.kvTxn.IterateReverse(prefix, t.opener(fn))
+}
+
+//opener wraps an iteration function so that it is passed decrypted values
+func (t *encryptedTxn) opener(fn func(key, value []byte) error) func(key, value []byte) error {
+	return func(key, value []byte) error {
+		if isDBMetadata(key) {
+			return fn(key, value)
+		}
+
+		value, err := t.kv.open(value)
+		if err != nil {
+			return fmt.Errorf("Decrypting %s: %v", key, err)
+		}
+		return fn(key, value)
+	}
+}
+
+/*******************************************************************************
+Key rotation
+*******************************************************************************/
+
+//RekeyBadgerStore re-encrypts the values of the badger database in path with
+//newKey. oldKey is the current Key of the database, or nil if it is not
+//encrypted. A nil newKey decrypts the database. An interrupted rekey can be
+//resumed by running it again with the same Keys, and a database which already
+//has newKey is left as is. It returns the number of values which were
+//re-encrypted.
+func RekeyBadgerStore(path string, oldKey, newKey *encryption.Key) (int, error) {
+	handle, err := openBadgerDB(path, false)
+	if err != nil {
+		return 0, err
+	}
+	db := &badgerKV{handle}
+	defer db.Close()
+
+	return rekey(db, path, oldKey, newKey)
+}
+
+//RekeyBoltStore re-encrypts the values of the bbolt database file in path, like
+//RekeyBadgerStore
+func RekeyBoltStore(path string, oldKey, newKey *encryption.Key) (int, error) {
+	handle, err := openBoltDB(path, false)
+	if err != nil {
+		return 0, err
+	}
+	db := &boltKV{handle}
+	defer db.Close()
+
+	return rekey(db, path, oldKey, newKey)
+}
+
+func rekey(db kvDB, path string, oldKey, newKey *encryption.Key) (int, error) {
+	id, err := dbGetEncryptionKeyID(db)
+	if err != nil {
+		return 0, err
+	}
+
+	//the database was done by an interrupted rekey, which stopped later, eg.
+	//on the snapshots
+	if newKey != nil && id == newKey.ID() || newKey == nil && id == "" && oldKey != nil {
+		return 0, nil
+	}
+
+	//the recorded ID only changes once all the values are re-encrypted
+	if id == "" && oldKey != nil || id != "" && (oldKey == nil || id != oldKey.ID()) {
+		return 0, EncryptionError{Path: path, KeyID: id}
+	}
+
+	src := &encryptedKV{
+		kvDB:      db,
+		keys:      []*encryption.Key{oldKey, newKey},
+		plaintext: true,
+	}
+	dst := &encryptedKV{
+		kvDB: db,
+		keys: []*encryption.Key{newKey},
+	}
+
+	keys := [][]byte{}
+	err = db.View(func(txn kvTxn) error {
+		return txn.Iterate([]byte{}, func(key, value []byte) error {
+			if !isDBMetadata(key) {
+				keys = append(keys, append([]byte{}, key...))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := 0; i < len(keys); i += rekeyBatchSize {
+		end := i + rekeyBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		err := db.Update(func(txn kvTxn) error {
+			for _, key := range keys[i:end] {
+				value, err := txn.Get(key)
+				if err != nil {
+					return err
+				}
+
+				//skip the values which were re-encrypted by an interrupted
+				//rekey
+				sealedBy, sealed := encryption.SealedBy(value)
+				if newKey != nil && sealed && sealedBy == newKey.ID() {
+					continue
+				}
+
+				plaintext, err := src.open(value)
+				if err != nil {
+					return fmt.Errorf("Decrypting %s: %v", key, err)
+				}
+
+				value, err = dst.seal(plaintext)
+				if err != nil {
+					return err
+				}
+
+				if err := txn.Set(key, value); err != nil {
+					return err
+				}
+				count++
+			}
+			return nil
+		})
+		if err != nil {
+			return count, err
+		}
+	}
+
+	err = db.Update(func(txn kvTxn) error {
+		if newKey == nil {
+			return txn.Delete([]byte(encryptionKeyIDKey))
+		}
+		return txn.Set([]byte(encryptionKeyIDKey), []byte(newKey.ID()))
+	})
+
+	return count, err
+}
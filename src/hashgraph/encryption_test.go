@@ -0,0 +1,167 @@
+package hashgraph
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/abassian/huron/src/crypto/encryption"
+)
+
+func newTestKey(t *testing.T) *encryption.Key {
+	secret, err := encryption.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := encryption.NewKey(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+//openEncryptedStore and rekeyStore open and rekey a database of the given
+//backend
+func openEncryptedStore(backend, path string, key *encryption.Key) (*DBStore, error) {
+	if backend == "bolt" {
+		store, err := NewEncryptedBoltStore(cacheSize, path, key)
+		if err != nil {
+			return nil, err
+		}
+		return store.DBStore, nil
+	}
+
+	store, err := NewEncryptedBadgerStore(cacheSize, path, key)
+	if err != nil {
+		return nil, err
+	}
+	return store.DBStore, nil
+}
+
+func rekeyStore(backend, path string, oldKey, newKey *encryption.Key) (int, error) {
+	if backend == "bolt" {
+		return RekeyBoltStore(path, oldKey, newKey)
+	}
+	return RekeyBadgerStore(path, oldKey, newKey)
+}
+
+func TestEncryptedDBStore(t *testing.T) {
+	forEachBackend(t, testEncryptedDBStore)
+}
+
+func testEncryptedDBStore(t *testing.T, backend string) {
+	store := initDBStore(backend, cacheSize, t)
+	path := store.path
+	removeDBStore(store, t)
+
+	key := newTestKey(t)
+
+	store, err := openEncryptedStore(backend, path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		removeDBStore(store, t)
+	}()
+
+	peerSet, _ := initPeers(3)
+	if err := store.SetPeerSet(0, peerSet); err != nil {
+		t.Fatal(err)
+	}
+
+	tx := []byte("confidential transaction")
+	block := NewBlock(0, 1, []byte("framehash"), peerSet.Peers, [][]byte{tx}, []InternalTransaction{})
+	if err := store.SetBlock(block); err != nil {
+		t.Fatal(err)
+	}
+
+	//the values are not readable in the underlying engine
+	raw := store.db.(*encryptedKV).kvDB
+	err = raw.View(func(txn kvTxn) error {
+		return txn.Iterate([]byte{}, func(key, value []byte) error {
+			if bytes.Contains(value, tx) {
+				t.Fatalf("Value of %s is not encrypted", key)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store.Close()
+
+	//the database cannot be opened without its key
+	if _, err := openEncryptedStore(backend, path, nil); err == nil {
+		t.Fatalf("Opening an encrypted database without key should fail")
+	}
+	if _, err := openEncryptedStore(backend, path, newTestKey(t)); err == nil {
+		t.Fatalf("Opening an encrypted database with another key should fail")
+	}
+
+	//rotate the key
+	newKey := newTestKey(t)
+	if _, err := rekeyStore(backend, path, newTestKey(t), newKey); err == nil {
+		t.Fatalf("Rekeying with the wrong current key should fail")
+	}
+	count, err := rekeyStore(backend, path, key, newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count == 0 {
+		t.Fatalf("Rekeying should have re-encrypted the values")
+	}
+
+	//running the same rekey again, to resume the snapshots, is a no-op
+	if count, err := rekeyStore(backend, path, key, newKey); err != nil || count != 0 {
+		t.Fatalf("Repeating the rekey should not re-encrypt any value, got %d, %v", count, err)
+	}
+
+	if _, err := openEncryptedStore(backend, path, key); err == nil {
+		t.Fatalf("Opening a rekeyed database with the old key should fail")
+	}
+
+	store, err = openEncryptedStore(backend, path, newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkBlock := func() {
+		storedBlock, err := store.dbGetBlock(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(block.Body, storedBlock.Body) {
+			t.Fatalf("Block should be %#v, not %#v", block.Body, storedBlock.Body)
+		}
+	}
+
+	checkBlock()
+	store.Close()
+
+	//decrypt the database
+	if _, err := rekeyStore(backend, path, newKey, nil); err != nil {
+		t.Fatal(err)
+	}
+	if count, err := rekeyStore(backend, path, newKey, nil); err != nil || count != 0 {
+		t.Fatalf("Repeating the decryption should not change any value, got %d, %v", count, err)
+	}
+
+	store, err = openEncryptedStore(backend, path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkBlock()
+
+	//an unencrypted database is not silently opened with a key
+	store.Close()
+	if _, err := openEncryptedStore(backend, path, newKey); err == nil {
+		t.Fatalf("Opening an unencrypted database with a key should fail")
+	}
+
+	store, err = openEncryptedStore(backend, path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
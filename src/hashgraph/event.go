@@ -5,6 +5,7 @@ import (
 	"crypto/ecdsa"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/abassian/huron/src/common"
 	"github.com/abassian/huron/src/crypto"
@@ -98,6 +99,11 @@ type Event struct {
 	creator string
 	hash    []byte
 	hex     string
+
+	//local time of insertion in the hashgraph, used to measure the time to
+	//consensus since local insertion. It is not stored, so it is zero for
+	//Events read back from a Store.
+	insertedAt time.Time
 }
 
 // NewEvent ...
@@ -0,0 +1,185 @@
+package hashgraph
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/abassian/huron/src/common"
+	"github.com/sirupsen/logrus"
+)
+
+//bootstrapBase is what FastBootstrap loads from the DB: a Block, its Frame, the
+//FrameEvents to insert along with the Frame, and the Events which follow them,
+//in topological order.
+type bootstrapBase struct {
+	block       *Block
+	frame       *Frame
+	frameEvents SortedFrameEvents
+	events      []*Event
+	pruneBase   *PruneBase
+	nextIndex   int
+}
+
+/*
+FastBootstrap resets the Hashgraph from a Block and its Frame, read from the DB,
+and only inserts the Events which come after them, in topological order, instead
+of replaying the whole history like Bootstrap. The Blocks which follow the base
+are created and committed again, so the application state is assumed to have
+been restored to the base Block. The base must not precede the PruneBase, if
+the DB was pruned, and every subsequent Event must have its parents in the
+Frame or among the other subsequent Events, which FastBootstrapFrame verifies.
+*/
+func (h *Hashgraph) FastBootstrap(blockIndex int) error {
+	//Reuse the base loaded by FastBootstrapFrame, if any
+	base := h.bootstrapBase
+	h.bootstrapBase = nil
+
+	if base == nil || base.block.Index() != blockIndex {
+		var err error
+		if base, err = h.loadBootstrapBase(blockIndex); err != nil {
+			return err
+		}
+	}
+
+	if err := h.reset(base.block, base.frame, base.frameEvents); err != nil {
+		return err
+	}
+
+	if base.pruneBase != nil {
+		h.setPruneBase(base.pruneBase)
+	}
+
+	//Events inserted from now on must not overwrite the topological index of
+	//the Events in the DB
+	h.topologicalIndex = base.nextIndex
+
+	for _, e := range base.events {
+		if err := h.InsertEventAndRunConsensus(e, true); err != nil {
+			return err
+		}
+	}
+
+	h.logger.WithFields(logrus.Fields{
+		"block":  blockIndex,
+		"round":  base.block.RoundReceived(),
+		"events": len(base.events),
+	}).Debug("Fast Bootstrap")
+
+	return h.ProcessSigPool()
+}
+
+//FastBootstrapFrame returns the Frame from which FastBootstrap would reset the
+//Hashgraph, or an error if the Hashgraph cannot be bootstrapped from the Block.
+//It does not modify the consensus state of the Hashgraph, but keeps the loaded
+//base for a subsequent FastBootstrap from the same Block.
+func (h *Hashgraph) FastBootstrapFrame(blockIndex int) (*Frame, error) {
+	base, err := h.loadBootstrapBase(blockIndex)
+	if err != nil {
+		return nil, err
+	}
+	h.bootstrapBase = base
+	return base.frame, nil
+}
+
+func (h *Hashgraph) loadBootstrapBase(blockIndex int) (*bootstrapBase, error) {
+	s, ok := getDBStore(h.Store)
+	if !ok {
+		return nil, fmt.Errorf("FastBootstrap requires a persistent Store")
+	}
+
+	pruneBase, err := s.GetPruneBase()
+	if err != nil {
+		if !common.Is(err, common.KeyNotFound) {
+			return nil, err
+		}
+		pruneBase = nil
+	}
+
+	if pruneBase != nil && blockIndex < pruneBase.Block {
+		return nil, fmt.Errorf("Block %d precedes the PruneBase Block %d", blockIndex, pruneBase.Block)
+	}
+
+	block, err := s.dbGetBlock(blockIndex)
+	if err != nil {
+		return nil, fmt.Errorf("No Block %d: %v", blockIndex, err)
+	}
+
+	frame, err := s.dbGetFrame(block.RoundReceived())
+	if err != nil {
+		return nil, fmt.Errorf("No Frame %d: %v", block.RoundReceived(), err)
+	}
+
+	frameEvents := SortedFrameEvents(frame.SortedFrameEvents())
+
+	//known is the index of the last Event of each participant in the Frame
+	known := make(map[string]int)
+	available := make(map[string]bool)
+	for _, fe := range frameEvents {
+		available[fe.Core.Hex()] = true
+		if last, ok := known[fe.Core.Creator()]; !ok || fe.Core.Index() > last {
+			known[fe.Core.Creator()] = fe.Core.Index()
+		}
+	}
+
+	//The FrameEvents of the PruneBase which precede the Frame are inserted
+	//along with it, so that the Events which follow the Frame find their
+	//parents
+	if pruneBase != nil {
+		for x, fe := range pruneBase.frameEvents() {
+			last, ok := known[fe.Core.Creator()]
+			if available[x] || !ok || fe.Core.Index() > last {
+				continue
+			}
+			frameEvents = append(frameEvents, fe)
+			available[x] = true
+		}
+		sort.Sort(frameEvents)
+	}
+
+	//The Events which are not covered by the Frame descend from the last
+	//Event of their creator in the Frame, so they follow it in topological
+	//order. The DB is read backwards until the last Event of every creator in
+	//the Frame is reached.
+	pending := make(map[string]bool, len(known))
+	for creator := range known {
+		pending[creator] = true
+	}
+	topologicalEvents, err := s.dbLastTopologicalEvents(func(e *Event) bool {
+		if last, ok := known[e.Creator()]; ok && e.Index() <= last {
+			delete(pending, e.Creator())
+		}
+		return len(known) > 0 && len(pending) == 0
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	base := &bootstrapBase{
+		block:       block,
+		frame:       frame,
+		frameEvents: frameEvents,
+		pruneBase:   pruneBase,
+	}
+
+	if len(topologicalEvents) > 0 {
+		base.nextIndex = topologicalEvents[len(topologicalEvents)-1].topologicalIndex + 1
+	}
+
+	//Skip the Events which are covered by the Frame
+	for _, e := range topologicalEvents {
+		if last, ok := known[e.Creator()]; ok && e.Index() <= last {
+			continue
+		}
+
+		for _, p := range []string{e.SelfParent(), e.OtherParent()} {
+			if p != "" && !available[p] {
+				return nil, fmt.Errorf("Parent %s of Event %s precedes the Frame", p, e.Hex())
+			}
+		}
+
+		available[e.Hex()] = true
+		base.events = append(base.events, e)
+	}
+
+	return base, nil
+}
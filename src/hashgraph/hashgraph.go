@@ -6,6 +6,7 @@ import (
 	"reflect"
 	"sort"
 	"strconv"
+	"time"
 
 	"github.com/abassian/huron/src/common"
 	"github.com/abassian/huron/src/peers"
@@ -41,6 +42,9 @@ type Hashgraph struct {
 	PendingLoadedEvents     int                    //number of loaded events that are not yet committed
 	commitCallback          InternalCommitCallback //commit block callback
 	topologicalIndex        int                    //counter used to order events in topological order (only local)
+	pruneBase               *PruneBase             //base recorded by the last pruning, if any
+	prunedFrameEvents       map[string]*FrameEvent //[hash] => FrameEvents recorded by the PruneBase
+	bootstrapBase           *bootstrapBase         //base loaded by FastBootstrapFrame for FastBootstrap
 
 	ancestorCache     *common.LRU
 	selfAncestorCache *common.LRU
@@ -61,18 +65,18 @@ func NewHashgraph(store Store, commitCallback InternalCommitCallback, logger *lo
 		logger = logrus.NewEntry(log)
 	}
 
-	cacheSize := store.CacheSize()
+	caches := store.Caches()
 	hashgraph := Hashgraph{
 		Store:             store,
 		PendingRounds:     NewPendingRoundsCache(),
 		PendingSignatures: NewSigPool(),
 		commitCallback:    commitCallback,
-		ancestorCache:     common.NewLRU(cacheSize, nil),
-		selfAncestorCache: common.NewLRU(cacheSize, nil),
-		stronglySeeCache:  common.NewLRU(cacheSize, nil),
-		roundCache:        common.NewLRU(cacheSize, nil),
-		timestampCache:    common.NewLRU(cacheSize, nil),
-		witnessCache:      common.NewLRU(cacheSize, nil),
+		ancestorCache:     caches.lru(AncestorCache, nil),
+		selfAncestorCache: caches.lru(SelfAncestorCache, nil),
+		stronglySeeCache:  caches.lru(StronglySeeCache, nil),
+		roundCache:        caches.lru(RoundCache, nil),
+		timestampCache:    caches.lru(TimestampCache, nil),
+		witnessCache:      caches.lru(WitnessCache, nil),
 		logger:            logger,
 	}
 
@@ -209,6 +213,9 @@ func (h *Hashgraph) round(x string) (int, error) {
 	if c, ok := h.roundCache.Get(x); ok {
 		return c.(int), nil
 	}
+	if fe, ok := h.prunedFrameEvent(x); ok {
+		return fe.Round, nil
+	}
 	r, err := h._round(x)
 	if err != nil {
 		return -1, err
@@ -281,6 +288,9 @@ func (h *Hashgraph) witness(x string) (bool, error) {
 	if c, ok := h.witnessCache.Get(x); ok {
 		return c.(bool), nil
 	}
+	if fe, ok := h.prunedFrameEvent(x); ok {
+		return fe.Witness, nil
+	}
 	r, err := h._witness(x)
 	if err != nil {
 		return false, err
@@ -340,6 +350,9 @@ func (h *Hashgraph) lamportTimestamp(x string) (int, error) {
 	if c, ok := h.timestampCache.Get(x); ok {
 		return c.(int), nil
 	}
+	if fe, ok := h.prunedFrameEvent(x); ok {
+		return fe.LamportTimestamp, nil
+	}
 	r, err := h._lamportTimestamp(x)
 	if err != nil {
 		return -1, err
@@ -506,6 +519,10 @@ func (h *Hashgraph) updateAncestorFirstDescendant(event *Event) error {
 }
 
 func (h *Hashgraph) createFrameEvent(x string) (*FrameEvent, error) {
+	if fe, ok := h.prunedFrameEvent(x); ok {
+		return fe, nil
+	}
+
 	ev, err := h.Store.GetEvent(x)
 	if err != nil {
 		return nil, fmt.Errorf("FrameEvent %s not found", x)
@@ -705,6 +722,8 @@ func (h *Hashgraph) InsertEvent(event *Event, setWireInfo bool) error {
 		return fmt.Errorf("InitEventCoordinates: %s", err)
 	}
 
+	event.insertedAt = time.Now()
+
 	if err := h.Store.SetEvent(event); err != nil {
 		return fmt.Errorf("SetEvent: %s", err)
 	}
@@ -719,6 +738,8 @@ func (h *Hashgraph) InsertEvent(event *Event, setWireInfo bool) error {
 		h.PendingLoadedEvents++
 	}
 
+	eventsInserted.Inc()
+
 	for _, bs := range event.BlockSignatures() {
 		h.logger.Debugf("Inserting pending signature %v", bs.Key())
 		h.PendingSignatures.Add(bs)
@@ -1042,6 +1063,10 @@ func (h *Hashgraph) DecideRoundReceived() error {
 
 				ex.SetRoundReceived(i)
 
+				if !ex.insertedAt.IsZero() {
+					consensusLatency.Observe(time.Since(ex.insertedAt).Seconds())
+				}
+
 				err = h.Store.SetEvent(ex)
 				if err != nil {
 					return err
@@ -1116,6 +1141,7 @@ func (h *Hashgraph) ProcessDecidedRounds() error {
 				}
 
 				h.ConsensusTransactions += len(e.Core.Transactions())
+				consensusEvents.Inc()
 
 				if e.Core.IsLoaded() {
 					h.PendingLoadedEvents--
@@ -1134,6 +1160,7 @@ func (h *Hashgraph) ProcessDecidedRounds() error {
 				if err := h.Store.SetBlock(block); err != nil {
 					return err
 				}
+				blocksCreated.Inc()
 
 				err := h.commitCallback(block)
 				if err != nil {
@@ -1145,6 +1172,7 @@ func (h *Hashgraph) ProcessDecidedRounds() error {
 		}
 
 		processedRounds = append(processedRounds, r.Index)
+		roundsDecided.Inc()
 
 		if h.LastConsensusRound == nil || r.Index > *h.LastConsensusRound {
 			h.setLastConsensusRound(r.Index)
@@ -1392,8 +1420,40 @@ func (h *Hashgraph) GetAnchorBlockWithFrame() (*Block, *Frame, error) {
 	return block, frame, nil
 }
 
+//GetBlockWithFrame returns the Block with the given index and the corresponding
+//Frame, but only if the Block has collected enough signatures (+1/3) to be used
+//as a base to Reset a Hashgraph.
+func (h *Hashgraph) GetBlockWithFrame(index int) (*Block, *Frame, error) {
+	block, err := h.Store.GetBlock(index)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	peerSet, err := h.Store.GetPeerSet(block.RoundReceived())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(block.Signatures) <= peerSet.TrustCount() {
+		return nil, nil, fmt.Errorf("Not enough signatures on Block %d: got %d, need %d",
+			index, len(block.Signatures), peerSet.TrustCount()+1)
+	}
+
+	frame, err := h.GetFrame(block.RoundReceived())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return block, frame, nil
+}
+
 //Reset clears the Hashgraph and resets it from a new base.
 func (h *Hashgraph) Reset(block *Block, frame *Frame) error {
+	return h.reset(block, frame, frame.SortedFrameEvents())
+}
+
+//reset is Reset with the FrameEvents to insert, in order, along with the Frame
+func (h *Hashgraph) reset(block *Block, frame *Frame, frameEvents SortedFrameEvents) error {
 	//Clear all state
 	h.LastConsensusRound = nil
 	h.FirstConsensusRound = nil
@@ -1404,12 +1464,12 @@ func (h *Hashgraph) Reset(block *Block, frame *Frame) error {
 	h.PendingLoadedEvents = 0
 	h.topologicalIndex = 0
 
-	cacheSize := h.Store.CacheSize()
-	h.ancestorCache = common.NewLRU(cacheSize, nil)
-	h.selfAncestorCache = common.NewLRU(cacheSize, nil)
-	h.stronglySeeCache = common.NewLRU(cacheSize, nil)
-	h.roundCache = common.NewLRU(cacheSize, nil)
-	h.witnessCache = common.NewLRU(cacheSize, nil)
+	caches := h.Store.Caches()
+	h.ancestorCache = caches.lru(AncestorCache, nil)
+	h.selfAncestorCache = caches.lru(SelfAncestorCache, nil)
+	h.stronglySeeCache = caches.lru(StronglySeeCache, nil)
+	h.roundCache = caches.lru(RoundCache, nil)
+	h.witnessCache = caches.lru(WitnessCache, nil)
 
 	//Initialize new Roots
 	if err := h.Store.Reset(frame); err != nil {
@@ -1417,8 +1477,7 @@ func (h *Hashgraph) Reset(block *Block, frame *Frame) error {
 	}
 
 	//Insert FrameEvents
-	sortedFrameEvents := frame.SortedFrameEvents()
-	for _, rev := range sortedFrameEvents {
+	for _, rev := range frameEvents {
 		if err := h.InsertFrameEvent(rev); err != nil {
 			return err
 		}
@@ -1438,25 +1497,36 @@ func (h *Hashgraph) Reset(block *Block, frame *Frame) error {
 Bootstrap loads all Events from the Store's DB (if there is one) and feeds
 them to the Hashgraph consensus methods in topological order. It is assumed that
 no events are skipped/lost when loading from the database - WE CAN ONLY
-BOOTSTRAP FROM 0. As Events are inserted and processed, Blocks will be created
-and committed to the App layer (via the commit callback), so it is also assumed
-that the application state was reset.
+BOOTSTRAP FROM 0, unless the DB was pruned, in which case the Hashgraph is fast
+bootstrapped from the PruneBase Block. As Events are inserted and processed,
+Blocks will be created and committed to the App layer (via the commit
+callback), so it is also assumed that the application state was
+reset, or restored from the PruneBase snapshot.
 */
 func (h *Hashgraph) Bootstrap() error {
-	if badgerStore, ok := h.Store.(*BadgerStore); ok {
+	if dbStore, ok := getDBStore(h.Store); ok {
+		base, err := dbStore.GetPruneBase()
+		if err != nil && !common.Is(err, common.KeyNotFound) {
+			return err
+		}
+
+		if base != nil {
+			return h.FastBootstrap(base.Block)
+		}
+
 		//Load Genesis PeerSet
-		peerSet, err := badgerStore.dbGetPeerSet(0)
+		peerSet, err := dbStore.dbGetPeerSet(0)
 		if err != nil {
 			return fmt.Errorf("No Genesis PeerSet: %v", err)
 		}
 
 		//Initialize the InmemStore with Genesis PeerSet. This has side-effects:
 		//It will create the corresponding Roots and populate the Repertoires.
-		badgerStore.inmemStore.SetPeerSet(0, peerSet)
+		dbStore.inmemStore.SetPeerSet(0, peerSet)
 
 		//Retreive the Events from the underlying DB. They come out in topological
 		//order
-		topologicalEvents, err := badgerStore.dbTopologicalEvents()
+		topologicalEvents, err := dbStore.dbTopologicalEvents()
 		if err != nil {
 			return err
 		}
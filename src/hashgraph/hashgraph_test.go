@@ -19,8 +19,38 @@ var (
 	cacheSize = 100
 	n         = 3
 	badgerDir = "test_data/badger"
+	boltFile  = "test_data/bolt.db"
 )
 
+//dbBackend is the backend of the Hashgraphs created with a DB
+var dbBackend = "badger"
+
+func openTestDBStore(t testing.TB) Store {
+	//test_data only exists if another test created it
+	if err := os.MkdirAll("test_data", 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	if dbBackend == "bolt" {
+		store, err := NewBoltStore(cacheSize, boltFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	}
+
+	store, err := NewBadgerStore(cacheSize, badgerDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func removeTestDBStore() {
+	os.RemoveAll(badgerDir)
+	os.RemoveAll(boltFile)
+}
+
 type TestNode struct {
 	PubID    uint32
 	PubBytes []byte
@@ -114,11 +144,7 @@ func playEvents(plays []play, nodes []TestNode, index map[string]string, ordered
 func createHashgraph(db bool, orderedEvents *[]*Event, peerSet *peers.PeerSet, t testing.TB) *Hashgraph {
 	var store Store
 	if db {
-		var err error
-		store, err = NewBadgerStore(cacheSize, badgerDir)
-		if err != nil {
-			t.Fatal(err)
-		}
+		store = openTestDBStore(t)
 	} else {
 		store = NewInmemStore(cacheSize)
 	}
@@ -1908,6 +1934,12 @@ func TestResetFromFrame(t *testing.T) {
 }
 
 func TestBootstrap(t *testing.T) {
+	forEachBackend(t, testBootstrap)
+}
+
+func testBootstrap(t *testing.T, backend string) {
+	dbBackend = backend
+	defer func() { dbBackend = "badger" }()
 
 	//Initialize a first Hashgraph with a DB backend
 	//Add events and run consensus methods on it
@@ -1918,11 +1950,11 @@ func TestBootstrap(t *testing.T) {
 	h.ProcessDecidedRounds()
 
 	h.Store.Close()
-	defer os.RemoveAll(badgerDir)
+	defer removeTestDBStore()
 
 	//Now we want to create a new Hashgraph based on the database of the previous
 	//Hashgraph and see if we can boostrap it to the same state.
-	recycledStore, _ := NewBadgerStore(cacheSize, badgerDir)
+	recycledStore := openTestDBStore(t)
 
 	nh := NewHashgraph(recycledStore, DummyInternalCommitCallback, logrus.New().WithField("id", "bootstrapped"))
 
@@ -1966,6 +1998,83 @@ func TestBootstrap(t *testing.T) {
 	}
 }
 
+func TestFastBootstrap(t *testing.T) {
+	forEachBackend(t, testFastBootstrap)
+}
+
+func testFastBootstrap(t *testing.T, backend string) {
+	dbBackend = backend
+	defer func() { dbBackend = "badger" }()
+
+	h, _ := initConsensusHashgraph(true, t)
+	h.DivideRounds()
+	h.DecideFame()
+	h.DecideRoundReceived()
+	h.ProcessDecidedRounds()
+
+	h.Store.Close()
+	defer removeTestDBStore()
+
+	recycledStore := openTestDBStore(t)
+
+	nh := NewHashgraph(recycledStore, DummyInternalCommitCallback, logrus.New().WithField("id", "bootstrapped"))
+
+	if _, err := nh.FastBootstrapFrame(h.Store.LastBlockIndex() + 1); err == nil {
+		t.Fatalf("FastBootstrapFrame should fail for an unknown Block")
+	}
+
+	if _, err := nh.FastBootstrapFrame(1); err != nil {
+		t.Fatal(err)
+	}
+
+	//The base is loaded once, and only the end of the topological order is
+	//read
+	base := nh.bootstrapBase
+	if base == nil || base.block.Index() != 1 {
+		t.Fatalf("FastBootstrapFrame should keep the base of Block 1")
+	}
+
+	dbStore, _ := getDBStore(recycledStore)
+	allEvents, err := dbStore.dbTopologicalEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base.nextIndex != allEvents[len(allEvents)-1].topologicalIndex+1 {
+		t.Fatalf("Next topological index should be %d, not %d",
+			allEvents[len(allEvents)-1].topologicalIndex+1, base.nextIndex)
+	}
+
+	if err := nh.FastBootstrap(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if nh.bootstrapBase != nil {
+		t.Fatalf("FastBootstrap should release the base")
+	}
+
+	hKnown := h.Store.KnownEvents()
+	nhKnown := nh.Store.KnownEvents()
+	if !reflect.DeepEqual(hKnown, nhKnown) {
+		t.Fatalf("Bootstrapped hashgraph's Known should be %#v, not %#v",
+			hKnown, nhKnown)
+	}
+
+	if *h.LastConsensusRound != *nh.LastConsensusRound {
+		t.Fatalf("Bootstrapped hashgraph's LastConsensusRound should be %#v, not %#v",
+			*h.LastConsensusRound, *nh.LastConsensusRound)
+	}
+
+	if h.Store.LastBlockIndex() != nh.Store.LastBlockIndex() {
+		t.Fatalf("Bootstrapped hashgraph's LastBlockIndex should be %d, not %d",
+			h.Store.LastBlockIndex(), nh.Store.LastBlockIndex())
+	}
+
+	if len(h.UndeterminedEvents) != len(nh.UndeterminedEvents) {
+		t.Fatalf("Bootstrapped hashgraph should have %d undetermined events, not %d",
+			len(h.UndeterminedEvents), len(nh.UndeterminedEvents))
+	}
+}
+
 /*
 
 	The fame of witness w00 is never decided.
@@ -0,0 +1,684 @@
+package hashgraph
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"strings"
+
+	cm "github.com/abassian/huron/src/common"
+	"github.com/abassian/huron/src/crypto/encryption"
+)
+
+/*******************************************************************************
+HybridStore
+
+The HybridStore is an InmemStore which keeps the hot window of the hashgraph in
+its caches, and spills the Events and RoundInfos evicted from the caches to an
+append-only segment file, instead of forgetting them. The index of the segment
+file, and the hashes of the Events of each participant, remain in memory; they
+are much smaller than the Events. So peers which lag by more than CacheSize
+Events can still be served a sync from local data, instead of getting TooLate
+errors and needing a fast-sync.
+
+The index is bounded: it forgets its oldest records beyond spillFactor times
+the CacheSize, along with the start of the participant histories, so peers
+which lag by more than that still need a fast-sync. The records which are no
+longer indexed, because they were forgotten, pruned, or replaced by a newer
+record of the same item, are reclaimed by compacting the segment file once they
+make up most of it, and after a Prune.
+
+Like the InmemStore, it is not persistent: the segment file is truncated when
+the store is created or reset, and removed when it is closed.
+*******************************************************************************/
+
+//spillFactor is the size of the index of the segment file, in records,
+//relative to the CacheSize. The index is never smaller than minSpillLimit.
+const (
+	spillFactor   = 10
+	minSpillLimit = 10000
+)
+
+//HybridStore is an InmemStore which spills old Events and RoundInfos to disk
+type HybridStore struct {
+	*InmemStore
+
+	segment *segmentFile
+	events  map[string]spilledRecord //hash => record of spilled Event
+	rounds  map[int]spilledRecord    //round => record of spilled RoundInfo
+	history map[string]*participantHistory
+
+	//spilled lists the records of the index in the order they were written,
+	//so that the oldest are forgotten first when the index holds more than
+	//spillLimit records. It may still list records which were forgotten or
+	//replaced since.
+	spilled    []spilledKey
+	spillLimit int
+	//garbage is the size of the records of the segment file which are no
+	//longer indexed
+	garbage int64
+
+	//spillErr is the first error encountered while spilling, which is returned
+	//by the next write
+	spillErr error
+	//pruning disables the spilling of the items removed by Prune, and forgets
+	//their spilled copies
+	pruning bool
+}
+
+//participantHistory records the hashes of all the Events of a participant,
+//from index first, while the ParticipantEventsCache only keeps the last ones
+type participantHistory struct {
+	first  int
+	hashes []string
+}
+
+//spilledRecord locates the record of an item in the segment file
+type spilledRecord struct {
+	offset int64
+	size   int64
+	sum    uint64 //hash of the unsealed record, to skip rewriting it unchanged
+}
+
+//spilledKey identifies a record of the index: the record of an Event, if event
+//is not empty, or of the RoundInfo of round otherwise
+type spilledKey struct {
+	event  string
+	round  int
+	offset int64
+}
+
+//spilledEvent is the record of an Event in the segment file. Unlike
+//Event.Marshal, it includes the fields computed by the Hashgraph.
+type spilledEvent struct {
+	Event                *Event
+	TopologicalIndex     int
+	Round                *int
+	LamportTimestamp     *int
+	RoundReceived        *int
+	LastAncestors        map[string]spilledCoordinates
+	FirstDescendants     map[string]spilledCoordinates
+	CreatorID            uint32
+	OtherParentCreatorID uint32
+	SelfParentIndex      int
+	OtherParentIndex     int
+}
+
+type spilledCoordinates struct {
+	Hash  string
+	Index int
+}
+
+//spilledRound is the record of a RoundInfo in the segment file
+type spilledRound struct {
+	Round   *RoundInfo
+	Queued  bool
+	Decided bool
+}
+
+//NewHybridStore creates a HybridStore whose segment file is in path
+func NewHybridStore(cacheSize int, path string) (*HybridStore, error) {
+	return NewEncryptedHybridStore(cacheSize, path, nil)
+}
+
+//NewEncryptedHybridStore creates a HybridStore whose segment file is encrypted
+//with key. A nil key does not encrypt it.
+func NewEncryptedHybridStore(cacheSize int, path string, key *encryption.Key) (*HybridStore, error) {
+	segment, err := createSegmentFile(path, key)
+	if err != nil {
+		return nil, err
+	}
+
+	spillLimit := spillFactor * cacheSize
+	if spillLimit < minSpillLimit {
+		spillLimit = minSpillLimit
+	}
+
+	store := &HybridStore{
+		segment:    segment,
+		events:     make(map[string]spilledRecord),
+		rounds:     make(map[int]spilledRecord),
+		history:    make(map[string]*participantHistory),
+		spillLimit: spillLimit,
+	}
+
+	store.InmemStore = newInmemStore(cacheSize, store.spillEvent, store.spillRound)
+
+	return store, nil
+}
+
+//SpilledEvents returns the number of Events which can be read from the segment
+//file
+func (s *HybridStore) SpilledEvents() int {
+	return len(s.events)
+}
+
+// GetEvent ...
+func (s *HybridStore) GetEvent(key string) (*Event, error) {
+	event, err := s.InmemStore.GetEvent(key)
+	if err == nil {
+		return event, nil
+	}
+
+	record, ok := s.events[key]
+	if !ok {
+		return nil, err
+	}
+
+	return s.readEvent(record.offset)
+}
+
+//SetEvent adds the Event to the cache. An Event which was spilled is put back
+//in the cache, where it is updated, without being added to the history again.
+func (s *HybridStore) SetEvent(event *Event) error {
+	if s.spillErr != nil {
+		return s.spillErr
+	}
+
+	key := event.Hex()
+
+	_, inCache := s.eventCache.Peek(key)
+	_, spilled := s.events[key]
+
+	if !inCache && spilled {
+		s.eventCache.Add(key, event)
+		return s.spillErr
+	}
+
+	if err := s.InmemStore.SetEvent(event); err != nil {
+		return err
+	}
+
+	if !inCache {
+		s.addToHistory(event.Creator(), key, event.Index())
+	}
+
+	return s.spillErr
+}
+
+// ParticipantEvents ...
+func (s *HybridStore) ParticipantEvents(participant string, skip int) ([]string, error) {
+	res, err := s.InmemStore.ParticipantEvents(participant, skip)
+	if err == nil || !cm.Is(err, cm.TooLate) {
+		return res, err
+	}
+
+	h, ok := s.history[strings.ToUpper(participant)]
+	if !ok || skip+1 < h.first {
+		return res, err
+	}
+
+	start := skip + 1 - h.first
+	if start >= len(h.hashes) {
+		return []string{}, nil
+	}
+
+	return append([]string{}, h.hashes[start:]...), nil
+}
+
+// ParticipantEvent ...
+func (s *HybridStore) ParticipantEvent(participant string, index int) (string, error) {
+	res, err := s.InmemStore.ParticipantEvent(participant, index)
+	if err == nil || !cm.Is(err, cm.TooLate) {
+		return res, err
+	}
+
+	h, ok := s.history[strings.ToUpper(participant)]
+	if !ok || index < h.first || index-h.first >= len(h.hashes) {
+		return res, err
+	}
+
+	return h.hashes[index-h.first], nil
+}
+
+// GetRound ...
+func (s *HybridStore) GetRound(r int) (*RoundInfo, error) {
+	round, err := s.InmemStore.GetRound(r)
+	if err == nil {
+		return round, nil
+	}
+
+	record, ok := s.rounds[r]
+	if !ok {
+		return nil, err
+	}
+
+	return s.readRound(record.offset)
+}
+
+// SetRound ...
+func (s *HybridStore) SetRound(r int, round *RoundInfo) error {
+	if s.spillErr != nil {
+		return s.spillErr
+	}
+	if err := s.InmemStore.SetRound(r, round); err != nil {
+		return err
+	}
+	return s.spillErr
+}
+
+// RoundWitnesses ...
+func (s *HybridStore) RoundWitnesses(r int) []string {
+	round, err := s.GetRound(r)
+	if err != nil {
+		return []string{}
+	}
+	return round.Witnesses()
+}
+
+// RoundEvents ...
+func (s *HybridStore) RoundEvents(r int) int {
+	round, err := s.GetRound(r)
+	if err != nil {
+		return 0
+	}
+	return len(round.CreatedEvents)
+}
+
+//Reset resets the InmemStore and truncates the segment file
+func (s *HybridStore) Reset(frame *Frame) error {
+	if err := s.segment.truncate(); err != nil {
+		return err
+	}
+
+	s.events = make(map[string]spilledRecord)
+	s.rounds = make(map[int]spilledRecord)
+	s.history = make(map[string]*participantHistory)
+	s.spilled = nil
+	s.garbage = 0
+	s.spillErr = nil
+
+	return s.InmemStore.Reset(frame)
+}
+
+//Prune implements Pruner. The pruned Events and RoundInfos are removed from
+//the caches without being spilled, and the spilled ones are removed from the
+//index and the histories. The segment file is then compacted to reclaim their
+//space.
+func (s *HybridStore) Prune(base *PruneBase) (int, error) {
+	kept := base.frameEvents()
+
+	//the spilled Events of the pruned rounds, whether the rounds are cached or
+	//spilled
+	pruned := 0
+	forget := func(round *RoundInfo) {
+		for _, e := range round.ReceivedEvents {
+			if _, ok := kept[e]; ok {
+				continue
+			}
+			if _, ok := s.events[e]; ok && !s.eventCache.Contains(e) {
+				s.forgetEvent(e)
+				pruned++
+			}
+		}
+	}
+
+	for _, k := range s.roundCache.Keys() {
+		if r := k.(int); r < base.Round {
+			if round, ok := s.roundCache.Peek(r); ok {
+				forget(round.(*RoundInfo))
+			}
+		}
+	}
+
+	for r, record := range s.rounds {
+		if r >= base.Round {
+			continue
+		}
+
+		round, err := s.readRound(record.offset)
+		if err != nil {
+			return pruned, err
+		}
+		forget(round)
+
+		s.forgetRound(r)
+	}
+
+	s.pruning = true
+	n, err := s.InmemStore.Prune(base)
+	s.pruning = false
+	if err != nil {
+		return pruned + n, err
+	}
+
+	s.trimHistory()
+
+	if s.garbage > 0 {
+		if err := s.compact(); err != nil {
+			return pruned + n, err
+		}
+	}
+
+	return pruned + n, nil
+}
+
+//Close removes the segment file
+func (s *HybridStore) Close() error {
+	if err := s.InmemStore.Close(); err != nil {
+		return err
+	}
+	return s.segment.close()
+}
+
+// StorePath ...
+func (s *HybridStore) StorePath() string {
+	return s.segment.path
+}
+
+func (s *HybridStore) addToHistory(participant, hash string, index int) {
+	participant = strings.ToUpper(participant)
+
+	h, ok := s.history[participant]
+	if !ok {
+		h = &participantHistory{first: index}
+		s.history[participant] = h
+	}
+
+	//like the ParticipantEventsCache, assume there are no gaps between indexes
+	if index == h.first+len(h.hashes) {
+		h.hashes = append(h.hashes, hash)
+	}
+}
+
+//trimHistory removes the hashes of the Events which are neither cached nor
+//indexed from the start of the histories
+func (s *HybridStore) trimHistory() {
+	for participant, h := range s.history {
+		n := 0
+		for n < len(h.hashes) && !s.knows(h.hashes[n]) {
+			n++
+		}
+
+		if n == len(h.hashes) {
+			delete(s.history, participant)
+			continue
+		}
+
+		h.first += n
+		h.hashes = h.hashes[n:]
+	}
+}
+
+//knows returns true if the Event can be read from the cache or the segment
+//file
+func (s *HybridStore) knows(hash string) bool {
+	if _, ok := s.events[hash]; ok {
+		return true
+	}
+	return s.eventCache.Contains(hash)
+}
+
+/*******************************************************************************
+Spilling
+*******************************************************************************/
+
+//spillEvent is the eviction callback of the Event cache
+func (s *HybridStore) spillEvent(key, value interface{}) {
+	hash := key.(string)
+
+	if s.pruning {
+		s.forgetEvent(hash)
+		return
+	}
+	if s.spillErr != nil {
+		return
+	}
+
+	event := value.(*Event)
+
+	record := spilledEvent{
+		Event:                event,
+		TopologicalIndex:     event.topologicalIndex,
+		Round:                event.round,
+		LamportTimestamp:     event.lamportTimestamp,
+		RoundReceived:        event.roundReceived,
+		LastAncestors:        fromCoordinatesMap(event.lastAncestors),
+		FirstDescendants:     fromCoordinatesMap(event.firstDescendants),
+		CreatorID:            event.Body.creatorID,
+		OtherParentCreatorID: event.Body.otherParentCreatorID,
+		SelfParentIndex:      event.Body.selfParentIndex,
+		OtherParentIndex:     event.Body.otherParentIndex,
+	}
+
+	prev, indexed := s.events[hash]
+
+	spilled, err := s.writeRecord(record, prev, indexed)
+	if err != nil {
+		s.spillErr = err
+		return
+	}
+
+	s.events[hash] = spilled
+
+	if !indexed || spilled.offset != prev.offset {
+		s.spilled = append(s.spilled, spilledKey{event: hash, offset: spilled.offset})
+		s.forgetOldest()
+	}
+}
+
+//spillRound is the eviction callback of the RoundInfo cache
+func (s *HybridStore) spillRound(key, value interface{}) {
+	r := key.(int)
+
+	if s.pruning {
+		s.forgetRound(r)
+		return
+	}
+	if s.spillErr != nil {
+		return
+	}
+
+	round := value.(*RoundInfo)
+
+	record := spilledRound{
+		Round:   round,
+		Queued:  round.queued,
+		Decided: round.decided,
+	}
+
+	prev, indexed := s.rounds[r]
+
+	spilled, err := s.writeRecord(record, prev, indexed)
+	if err != nil {
+		s.spillErr = err
+		return
+	}
+
+	s.rounds[r] = spilled
+
+	if !indexed || spilled.offset != prev.offset {
+		s.spilled = append(s.spilled, spilledKey{round: r, offset: spilled.offset})
+		s.forgetOldest()
+	}
+}
+
+//writeRecord appends the record of an item to the segment file, unless prev,
+//the record of the item which is already indexed, if any, has the same
+//content. The record which is replaced becomes garbage.
+func (s *HybridStore) writeRecord(record interface{}, prev spilledRecord, indexed bool) (spilledRecord, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return spilledRecord{}, err
+	}
+
+	hash := fnv.New64a()
+	hash.Write(data)
+	sum := hash.Sum64()
+
+	if indexed && prev.sum == sum {
+		return prev, nil
+	}
+
+	offset, size, err := s.segment.append(data)
+	if err != nil {
+		return spilledRecord{}, err
+	}
+
+	if indexed {
+		s.garbage += prev.size
+	}
+
+	return spilledRecord{offset: offset, size: size, sum: sum}, nil
+}
+
+//forgetEvent removes a spilled Event from the index. Its record becomes
+//garbage.
+func (s *HybridStore) forgetEvent(hash string) {
+	if record, ok := s.events[hash]; ok {
+		s.garbage += record.size
+		delete(s.events, hash)
+	}
+}
+
+//forgetRound removes a spilled RoundInfo from the index. Its record becomes
+//garbage.
+func (s *HybridStore) forgetRound(r int) {
+	if record, ok := s.rounds[r]; ok {
+		s.garbage += record.size
+		delete(s.rounds, r)
+	}
+}
+
+//indexed returns true if the record is still the indexed record of its item
+func (s *HybridStore) indexed(k spilledKey) bool {
+	var record spilledRecord
+	var ok bool
+	if k.event != "" {
+		record, ok = s.events[k.event]
+	} else {
+		record, ok = s.rounds[k.round]
+	}
+	return ok && record.offset == k.offset
+}
+
+//forgetOldest forgets the oldest records while the index holds more than
+//spillLimit records, and compacts the segment file once most of it is garbage
+func (s *HybridStore) forgetOldest() {
+	forgot := false
+	for len(s.events)+len(s.rounds) > s.spillLimit && len(s.spilled) > 0 {
+		k := s.spilled[0]
+		s.spilled = s.spilled[1:]
+
+		if !s.indexed(k) {
+			continue
+		}
+
+		if k.event != "" {
+			s.forgetEvent(k.event)
+		} else {
+			s.forgetRound(k.round)
+		}
+		forgot = true
+	}
+
+	if forgot {
+		s.trimHistory()
+	}
+
+	if s.garbage*2 > s.segment.size {
+		if err := s.compact(); err != nil {
+			s.spillErr = err
+		}
+	}
+}
+
+//compact rewrites the segment file with the indexed records only
+func (s *HybridStore) compact() error {
+	spilled := []spilledKey{}
+	offsets := []int64{}
+	for _, k := range s.spilled {
+		if s.indexed(k) {
+			spilled = append(spilled, k)
+			offsets = append(offsets, k.offset)
+		}
+	}
+
+	moved, err := s.segment.compact(offsets)
+	if err != nil {
+		return err
+	}
+
+	for i, k := range spilled {
+		spilled[i].offset = moved[k.offset]
+		if k.event != "" {
+			record := s.events[k.event]
+			record.offset = moved[k.offset]
+			s.events[k.event] = record
+		} else {
+			record := s.rounds[k.round]
+			record.offset = moved[k.offset]
+			s.rounds[k.round] = record
+		}
+	}
+
+	s.spilled = spilled
+	s.garbage = 0
+
+	return nil
+}
+
+func (s *HybridStore) readEvent(offset int64) (*Event, error) {
+	data, err := s.segment.read(offset)
+	if err != nil {
+		return nil, err
+	}
+
+	var record spilledEvent
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+
+	event := record.Event
+	event.topologicalIndex = record.TopologicalIndex
+	event.round = record.Round
+	event.lamportTimestamp = record.LamportTimestamp
+	event.roundReceived = record.RoundReceived
+	event.lastAncestors = toCoordinatesMap(record.LastAncestors)
+	event.firstDescendants = toCoordinatesMap(record.FirstDescendants)
+	event.SetWireInfo(record.SelfParentIndex,
+		record.OtherParentCreatorID,
+		record.OtherParentIndex,
+		record.CreatorID)
+
+	return event, nil
+}
+
+func (s *HybridStore) readRound(offset int64) (*RoundInfo, error) {
+	data, err := s.segment.read(offset)
+	if err != nil {
+		return nil, err
+	}
+
+	var record spilledRound
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+
+	round := record.Round
+	round.queued = record.Queued
+	round.decided = record.Decided
+
+	return round, nil
+}
+
+func fromCoordinatesMap(c CoordinatesMap) map[string]spilledCoordinates {
+	if c == nil {
+		return nil
+	}
+	res := make(map[string]spilledCoordinates, len(c))
+	for k, v := range c {
+		res[k] = spilledCoordinates{Hash: v.hash, Index: v.index}
+	}
+	return res
+}
+
+func toCoordinatesMap(c map[string]spilledCoordinates) CoordinatesMap {
+	if c == nil {
+		return nil
+	}
+	res := make(CoordinatesMap, len(c))
+	for k, v := range c {
+		res[k] = EventCoordinates{hash: v.Hash, index: v.Index}
+	}
+	return res
+}
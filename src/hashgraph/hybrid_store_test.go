@@ -0,0 +1,484 @@
+package hashgraph
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	cm "github.com/abassian/huron/src/common"
+)
+
+func initHybridStore(cacheSize int, t *testing.T) (*HybridStore, string) {
+	dir, err := ioutil.TempDir("", "huron-hybrid")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewHybridStore(cacheSize, filepath.Join(dir, "spill.seg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return store, dir
+}
+
+func TestHybridEvents(t *testing.T) {
+	cacheSize := 5
+	testSize := 30
+
+	store, dir := initHybridStore(cacheSize, t)
+	defer os.RemoveAll(dir)
+	defer store.Close()
+
+	peerSet, participants := initPeers(3)
+	if err := store.SetPeerSet(0, peerSet); err != nil {
+		t.Fatal(err)
+	}
+
+	events := make(map[string][]*Event)
+	for _, p := range participants {
+		for k := 0; k < testSize; k++ {
+			event := NewEvent([][]byte{[]byte(fmt.Sprintf("%s_%d", p.hex[:5], k))},
+				nil,
+				[]BlockSignature{{Validator: []byte("validator"), Index: 0, Signature: "r|s"}},
+				[]string{"", ""},
+				p.pubKey,
+				k)
+			event.SetWireInfo(k-1, p.id, k-1, p.id)
+			event.SetRound(k)
+			event.lastAncestors = CoordinatesMap{p.hex: EventCoordinates{hash: "last", index: k}}
+			if err := store.SetEvent(event); err != nil {
+				t.Fatal(err)
+			}
+			events[p.hex] = append(events[p.hex], event)
+		}
+	}
+
+	if store.SpilledEvents() == 0 {
+		t.Fatalf("Events should have been spilled")
+	}
+
+	//the spilled Events are read back with the fields computed by the Hashgraph
+	for p, evs := range events {
+		for k, ev := range evs {
+			rev, err := store.GetEvent(ev.Hex())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(ev.Body, rev.Body) {
+				t.Fatalf("events[%s][%d] should be %#v, not %#v", p, k, ev.Body, rev.Body)
+			}
+			if !reflect.DeepEqual(ev.ToWire(), rev.ToWire()) {
+				t.Fatalf("events[%s][%d] wire should be %#v, not %#v", p, k, ev.ToWire(), rev.ToWire())
+			}
+			if *rev.GetRound() != k || !reflect.DeepEqual(ev.lastAncestors, rev.lastAncestors) {
+				t.Fatalf("events[%s][%d] should keep its round and last ancestors", p, k)
+			}
+		}
+	}
+
+	//peers far behind are served from the history, instead of TooLate
+	inmem := NewInmemStore(cacheSize)
+	inmem.SetPeerSet(0, peerSet)
+	for _, evs := range events {
+		for _, ev := range evs {
+			inmem.SetEvent(ev)
+		}
+	}
+
+	for _, p := range participants {
+		if _, err := inmem.ParticipantEvents(p.hex, -1); !cm.Is(err, cm.TooLate) {
+			t.Fatalf("InmemStore should return TooLate, not %v", err)
+		}
+
+		pEvents, err := store.ParticipantEvents(p.hex, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pEvents) != testSize {
+			t.Fatalf("%s should have %d Events, not %d", p.hex, testSize, len(pEvents))
+		}
+
+		for k, e := range pEvents {
+			if e != events[p.hex][k].Hex() {
+				t.Fatalf("ParticipantEvents[%s][%d] should be %s, not %s", p.hex, k, events[p.hex][k].Hex(), e)
+			}
+		}
+
+		skipped, err := store.ParticipantEvents(p.hex, 9)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(skipped) != testSize-10 || skipped[0] != events[p.hex][10].Hex() {
+			t.Fatalf("ParticipantEvents should skip the first 10 Events")
+		}
+
+		first, err := store.ParticipantEvent(p.hex, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first != events[p.hex][0].Hex() {
+			t.Fatalf("ParticipantEvent[%s][0] should be %s, not %s", p.hex, events[p.hex][0].Hex(), first)
+		}
+	}
+
+	//a spilled Event which is updated is put back in the cache
+	ev := events[participants[0].hex][0]
+	ev.SetRoundReceived(42)
+	if err := store.SetEvent(ev); err != nil {
+		t.Fatal(err)
+	}
+	rev, err := store.GetEvent(ev.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rev.roundReceived == nil || *rev.roundReceived != 42 {
+		t.Fatalf("Updated Event should have round received 42")
+	}
+	if last, _ := store.LastEventFrom(participants[0].hex); last != events[participants[0].hex][testSize-1].Hex() {
+		t.Fatalf("Updating a spilled Event should not change the last Event")
+	}
+}
+
+func TestHybridRounds(t *testing.T) {
+	store, dir := initHybridStore(3, t)
+	defer os.RemoveAll(dir)
+	defer store.Close()
+
+	rounds := []*RoundInfo{}
+	for r := 0; r < 10; r++ {
+		round := NewRoundInfo()
+		round.AddCreatedEvent(fmt.Sprintf("w%d", r), true)
+		round.AddReceivedEvent(fmt.Sprintf("e%d", r))
+		round.queued = true
+		if err := store.SetRound(r, round); err != nil {
+			t.Fatal(err)
+		}
+		rounds = append(rounds, round)
+	}
+
+	for r, round := range rounds {
+		stored, err := store.GetRound(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(round, stored) {
+			t.Fatalf("Round %d should be %#v, not %#v", r, round, stored)
+		}
+		if w := store.RoundWitnesses(r); len(w) != 1 || w[0] != fmt.Sprintf("w%d", r) {
+			t.Fatalf("Round %d witnesses should be [w%d], not %v", r, r, w)
+		}
+	}
+
+	if _, err := store.GetRound(10); !cm.Is(err, cm.KeyNotFound) {
+		t.Fatalf("Unknown round should not be found, not %v", err)
+	}
+}
+
+func TestHybridSegmentFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "huron-hybrid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "spill.seg")
+	store, err := NewEncryptedHybridStore(1, path, newTestKey(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peerSet, participants := initPeers(1)
+	store.SetPeerSet(0, peerSet)
+
+	tx := []byte("confidential transaction")
+	for k := 0; k < 3; k++ {
+		event := NewEvent([][]byte{tx}, nil, nil, []string{"", ""}, participants[0].pubKey, k)
+		if err := store.SetEvent(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) == 0 || bytes.Contains(data, tx) {
+		t.Fatalf("Segment file should contain encrypted Events")
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("Segment file should be removed on Close")
+	}
+}
+
+//newHybridEvent creates the k-th Event of participant p, with the fields which
+//are computed by the Hashgraph
+func newHybridEvent(p participant, k int) *Event {
+	event := NewEvent([][]byte{[]byte(fmt.Sprintf("%s_%d", p.hex[:5], k))},
+		nil,
+		nil,
+		[]string{"", ""},
+		p.pubKey,
+		k)
+	event.SetWireInfo(k-1, p.id, k-1, p.id)
+	event.SetRound(k)
+	return event
+}
+
+func TestHybridRespill(t *testing.T) {
+	store, dir := initHybridStore(1, t)
+	defer os.RemoveAll(dir)
+	defer store.Close()
+
+	peerSet, participants := initPeers(1)
+	store.SetPeerSet(0, peerSet)
+
+	events := []*Event{}
+	for k := 0; k < 3; k++ {
+		event := newHybridEvent(participants[0], k)
+		if err := store.SetEvent(event); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+	}
+
+	//putting Event 0 back in the cache spills Event 2
+	if err := store.SetEvent(events[0]); err != nil {
+		t.Fatal(err)
+	}
+	size := store.segment.size
+
+	//Event 0 is spilled again without being changed
+	if err := store.SetEvent(events[1]); err != nil {
+		t.Fatal(err)
+	}
+	if store.segment.size != size || store.garbage != 0 {
+		t.Fatalf("An unchanged Event should not be written again")
+	}
+
+	//Event 0 is spilled again after being changed
+	events[0].SetRoundReceived(7)
+	if err := store.SetEvent(events[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetEvent(events[2]); err != nil {
+		t.Fatal(err)
+	}
+	if store.segment.size == size || store.garbage == 0 {
+		t.Fatalf("A changed Event should be written again, and replace its previous record")
+	}
+
+	event, err := store.GetEvent(events[0].Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.roundReceived == nil || *event.roundReceived != 7 {
+		t.Fatalf("Spilled Event should have round received 7")
+	}
+	if store.SpilledEvents() != 3 || len(store.spilled) != 4 {
+		t.Fatalf("The index should have 3 Events, in 4 records, not %d in %d", store.SpilledEvents(), len(store.spilled))
+	}
+}
+
+func TestHybridSpillLimit(t *testing.T) {
+	store, dir := initHybridStore(2, t)
+	defer os.RemoveAll(dir)
+	defer store.Close()
+
+	store.spillLimit = 10
+
+	peerSet, participants := initPeers(1)
+	store.SetPeerSet(0, peerSet)
+	p := participants[0]
+
+	testSize := 100
+	events := []*Event{}
+	var recordSize int64
+	for k := 0; k < testSize; k++ {
+		event := newHybridEvent(p, k)
+		if err := store.SetEvent(event); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+
+		for _, record := range store.events {
+			if record.size > recordSize {
+				recordSize = record.size
+			}
+		}
+	}
+
+	if store.SpilledEvents() > store.spillLimit {
+		t.Fatalf("The index should have at most %d Events, not %d", store.spillLimit, store.SpilledEvents())
+	}
+
+	//the forgotten records are reclaimed by compaction
+	if store.segment.size > 2*int64(store.spillLimit+1)*recordSize {
+		t.Fatalf("Segment file should have been compacted, its size is %d", store.segment.size)
+	}
+
+	//the oldest Events are forgotten, along with the start of the history
+	if _, err := store.GetEvent(events[0].Hex()); err == nil {
+		t.Fatalf("The oldest Event should have been forgotten")
+	}
+	if _, err := store.ParticipantEvents(p.hex, -1); !cm.Is(err, cm.TooLate) {
+		t.Fatalf("ParticipantEvents from the start should return TooLate, not %v", err)
+	}
+
+	//the recent Events are still served
+	skip := testSize - store.spillLimit - 1
+	recent, err := store.ParticipantEvents(p.hex, skip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recent) != store.spillLimit {
+		t.Fatalf("ParticipantEvents should return %d Events, not %d", store.spillLimit, len(recent))
+	}
+	for i, hash := range recent {
+		event, err := store.GetEvent(hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hash != events[skip+1+i].Hex() || !reflect.DeepEqual(event.Body, events[skip+1+i].Body) {
+			t.Fatalf("Event %d should be read back after compaction", skip+1+i)
+		}
+	}
+}
+
+func TestHybridPrune(t *testing.T) {
+	store, dir := initHybridStore(2, t)
+	defer os.RemoveAll(dir)
+	defer store.Close()
+
+	peerSet, participants := initPeers(1)
+	store.SetPeerSet(0, peerSet)
+
+	//round r receives Events 2r and 2r+1
+	events := []*Event{}
+	for r := 0; r < 10; r++ {
+		round := NewRoundInfo()
+		for k := 2 * r; k < 2*r+2; k++ {
+			event := newHybridEvent(participants[0], k)
+			if err := store.SetEvent(event); err != nil {
+				t.Fatal(err)
+			}
+			events = append(events, event)
+			round.AddReceivedEvent(event.Hex())
+		}
+		if err := store.SetRound(r, round); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	size := store.segment.size
+
+	if _, err := store.Prune(&PruneBase{Round: 8}); err != nil {
+		t.Fatal(err)
+	}
+
+	if store.segment.size >= size || store.garbage != 0 {
+		t.Fatalf("Prune should compact the segment file, from %d to %d bytes", size, store.segment.size)
+	}
+
+	for k, event := range events {
+		_, err := store.GetEvent(event.Hex())
+		if k < 16 && err == nil {
+			t.Fatalf("Event %d should have been pruned", k)
+		}
+		if k >= 16 && err != nil {
+			t.Fatalf("Event %d should not have been pruned: %v", k, err)
+		}
+	}
+
+	if _, err := store.GetRound(7); err == nil {
+		t.Fatalf("Round 7 should have been pruned")
+	}
+	if _, err := store.GetRound(8); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.ParticipantEvent(participants[0].hex, 0); err == nil {
+		t.Fatalf("The history of the pruned Events should have been trimmed")
+	}
+}
+
+//A Hashgraph whose caches are much smaller than the hashgraph reaches the same
+//consensus from a HybridStore
+func TestHybridConsensus(t *testing.T) {
+	h, index := initConsensusHashgraph(false, t)
+	runConsensus(h, t)
+
+	peerSet, err := h.Store.GetPeerSet(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := []*Event{}
+	for _, hash := range index {
+		ev, err := h.Store.GetEvent(hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, ev)
+	}
+	sort.Sort(ByTopologicalOrder(events))
+
+	store, dir := initHybridStore(2, t)
+	defer os.RemoveAll(dir)
+	defer store.Close()
+
+	h2 := NewHashgraph(store, DummyInternalCommitCallback, testLogger(t))
+	if err := h2.Init(peerSet); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, ev := range events {
+		fresh := &Event{Body: ev.Body, Signature: ev.Signature}
+		if err := h2.InsertEvent(fresh, true); err != nil {
+			t.Fatalf("ERROR inserting event %d: %s", i, err)
+		}
+	}
+	runConsensus(h2, t)
+
+	if store.SpilledEvents() == 0 {
+		t.Fatalf("Events should have been spilled")
+	}
+
+	if h2.Store.LastBlockIndex() != h.Store.LastBlockIndex() {
+		t.Fatalf("Last block should be %d, not %d", h.Store.LastBlockIndex(), h2.Store.LastBlockIndex())
+	}
+
+	for i := 0; i <= h.Store.LastBlockIndex(); i++ {
+		b, _ := h.Store.GetBlock(i)
+		b2, err := h2.Store.GetBlock(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(b.Body, b2.Body) {
+			t.Fatalf("Block %d should be %#v, not %#v", i, b.Body, b2.Body)
+		}
+	}
+}
+
+func runConsensus(h *Hashgraph, t *testing.T) {
+	if err := h.DivideRounds(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.DecideFame(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.DecideRoundReceived(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.ProcessDecidedRounds(); err != nil {
+		t.Fatal(err)
+	}
+}
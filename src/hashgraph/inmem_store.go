@@ -10,6 +10,7 @@ import (
 // InmemStore ...
 type InmemStore struct {
 	cacheSize              int
+	caches                 *Caches
 	eventCache             *cm.LRU          //hash => Event
 	roundCache             *cm.LRU          //round number => Round
 	blockCache             *cm.LRU          //index => Block
@@ -22,16 +23,27 @@ type InmemStore struct {
 	lastRound              int
 	lastConsensusEvents    map[string]string //[participant] => hex() of last consensus event
 	lastBlock              int
+	pruneBase              *PruneBase
+
+	//called when Events and RoundInfos leave the caches
+	onEventEvicted cm.EvictCallback
+	onRoundEvicted cm.EvictCallback
 }
 
 // NewInmemStore ...
 func NewInmemStore(cacheSize int) *InmemStore {
+	return newInmemStore(cacheSize, nil, nil)
+}
+
+func newInmemStore(cacheSize int, onEventEvicted, onRoundEvicted cm.EvictCallback) *InmemStore {
+	caches := NewCaches(cacheSize)
 	store := &InmemStore{
 		cacheSize:              cacheSize,
-		eventCache:             cm.NewLRU(cacheSize, nil),
-		roundCache:             cm.NewLRU(cacheSize, nil),
-		blockCache:             cm.NewLRU(cacheSize, nil),
-		frameCache:             cm.NewLRU(cacheSize, nil),
+		caches:                 caches,
+		eventCache:             caches.lru(EventStoreCache, onEventEvicted),
+		roundCache:             caches.lru(RoundStoreCache, onRoundEvicted),
+		blockCache:             caches.lru(BlockStoreCache, nil),
+		frameCache:             caches.lru(FrameStoreCache, nil),
 		consensusCache:         cm.NewRollingIndex("ConsensusCache", cacheSize),
 		peerSetCache:           NewPeerSetCache(),
 		participantEventsCache: NewParticipantEventsCache(cacheSize),
@@ -39,6 +51,8 @@ func NewInmemStore(cacheSize int) *InmemStore {
 		lastRound:              -1,
 		lastBlock:              -1,
 		lastConsensusEvents:    map[string]string{},
+		onEventEvicted:         onEventEvicted,
+		onRoundEvicted:         onRoundEvicted,
 	}
 	return store
 }
@@ -48,6 +62,12 @@ func (s *InmemStore) CacheSize() int {
 	return s.cacheSize
 }
 
+//Caches returns the Caches which size the LRU caches of the Store, and of the
+//Hashgraph which uses it
+func (s *InmemStore) Caches() *Caches {
+	return s.caches
+}
+
 // GetPeerSet ...
 func (s *InmemStore) GetPeerSet(round int) (*peers.PeerSet, error) {
 	return s.peerSetCache.Get(round)
@@ -280,10 +300,10 @@ func (s *InmemStore) SetFrame(frame *Frame) error {
 func (s *InmemStore) Reset(frame *Frame) error {
 	//Clear all caches
 	s.peerSetCache = NewPeerSetCache()
-	s.eventCache = cm.NewLRU(s.cacheSize, nil)
-	s.roundCache = cm.NewLRU(s.cacheSize, nil)
-	s.blockCache = cm.NewLRU(s.cacheSize, nil)
-	s.frameCache = cm.NewLRU(s.cacheSize, nil)
+	s.eventCache = s.caches.lru(EventStoreCache, s.onEventEvicted)
+	s.roundCache = s.caches.lru(RoundStoreCache, s.onRoundEvicted)
+	s.blockCache = s.caches.lru(BlockStoreCache, nil)
+	s.frameCache = s.caches.lru(FrameStoreCache, nil)
 	s.participantEventsCache = NewParticipantEventsCache(s.cacheSize)
 	s.roots = make(map[string]*Root)
 	s.lastRound = -1
@@ -304,6 +324,49 @@ func (s *InmemStore) Reset(frame *Frame) error {
 	return s.SetFrame(frame)
 }
 
+// Prune implements Pruner. It removes the pruned Events and RoundInfos from the
+// caches, except the Events recorded by the PruneBase, which may still be the
+// parents of new Events.
+func (s *InmemStore) Prune(base *PruneBase) (int, error) {
+	kept := base.frameEvents()
+
+	pruned := 0
+	for _, k := range s.roundCache.Keys() {
+		r := k.(int)
+		if r >= base.Round {
+			continue
+		}
+
+		round, ok := s.roundCache.Peek(r)
+		if !ok {
+			continue
+		}
+
+		for _, e := range round.(*RoundInfo).ReceivedEvents {
+			if _, ok := kept[e]; ok {
+				continue
+			}
+			if s.eventCache.Remove(e) {
+				pruned++
+			}
+		}
+
+		s.roundCache.Remove(r)
+	}
+
+	s.pruneBase = base
+
+	return pruned, nil
+}
+
+// GetPruneBase implements Pruner
+func (s *InmemStore) GetPruneBase() (*PruneBase, error) {
+	if s.pruneBase == nil {
+		return nil, cm.NewStoreErr("PruneBase", cm.KeyNotFound, "")
+	}
+	return s.pruneBase, nil
+}
+
 // Close ...
 func (s *InmemStore) Close() error {
 	return nil
@@ -0,0 +1,62 @@
+package hashgraph
+
+import "errors"
+
+/*******************************************************************************
+Key-Value engines
+
+A DBStore persists its objects in an ordered key-value engine, accessed through
+the kvDB interface. BadgerStore and BoltStore provide the badger and bbolt
+engines. Both use the same keys and values, so the rest of the DBStore does not
+depend on the engine.
+*******************************************************************************/
+
+//errKVKeyNotFound is returned by kvTxn.Get when a key is not in the DB
+var errKVKeyNotFound = errors.New("Key not found")
+
+//errStopIteration can be returned by the function passed to kvTxn.Iterate to
+//stop the iteration early. Iterate then returns it.
+var errStopIteration = errors.New("Stop iteration")
+
+//kvDB is an ordered key-value engine
+type kvDB interface {
+	//View runs fn in a read-only transaction
+	View(fn func(txn kvTxn) error) error
+	//Update runs fn in a read-write transaction, which is committed if fn
+	//returns nil
+	Update(fn func(txn kvTxn) error) error
+	Close() error
+}
+
+//kvTxn is a transaction of a kvDB
+type kvTxn interface {
+	//Get returns the value of a key, or errKVKeyNotFound
+	Get(key []byte) ([]byte, error)
+	Set(key, value []byte) error
+	Delete(key []byte) error
+	//Iterate calls fn for the keys which start with prefix, in increasing
+	//order, and stops at the first error returned by fn. The key and value
+	//passed to fn are only valid until fn returns. Values returned by Get
+	//remain valid after the transaction.
+	Iterate(prefix []byte, fn func(key, value []byte) error) error
+	//IterateReverse is like Iterate, in decreasing order
+	IterateReverse(prefix []byte, fn func(key, value []byte) error) error
+}
+
+//kvSet writes a single key in its own transaction
+func kvSet(db kvDB, key, value []byte) error {
+	return db.Update(func(txn kvTxn) error {
+		return txn.Set(key, value)
+	})
+}
+
+//kvGet reads a single key in its own transaction
+func kvGet(db kvDB, key []byte) ([]byte, error) {
+	var value []byte
+	err := db.View(func(txn kvTxn) error {
+		var err error
+		value, err = txn.Get(key)
+		return err
+	})
+	return value, err
+}
@@ -0,0 +1,64 @@
+package hashgraph
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	eventsInserted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "huron",
+		Subsystem: "hashgraph",
+		Name:      "events_inserted_total",
+		Help:      "Number of Events inserted in the hashgraph.",
+	})
+
+	consensusEvents = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "huron",
+		Subsystem: "hashgraph",
+		Name:      "consensus_events_total",
+		Help:      "Number of Events that reached consensus.",
+	})
+
+	roundsDecided = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "huron",
+		Subsystem: "hashgraph",
+		Name:      "rounds_decided_total",
+		Help:      "Number of rounds whose witnesses have been decided.",
+	})
+
+	blocksCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "huron",
+		Subsystem: "hashgraph",
+		Name:      "blocks_created_total",
+		Help:      "Number of blocks created from consensus rounds.",
+	})
+
+	eventsPruned = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "huron",
+		Subsystem: "hashgraph",
+		Name:      "events_pruned_total",
+		Help:      "Number of Events deleted from the Store by pruning.",
+	})
+
+	//consensusLatency is measured from the local insertion of an Event, because
+	//Events carry no creation time. Events which were not inserted since the
+	//node started, or which were read back from a Store, are not measured.
+	consensusLatency = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "huron",
+		Subsystem: "hashgraph",
+		Name:      "insertion_to_consensus_seconds",
+		Help:      "Time between the local insertion of an Event and its round-received being decided. Events inserted before a restart or read back from the Store are not measured.",
+		Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+	})
+)
+
+func init() {
+	prometheus.MustRegister(
+		eventsInserted,
+		consensusEvents,
+		roundsDecided,
+		blocksCreated,
+		eventsPruned,
+		consensusLatency,
+	)
+}
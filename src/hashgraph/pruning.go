@@ -0,0 +1,275 @@
+package hashgraph
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/abassian/huron/src/common"
+	"github.com/sirupsen/logrus"
+)
+
+//PruneBase is the base from which a pruned Hashgraph is bootstrapped. The
+//Events received before Round, and the RoundInfos below Round, were deleted.
+//Block is the index of a Block whose Frame was stored, and Snapshot the
+//corresponding application snapshot. Roots are the Roots of all participants at
+//the time of pruning, and Events the FrameEvents of the other consensus Events
+//which were kept but whose round, witness flag or timestamp depend on the
+//deleted history. They stand in for that history when computing subsequent
+//Frames, and are inserted along with the Block's Frame when bootstrapping.
+type PruneBase struct {
+	Block    int
+	Round    int
+	Roots    map[string]*Root
+	Events   []*FrameEvent
+	Snapshot []byte
+}
+
+//Marshal - json encoding of PruneBase
+func (pb *PruneBase) Marshal() ([]byte, error) {
+	var b bytes.Buffer
+
+	enc := json.NewEncoder(&b)
+
+	if err := enc.Encode(pb); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+//Unmarshal decodes data into a PruneBase object
+func (pb *PruneBase) Unmarshal(data []byte) error {
+	b := bytes.NewBuffer(data)
+
+	dec := json.NewDecoder(b) //will read from b
+
+	return dec.Decode(pb)
+}
+
+//frameEvents indexes the FrameEvents of the Roots and Events by hash
+func (pb *PruneBase) frameEvents() map[string]*FrameEvent {
+	res := make(map[string]*FrameEvent)
+	for _, r := range pb.Roots {
+		for _, fe := range r.Events {
+			res[fe.Core.Hex()] = fe
+		}
+	}
+	for _, fe := range pb.Events {
+		res[fe.Core.Hex()] = fe
+	}
+	return res
+}
+
+/*
+Prune deletes the history which lies more than depth rounds behind block, which
+is usually the AnchorBlock, as long as the Store implements Pruner. The cut-off
+round is lowered so as to keep the pending Rounds, and the parents of the Events
+which come after block. snapshot is the application snapshot corresponding to
+block; it is recorded along with block's Frame and the FrameEvents which stand
+in for the deleted history, so that the pruned Hashgraph can still be
+bootstrapped.
+*/
+func (h *Hashgraph) Prune(block *Block, depth int, snapshot []byte) error {
+	pruner, ok := h.Store.(Pruner)
+	if !ok {
+		return fmt.Errorf("Store does not support pruning")
+	}
+
+	baseRound := block.RoundReceived()
+
+	roots, err := h.pruneRoots()
+	if err != nil {
+		return err
+	}
+
+	round, err := h.pruneRound(baseRound-depth, baseRound, roots)
+	if err != nil {
+		return err
+	}
+
+	if round <= 0 || (h.pruneBase != nil && h.pruneBase.Round >= round) {
+		h.logger.WithField("round", round).Debug("Nothing to prune")
+		return nil
+	}
+
+	//The Frame of the base is computed, and stored, before the history it
+	//depends on is deleted
+	if _, err := h.GetFrame(baseRound); err != nil {
+		return err
+	}
+
+	events, err := h.pruneEvents(round, baseRound)
+	if err != nil {
+		return err
+	}
+
+	base := &PruneBase{
+		Block:    block.Index(),
+		Round:    round,
+		Roots:    roots,
+		Events:   events,
+		Snapshot: snapshot,
+	}
+
+	pruned, err := pruner.Prune(base)
+	if err != nil {
+		return err
+	}
+
+	h.setPruneBase(base)
+
+	eventsPruned.Add(float64(pruned))
+
+	h.logger.WithFields(logrus.Fields{
+		"block":  block.Index(),
+		"round":  round,
+		"events": pruned,
+	}).Debug("Pruned")
+
+	return nil
+}
+
+//pruneRoots creates the current Roots of all participants
+func (h *Hashgraph) pruneRoots() (map[string]*Root, error) {
+	roots := make(map[string]*Root)
+	for p, peer := range h.Store.RepertoireByPubKey() {
+		if _, ok := h.Store.FirstRound(peer.ID()); !ok {
+			continue
+		}
+
+		lastConsensusEventHash, err := h.Store.LastConsensusEventFrom(p)
+		if err != nil {
+			return nil, err
+		}
+
+		root, err := h.createRoot(p, lastConsensusEventHash)
+		if err != nil {
+			return nil, err
+		}
+
+		roots[p] = root
+	}
+	return roots, nil
+}
+
+//pruneRound lowers the cut-off round so that the pending Rounds are kept, as
+//well as the parents of the Events which are inserted after the base Frame when
+//bootstrapping, ie. the undetermined Events and those received after
+//baseRound. The parents which belong to the Roots are kept anyway.
+func (h *Hashgraph) pruneRound(round int, baseRound int, roots map[string]*Root) (int, error) {
+	for _, pr := range h.PendingRounds.GetOrderedPendingRounds() {
+		if pr.Index < round {
+			round = pr.Index
+		}
+	}
+
+	for _, x := range h.UndeterminedEvents {
+		r, err := h.round(x)
+		if err != nil {
+			return 0, err
+		}
+		if r < round {
+			round = r
+		}
+	}
+
+	rootEvents := (&PruneBase{Roots: roots}).frameEvents()
+
+	after, err := h.receivedEvents(baseRound+1, h.lastConsensusRound())
+	if err != nil {
+		return 0, err
+	}
+	after = append(after, h.UndeterminedEvents...)
+
+	for _, x := range after {
+		ex, err := h.Store.GetEvent(x)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, p := range []string{ex.SelfParent(), ex.OtherParent()} {
+			if _, ok := rootEvents[p]; ok || p == "" {
+				continue
+			}
+
+			rr, err := h.roundReceived(p)
+			if err != nil {
+				//The parent is unknown, as after a Reset
+				continue
+			}
+
+			if rr >= 0 && rr < round {
+				round = rr
+			}
+		}
+	}
+
+	return round, nil
+}
+
+//pruneEvents creates the FrameEvents of the consensus Events which are kept but
+//which precede the base Frame, ie. those received from round to baseRound, and
+//of the subsequent consensus Events created before round.
+func (h *Hashgraph) pruneEvents(round int, baseRound int) ([]*FrameEvent, error) {
+	received, err := h.receivedEvents(round, h.lastConsensusRound())
+	if err != nil {
+		return nil, err
+	}
+
+	events := []*FrameEvent{}
+	for _, x := range received {
+		fe, err := h.createFrameEvent(x)
+		if err != nil {
+			return nil, err
+		}
+
+		rr, err := h.roundReceived(x)
+		if err != nil {
+			return nil, err
+		}
+
+		if rr < baseRound || fe.Round < round {
+			events = append(events, fe)
+		}
+	}
+
+	return events, nil
+}
+
+//receivedEvents returns the Events received from round from to round to
+func (h *Hashgraph) receivedEvents(from int, to int) ([]string, error) {
+	res := []string{}
+	for r := from; r <= to; r++ {
+		round, err := h.Store.GetRound(r)
+		if err != nil {
+			if common.Is(err, common.KeyNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		res = append(res, round.ReceivedEvents...)
+	}
+	return res, nil
+}
+
+func (h *Hashgraph) lastConsensusRound() int {
+	if h.LastConsensusRound == nil {
+		return -1
+	}
+	return *h.LastConsensusRound
+}
+
+func (h *Hashgraph) setPruneBase(base *PruneBase) {
+	h.pruneBase = base
+	h.prunedFrameEvents = base.frameEvents()
+}
+
+//prunedFrameEvent returns the FrameEvent of x if it was recorded by the
+//PruneBase. These are consensus Events whose round, witness flag and timestamp
+//cannot change, but which may no longer be computed because their ancestors or
+//RoundInfos were pruned.
+func (h *Hashgraph) prunedFrameEvent(x string) (*FrameEvent, bool) {
+	fe, ok := h.prunedFrameEvents[x]
+	return fe, ok
+}
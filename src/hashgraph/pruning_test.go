@@ -0,0 +1,115 @@
+package hashgraph
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestPruneAndBootstrap(t *testing.T) {
+	h, index := initConsensusHashgraph(true, t)
+	defer os.RemoveAll(badgerDir)
+
+	h.DivideRounds()
+	h.DecideFame()
+	h.DecideRoundReceived()
+	if err := h.ProcessDecidedRounds(); err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := h.Store.GetBlock(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	frame, err := h.GetFrame(block.RoundReceived())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//The Events received in round 1 are deleted
+	round1, err := h.Store.GetRound(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pruned := round1.ReceivedEvents
+	if len(pruned) == 0 {
+		t.Fatalf("Round 1 should have received Events")
+	}
+
+	if err := h.Prune(block, 0, []byte("snapshot")); err != nil {
+		t.Fatal(err)
+	}
+
+	badgerStore := h.Store.(*BadgerStore)
+
+	for _, e := range pruned {
+		if _, err := badgerStore.dbGetEvent(e); err == nil {
+			t.Fatalf("Event %s should have been pruned", getName(index, e))
+		}
+	}
+
+	for r := 0; r < block.RoundReceived(); r++ {
+		if _, err := badgerStore.dbGetRound(r); err == nil {
+			t.Fatalf("Round %d should have been pruned", r)
+		}
+	}
+
+	if _, err := badgerStore.dbGetRound(block.RoundReceived()); err != nil {
+		t.Fatalf("Round %d should not have been pruned: %v", block.RoundReceived(), err)
+	}
+
+	//Frames are computed identically after pruning
+	h.Store.(*BadgerStore).inmemStore.frameCache.Purge()
+	recomputed, err := h.GetFrame(block.RoundReceived())
+	if err != nil {
+		t.Fatal(err)
+	}
+	frameHash, _ := frame.Hash()
+	recomputedHash, _ := recomputed.Hash()
+	if !reflect.DeepEqual(frameHash, recomputedHash) {
+		t.Fatalf("Frame computed after pruning should not change")
+	}
+
+	//Bootstrap a new Hashgraph from the pruned DB
+	h.Store.Close()
+
+	recycledStore, err := NewBadgerStore(cacheSize, badgerDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nh := NewHashgraph(recycledStore, DummyInternalCommitCallback, logrus.New().WithField("id", "bootstrapped"))
+
+	if err := nh.Bootstrap(); err != nil {
+		t.Fatal(err)
+	}
+
+	if nh.pruneBase == nil {
+		t.Fatalf("Hashgraph should have been bootstrapped from the PruneBase")
+	}
+
+	base, err := recycledStore.GetPruneBase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base.Block != block.Index() || string(base.Snapshot) != "snapshot" {
+		t.Fatalf("PruneBase should reference Block %d, not %d", block.Index(), base.Block)
+	}
+
+	if hKnown, nhKnown := h.Store.KnownEvents(), nh.Store.KnownEvents(); !reflect.DeepEqual(hKnown, nhKnown) {
+		t.Fatalf("Bootstrapped hashgraph's Known should be %#v, not %#v", hKnown, nhKnown)
+	}
+
+	if *h.LastConsensusRound != *nh.LastConsensusRound {
+		t.Fatalf("Bootstrapped hashgraph's LastConsensusRound should be %d, not %d",
+			*h.LastConsensusRound, *nh.LastConsensusRound)
+	}
+
+	if len(h.UndeterminedEvents) != len(nh.UndeterminedEvents) {
+		t.Fatalf("Bootstrapped hashgraph should have %d undetermined events, not %d",
+			len(h.UndeterminedEvents), len(nh.UndeterminedEvents))
+	}
+}
@@ -0,0 +1,281 @@
+package hashgraph
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+)
+
+/*******************************************************************************
+Schema
+
+The layout of the keys and the encoding of the values written by the DBStore
+form its schema, whatever the key-value engine. The version of the schema is
+recorded in the database, and the DBStore refuses to open a database with a
+different version, instead of misreading it. Older databases are upgraded by
+MigrateBadgerStore or MigrateBoltStore, which apply the migrations one version
+at a time, after backing up the database.
+
+Any change to the keys or to the encoding of Events, Rounds, Blocks, Frames,
+Roots or PeerSets must increment SchemaVersion and register the corresponding
+migration.
+*******************************************************************************/
+
+//SchemaVersion is the version of the schema written by this DBStore
+const SchemaVersion = 1
+
+const schemaVersionKey = "schema_version"
+
+//SchemaError is returned when a database does not have the expected schema
+//version.
+type SchemaError struct {
+	Path    string
+	Version int
+}
+
+//Error implements the error interface
+func (e SchemaError) Error() string {
+	if e.Version > SchemaVersion {
+		return fmt.Sprintf("Database %s has schema version %d, which is newer than the supported version %d",
+			e.Path, e.Version, SchemaVersion)
+	}
+	return fmt.Sprintf("Database %s has schema version %d instead of %d; run 'huron db migrate' to upgrade it",
+		e.Path, e.Version, SchemaVersion)
+}
+
+//migration upgrades a database from version to version+1
+type migration struct {
+	version     int
+	description string
+	apply       func(db kvDB) error
+}
+
+//migrations are indexed by the version they upgrade from
+var migrations = []migration{
+	{
+		version:     0,
+		description: "Record the schema version of databases created before versioning",
+		apply:       func(db kvDB) error { return nil },
+	},
+}
+
+//MigrateBadgerStore upgrades the badger database in path to SchemaVersion.
+//Before each migration, the database is copied to the directory returned by
+//SchemaBackupPath. If that backup already exists, it was left by a failed
+//attempt at the same migration, and is kept rather than overwritten by the
+//partially migrated database. It returns the version the database was migrated
+//from.
+func MigrateBadgerStore(path string, logger *logrus.Entry) (int, error) {
+	open := func() (kvDB, error) {
+		handle, err := openBadgerDB(path, false)
+		if err != nil {
+			return nil, err
+		}
+		return &badgerKV{handle}, nil
+	}
+	return migrate(path, open, logger)
+}
+
+//MigrateBoltStore upgrades the bbolt database file in path to SchemaVersion,
+//like MigrateBadgerStore
+func MigrateBoltStore(path string, logger *logrus.Entry) (int, error) {
+	open := func() (kvDB, error) {
+		handle, err := openBoltDB(path, false)
+		if err != nil {
+			return nil, err
+		}
+		return &boltKV{handle}, nil
+	}
+	return migrate(path, open, logger)
+}
+
+func migrate(path string, open func() (kvDB, error), logger *logrus.Entry) (int, error) {
+	db, err := open()
+	if err != nil {
+		return 0, err
+	}
+
+	from, err := dbGetSchemaVersion(db)
+	db.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	if from > SchemaVersion {
+		return from, SchemaError{Path: path, Version: from}
+	}
+
+	for v := from; v < SchemaVersion; v++ {
+		m := migrations[v]
+
+		backup := SchemaBackupPath(path, v)
+		reused, err := backupDB(path, backup)
+		if err != nil {
+			return from, fmt.Errorf("Backing up %s: %v", path, err)
+		}
+
+		logger.WithFields(logrus.Fields{
+			"version": v,
+			"backup":  backup,
+			"reused":  reused,
+		}).Info(m.description)
+
+		if err := applyMigration(open, m); err != nil {
+			return from, fmt.Errorf("Migrating %s from version %d: %v. The database before the migration is backed up in %s, and is kept if the migration is run again",
+				path, v, err, backup)
+		}
+	}
+
+	return from, nil
+}
+
+//SchemaBackupPath returns the directory where MigrateBadgerStore backs up the
+//database in path before migrating it from version
+func SchemaBackupPath(path string, version int) string {
+	return fmt.Sprintf("%s--schema-v%d", filepath.Clean(path), version)
+}
+
+func applyMigration(open func() (kvDB, error), m migration) error {
+	db, err := open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := m.apply(db); err != nil {
+		return err
+	}
+
+	return dbSetSchemaVersion(db, m.version+1)
+}
+
+//checkSchemaVersion verifies that db has the current SchemaVersion. An empty
+//database is new, so the current SchemaVersion is recorded.
+func checkSchemaVersion(db kvDB, path string) error {
+	version, err := dbGetSchemaVersion(db)
+	if err != nil {
+		return err
+	}
+
+	if version == SchemaVersion {
+		return nil
+	}
+
+	if version == 0 {
+		empty, err := dbIsEmpty(db)
+		if err != nil {
+			return err
+		}
+		if empty {
+			return dbSetSchemaVersion(db, SchemaVersion)
+		}
+	}
+
+	return SchemaError{Path: path, Version: version}
+}
+
+//dbGetSchemaVersion returns the recorded schema version, or 0 if there is none
+func dbGetSchemaVersion(db kvDB) (int, error) {
+	versionBytes, err := kvGet(db, []byte(schemaVersionKey))
+	if err != nil {
+		if isDBKeyNotFound(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	return strconv.Atoi(string(versionBytes))
+}
+
+func dbSetSchemaVersion(db kvDB, version int) error {
+	//insert [schema_version] => [version]
+	return kvSet(db, []byte(schemaVersionKey), []byte(strconv.Itoa(version)))
+}
+
+//dbIsEmpty returns true if db contains no key other than its metadata
+func dbIsEmpty(db kvDB) (bool, error) {
+	empty := true
+	err := db.View(func(txn kvTxn) error {
+		return txn.Iterate([]byte{}, func(key, value []byte) error {
+			if isDBMetadata(key) {
+				return nil
+			}
+			empty = false
+			return errStopIteration
+		})
+	})
+	if err == errStopIteration {
+		err = nil
+	}
+	return empty, err
+}
+
+//backupDB copies the database in src to dst, unless dst already exists, in
+//which case it returns true. The copy is made to a temporary directory which is
+//only renamed to dst once complete, so an existing dst is always a complete
+//backup.
+func backupDB(src, dst string) (bool, error) {
+	if _, err := os.Stat(dst); err == nil {
+		return true, nil
+	}
+
+	tmp := dst + ".tmp"
+	if err := os.RemoveAll(tmp); err != nil {
+		return false, err
+	}
+
+	if err := copyDir(src, tmp); err != nil {
+		return false, err
+	}
+
+	return false, os.Rename(tmp, dst)
+}
+
+//copyDir copies the src directory, or file, to a new dst
+func copyDir(src, dst string) error {
+	if _, err := os.Stat(dst); err == nil {
+		return fmt.Errorf("%s already exists", dst)
+	}
+
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode())
+		}
+
+		return copyFile(path, target, info.Mode())
+	})
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,152 @@
+package hashgraph
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/abassian/huron/src/peers"
+	"github.com/sirupsen/logrus"
+)
+
+//openStore and migrateStore open and migrate a database of the given backend
+func openStore(backend, path string) (*DBStore, error) {
+	if backend == "bolt" {
+		store, err := NewBoltStore(cacheSize, path)
+		if err != nil {
+			return nil, err
+		}
+		return store.DBStore, nil
+	}
+
+	store, err := NewBadgerStore(cacheSize, path)
+	if err != nil {
+		return nil, err
+	}
+	return store.DBStore, nil
+}
+
+func migrateStore(backend, path string) (int, error) {
+	logger := logrus.New().WithField("test", "migrate")
+	if backend == "bolt" {
+		return MigrateBoltStore(path, logger)
+	}
+	return MigrateBadgerStore(path, logger)
+}
+
+func TestSchemaMigration(t *testing.T) {
+	forEachBackend(t, testSchemaMigration)
+}
+
+func testSchemaMigration(t *testing.T, backend string) {
+	store := initDBStore(backend, cacheSize, t)
+	path := store.path
+	defer os.RemoveAll(SchemaBackupPath(path, 0))
+	defer os.RemoveAll(path)
+
+	version, err := dbGetSchemaVersion(store.db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != SchemaVersion {
+		t.Fatalf("New database should have schema version %d, not %d", SchemaVersion, version)
+	}
+
+	//Simulate a database created before versioning
+	block := NewBlock(0, 1, []byte("framehash"), []*peers.Peer{}, [][]byte{[]byte("tx")}, []InternalTransaction{})
+	if err := store.dbSetBlock(block); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.dbDelete([][]byte{[]byte(schemaVersionKey)}); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	_, err = openStore(backend, path)
+	if serr, ok := err.(SchemaError); !ok || serr.Version != 0 {
+		t.Fatalf("Opening an unversioned database should fail with a SchemaError, not %v", err)
+	}
+
+	from, err := migrateStore(backend, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from != 0 {
+		t.Fatalf("Database should have been migrated from version 0, not %d", from)
+	}
+
+	if _, err := os.Stat(SchemaBackupPath(path, 0)); err != nil {
+		t.Fatalf("Database should have been backed up: %v", err)
+	}
+
+	store, err = openStore(backend, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.dbGetBlock(0); err != nil {
+		t.Fatalf("Migrated database should retain its Blocks: %v", err)
+	}
+
+	//Databases written by a newer version are refused
+	if err := dbSetSchemaVersion(store.db, SchemaVersion+1); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	if _, err := openStore(backend, path); err == nil {
+		t.Fatalf("Opening a database with a newer schema version should fail")
+	}
+	if _, err := migrateStore(backend, path); err == nil {
+		t.Fatalf("Migrating a database with a newer schema version should fail")
+	}
+}
+
+func TestSchemaMigrationRetry(t *testing.T) {
+	forEachBackend(t, testSchemaMigrationRetry)
+}
+
+func testSchemaMigrationRetry(t *testing.T, backend string) {
+	store := initDBStore(backend, cacheSize, t)
+	path := store.path
+	defer os.RemoveAll(SchemaBackupPath(path, 0))
+	defer os.RemoveAll(path)
+
+	//Simulate a database created before versioning
+	if err := store.dbDelete([][]byte{[]byte(schemaVersionKey)}); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	//The first attempt fails after the backup
+	migration := migrations[0]
+	migrations[0].apply = func(db kvDB) error { return fmt.Errorf("Failed") }
+
+	_, err := migrateStore(backend, path)
+	migrations[0] = migration
+
+	if err == nil {
+		t.Fatalf("A failed migration should return an error")
+	}
+	if _, err := os.Stat(SchemaBackupPath(path, 0)); err != nil {
+		t.Fatalf("Database should have been backed up: %v", err)
+	}
+
+	//Running it again reuses the backup
+	from, err := migrateStore(backend, path)
+	if err != nil {
+		t.Fatalf("Migrating again should reuse the backup: %v", err)
+	}
+	if from != 0 {
+		t.Fatalf("Database should have been migrated from version 0, not %d", from)
+	}
+	if _, err := os.Stat(SchemaBackupPath(path, 0) + ".tmp"); !os.IsNotExist(err) {
+		t.Fatalf("No temporary backup should be left")
+	}
+
+	store, err = openStore(backend, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+}
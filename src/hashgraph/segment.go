@@ -0,0 +1,177 @@
+package hashgraph
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/abassian/huron/src/crypto/encryption"
+)
+
+//segmentHeaderSize is the size of the header of a record: the length of its
+//data, and the CRC32 checksum of its data
+const segmentHeaderSize = 8
+
+//segmentFile is an append-only file of records, which are read back by their
+//offset. The records are sealed with the encryption Key, if any.
+type segmentFile struct {
+	file *os.File
+	path string
+	size int64
+	key  *encryption.Key
+}
+
+//createSegmentFile creates the segment file in path, truncating any previous
+//one
+func createSegmentFile(path string, key *encryption.Key) (*segmentFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	segment := &segmentFile{
+		file: file,
+		path: path,
+		key:  key,
+	}
+	return segment, nil
+}
+
+//append writes a record at the end of the file and returns its offset, and its
+//size in the file
+func (s *segmentFile) append(data []byte) (int64, int64, error) {
+	if s.key != nil {
+		var err error
+		if data, err = s.key.Seal(data); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	record := make([]byte, segmentHeaderSize+len(data))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(data))
+	copy(record[segmentHeaderSize:], data)
+
+	offset := s.size
+	if _, err := s.file.WriteAt(record, offset); err != nil {
+		return 0, 0, err
+	}
+	s.size += int64(len(record))
+
+	return offset, int64(len(record)), nil
+}
+
+//read returns the data of the record at offset
+func (s *segmentFile) read(offset int64) ([]byte, error) {
+	header := make([]byte, segmentHeaderSize)
+	if _, err := s.file.ReadAt(header, offset); err != nil {
+		return nil, fmt.Errorf("Reading segment %s at %d: %v", s.path, offset, err)
+	}
+
+	size := binary.BigEndian.Uint32(header[0:4])
+	if offset+segmentHeaderSize+int64(size) > s.size {
+		return nil, fmt.Errorf("Segment %s: record at %d is truncated", s.path, offset)
+	}
+
+	data := make([]byte, size)
+	if _, err := s.file.ReadAt(data, offset+segmentHeaderSize); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("Reading segment %s at %d: %v", s.path, offset, err)
+	}
+
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, fmt.Errorf("Segment %s: record at %d is corrupted", s.path, offset)
+	}
+
+	if s.key != nil {
+		return s.key.Open(data)
+	}
+
+	return data, nil
+}
+
+//compact rewrites the file with the records at the given offsets only, and
+//returns their new offsets. The other records are lost. The records are copied
+//as they are, without being unsealed.
+func (s *segmentFile) compact(offsets []int64) (map[int64]int64, error) {
+	sorted := append([]int64{}, offsets...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	tmpPath := s.path + ".compact"
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	moved, size, err := s.copyRecords(tmp, sorted)
+	if err == nil {
+		err = os.Rename(tmpPath, s.path)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return nil, err
+	}
+
+	s.file.Close()
+	s.file = tmp
+	s.size = size
+
+	return moved, nil
+}
+
+//copyRecords writes the records at the given offsets, in that order, to dst,
+//and returns their offsets in dst and the size of dst
+func (s *segmentFile) copyRecords(dst *os.File, offsets []int64) (map[int64]int64, int64, error) {
+	moved := make(map[int64]int64, len(offsets))
+	header := make([]byte, segmentHeaderSize)
+
+	var size int64
+	for _, offset := range offsets {
+		if _, err := s.file.ReadAt(header, offset); err != nil {
+			return nil, 0, fmt.Errorf("Reading segment %s at %d: %v", s.path, offset, err)
+		}
+
+		record := make([]byte, segmentHeaderSize+int64(binary.BigEndian.Uint32(header[0:4])))
+		if offset+int64(len(record)) > s.size {
+			return nil, 0, fmt.Errorf("Segment %s: record at %d is truncated", s.path, offset)
+		}
+		if _, err := s.file.ReadAt(record, offset); err != nil && err != io.EOF {
+			return nil, 0, fmt.Errorf("Reading segment %s at %d: %v", s.path, offset, err)
+		}
+
+		if _, err := dst.WriteAt(record, size); err != nil {
+			return nil, 0, err
+		}
+
+		moved[offset] = size
+		size += int64(len(record))
+	}
+
+	return moved, size, nil
+}
+
+//truncate removes all the records
+func (s *segmentFile) truncate() error {
+	if err := s.file.Truncate(0); err != nil {
+		return err
+	}
+	s.size = 0
+	return nil
+}
+
+//close closes and removes the file, whose records are not meant to outlive the
+//process
+func (s *segmentFile) close() error {
+	if err := s.file.Close(); err != nil {
+		return err
+	}
+	return os.Remove(s.path)
+}
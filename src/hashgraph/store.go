@@ -1,10 +1,15 @@
 package hashgraph
 
-import "github.com/abassian/huron/src/peers"
+import (
+	"io"
+
+	"github.com/abassian/huron/src/peers"
+)
 
 // Store ...
 type Store interface {
 	CacheSize() int
+	Caches() *Caches
 	GetPeerSet(int) (*peers.PeerSet, error)
 	SetPeerSet(int, *peers.PeerSet) error
 	GetAllPeerSets() (map[int][]*peers.Peer, error)
@@ -36,3 +41,26 @@ type Store interface {
 	Close() error
 	StorePath() string
 }
+
+// Compactor is implemented by Stores which can reclaim the space used by
+// obsolete data
+type Compactor interface {
+	Compact() error
+}
+
+// Backuper is implemented by Stores which can be backed up while in use
+type Backuper interface {
+	// Backup writes a consistent copy of the whole Store to w
+	Backup(w io.Writer) error
+}
+
+// Pruner is implemented by Stores which can delete the history that lies
+// behind a PruneBase
+type Pruner interface {
+	// Prune records the PruneBase, then deletes the Events received before
+	// base.Round, the RoundInfos below base.Round, and the corresponding
+	// indexes. It returns the number of deleted Events.
+	Prune(base *PruneBase) (int, error)
+	// GetPruneBase returns the PruneBase recorded by the last call to Prune
+	GetPruneBase() (*PruneBase, error)
+}
@@ -1,6 +1,7 @@
 package huron
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"time"
@@ -39,16 +40,31 @@ func NewHuron(config *HuronConfig) *Huron {
 // Init initialises the huron engine
 func (b *Huron) Init() error {
 
+	if err := b.Config.NodeConfig.Validate(); err != nil {
+		b.Config.Logger.WithError(err).Error("huron.go:Init() Validate")
+		return err
+	}
+
 	if err := b.initPeers(); err != nil {
 		b.Config.Logger.WithError(err).Error("huron.go:Init() initPeers")
 		return err
 	}
 
+	if err := b.initEncryption(); err != nil {
+		b.Config.Logger.WithError(err).Error("huron.go:Init() initEncryption")
+		return err
+	}
+
 	if err := b.initStore(); err != nil {
 		b.Config.Logger.WithError(err).Error("huron.go:Init() initStore")
 		return err
 	}
 
+	if err := b.initCaches(); err != nil {
+		b.Config.Logger.WithError(err).Error("huron.go:Init() initCaches")
+		return err
+	}
+
 	if err := b.initTransport(); err != nil {
 		b.Config.Logger.WithError(err).Error("huron.go:Init() initTransport")
 		return err
@@ -72,13 +88,37 @@ func (b *Huron) Init() error {
 	return nil
 }
 
-// Run starts the Huron Node running
-func (b *Huron) Run() {
+// Run starts the Huron Node and its HTTP service, and blocks until the node
+// is shut down or ctx is cancelled. The service is stopped before returning.
+func (b *Huron) Run(ctx context.Context) error {
 	if b.Service != nil {
 		go b.Service.Serve()
 	}
 
-	b.Node.Run(true)
+	err := b.Node.Run(ctx, true)
+
+	if b.Service != nil {
+		if serr := b.Service.Shutdown(context.Background()); serr != nil {
+			b.Config.Logger.WithError(serr).Error("huron.go:Run() Service Shutdown")
+		}
+	}
+
+	return err
+}
+
+// Leave politely leaves the network and shuts the node down
+func (b *Huron) Leave(ctx context.Context) error {
+	return b.Node.Leave(ctx)
+}
+
+// Shutdown stops the node without leaving the network
+func (b *Huron) Shutdown() error {
+	return b.Node.Shutdown()
+}
+
+// Done returns a channel that is closed once the node has shut down
+func (b *Huron) Done() <-chan struct{} {
+	return b.Node.Done()
 }
 
 func (b *Huron) initTransport() error {
@@ -137,45 +177,114 @@ func (b *Huron) initPeers() error {
 	return nil
 }
 
+func (b *Huron) initEncryption() error {
+	if b.Config.NodeConfig.EncryptionKey != nil {
+		return nil
+	}
+
+	key, err := b.Config.EncryptionKey()
+	if err != nil {
+		return err
+	}
+
+	if key != nil {
+		b.Config.Logger.WithField("key", key.ID()).Debug("Encryption at rest enabled")
+	}
+
+	b.Config.NodeConfig.EncryptionKey = key
+
+	return nil
+}
+
 func (b *Huron) initStore() error {
-	if !b.Config.Store {
+	backend := b.Config.Backend()
+
+	switch backend {
+	case InmemBackend:
 		b.Config.Logger.Debug("Creating InmemStore")
 		b.Store = h.NewInmemStore(b.Config.NodeConfig.CacheSize)
-	} else {
-		dbPath := b.Config.BadgerDir()
+		return nil
+	case HybridBackend:
+		spillFile := b.Config.SpillFile()
+		b.Config.Logger.WithField("path", spillFile).Debug("Creating HybridStore")
+		hybridStore, err := h.NewEncryptedHybridStore(b.Config.NodeConfig.CacheSize, spillFile, b.Config.NodeConfig.EncryptionKey)
+		if err != nil {
+			return err
+		}
+		b.Store = hybridStore
+		return nil
+	case BadgerBackend, BoltBackend:
+	default:
+		return fmt.Errorf("Unknown store backend %q", backend)
+	}
+
+	dbPath := b.Config.StorePath()
 
-		b.Config.Logger.WithField("path", dbPath).Debug("Creating BadgerStore")
+	b.Config.Logger.WithFields(logrus.Fields{
+		"backend": backend,
+		"path":    dbPath,
+	}).Debug("Creating Store")
 
-		if !b.Config.NodeConfig.Bootstrap {
-			b.Config.Logger.Debug("No Bootstrap")
+	if !b.Config.NodeConfig.Bootstrap {
+		b.Config.Logger.Debug("No Bootstrap")
 
-			backup := backupFileName(dbPath)
+		backup := backupFileName(dbPath)
 
-			err := os.Rename(dbPath, backup)
+		err := os.Rename(dbPath, backup)
 
-			if err != nil {
-				if !os.IsNotExist(err) {
-					return err
-				}
-				b.Config.Logger.Debug("Nothing to backup")
-			} else {
-				b.Config.Logger.WithField("path", backup).Debug("Created backup")
+		if err != nil {
+			if !os.IsNotExist(err) {
+				return err
 			}
+			b.Config.Logger.Debug("Nothing to backup")
+		} else {
+			b.Config.Logger.WithField("path", backup).Debug("Created backup")
 		}
+	}
 
-		b.Config.Logger.WithField("path", dbPath).Debug("Opening BadgerStore")
+	b.Config.Logger.WithField("path", dbPath).Debug("Opening Store")
 
-		dbStore, err := h.NewBadgerStore(b.Config.NodeConfig.CacheSize, dbPath)
+	key := b.Config.NodeConfig.EncryptionKey
+
+	if backend == BoltBackend {
+		dbStore, err := h.NewEncryptedBoltStore(b.Config.NodeConfig.CacheSize, dbPath, key)
+		if err != nil {
+			return err
+		}
+		b.Store = dbStore
+	} else {
+		dbStore, err := h.NewEncryptedBadgerStore(b.Config.NodeConfig.CacheSize, dbPath, key)
 		if err != nil {
 			return err
 		}
-
 		b.Store = dbStore
 	}
 
 	return nil
 }
 
+//initCaches sizes the caches of the Store, and of the Hashgraph created by the
+//node, with the memory budget of the config
+func (b *Huron) initCaches() error {
+	conf, err := b.Config.NodeConfig.CacheConfig()
+	if err != nil {
+		return err
+	}
+
+	if err := b.Store.Caches().Configure(conf); err != nil {
+		return err
+	}
+
+	if conf.Budget > 0 {
+		b.Config.Logger.WithFields(logrus.Fields{
+			"budget": conf.Budget,
+			"shares": conf.Shares,
+		}).Debug("Caches sized by memory")
+	}
+
+	return nil
+}
+
 func (b *Huron) initKey() error {
 	if b.Config.Key == nil {
 		simpleKeyfile := keys.NewSimpleKeyfile(b.Config.Keyfile())
@@ -226,6 +335,10 @@ func (b *Huron) initNode() error {
 		"moniker":       validator.Moniker,
 	}).Debug("PARTICIPANTS")
 
+	if b.Config.NodeConfig.SnapshotDir == "" {
+		b.Config.NodeConfig.SnapshotDir = b.Config.SnapshotDir()
+	}
+
 	b.Node = node.NewNode(
 		&b.Config.NodeConfig,
 		validator,
@@ -245,7 +358,11 @@ func (b *Huron) initNode() error {
 
 func (b *Huron) initService() error {
 	if b.Config.ServiceAddr != "" {
-		b.Service = service.NewService(b.Config.ServiceAddr, b.Node, b.Config.Logger)
+		s, err := service.NewService(b.Config.ServiceAddr, b.Config.ServiceConfig(), b.Node, b.Config.Logger)
+		if err != nil {
+			return fmt.Errorf("failed to create service: %s", err)
+		}
+		b.Service = s
 	}
 	return nil
 }
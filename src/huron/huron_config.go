@@ -2,19 +2,35 @@ package huron
 
 import (
 	"crypto/ecdsa"
+	"fmt"
 	"os"
 	"os/user"
 	"path/filepath"
 	"runtime"
 
+	"github.com/abassian/huron/src/crypto/encryption"
 	"github.com/abassian/huron/src/node"
 	"github.com/abassian/huron/src/proxy"
+	"github.com/abassian/huron/src/service"
 	"github.com/sirupsen/logrus"
 )
 
 // DefaultKeyfile ...
 const DefaultKeyfile = "priv_key"
 
+// Store backends
+const (
+	// InmemBackend keeps the hashgraph in memory only
+	InmemBackend = "inmem"
+	// HybridBackend keeps the hashgraph in memory, and spills the events and
+	// rounds evicted from the caches to a segment file
+	HybridBackend = "hybrid"
+	// BadgerBackend persists the hashgraph in a badger database
+	BadgerBackend = "badger"
+	// BoltBackend persists the hashgraph in a bbolt database file
+	BoltBackend = "bolt"
+)
+
 // HuronConfig ...
 type HuronConfig struct {
 	NodeConfig node.Config `mapstructure:",squash"`
@@ -23,10 +39,49 @@ type HuronConfig struct {
 	BindAddr    string `mapstructure:"listen"`
 	ServiceAddr string `mapstructure:"service-listen"`
 	MaxPool     int    `mapstructure:"max-pool"`
-	Store       bool   `mapstructure:"store"`
 	LogLevel    string `mapstructure:"log"`
 	Moniker     string `mapstructure:"moniker"`
 
+	// StoreBackend is the Store implementation: inmem, hybrid, badger or bolt
+	StoreBackend string `mapstructure:"store-backend"`
+
+	// Store is deprecated, it selects the badger backend when StoreBackend is
+	// inmem
+	Store bool `mapstructure:"store"`
+
+	// EncryptionKeyFile and EncryptionPassphrase provide the key which
+	// encrypts the store and the snapshots at rest. The key file contains the
+	// hex dump of a 32-byte key. The passphrase, which can also be given by the
+	// HURON_ENCRYPTION_PASSPHRASE environment variable, is derived into a key
+	// with a salt stored in the data directory. Nothing is encrypted if neither
+	// is set.
+	EncryptionKeyFile    string `mapstructure:"encryption-key-file"`
+	EncryptionPassphrase string `mapstructure:"encryption-passphrase"`
+
+	// ServiceToken is the bearer token granting the admin role on the HTTP
+	// service, which is required to submit transactions. Admin endpoints are
+	// disabled if neither ServiceToken nor ServiceAdminClients is set.
+	ServiceToken string `mapstructure:"service-token"`
+
+	// ServiceReadToken is the bearer token granting the read-only role on the
+	// HTTP service. Read endpoints are not authenticated if neither
+	// ServiceReadToken nor ServiceClientCA is set.
+	ServiceReadToken string `mapstructure:"service-read-token"`
+
+	// ServiceCert and ServiceKey enable HTTPS on the HTTP service
+	ServiceCert string `mapstructure:"service-cert"`
+	ServiceKey  string `mapstructure:"service-key"`
+
+	// ServiceClientCA is the CA bundle used to verify client certificates.
+	// Clients whose certificate common name is listed in ServiceAdminClients
+	// are granted the admin role, others the read-only role.
+	ServiceClientCA     string   `mapstructure:"service-client-ca"`
+	ServiceAdminClients []string `mapstructure:"service-admin-clients"`
+
+	// ServiceAllowedOrigins are the origins allowed to make cross-origin
+	// requests to the HTTP service. "*" allows any origin.
+	ServiceAllowedOrigins []string `mapstructure:"service-allowed-origins"`
+
 	LoadPeers bool
 	Proxy     proxy.AppProxy
 	Key       *ecdsa.PrivateKey
@@ -43,26 +98,118 @@ func NewDefaultConfig() *HuronConfig {
 	nodeConfig.Logger = logger
 
 	config := &HuronConfig{
-		NodeConfig:  nodeConfig,
-		DataDir:     DefaultDataDir(),
-		BindAddr:    "127.0.0.1:1337",
-		ServiceAddr: "127.0.0.1:8000",
-		MaxPool:     2,
-		Store:       false,
-		LoadPeers:   true,
-		Proxy:       nil,
-		Key:         nil,
-		Logger:      logger,
+		NodeConfig:   nodeConfig,
+		DataDir:      DefaultDataDir(),
+		BindAddr:     "127.0.0.1:1337",
+		ServiceAddr:  "127.0.0.1:8000",
+		MaxPool:      2,
+		StoreBackend: InmemBackend,
+		Store:        false,
+		LoadPeers:    true,
+		Proxy:        nil,
+		Key:          nil,
+		Logger:       logger,
 	}
 
 	return config
 }
 
+// ServiceConfig returns the security settings of the HTTP service
+func (c *HuronConfig) ServiceConfig() service.Config {
+	return service.Config{
+		CertFile:       c.ServiceCert,
+		KeyFile:        c.ServiceKey,
+		ClientCAFile:   c.ServiceClientCA,
+		AdminClients:   c.ServiceAdminClients,
+		ReadToken:      c.ServiceReadToken,
+		AdminToken:     c.ServiceToken,
+		AllowedOrigins: c.ServiceAllowedOrigins,
+	}
+}
+
 // BadgerDir ...
 func (c *HuronConfig) BadgerDir() string {
 	return filepath.Join(c.DataDir, "badger_db")
 }
 
+// BoltFile ...
+func (c *HuronConfig) BoltFile() string {
+	return filepath.Join(c.DataDir, "bolt.db")
+}
+
+// SpillFile ...
+func (c *HuronConfig) SpillFile() string {
+	return filepath.Join(c.DataDir, "spill.seg")
+}
+
+// Backend returns the StoreBackend, taking the deprecated Store flag into
+// account
+func (c *HuronConfig) Backend() string {
+	if c.Store && (c.StoreBackend == "" || c.StoreBackend == InmemBackend) {
+		return BadgerBackend
+	}
+	if c.StoreBackend == "" {
+		return InmemBackend
+	}
+	return c.StoreBackend
+}
+
+// StorePath returns the path of the database of the persistent backends, or
+// of the segment file of the hybrid backend
+func (c *HuronConfig) StorePath() string {
+	switch c.Backend() {
+	case BoltBackend:
+		return c.BoltFile()
+	case HybridBackend:
+		return c.SpillFile()
+	}
+	return c.BadgerDir()
+}
+
+// SaltFile ...
+func (c *HuronConfig) SaltFile() string {
+	return filepath.Join(c.DataDir, "encryption_salt")
+}
+
+// EncryptionKey returns the key which encrypts the store and the snapshots, or
+// nil if encryption is not enabled
+func (c *HuronConfig) EncryptionKey() (*encryption.Key, error) {
+	return LoadEncryptionKey(c.EncryptionKeyFile, c.EncryptionPassphrase, c.SaltFile())
+}
+
+// LoadEncryptionKey reads a key from keyFile, or derives it from passphrase with
+// the salt in saltFile, which is created if needed. If both are empty, the
+// passphrase is read from the HURON_ENCRYPTION_PASSPHRASE environment variable,
+// and nil is returned if it is not set.
+func LoadEncryptionKey(keyFile, passphrase, saltFile string) (*encryption.Key, error) {
+	if keyFile != "" && passphrase != "" {
+		return nil, fmt.Errorf("An encryption key file and passphrase cannot both be given")
+	}
+
+	if keyFile != "" {
+		return encryption.ReadKeyFile(keyFile)
+	}
+
+	if passphrase == "" {
+		passphrase = os.Getenv("HURON_ENCRYPTION_PASSPHRASE")
+	}
+	if passphrase == "" {
+		return nil, nil
+	}
+
+	salt, err := encryption.ReadOrCreateSalt(saltFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return encryption.KeyFromPassphrase(passphrase, salt)
+}
+
+// SnapshotDir ...
+func (c *HuronConfig) SnapshotDir() string {
+	return filepath.Join(c.DataDir, "snapshots")
+}
+
 // Keyfile ...
 func (c *HuronConfig) Keyfile() string {
 	return filepath.Join(c.DataDir, DefaultKeyfile)
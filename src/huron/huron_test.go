@@ -1,25 +1,36 @@
 package huron
 
 import (
+	"bytes"
 	"crypto/ecdsa"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	bkeys "github.com/abassian/huron/src/crypto/keys"
+	h "github.com/abassian/huron/src/hashgraph"
 	"github.com/abassian/huron/src/peers"
 )
 
 func TestInitStore(t *testing.T) {
+	for _, backend := range []string{BadgerBackend, BoltBackend} {
+		t.Run(backend, func(t *testing.T) {
+			testInitStore(backend, t)
+		})
+	}
+}
+
+func testInitStore(backend string, t *testing.T) {
 	os.RemoveAll("test_data")
 	os.Mkdir("test_data", os.ModeDir|0777)
 	defer os.RemoveAll("test_data")
 
 	conf := NewDefaultConfig()
 	conf.DataDir = "test_data"
-	conf.Store = true
+	conf.StoreBackend = backend
 	conf.NodeConfig.Bootstrap = false
 
 	jsonPeerSet := peers.NewJSONPeerSet("test_data", true)
@@ -49,6 +60,7 @@ func TestInitStore(t *testing.T) {
 	if err := huron.initStore(); err != nil {
 		t.Fatal(err)
 	}
+	huron.Store.Close()
 
 	huron2 := NewHuron(conf)
 
@@ -63,7 +75,7 @@ func TestInitStore(t *testing.T) {
 	}
 	dbFiles := []string{}
 	for _, f := range files {
-		if strings.Contains(f.Name(), "badger_db") {
+		if strings.Contains(f.Name(), filepath.Base(conf.StorePath())) {
 			dbFiles = append(dbFiles, f.Name())
 		}
 	}
@@ -71,3 +83,166 @@ func TestInitStore(t *testing.T) {
 		t.Fatalf("initStore should have created a new db file")
 	}
 }
+
+func TestRestoreStore(t *testing.T) {
+	for _, backend := range []string{BadgerBackend, BoltBackend} {
+		t.Run(backend, func(t *testing.T) {
+			testRestoreStore(backend, t)
+		})
+	}
+}
+
+func testRestoreStore(backend string, t *testing.T) {
+	os.RemoveAll("test_data")
+	os.Mkdir("test_data", os.ModeDir|0777)
+	defer os.RemoveAll("test_data")
+
+	conf := NewDefaultConfig()
+	conf.DataDir = "test_data"
+	conf.StoreBackend = backend
+
+	newPeerSet := func(n int) *peers.PeerSet {
+		peerSlice := []*peers.Peer{}
+		for i := 0; i < n; i++ {
+			key, _ := bkeys.GenerateECDSAKey()
+			peerSlice = append(peerSlice, peers.NewPeer(bkeys.PublicKeyHex(&key.PublicKey), fmt.Sprintf("addr%d", i), ""))
+		}
+		return peers.NewPeerSet(peerSlice)
+	}
+
+	genesis := newPeerSet(3)
+	if err := peers.NewJSONPeerSet("test_data", false).Write(genesis.Peers); err != nil {
+		t.Fatal(err)
+	}
+
+	var store h.Store
+	var err error
+	if backend == BoltBackend {
+		store, err = h.NewBoltStore(conf.NodeConfig.CacheSize, conf.StorePath())
+	} else {
+		store, err = h.NewBadgerStore(conf.NodeConfig.CacheSize, conf.StorePath())
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetPeerSet(0, genesis); err != nil {
+		t.Fatal(err)
+	}
+
+	var backup bytes.Buffer
+	if err := store.(h.Backuper).Backup(&backup); err != nil {
+		t.Fatal(err)
+	}
+
+	// The backup can only be restored to a persistent backend
+	inmem := *conf
+	inmem.StoreBackend = InmemBackend
+	if _, err := RestoreStore(&inmem, bytes.NewReader(backup.Bytes())); err == nil {
+		t.Fatalf("Restoring to the inmem backend should fail")
+	}
+
+	// The store is in use
+	if _, err := RestoreStore(conf, bytes.NewReader(backup.Bytes())); err == nil {
+		t.Fatalf("Restoring over an open store should fail")
+	}
+	store.Close()
+
+	replaced, err := RestoreStore(conf, bytes.NewReader(backup.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(replaced); err != nil {
+		t.Fatalf("The replaced store should have been kept: %v", err)
+	}
+
+	// The backup belongs to another network
+	if err := peers.NewJSONPeerSet("test_data", false).Write(newPeerSet(3).Peers); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RestoreStore(conf, bytes.NewReader(backup.Bytes())); err == nil {
+		t.Fatalf("Restoring a backup with other genesis peers should fail")
+	}
+
+	// The store path is not a database of the backend: a file instead of a
+	// badger directory, or a directory instead of a bbolt file
+	other := *conf
+	other.DataDir = "test_data/other"
+	os.Mkdir(other.DataDir, os.ModeDir|0777)
+	if err := peers.NewJSONPeerSet(other.DataDir, false).Write(genesis.Peers); err != nil {
+		t.Fatal(err)
+	}
+	if backend == BoltBackend {
+		err = os.Mkdir(other.StorePath(), os.ModeDir|0777)
+	} else {
+		err = ioutil.WriteFile(other.StorePath(), []byte("not a database"), 0600)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RestoreStore(&other, bytes.NewReader(backup.Bytes())); err == nil {
+		t.Fatalf("Restoring over something which is not a %s database should fail", backend)
+	}
+
+	restored, err := openDBStore(conf, conf.StorePath(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+
+	peerSet, err := restored.DBGetPeerSet(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if peerSet.Hex() != genesis.Hex() {
+		t.Fatalf("Restored genesis peers should be %s, not %s", genesis.Hex(), peerSet.Hex())
+	}
+}
+
+func TestInitEncryptedStore(t *testing.T) {
+	os.RemoveAll("test_data")
+	os.Mkdir("test_data", os.ModeDir|0777)
+	defer os.RemoveAll("test_data")
+
+	conf := NewDefaultConfig()
+	conf.DataDir = "test_data"
+	conf.StoreBackend = BadgerBackend
+	conf.EncryptionPassphrase = "passphrase"
+	conf.NodeConfig.Bootstrap = true
+
+	huron := NewHuron(conf)
+	if err := huron.initEncryption(); err != nil {
+		t.Fatal(err)
+	}
+	if err := huron.initStore(); err != nil {
+		t.Fatal(err)
+	}
+	huron.Store.Close()
+
+	if _, err := os.Stat(conf.SaltFile()); err != nil {
+		t.Fatalf("A salt should have been created: %v", err)
+	}
+
+	// The same passphrase opens the store
+	conf2 := *conf
+	conf2.NodeConfig.EncryptionKey = nil
+	huron2 := NewHuron(&conf2)
+	if err := huron2.initEncryption(); err != nil {
+		t.Fatal(err)
+	}
+	if err := huron2.initStore(); err != nil {
+		t.Fatal(err)
+	}
+	huron2.Store.Close()
+
+	// Another passphrase does not
+	conf3 := *conf
+	conf3.NodeConfig.EncryptionKey = nil
+	conf3.EncryptionPassphrase = "another passphrase"
+	huron3 := NewHuron(&conf3)
+	if err := huron3.initEncryption(); err != nil {
+		t.Fatal(err)
+	}
+	if err := huron3.initStore(); err == nil {
+		t.Fatalf("Opening the store with another passphrase should fail")
+	}
+}
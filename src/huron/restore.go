@@ -0,0 +1,181 @@
+package huron
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/abassian/huron/src/crypto/encryption"
+	h "github.com/abassian/huron/src/hashgraph"
+	"github.com/abassian/huron/src/peers"
+)
+
+// RestoreStore replaces the store of the configured backend with a backup
+// written by the Backup method of that backend's store. The backup is first loaded next to the
+// store's path, and only replaces it if it has the current schema version and
+// the genesis peer-set of the data directory. The node must not be running.
+// The replaced store is renamed following the backupFileName convention, and
+// its new path is returned, or an empty string if there was no store.
+func RestoreStore(conf *HuronConfig, r io.Reader) (string, error) {
+	dbPath, err := restoreTarget(conf)
+	if err != nil {
+		return "", err
+	}
+
+	genesisPeers, err := loadGenesisPeers(conf.DataDir)
+	if err != nil {
+		return "", fmt.Errorf("Reading the genesis peers of %s: %v", conf.DataDir, err)
+	}
+
+	key, err := conf.EncryptionKey()
+	if err != nil {
+		return "", err
+	}
+
+	// Badger and bbolt refuse to open a database which is in use
+	exists := false
+	if _, err := os.Stat(dbPath); err == nil {
+		exists = true
+
+		store, err := openDBStore(conf, dbPath, key)
+		if err != nil {
+			if !isStoreVersionError(err) {
+				return "", fmt.Errorf("Cannot open %s, it may be in use: %v", dbPath, err)
+			}
+		} else {
+			store.Close()
+		}
+	}
+
+	restorePath := filepath.Clean(dbPath) + "--restore"
+	if err := os.RemoveAll(restorePath); err != nil {
+		return "", err
+	}
+
+	conf.Logger.WithField("path", restorePath).Debug("Loading backup")
+
+	restore := h.RestoreBadgerStore
+	if conf.Backend() == BoltBackend {
+		restore = h.RestoreBoltStore
+	}
+
+	if err := restore(r, restorePath); err != nil {
+		os.RemoveAll(restorePath)
+		return "", err
+	}
+
+	if err := checkGenesisPeers(conf, restorePath, genesisPeers, key); err != nil {
+		os.RemoveAll(restorePath)
+		return "", err
+	}
+
+	backup := ""
+	if exists {
+		backup = backupFileName(dbPath)
+		if err := os.Rename(dbPath, backup); err != nil {
+			os.RemoveAll(restorePath)
+			return "", err
+		}
+		conf.Logger.WithField("path", backup).Debug("Created backup")
+	}
+
+	if err := os.Rename(restorePath, dbPath); err != nil {
+		return backup, err
+	}
+
+	return backup, nil
+}
+
+// restoreTarget returns the path of the store replaced by RestoreStore. The
+// backend must be able to load the backup, and the path, if it exists, must be
+// a database of that backend.
+func restoreTarget(conf *HuronConfig) (string, error) {
+	backend := conf.Backend()
+	if backend != BadgerBackend && backend != BoltBackend {
+		return "", fmt.Errorf("Backups cannot be restored to the %s backend", backend)
+	}
+
+	path := conf.StorePath()
+
+	// A badger database is a directory, a bbolt database a single file
+	info, err := os.Stat(path)
+	if err == nil && info.IsDir() != (backend == BadgerBackend) {
+		return "", fmt.Errorf("%s is not a %s database", path, backend)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// openDBStore opens the database in path with the configured backend
+func openDBStore(conf *HuronConfig, path string, key *encryption.Key) (*h.DBStore, error) {
+	if conf.Backend() == BoltBackend {
+		store, err := h.NewEncryptedBoltStore(conf.NodeConfig.CacheSize, path, key)
+		if err != nil {
+			return nil, err
+		}
+		return store.DBStore, nil
+	}
+
+	store, err := h.NewEncryptedBadgerStore(conf.NodeConfig.CacheSize, path, key)
+	if err != nil {
+		return nil, err
+	}
+	return store.DBStore, nil
+}
+
+// loadGenesisPeers reads the genesis peer-set like initPeers, from
+// peers.genesis.json, or from peers.json if there is no genesis file
+func loadGenesisPeers(dataDir string) (*peers.PeerSet, error) {
+	genesisPeers, err := peers.NewJSONPeerSet(dataDir, false).PeerSet()
+	if err == nil {
+		return genesisPeers, nil
+	}
+	return peers.NewJSONPeerSet(dataDir, true).PeerSet()
+}
+
+// isStoreVersionError returns true if a store could not be opened because of
+// its schema version or encryption, rather than because it is in use
+func isStoreVersionError(err error) bool {
+	switch err.(type) {
+	case h.SchemaError, h.EncryptionError:
+		return true
+	}
+	return false
+}
+
+// checkGenesisPeers verifies that the store in path has the given genesis
+// peer-set, which the hashgraph records at round 0. key is the encryption key
+// of the store, which must match the key of the backup.
+func checkGenesisPeers(conf *HuronConfig, path string, genesisPeers *peers.PeerSet, key *encryption.Key) error {
+	store, err := openDBStore(conf, path, key)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	backupPeers, err := store.DBGetPeerSet(0)
+	if err != nil {
+		return fmt.Errorf("Backup has no genesis peer-set: %v", err)
+	}
+
+	backupHash, err := backupPeers.Hash()
+	if err != nil {
+		return err
+	}
+
+	genesisHash, err := genesisPeers.Hash()
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(backupHash, genesisHash) {
+		return fmt.Errorf("Backup genesis peer-set %s does not match %s", backupPeers.Hex(), genesisPeers.Hex())
+	}
+
+	return nil
+}
@@ -16,3 +16,11 @@ type CommitHandler interface {
 type ExceptionHandler interface {
 	OnException(string)
 }
+
+// NotificationHandler receives the node's notifications. notificationType is
+// one of StateChanged, RoundDecided, BlockCommitted, ValidatorsChanged,
+// JoinAccepted, JoinRefused or LeaveCompleted, and notification is the JSON
+// encoded notification.
+type NotificationHandler interface {
+	OnNotification(notificationType string, notification []byte)
+}
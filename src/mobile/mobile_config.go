@@ -12,9 +12,11 @@ type MobileConfig struct {
 	TCPTimeout     int    //TCP timeout in milliseconds
 	MaxPool        int    //Max number of pooled connections
 	CacheSize      int    //Number of items in LRU cache
+	CacheMemory    int    //Memory budget of the LRU caches in MB, overriding CacheSize
 	SyncLimit      int    //Max Events per sync
 	EnableFastSync bool   //Enable fast sync
-	Store          bool   //Use badger store
+	Store          bool   //Use badger store (deprecated, see StoreBackend)
+	StoreBackend   string //inmem, hybrid, badger or bolt
 	LogLevel       string //debug, info, warn, error, fatal, panic
 	Moniker        string //optional name
 }
@@ -64,12 +66,16 @@ func (c *MobileConfig) toHuronConfig() *huron.HuronConfig {
 
 	huronConfig.MaxPool = c.MaxPool
 	huronConfig.Store = c.Store
+	if c.StoreBackend != "" {
+		huronConfig.StoreBackend = c.StoreBackend
+	}
 	huronConfig.LogLevel = c.LogLevel
 	huronConfig.Moniker = c.Moniker
 
 	huronConfig.NodeConfig.HeartbeatTimeout = time.Duration(c.Heartbeat) * time.Millisecond
 	huronConfig.NodeConfig.TCPTimeout = time.Duration(c.TCPTimeout) * time.Millisecond
 	huronConfig.NodeConfig.CacheSize = c.CacheSize
+	huronConfig.NodeConfig.CacheMemory = c.CacheMemory
 	huronConfig.NodeConfig.SyncLimit = c.SyncLimit
 	huronConfig.NodeConfig.EnableFastSync = c.EnableFastSync
 
@@ -2,13 +2,15 @@ package mobile
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"strings"
+	"sync"
 
-	"github.com/abassian/huron/src/huron"
 	"github.com/abassian/huron/src/crypto/keys"
+	"github.com/abassian/huron/src/huron"
 	"github.com/abassian/huron/src/node"
 	"github.com/abassian/huron/src/peers"
 	"github.com/abassian/huron/src/proxy"
@@ -16,12 +18,21 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// notificationBufferSize is the number of notifications buffered before they
+// are dropped
+const notificationBufferSize = 100
+
 // Node ...
 type Node struct {
 	nodeID uint32
 	node   *node.Node
 	proxy  proxy.AppProxy
 	logger *logrus.Logger
+
+	// subscription is replaced and closed by Subscribe and Unsubscribe, which
+	// may be called concurrently from the mobile app
+	subscription     *node.Subscription
+	subscriptionLock sync.Mutex
 }
 
 // New initializes Node struct
@@ -96,19 +107,64 @@ func New(privKey string,
 func (n *Node) Run(async bool) {
 	if async {
 		n.node.RunAsync(true)
-	} else {
-		n.node.Run(true)
+	} else if err := n.node.Run(context.Background(), true); err != nil {
+		n.logger.WithError(err).Error("Run")
 	}
 }
 
 // Leave ...
 func (n *Node) Leave() {
-	n.node.Leave()
+	if err := n.node.Leave(context.Background()); err != nil {
+		n.logger.WithError(err).Error("Leave")
+	}
 }
 
 // Shutdown ...
 func (n *Node) Shutdown() {
-	n.node.Shutdown()
+	if err := n.node.Shutdown(); err != nil {
+		n.logger.WithError(err).Error("Shutdown")
+	}
+}
+
+// Subscribe relays the node's notifications to handler until Unsubscribe is
+// called or the node shuts down. It replaces any previous subscription.
+func (n *Node) Subscribe(handler NotificationHandler) {
+	n.subscriptionLock.Lock()
+	defer n.subscriptionLock.Unlock()
+
+	n.unsubscribe()
+
+	sub := n.node.Subscribe(notificationBufferSize)
+	n.subscription = sub
+
+	go func() {
+		for notif := range sub.Notifications() {
+			data, err := json.Marshal(notif)
+			if err != nil {
+				n.logger.WithError(err).Error("Marshalling Notification")
+				continue
+			}
+
+			handler.OnNotification(notif.Type.String(), data)
+		}
+	}()
+}
+
+// Unsubscribe stops relaying notifications
+func (n *Node) Unsubscribe() {
+	n.subscriptionLock.Lock()
+	defer n.subscriptionLock.Unlock()
+
+	n.unsubscribe()
+}
+
+// unsubscribe closes the current subscription. The subscriptionLock must be
+// held.
+func (n *Node) unsubscribe() {
+	if n.subscription != nil {
+		n.subscription.Close()
+		n.subscription = nil
+	}
 }
 
 // SubmitTx ...
@@ -0,0 +1,61 @@
+package net
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	rpcDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "huron",
+		Subsystem: "net",
+		Name:      "rpc_duration_seconds",
+		Help:      "Duration of outgoing RPCs, by RPC type and target peer.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+	}, []string{"rpc", "peer"})
+
+	rpcErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "huron",
+		Subsystem: "net",
+		Name:      "rpc_errors_total",
+		Help:      "Number of failed outgoing RPCs, by RPC type and target peer.",
+	}, []string{"rpc", "peer"})
+
+	pooledConnections = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "huron",
+		Subsystem: "net",
+		Name:      "pooled_connections",
+		Help:      "Number of idle connections in the connection pools.",
+	})
+
+	dialedConnections = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "huron",
+		Subsystem: "net",
+		Name:      "dialed_connections_total",
+		Help:      "Number of connections dialed because none was available in the pool.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(
+		rpcDuration,
+		rpcErrors,
+		pooledConnections,
+		dialedConnections,
+	)
+}
+
+// rpcName returns the name of an RPC type, as used in metric labels
+func rpcName(rpcType uint8) string {
+	switch rpcType {
+	case rpcSync:
+		return "sync"
+	case rpcEagerSync:
+		return "eager_sync"
+	case rpcFastForward:
+		return "fast_forward"
+	case rpcJoin:
+		return "join"
+	default:
+		return "unknown"
+	}
+}
@@ -123,10 +123,25 @@ func (n *NetworkTransport) Close() error {
 		close(n.shutdownCh)
 		n.stream.Close()
 		n.shutdown = true
+		n.releasePool()
 	}
 	return nil
 }
 
+// releasePool closes the pooled connections
+func (n *NetworkTransport) releasePool() {
+	n.connPoolLock.Lock()
+	defer n.connPoolLock.Unlock()
+
+	for target, conns := range n.connPool {
+		for _, conn := range conns {
+			conn.Release()
+			pooledConnections.Dec()
+		}
+		delete(n.connPool, target)
+	}
+}
+
 // Consumer implements the Transport interface.
 func (n *NetworkTransport) Consumer() <-chan RPC {
 	return n.consumeCh
@@ -161,6 +176,7 @@ func (n *NetworkTransport) getPooledConn(target string) *netConn {
 	num := len(conns)
 	conn, conns[num-1] = conns[num-1], nil
 	n.connPool[target] = conns[:num-1]
+	pooledConnections.Dec()
 	return conn
 }
 
@@ -176,6 +192,7 @@ func (n *NetworkTransport) getConn(target string, timeout time.Duration) (*netCo
 	if err != nil {
 		return nil, err
 	}
+	dialedConnections.Inc()
 
 	// Wrap the conn
 	netConn := &netConn{
@@ -202,6 +219,7 @@ func (n *NetworkTransport) returnConn(conn *netConn) {
 
 	if !n.IsShutdown() && len(conns) < n.maxPool {
 		n.connPool[key] = append(conns, conn)
+		pooledConnections.Inc()
 	} else {
 		conn.Release()
 	}
@@ -228,7 +246,16 @@ func (n *NetworkTransport) Join(target string, args *JoinRequest, resp *JoinResp
 }
 
 // genericRPC handles a simple request/response RPC.
-func (n *NetworkTransport) genericRPC(target string, rpcType uint8, timeout time.Duration, args interface{}, resp interface{}) error {
+func (n *NetworkTransport) genericRPC(target string, rpcType uint8, timeout time.Duration, args interface{}, resp interface{}) (err error) {
+	start := time.Now()
+	defer func() {
+		if err != nil {
+			rpcErrors.WithLabelValues(rpcName(rpcType), target).Inc()
+		} else {
+			rpcDuration.WithLabelValues(rpcName(rpcType), target).Observe(time.Since(start).Seconds())
+		}
+	}()
+
 	// Get a conn
 	conn, err := n.getConn(target, timeout)
 	if err != nil {
@@ -8,6 +8,7 @@ import (
 
 	"github.com/abassian/huron/src/common"
 	"github.com/abassian/huron/src/hashgraph"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestNetworkTransport_PooledConn(t *testing.T) {
@@ -68,6 +69,8 @@ func TestNetworkTransport_PooledConn(t *testing.T) {
 		}
 	}()
 
+	pooled := testutil.ToFloat64(pooledConnections)
+
 	// Transport 2 makes outbound request, 3 conn pool
 	trans2, err := NewTCPTransport("127.0.0.1:0", nil, 3, time.Second, 2*time.Second, common.NewTestLogger(t))
 	if err != nil {
@@ -105,4 +108,16 @@ func TestNetworkTransport_PooledConn(t *testing.T) {
 	if len(trans2.connPool[addr]) != 3 {
 		t.Fatalf("Expected 3 pooled conns!")
 	}
+	if g := testutil.ToFloat64(pooledConnections); g != pooled+3 {
+		t.Fatalf("Pooled connections gauge should be %v, not %v", pooled+3, g)
+	}
+
+	// Closing the transport releases the pooled conns
+	trans2.Close()
+	if len(trans2.connPool[addr]) != 0 {
+		t.Fatalf("Expected no pooled conns after Close")
+	}
+	if g := testutil.ToFloat64(pooledConnections); g != pooled {
+		t.Fatalf("Pooled connections gauge should be %v after Close, not %v", pooled, g)
+	}
 }
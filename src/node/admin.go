@@ -0,0 +1,99 @@
+package node
+
+import (
+	"fmt"
+	"io"
+	"runtime/pprof"
+	"sync/atomic"
+
+	hg "github.com/abassian/huron/src/hashgraph"
+	"github.com/sirupsen/logrus"
+)
+
+/*******************************************************************************
+Runtime operations, used by the admin endpoints of the service
+*******************************************************************************/
+
+// SetLogLevel changes the level of the node's logger at runtime. The logger
+// is usually shared with the other components of Huron.
+func (n *Node) SetLogLevel(level string) error {
+	l, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	n.conf.Logger.SetLevel(l)
+
+	n.logger.WithField("level", l.String()).Info("Log level changed")
+
+	return nil
+}
+
+// PauseGossip stops the node from initiating gossip. It keeps answering the
+// requests of other nodes.
+func (n *Node) PauseGossip() {
+	atomic.StoreUint32(&n.gossipPaused, 1)
+	n.logger.Info("Gossip paused")
+}
+
+// ResumeGossip restarts gossip after PauseGossip
+func (n *Node) ResumeGossip() {
+	atomic.StoreUint32(&n.gossipPaused, 0)
+	n.logger.Info("Gossip resumed")
+}
+
+// IsGossipPaused returns true if gossip is paused
+func (n *Node) IsGossipPaused() bool {
+	return atomic.LoadUint32(&n.gossipPaused) == 1
+}
+
+// ForceFastSync makes a Babbling node fast-forward to the latest state of its
+// peers, whether fast-sync is enabled or not.
+func (n *Node) ForceFastSync() error {
+	if state := n.getState(); state != Babbling {
+		return fmt.Errorf("Cannot fast-sync in %s state", state)
+	}
+
+	n.logger.Info("Forcing fast-sync")
+
+	n.setState(CatchingUp)
+
+	return nil
+}
+
+// WriteProfile writes the runtime/pprof profile with the given name, for
+// example "goroutine" or "heap". debug is passed to pprof.Profile.WriteTo.
+func (n *Node) WriteProfile(name string, debug int, w io.Writer) error {
+	profile := pprof.Lookup(name)
+	if profile == nil {
+		return fmt.Errorf("Unknown profile %s", name)
+	}
+
+	return profile.WriteTo(w, debug)
+}
+
+// CompactStore reclaims the space used by obsolete data in the node's Store,
+// if it supports compaction.
+func (n *Node) CompactStore() error {
+	store, ok := n.core.hg.Store.(hg.Compactor)
+	if !ok {
+		return fmt.Errorf("Store does not support compaction")
+	}
+
+	n.logger.Info("Compacting Store")
+
+	return store.Compact()
+}
+
+// BackupStore writes a backup of the node's Store to w, without stopping the
+// node, if the Store supports it.
+func (n *Node) BackupStore(w io.Writer) error {
+	store, ok := n.core.hg.Store.(hg.Backuper)
+	if !ok {
+		return fmt.Errorf("Store does not support backups")
+	}
+
+	n.logger.Info("Backing up Store")
+
+	return store.Backup(w)
+}
@@ -0,0 +1,88 @@
+package node
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/abassian/huron/src/common"
+	"github.com/sirupsen/logrus"
+)
+
+func TestAdmin(t *testing.T) {
+	logger := common.NewTestLogger(t)
+	keys, peers := initPeers(t, 4)
+
+	genesisPeerSet := clonePeerSet(t, peers.Peers)
+
+	nodes := initNodes(keys, peers, genesisPeerSet, 1000, 400, 5, false, "inmem", 10*time.Millisecond, logger, t)
+	defer shutdownNodes(nodes)
+
+	node := nodes[0]
+
+	if err := node.SetLogLevel("nonsense"); err == nil {
+		t.Fatalf("SetLogLevel should refuse an invalid level")
+	}
+	if err := node.SetLogLevel("warn"); err != nil {
+		t.Fatal(err)
+	}
+	if l := node.conf.Logger.GetLevel(); l != logrus.WarnLevel {
+		t.Fatalf("Log level should be warn, not %s", l)
+	}
+
+	var buf bytes.Buffer
+	if err := node.WriteProfile("goroutine", 1, &buf); err != nil || buf.Len() == 0 {
+		t.Fatalf("WriteProfile should dump goroutines: %v", err)
+	}
+	if err := node.WriteProfile("nonsense", 0, &buf); err == nil {
+		t.Fatalf("WriteProfile should refuse unknown profiles")
+	}
+
+	if err := node.CompactStore(); err == nil {
+		t.Fatalf("InmemStore should not support compaction")
+	}
+
+	err := gossip(nodes, 10, false, 6*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//A node whose gossip is paused falls behind
+	node.PauseGossip()
+	if !node.IsGossipPaused() {
+		t.Fatalf("Gossip should be paused")
+	}
+
+	err = bombardAndWait(nodes[1:], 20, 6*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node.ResumeGossip()
+
+	//and catches up by fast-syncing
+	if err := node.ForceFastSync(); err != nil {
+		t.Fatal(err)
+	}
+
+	err = bombardAndWait(nodes[1:], 30, 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//The blocks before the fast-sync anchor are not in the node's Store, so
+	//only its last block index is checked.
+	timeout := time.After(10 * time.Second)
+	for node.GetLastBlockIndex() < 30 {
+		select {
+		case <-timeout:
+			t.Fatalf("Timeout waiting for node to reach block 30, currently %d", node.GetLastBlockIndex())
+		default:
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if state := node.getState(); state != Babbling {
+		t.Fatalf("Node should be Babbling after fast-sync, not %s", state)
+	}
+}
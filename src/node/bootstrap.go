@@ -0,0 +1,94 @@
+package node
+
+import (
+	hg "github.com/abassian/huron/src/hashgraph"
+	"github.com/sirupsen/logrus"
+)
+
+// Names of the bootstrap modes, as used in Config.BootstrapMode
+const (
+	FastBootstrap = "fast"
+	FullBootstrap = "full"
+)
+
+// bootstrap loads the hashgraph from the database. In fast mode, the hashgraph
+// is reset from the newest Block for which a snapshot is stored, the App is
+// restored from that snapshot, and only the subsequent events are replayed. In
+// full mode, or when no snapshot is suitable, all the events are replayed,
+// starting from the PruneBase if the Store was pruned. A warning is logged when
+// fast mode falls back to a full replay.
+func (n *Node) bootstrap() error {
+	if n.conf.BootstrapMode != FullBootstrap {
+		for _, index := range n.snapshots.Indexes() {
+			frame, err := n.core.hg.FastBootstrapFrame(index)
+			if err != nil {
+				n.logger.WithError(err).Debugf("Snapshot %d is not a suitable bootstrap base", index)
+				continue
+			}
+
+			snapshot, err := n.snapshots.Get(index)
+			if err != nil {
+				n.logger.WithError(err).Errorf("Reading Snapshot %d", index)
+				continue
+			}
+
+			return n.fastBootstrap(index, frame, snapshot)
+		}
+	}
+
+	base, err := n.getPruneBase()
+	if err != nil {
+		return err
+	}
+
+	// The events which precede the PruneBase were deleted, so the PruneBase
+	// is the oldest possible base
+	if base != nil {
+		frame, err := n.core.hg.FastBootstrapFrame(base.Block)
+		if err != nil {
+			return err
+		}
+
+		return n.fastBootstrap(base.Block, frame, base.Snapshot)
+	}
+
+	if err := n.core.Bootstrap(); err != nil {
+		return err
+	}
+
+	// An empty Store is not worth a warning
+	if n.conf.BootstrapMode != FullBootstrap && n.core.GetLastBlockIndex() >= 0 {
+		n.logFullReplay()
+	}
+
+	return nil
+}
+
+// logFullReplay warns that fast bootstrap found no base and replayed all the
+// events. Without periodic snapshots or pruning, no base is ever stored, and
+// every fast bootstrap is a full replay.
+func (n *Node) logFullReplay() {
+	entry := n.logger.WithFields(logrus.Fields{
+		"snapshot_interval": n.conf.SnapshotInterval,
+		"prune_depth":       n.conf.PruneDepth,
+	})
+
+	if n.conf.SnapshotInterval == 0 && n.conf.PruneDepth == 0 {
+		entry.Warning("Fast bootstrap requires snapshot-interval or prune-depth. Replayed all the events")
+		return
+	}
+
+	entry.Warning("No snapshot or PruneBase to fast bootstrap from. Replayed all the events")
+}
+
+// fastBootstrap restores the App from the snapshot of a Block and bootstraps
+// the hashgraph from that Block
+func (n *Node) fastBootstrap(blockIndex int, frame *hg.Frame, snapshot []byte) error {
+	n.logger.WithField("block", blockIndex).Debug("Restoring snapshot")
+
+	if err := n.proxy.Restore(snapshot); err != nil {
+		return err
+	}
+
+	return n.core.FastBootstrap(blockIndex, frame)
+}
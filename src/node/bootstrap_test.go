@@ -0,0 +1,146 @@
+package node
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abassian/huron/src/common"
+	"github.com/abassian/huron/src/proxy/dummy"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestFastBootstrap(t *testing.T) {
+	os.RemoveAll("test_data")
+	os.Mkdir("test_data", os.ModeDir|0777)
+
+	logger := common.NewTestLogger(t)
+	keys, peers := initPeers(t, 4)
+	genesisPeerSet := clonePeerSet(t, peers.Peers)
+
+	nodes := initNodes(keys, peers, genesisPeerSet, 1000, 1000, 10, false, "badger", 6*time.Millisecond, logger, t)
+
+	if err := gossip(nodes, 10, true, 6*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	checkGossip(nodes, 0, t)
+
+	//Store a snapshot of Block 5 on every node
+	const base = 5
+	for i, n := range nodes {
+		n.conf.SnapshotDir = filepath.Join("test_data", fmt.Sprintf("snapshots%d", i))
+		n.conf.SnapshotInterval = 1000
+
+		n.snapshots.dir = n.conf.SnapshotDir
+		n.snapshots.interval = n.conf.SnapshotInterval
+		if err := n.snapshots.Init(false); err != nil {
+			t.Fatal(err)
+		}
+		if err := n.snapshots.Take(base); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//The first node verifies its database with a full replay
+	nodes[0].conf.BootstrapMode = FullBootstrap
+
+	newNodes := recycleNodes(nodes, logger, t)
+
+	for i, n := range newNodes {
+		committed := len(n.proxy.(*dummy.InmemDummyClient).GetCommittedTransactions())
+
+		//Transactions are only committed again from the base Block onwards,
+		//unless the snapshot was not a suitable base and the node fell back to
+		//a full replay
+		full := countTransactions(n, 0, t)
+		fast := countTransactions(n, base+1, t)
+
+		if i == 0 && committed != full {
+			t.Fatalf("Node 0 should have committed %d transactions, not %d", full, committed)
+		}
+		if committed != fast && committed != full {
+			t.Fatalf("Node %d should have committed %d transactions, not %d", i, fast, committed)
+		}
+	}
+
+	//The new nodes keep running while their Blocks are checked, because the
+	//Blocks which precede the base are only in the DB, which is closed on
+	//shutdown
+	defer shutdownNodes(newNodes)
+	if err := gossip(newNodes, 20, false, 6*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	checkGossip(newNodes, 0, t)
+	checkGossip([]*Node{nodes[0], newNodes[1]}, 0, t)
+}
+
+//countTransactions counts the transactions in the node's Blocks from the given
+//index to the last one
+func countTransactions(n *Node, from int, t *testing.T) int {
+	count := 0
+	for b := from; b <= n.core.GetLastBlockIndex(); b++ {
+		block, err := n.core.hg.Store.GetBlock(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		count += len(block.Transactions())
+	}
+	return count
+}
+
+func TestFastBootstrapWithoutBase(t *testing.T) {
+	os.RemoveAll("test_data")
+	os.Mkdir("test_data", os.ModeDir|0777)
+
+	logger := common.NewTestLogger(t)
+	keys, peers := initPeers(t, 4)
+	genesisPeerSet := clonePeerSet(t, peers.Peers)
+
+	nodes := initNodes(keys, peers, genesisPeerSet, 1000, 1000, 10, false, "badger", 6*time.Millisecond, logger, t)
+
+	if err := gossip(nodes, 5, true, 6*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	//Without snapshots or pruning, fast bootstrap falls back to a full replay
+	//and says so
+	hook := test.NewLocal(logger)
+
+	newNodes := recycleNodes(nodes, logger, t)
+	defer shutdownNodes(newNodes)
+
+	warnings := 0
+	for _, e := range hook.AllEntries() {
+		if e.Level == logrus.WarnLevel && strings.Contains(e.Message, "Replayed all the events") {
+			warnings++
+		}
+	}
+
+	if warnings != len(newNodes) {
+		t.Fatalf("Every node should warn about the full replay, not %d", warnings)
+	}
+
+	for i, n := range newNodes {
+		committed := len(n.proxy.(*dummy.InmemDummyClient).GetCommittedTransactions())
+		if full := countTransactions(n, 0, t); committed != full {
+			t.Fatalf("Node %d should have committed %d transactions, not %d", i, full, committed)
+		}
+	}
+}
+
+func TestBootstrapModeValidation(t *testing.T) {
+	for _, mode := range []string{FastBootstrap, FullBootstrap, ""} {
+		if err := (&Config{BootstrapMode: mode}).Validate(); err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+	}
+
+	if err := (&Config{BootstrapMode: "fulll"}).Validate(); err == nil {
+		t.Fatalf("A Config with an unknown bootstrap mode should be refused")
+	}
+}
@@ -1,10 +1,13 @@
 package node
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/abassian/huron/src/common"
+	"github.com/abassian/huron/src/crypto/encryption"
+	hg "github.com/abassian/huron/src/hashgraph"
 	"github.com/sirupsen/logrus"
 )
 
@@ -17,7 +20,66 @@ type Config struct {
 	SyncLimit        int           `mapstructure:"sync-limit"`
 	EnableFastSync   bool          `mapstructure:"fast-sync"`
 	Bootstrap        bool          `mapstructure:"bootstrap"`
-	Logger           *logrus.Logger
+	Observer         bool          `mapstructure:"observer"`
+
+	// BootstrapMode is how the hashgraph is loaded from the database when
+	// Bootstrap is set: fast, from the newest stored snapshot, or full, by
+	// replaying all the events. Full replay can be used to verify the database.
+	// It defaults to fast. Fast mode needs a base, which is only stored with a
+	// SnapshotInterval or a PruneDepth; without one, it falls back to a full
+	// replay, with a warning.
+	BootstrapMode string `mapstructure:"bootstrap-mode"`
+
+	// PeerSelector is the strategy used to select the peers to gossip with:
+	// random, latency, unknown-events or round-robin.
+	PeerSelector string `mapstructure:"peer-selector"`
+
+	// FanOut is the number of peers to which new self-events are pushed
+	// eagerly, in parallel, on top of regular gossip. 0 disables fan-out.
+	FanOut int `mapstructure:"fan-out"`
+
+	// SnapshotInterval is the number of blocks between two snapshots taken
+	// from the application. 0 disables periodic snapshots, in which case
+	// FastForwardRequests are served with on-demand snapshots.
+	SnapshotInterval int `mapstructure:"snapshot-interval"`
+	// SnapshotRetention is the max number of snapshots kept in SnapshotDir.
+	// 0 means no limit.
+	SnapshotRetention int `mapstructure:"snapshot-retention"`
+	// SnapshotDir is the directory where periodic snapshots are stored.
+	SnapshotDir string
+	// EncryptionKey encrypts the snapshots stored in SnapshotDir. They are
+	// stored in plaintext if it is nil.
+	EncryptionKey *encryption.Key
+
+	// ReadyMaxRoundAge is the max time since the last consensus round for the
+	// node to be ready, while it has pending work. 0 disables the check.
+	ReadyMaxRoundAge time.Duration `mapstructure:"ready-max-round-age"`
+	// ReadyMaxPeerSilence is how long a peer is considered in contact after
+	// the last successful sync with it. The node is only ready if it is in
+	// contact with a supermajority of validators. 0 disables the check.
+	ReadyMaxPeerSilence time.Duration `mapstructure:"ready-max-peer-silence"`
+
+	// StallTimeout is the time without a new consensus round, while there is
+	// pending work, after which the watchdog logs a diagnostic of consensus. 0
+	// disables the watchdog.
+	StallTimeout time.Duration `mapstructure:"stall-timeout"`
+
+	// PruneDepth is the number of rounds kept behind the FastForward base
+	// when pruning the Store. It must leave enough history for the Events of
+	// lagging peers, whose parents cannot be pruned, to be accepted. 0
+	// disables periodic pruning.
+	PruneDepth int `mapstructure:"prune-depth"`
+	// PruneInterval is the time between two periodic prunings.
+	PruneInterval time.Duration `mapstructure:"prune-interval"`
+
+	// CacheMemory is the memory budget, in MB, shared by the LRU caches of the
+	// hashgraph and of its Store. 0 bounds each cache by CacheSize items.
+	CacheMemory int `mapstructure:"cache-memory"`
+	// CacheShares overrides the share of CacheMemory of some caches, eg.
+	// "store.event=40,hashgraph.ancestor=5". Shares are relative weights.
+	CacheShares string `mapstructure:"cache-shares"`
+
+	Logger *logrus.Logger
 }
 
 //NewConfig eturns a new Config Object
@@ -46,13 +108,49 @@ func DefaultConfig() *Config {
 	logger.Level = logrus.DebugLevel
 
 	return &Config{
-		HeartbeatTimeout: 10 * time.Millisecond,
-		TCPTimeout:       1000 * time.Millisecond,
-		JoinTimeout:      10000 * time.Millisecond,
-		CacheSize:        5000,
-		SyncLimit:        1000,
-		Logger:           logger,
+		HeartbeatTimeout:    10 * time.Millisecond,
+		TCPTimeout:          1000 * time.Millisecond,
+		JoinTimeout:         10000 * time.Millisecond,
+		CacheSize:           5000,
+		SyncLimit:           1000,
+		PeerSelector:        RandomSelector,
+		BootstrapMode:       FastBootstrap,
+		SnapshotRetention:   3,
+		ReadyMaxRoundAge:    time.Minute,
+		ReadyMaxPeerSilence: 30 * time.Second,
+		StallTimeout:        time.Minute,
+		PruneInterval:       time.Minute,
+		Logger:              logger,
+	}
+}
+
+//Validate returns an error if a setting of the Config has an invalid value
+func (c *Config) Validate() error {
+	if !validPeerSelector(c.PeerSelector) {
+		return fmt.Errorf("Unknown peer selector %s", c.PeerSelector)
+	}
+
+	switch c.BootstrapMode {
+	case FastBootstrap, FullBootstrap, "":
+	default:
+		return fmt.Errorf("Unknown bootstrap mode %s", c.BootstrapMode)
+	}
+
+	return nil
+}
+
+//CacheConfig returns the config of the hashgraph caches
+func (c *Config) CacheConfig() (hg.CacheConfig, error) {
+	shares, err := hg.ParseCacheShares(c.CacheShares)
+	if err != nil {
+		return hg.CacheConfig{}, err
+	}
+
+	conf := hg.CacheConfig{
+		Budget: int64(c.CacheMemory) << 20,
+		Shares: shares,
 	}
+	return conf, nil
 }
 
 //TestConfig returns a Preset Test Configuration
@@ -1,6 +1,7 @@
 package node
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"sort"
@@ -40,6 +41,13 @@ type Core struct {
 	peerSelector PeerSelector
 	selectorLock sync.Mutex
 
+	// selectorKind is the kind of PeerSelector created by SetPeers
+	selectorKind string
+
+	// peerHealth is shared by the successive peer-selectors, and used to back
+	// off from failing peers.
+	peerHealth *peerHealth
+
 	// Hash and Index of this instance's head Event
 	Head string
 	Seq  int
@@ -58,6 +66,19 @@ type Core struct {
 	// prevent them from having to wait.
 	TargetRound int
 
+	// lastRoundTime is the time at which the last consensus round was decided
+	// or reached by fast-forwarding. It is zero until then.
+	lastRoundTime time.Time
+
+	// workTime is the time at which the node last got pending work after being
+	// idle. It is zero while the node is idle.
+	workTime time.Time
+
+	// lastCommittedBlock is the index of the last Block committed to the App,
+	// or whose state the App was restored to. Blocks are stored before they
+	// are committed, and remain stored if the commit fails. Default -1.
+	lastCommittedBlock int
+
 	// Events that are not tied to this node's Head. This is managed by the Sync
 	// method. If the gossip condition is false (there is nothing interesting to
 	// record), items are added to heads; if the gossip condition is true, items
@@ -85,6 +106,14 @@ type Core struct {
 	// InternalTransactions go through consensus asynchronously.
 	promises map[string]*JoinPromise
 
+	// observer is true when the node follows consensus without being a
+	// validator. An observer never creates SelfEvents.
+	observer bool
+
+	// notifier publishes consensus Notifications to the node's subscribers.
+	// It may be nil.
+	notifier *notifier
+
 	logger *logrus.Entry
 }
 
@@ -112,6 +141,7 @@ func NewCore(
 		validators:              genesisPeers,
 		peers:                   peers,
 		peerSelector:            peerSelector,
+		peerHealth:              newPeerHealth(),
 		transactionPool:         [][]byte{},
 		internalTransactionPool: []hg.InternalTransaction{},
 		selfBlockSignatures:     hg.NewSigPool(),
@@ -123,6 +153,7 @@ func NewCore(
 		AcceptedRound:           -1,
 		RemovedRound:            -1,
 		TargetRound:             -1,
+		lastCommittedBlock:      -1,
 	}
 
 	core.hg = hg.NewHashgraph(store, core.Commit, logEntry)
@@ -175,10 +206,52 @@ func (c *Core) Bootstrap() error {
 	return c.hg.Bootstrap()
 }
 
-// SetPeers sets the peers property and a New RandomPeerSelector
+// FastBootstrap resets the hashgraph from a stored Block and its Frame, as
+// returned by FastBootstrapFrame, and only replays the subsequent events. The
+// validators are reset to the latest validator-set recorded by the Frame,
+// before the replay updates them.
+func (c *Core) FastBootstrap(blockIndex int, frame *hg.Frame) error {
+	c.logger.WithField("block", blockIndex).Debug("Fast Bootstrap")
+
+	last := -1
+	for round, ps := range frame.PeerSets {
+		if round > last {
+			last = round
+			c.validators = peers.NewPeerSet(ps)
+		}
+	}
+
+	//The App was restored to the state of the Block
+	c.lastCommittedBlock = blockIndex
+
+	return c.hg.FastBootstrap(blockIndex)
+}
+
+// IsValidator returns true if the peer belongs to the latest validator-set
+func (c *Core) IsValidator(id uint32) bool {
+	_, ok := c.validators.ByID[id]
+	return ok
+}
+
+// SetPeers sets the peers property and a new PeerSelector of the configured
+// kind
 func (c *Core) SetPeers(ps *peers.PeerSet) {
 	c.peers = ps
-	c.peerSelector = NewRandomPeerSelector(c.peers, c.validator.ID())
+
+	//The kind was checked by Config.Validate, which Node.Init calls before
+	//the node runs
+	selector, err := NewPeerSelector(c.selectorKind, c.peers, c.validator.ID())
+	if err != nil {
+		selector = NewRandomPeerSelector(c.peers, c.validator.ID())
+	}
+
+	if hs, ok := selector.(interface{ setHealth(*peerHealth) }); ok {
+		hs.setHealth(c.peerHealth)
+	}
+
+	c.selectorLock.Lock()
+	c.peerSelector = selector
+	c.selectorLock.Unlock()
 }
 
 /*******************************************************************************
@@ -187,11 +260,57 @@ Busy
 
 // Busy returns a boolean that denotes whether there is incomplete processing
 func (c *Core) Busy() bool {
+	return c.HasPendingWork() ||
+		(c.hg.LastConsensusRound != nil && *c.hg.LastConsensusRound < c.TargetRound)
+}
+
+// HasPendingWork returns true if there are Events with a payload whose
+// consensus order is not determined yet, or pending transactions, internal
+// transactions or block signatures. Without pending work, the node stops
+// creating Events, so no rounds are decided.
+func (c *Core) HasPendingWork() bool {
 	return c.hg.PendingLoadedEvents > 0 ||
 		len(c.transactionPool) > 0 ||
 		len(c.internalTransactionPool) > 0 ||
-		c.selfBlockSignatures.Len() > 0 ||
-		(c.hg.LastConsensusRound != nil && *c.hg.LastConsensusRound < c.TargetRound)
+		c.selfBlockSignatures.Len() > 0
+}
+
+// lastRoundAge returns the time since the last round was decided, or since
+// start before the first round
+func (c *Core) lastRoundAge(start time.Time) time.Duration {
+	if c.lastRoundTime.After(start) {
+		return time.Since(c.lastRoundTime)
+	}
+	return time.Since(start)
+}
+
+// consensusAge returns the time since consensus was last expected to progress,
+// and whether the node has pending work for it to progress on. It is the
+// lastRoundAge, or the time since the node got pending work after being idle
+// if that is shorter, so that an idle network is not stale as soon as a
+// transaction arrives.
+func (c *Core) consensusAge(start time.Time) (time.Duration, bool) {
+	pending := c.trackPendingWork()
+
+	age := c.lastRoundAge(start)
+	if pending && time.Since(c.workTime) < age {
+		age = time.Since(c.workTime)
+	}
+
+	return age, pending
+}
+
+// trackPendingWork records when the node goes from idle to having pending work
+func (c *Core) trackPendingWork() bool {
+	pending := c.HasPendingWork()
+
+	if !pending {
+		c.workTime = time.Time{}
+	} else if c.workTime.IsZero() {
+		c.workTime = time.Now()
+	}
+
+	return pending
 }
 
 /*******************************************************************************
@@ -247,6 +366,11 @@ func (c *Core) Sync(fromID uint32, unknownEvents []hg.WireEvent) error {
 		"target_round":              c.TargetRound,
 	}).Debug("Sync")
 
+	//Observers never record SelfEvents
+	if c.observer {
+		return nil
+	}
+
 	//Create new event with self head and other head only if there are pending
 	//loaded events or the pools are not empty
 	if c.Busy() {
@@ -327,6 +451,11 @@ func (c *Core) SignAndInsertSelfEvent(event *hg.Event) error {
 
 // InsertEventAndRunConsensus Inserts a hashgraph event and runs consensus
 func (c *Core) InsertEventAndRunConsensus(event *hg.Event, setWireInfo bool) error {
+	lastRound := -1
+	if c.hg.LastConsensusRound != nil {
+		lastRound = *c.hg.LastConsensusRound
+	}
+
 	if err := c.hg.InsertEventAndRunConsensus(event, setWireInfo); err != nil {
 		return err
 	}
@@ -334,6 +463,16 @@ func (c *Core) InsertEventAndRunConsensus(event *hg.Event, setWireInfo bool) err
 		c.Head = event.Hex()
 		c.Seq = event.Index()
 	}
+
+	if r := c.hg.LastConsensusRound; r != nil && *r > lastRound {
+		c.lastRoundTime = time.Now()
+
+		c.notifier.publish(Notification{
+			Type:  RoundDecided,
+			Round: *r,
+		})
+	}
+
 	return nil
 }
 
@@ -373,15 +512,33 @@ func (c *Core) FastForward(block *hg.Block, frame *hg.Frame) error {
 		return err
 	}
 
+	c.lastRoundTime = time.Now()
+	c.lastCommittedBlock = block.Index()
+
 	err = c.SetHeadAndSeq()
 	if err != nil {
 		return err
 	}
 
-	// Update peer-selector and validators
-	c.SetPeers(peers.NewPeerSet(frame.Peers))
+	// Update peer-selector and validators. Observers keep gossiping with the
+	// other observers they know of, ie. the peers that are not validators.
+	newPeers := peers.NewPeerSet(frame.Peers)
+	if c.observer {
+		for _, p := range c.peers.Peers {
+			if _, ok := c.validators.ByID[p.ID()]; !ok {
+				newPeers = newPeers.WithNewPeer(p)
+			}
+		}
+	}
+	c.SetPeers(newPeers)
 	c.validators = peers.NewPeerSet(frame.Peers)
 
+	c.notifier.publish(Notification{
+		Type:  ValidatorsChanged,
+		Round: frame.Round,
+		Peers: c.validators.Peers,
+	})
+
 	return nil
 }
 
@@ -390,12 +547,23 @@ func (c *Core) GetAnchorBlockWithFrame() (*hg.Block, *hg.Frame, error) {
 	return c.hg.GetAnchorBlockWithFrame()
 }
 
+//GetBlockWithFrame returns GetBlockWithFrame from the hashgraph
+func (c *Core) GetBlockWithFrame(index int) (*hg.Block, *hg.Frame, error) {
+	return c.hg.GetBlockWithFrame(index)
+}
+
 /*******************************************************************************
 Leave
 *******************************************************************************/
 
-// Leave causes the node to leave the network
-func (c *Core) Leave(leaveTimeout time.Duration) error {
+// Leave causes the node to leave the network. It gives up when leaveTimeout
+// expires or when ctx is cancelled.
+func (c *Core) Leave(ctx context.Context, leaveTimeout time.Duration) error {
+	if c.observer {
+		c.logger.Debug("Observer leaving")
+		return nil
+	}
+
 	p, ok := c.peers.ByID[c.validator.ID()]
 	if !ok {
 		return fmt.Errorf("Leaving: Peer not found")
@@ -415,6 +583,8 @@ func (c *Core) Leave(leaveTimeout time.Duration) error {
 			"peers":         len(resp.Peers),
 		}).Debug("LeaveRequest processed")
 		c.RemovedRound = resp.AcceptedRound
+	case <-ctx.Done():
+		return ctx.Err()
 	case <-timeout:
 		err := fmt.Errorf("Timeout waiting for LeaveRequest to go through consensus")
 		c.logger.WithError(err).Error()
@@ -430,6 +600,8 @@ func (c *Core) Leave(leaveTimeout time.Duration) error {
 				err := fmt.Errorf("Timeout waiting for leaving node to reach TargetRound")
 				c.logger.WithError(err).Error()
 				return err
+			case <-ctx.Done():
+				return ctx.Err()
 			default:
 				if c.hg.LastConsensusRound != nil && *c.hg.LastConsensusRound < c.TargetRound {
 					c.logger.Debugf("Waiting to reach TargetRound: %d/%d", *c.hg.LastConsensusRound, c.RemovedRound)
@@ -451,7 +623,9 @@ Commit
 // Commit the Block to the App using the proxyCommitCallback
 func (c *Core) Commit(block *hg.Block) error {
 	//Commit the Block to the App
+	start := time.Now()
 	commitResponse, err := c.proxyCommitCallback(*block)
+	blockCommitDuration.Observe(time.Since(start).Seconds())
 
 	c.logger.WithFields(logrus.Fields{
 		"block":                         block.Index(),
@@ -491,6 +665,18 @@ func (c *Core) Commit(block *hg.Block) error {
 		if err != nil {
 			return err
 		}
+
+		c.lastCommittedBlock = block.Index()
+
+		//Signatures are added to the block after it is committed, so we only
+		//publish the body
+		c.notifier.publish(Notification{
+			Type: BlockCommitted,
+			Block: &hg.Block{
+				Body:       block.Body,
+				Signatures: make(map[string]string),
+			},
+		})
 	}
 
 	return err
@@ -569,6 +755,12 @@ func (c *Core) ProcessAcceptedInternalTransactions(roundReceived int, receipts [
 			"validators":      len(validators.Peers),
 		}).Debug("Validators Changed")
 
+		c.notifier.publish(Notification{
+			Type:  ValidatorsChanged,
+			Round: effectiveRound,
+			Peers: validators.Peers,
+		})
+
 		// Update the current list of communicating peers. This is not
 		// necessarily equal to the latest recorded validator_set.
 		c.SetPeers(currentPeers)
@@ -769,3 +961,9 @@ func (c *Core) GetLastCommitedRoundEventsCount() int {
 func (c *Core) GetLastBlockIndex() int {
 	return c.hg.Store.LastBlockIndex()
 }
+
+// GetLastCommittedBlockIndex returns the index of the last block committed to
+// the App
+func (c *Core) GetLastCommittedBlockIndex() int {
+	return c.lastCommittedBlock
+}
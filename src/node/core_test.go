@@ -3,6 +3,9 @@ package node
 import (
 	"crypto/ecdsa"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"strconv"
@@ -18,6 +21,11 @@ import (
 func initCores(n int, t *testing.T) ([]*Core, map[uint32]*ecdsa.PrivateKey, map[string]string) {
 	cacheSize := 1000
 
+	return initCoresWithStores(n, func() hg.Store { return hg.NewInmemStore(cacheSize) }, t)
+}
+
+//initCoresWithStores creates n cores on the Stores returned by newStore
+func initCoresWithStores(n int, newStore func() hg.Store, t *testing.T) ([]*Core, map[uint32]*ecdsa.PrivateKey, map[string]string) {
 	cores := []*Core{}
 	index := make(map[string]string)
 	participantKeys := map[uint32]*ecdsa.PrivateKey{}
@@ -41,7 +49,7 @@ func initCores(n int, t *testing.T) ([]*Core, map[uint32]*ecdsa.PrivateKey, map[
 			NewValidator(key, peer.Moniker),
 			peerSet,
 			genesisPeerSet,
-			hg.NewInmemStore(cacheSize),
+			newStore(),
 			proxy.DummyCommitCallback,
 			common.NewTestLogger(t))
 
@@ -172,6 +180,75 @@ func TestEventDiff(t *testing.T) {
 	}
 }
 
+//Peers which lag by more than CacheSize Events are served from the Events
+//spilled by a HybridStore
+func TestEventDiffFromSpilledEvents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "huron-hybrid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cacheSize := 50
+	stores := []*hg.HybridStore{}
+	cores, _, _ := initCoresWithStores(3, func() hg.Store {
+		store, err := hg.NewHybridStore(cacheSize, filepath.Join(dir, fmt.Sprintf("hybrid%d", len(stores))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		stores = append(stores, store)
+		return store
+	}, t)
+	defer func() {
+		for _, s := range stores {
+			s.Close()
+		}
+	}()
+
+	//every sync creates an Event, so each core creates 2*cacheSize Events
+	for i := 0; i < 6*cacheSize; i++ {
+		from, to := i%3, (i+1)%3
+		if err := synchronizeCores(cores, from, to, [][]byte{[]byte(fmt.Sprintf("tx%d", i))}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if spilled := stores[1].SpilledEvents(); spilled == 0 {
+		t.Fatalf("Events should have been spilled")
+	}
+
+	//the diff for a peer which knows no Events holds all the Events of core1
+	known := cores[1].KnownEvents()
+	total := 0
+	for id, index := range known {
+		total += index + 1
+		known[id] = -1
+	}
+
+	diff, err := cores[1].EventDiff(known)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the InmemStore would only have the last CacheSize Events of each peer
+	if len(diff) != total {
+		t.Fatalf("Diff should have %d Events, not %d", total, len(diff))
+	}
+	first := make(map[string]bool)
+	for _, e := range diff {
+		if e.Index() == 0 {
+			first[e.Creator()] = true
+		}
+	}
+	if len(first) != len(cores) {
+		t.Fatalf("Diff should start from the first Event of each peer")
+	}
+
+	if _, err := cores[1].ToWire(diff); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestSync(t *testing.T) {
 	cores, _, index := initCores(3, t)
 
@@ -0,0 +1,198 @@
+package node
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+
+	hg "github.com/abassian/huron/src/hashgraph"
+	"github.com/abassian/huron/src/peers"
+	"github.com/sirupsen/logrus"
+)
+
+/*******************************************************************************
+Fan-out
+
+When Config.FanOut is positive, the node pushes the self-events created by its
+own SyncRequests to FanOut peers in parallel, through EagerSyncRequests,
+instead of waiting for them to spread one gossip at a time. Only the events
+that a peer is not known to have are pushed, according to the known-events
+exchanged during gossip.
+*******************************************************************************/
+
+// peerKnowledge records, for every peer, the index of the last event from
+// each creator that the peer is known to have.
+type peerKnowledge struct {
+	known map[uint32]map[uint32]int
+	lock  sync.Mutex
+}
+
+func newPeerKnowledge() *peerKnowledge {
+	return &peerKnowledge{
+		known: make(map[uint32]map[uint32]int),
+	}
+}
+
+// update merges known-events reported by, or inferred for, a peer
+func (pk *peerKnowledge) update(peer uint32, known map[uint32]int) {
+	pk.lock.Lock()
+	defer pk.lock.Unlock()
+
+	k, ok := pk.known[peer]
+	if !ok {
+		k = make(map[uint32]int, len(known))
+		pk.known[peer] = k
+	}
+
+	for id, index := range known {
+		if cur, ok := k[id]; !ok || index > cur {
+			k[id] = index
+		}
+	}
+}
+
+// updateFromEvents records that a peer has the given events
+func (pk *peerKnowledge) updateFromEvents(peer uint32, events []hg.WireEvent) {
+	known := make(map[uint32]int)
+	for _, e := range events {
+		if cur, ok := known[e.Body.CreatorID]; !ok || e.Body.Index > cur {
+			known[e.Body.CreatorID] = e.Body.Index
+		}
+	}
+
+	pk.update(peer, known)
+}
+
+// get returns a copy of the known-events of a peer, or nil if nothing is known
+// about it.
+func (pk *peerKnowledge) get(peer uint32) map[uint32]int {
+	pk.lock.Lock()
+	defer pk.lock.Unlock()
+
+	k, ok := pk.known[peer]
+	if !ok {
+		return nil
+	}
+
+	res := make(map[uint32]int, len(k))
+	for id, index := range k {
+		res[id] = index
+	}
+
+	return res
+}
+
+// triggerFanOut starts a fan-out of the node's new events, unless fan-out is
+// disabled or another one is already in progress, in which case the new
+// events will be pushed by the next one or by regular gossip.
+func (n *Node) triggerFanOut(exclude uint32) {
+	if n.conf.FanOut <= 0 || n.conf.Observer {
+		return
+	}
+
+	if !atomic.CompareAndSwapUint32(&n.fanningOut, 0, 1) {
+		return
+	}
+
+	n.goFunc(func() {
+		defer atomic.StoreUint32(&n.fanningOut, 0)
+		n.fanOut(exclude)
+	})
+}
+
+// fanOut pushes the events unknown to up to FanOut peers, other than exclude,
+// in parallel.
+func (n *Node) fanOut(exclude uint32) {
+	targets := n.fanOutTargets(exclude)
+
+	var wg sync.WaitGroup
+	for _, p := range targets {
+		wg.Add(1)
+		go func(p *peers.Peer) {
+			defer wg.Done()
+			n.pushUnknown(p)
+		}(p)
+	}
+	wg.Wait()
+}
+
+// fanOutTargets selects up to FanOut random peers which are available and
+// whose known-events are known, so that pushes can be deduplicated.
+func (n *Node) fanOutTargets(exclude uint32) []*peers.Peer {
+	n.core.selectorLock.Lock()
+	peerSet := n.core.peerSelector.Peers()
+	n.core.selectorLock.Unlock()
+
+	candidates := []*peers.Peer{}
+	for _, p := range peerSet.Peers {
+		if p.ID() == n.GetID() || p.ID() == exclude {
+			continue
+		}
+		if n.knowledge.get(p.ID()) == nil || !n.core.peerHealth.available(p.ID()) {
+			continue
+		}
+		candidates = append(candidates, p)
+	}
+
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+
+	if len(candidates) > n.conf.FanOut {
+		candidates = candidates[:n.conf.FanOut]
+	}
+
+	return candidates
+}
+
+// pushUnknown pushes to a peer the events that it is not known to have
+func (n *Node) pushUnknown(peer *peers.Peer) {
+	known := n.knowledge.get(peer.ID())
+
+	n.coreLock.Lock()
+	eventDiff, err := n.core.EventDiff(known)
+	if err == nil && len(eventDiff) > n.conf.SyncLimit {
+		eventDiff = eventDiff[:n.conf.SyncLimit]
+	}
+	var wireEvents []hg.WireEvent
+	if err == nil && len(eventDiff) > 0 {
+		wireEvents, err = n.core.ToWire(eventDiff)
+	}
+	n.coreLock.Unlock()
+
+	if err != nil {
+		n.logger.WithError(err).Error("Fan-out diff")
+		return
+	}
+
+	if len(wireEvents) == 0 {
+		return
+	}
+
+	resp, err := n.requestEagerSync(peer.NetAddr, wireEvents)
+
+	fanOutPushes.Inc()
+
+	//A peer which could not insert the events, for example because it misses
+	//their parents, does not have them
+	if err == nil && !resp.Success {
+		err = fmt.Errorf("Push rejected")
+	}
+
+	//Most failures are rejections of events whose parents the peer is still
+	//receiving through gossip, and the transports report them as errors too.
+	//They are not held against the peer, whose health is left to gossip.
+	if err != nil {
+		n.logger.WithFields(logrus.Fields{
+			"peer":  peer.Moniker,
+			"error": err,
+		}).Debug("Fan-out push")
+		fanOutFailures.Inc()
+		return
+	}
+
+	fanOutEvents.Add(float64(len(wireEvents)))
+
+	n.knowledge.updateFromEvents(peer.ID(), wireEvents)
+}
@@ -0,0 +1,150 @@
+package node
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/abassian/huron/src/common"
+	hg "github.com/abassian/huron/src/hashgraph"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPeerKnowledge(t *testing.T) {
+	pk := newPeerKnowledge()
+
+	if k := pk.get(1); k != nil {
+		t.Fatalf("Nothing should be known about an unknown peer: %v", k)
+	}
+
+	pk.update(1, map[uint32]int{1: 5, 2: 3})
+	pk.update(1, map[uint32]int{1: 4, 2: 6})
+
+	events := []hg.WireEvent{
+		{Body: hg.WireBody{CreatorID: 3, Index: 2}},
+		{Body: hg.WireBody{CreatorID: 3, Index: 7}},
+	}
+	pk.updateFromEvents(1, events)
+
+	expected := map[uint32]int{1: 5, 2: 6, 3: 7}
+	k := pk.get(1)
+	for id, index := range expected {
+		if k[id] != index {
+			t.Fatalf("Known[%d] should be %d, not %d", id, index, k[id])
+		}
+	}
+
+	//get returns a copy
+	k[1] = 100
+	if pk.get(1)[1] != 5 {
+		t.Fatalf("Modifying the result of get should not affect the knowledge")
+	}
+}
+
+func TestFanOut(t *testing.T) {
+	logger := common.NewTestLogger(t)
+	keys, peers := initPeers(t, 4)
+
+	genesisPeerSet := clonePeerSet(t, peers.Peers)
+
+	nodes := initNodes(keys, peers, genesisPeerSet, 1000, 400, 5, false, "inmem", 10*time.Millisecond, logger, t)
+	defer shutdownNodes(nodes)
+
+	for _, n := range nodes {
+		n.conf.FanOut = 2
+	}
+
+	pushes := testutil.ToFloat64(fanOutPushes)
+	events := testutil.ToFloat64(fanOutEvents)
+
+	err := gossip(nodes, 10, false, 6*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkGossip(nodes, 0, t)
+
+	if testutil.ToFloat64(fanOutPushes) == pushes {
+		t.Fatalf("Fan-out should have pushed events")
+	}
+	if testutil.ToFloat64(fanOutEvents) == events {
+		t.Fatalf("Fan-out should have delivered events")
+	}
+}
+
+// BenchmarkFanOut compares the time taken to reach consensus on blocks with
+// and without fan-out.
+func BenchmarkFanOut(b *testing.B) {
+	const target = 20
+
+	for _, fanOut := range []int{0, 2} {
+		b.Run(fmt.Sprintf("fan-out-%d", fanOut), func(b *testing.B) {
+			logger := common.NewTestLogger(b)
+
+			var total time.Duration
+			for i := 0; i < b.N; i++ {
+				keys, peers := initPeers(b, 4)
+
+				genesisPeerSet := clonePeerSet(b, peers.Peers)
+
+				nodes := initNodes(keys, peers, genesisPeerSet, 1000, 1000, 5, false, "inmem", 50*time.Millisecond, logger, b)
+				for _, n := range nodes {
+					n.conf.FanOut = fanOut
+				}
+
+				start := time.Now()
+				err := gossip(nodes, target, true, 30*time.Second)
+				total += time.Since(start)
+
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			b.ReportMetric(float64(total.Milliseconds())/float64(b.N*target), "ms/block")
+		})
+	}
+}
+
+func TestFanOutRejectedPush(t *testing.T) {
+	logger := common.NewTestLogger(t)
+	keys, peers := initPeers(t, 2)
+
+	genesisPeerSet := clonePeerSet(t, peers.Peers)
+
+	nodes := initNodes(keys, peers, genesisPeerSet, 1000, 400, 5, false, "inmem", 10*time.Millisecond, logger, t)
+	defer shutdownNodes(nodes)
+
+	//The receiver processes requests without gossiping
+	nodes[1].RunAsync(false)
+
+	pusher, receiver := nodes[0], peers.Peers[1]
+
+	for i := 0; i < 3; i++ {
+		pusher.coreLock.Lock()
+		err := pusher.core.AddSelfEvent("")
+		pusher.coreLock.Unlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//The receiver is wrongly believed to have the first events, so it is
+	//pushed events whose parents it does not have
+	known := map[uint32]int{pusher.GetID(): 1, receiver.ID(): -1}
+	pusher.knowledge.update(receiver.ID(), known)
+
+	failures := testutil.ToFloat64(fanOutFailures)
+
+	pusher.pushUnknown(receiver)
+
+	if testutil.ToFloat64(fanOutFailures) != failures+1 {
+		t.Fatalf("A rejected push should be counted as a failure")
+	}
+	if k := pusher.knowledge.get(receiver.ID()); k[pusher.GetID()] != 1 {
+		t.Fatalf("A rejected push should not update the knowledge of the peer, got %v", k)
+	}
+	if h := pusher.GetPeerHealth(receiver.ID()); h != nil && h.ConsecutiveFailures > 0 {
+		t.Fatalf("A rejected push should not back off gossip with the peer, got %+v", h)
+	}
+}
@@ -0,0 +1,131 @@
+package node
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/abassian/huron/src/proxy"
+)
+
+// Readiness describes whether a node is making progress and able to serve
+// its application. Failures lists the reasons why the node is not Ready.
+type Readiness struct {
+	Ready              bool     `json:"ready"`
+	State              string   `json:"state"`
+	LastConsensusRound *int     `json:"last_consensus_round"`
+	LastRoundAge       string   `json:"last_round_age"`
+	ContactedPeers     int      `json:"contacted_peers"`
+	SuperMajority      int      `json:"super_majority"`
+	AppReachable       bool     `json:"app_reachable"`
+	Failures           []string `json:"failures,omitempty"`
+}
+
+// IsAlive returns true until the node is shut down. A node that is alive but
+// not ready should be given time to recover rather than being restarted.
+func (n *Node) IsAlive() bool {
+	return n.getState() != Shutdown
+}
+
+// GetReadiness evaluates the readiness of the node against the thresholds in
+// its Config: the node must be Babbling, consensus must have progressed within
+// ReadyMaxRoundAge if the node has pending work, a supermajority of validators (counting this node if it is
+// one) must have been in contact within ReadyMaxPeerSilence, and the app proxy
+// must be reachable. A zero threshold disables the corresponding check.
+func (n *Node) GetReadiness() Readiness {
+	state := n.getState()
+
+	n.coreLock.Lock()
+	lastRound := n.core.GetLastConsensusRoundIndex()
+	if lastRound != nil {
+		r := *lastRound
+		lastRound = &r
+	}
+	roundAge := n.core.lastRoundAge(n.start)
+	consensusAge, pendingWork := n.core.consensusAge(n.start)
+	validators := n.core.validators
+	n.coreLock.Unlock()
+
+	contacted := 0
+	if _, ok := validators.ByID[n.GetID()]; ok {
+		contacted++
+	}
+	for id, t := range n.contacts.get() {
+		if _, ok := validators.ByID[id]; ok && time.Since(t) <= n.conf.ReadyMaxPeerSilence {
+			contacted++
+		}
+	}
+
+	appErr := pingApp(n.proxy)
+
+	r := Readiness{
+		State:              state.String(),
+		LastConsensusRound: lastRound,
+		LastRoundAge:       roundAge.Round(time.Millisecond).String(),
+		ContactedPeers:     contacted,
+		SuperMajority:      validators.SuperMajority(),
+		AppReachable:       appErr == nil,
+	}
+
+	if state != Babbling {
+		r.Failures = append(r.Failures, fmt.Sprintf("Node is %s", state))
+	}
+
+	//An idle network decides no rounds, and is not stale
+	if n.conf.ReadyMaxRoundAge > 0 && pendingWork && consensusAge > n.conf.ReadyMaxRoundAge {
+		r.Failures = append(r.Failures, fmt.Sprintf("No consensus round decided for %s with pending work", consensusAge.Round(time.Millisecond)))
+	}
+
+	if n.conf.ReadyMaxPeerSilence > 0 && contacted < r.SuperMajority {
+		r.Failures = append(r.Failures, fmt.Sprintf("In contact with %d validators, need %d", contacted, r.SuperMajority))
+	}
+
+	if appErr != nil {
+		r.Failures = append(r.Failures, fmt.Sprintf("App unreachable: %v", appErr))
+	}
+
+	r.Ready = len(r.Failures) == 0
+
+	return r
+}
+
+// pingApp checks that the application behind the proxy is reachable. Proxies
+// which do not implement proxy.Pinger are always considered reachable.
+func pingApp(p proxy.AppProxy) error {
+	if pinger, ok := p.(proxy.Pinger); ok {
+		return pinger.Ping()
+	}
+	return nil
+}
+
+// peerContacts records the last time the node successfully communicated with
+// each peer, in either direction.
+type peerContacts struct {
+	last map[uint32]time.Time
+	lock sync.Mutex
+}
+
+func newPeerContacts() *peerContacts {
+	return &peerContacts{
+		last: make(map[uint32]time.Time),
+	}
+}
+
+func (pc *peerContacts) record(id uint32) {
+	pc.lock.Lock()
+	defer pc.lock.Unlock()
+
+	pc.last[id] = time.Now()
+}
+
+func (pc *peerContacts) get() map[uint32]time.Time {
+	pc.lock.Lock()
+	defer pc.lock.Unlock()
+
+	res := make(map[uint32]time.Time, len(pc.last))
+	for id, t := range pc.last {
+		res[id] = t
+	}
+
+	return res
+}
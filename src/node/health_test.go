@@ -0,0 +1,120 @@
+package node
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/abassian/huron/src/common"
+)
+
+func TestReadiness(t *testing.T) {
+	logger := common.NewTestLogger(t)
+	keys, peers := initPeers(t, 4)
+
+	genesisPeerSet := clonePeerSet(t, peers.Peers)
+
+	nodes := initNodes(keys, peers, genesisPeerSet, 1000, 400, 5, false, "inmem", 10*time.Millisecond, logger, t)
+	defer shutdownNodes(nodes)
+
+	for _, n := range nodes {
+		n.conf.ReadyMaxRoundAge = 5 * time.Second
+		n.conf.ReadyMaxPeerSilence = 5 * time.Second
+	}
+
+	//Without any contact with other validators, the node is not ready
+	if r := nodes[0].GetReadiness(); r.Ready || r.ContactedPeers != 1 {
+		t.Fatalf("Node should not be ready before gossiping: %+v", r)
+	}
+
+	err := gossip(nodes, 10, false, 6*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := nodes[0].GetReadiness()
+	if !r.Ready {
+		t.Fatalf("Node should be ready after gossiping: %+v", r)
+	}
+	if r.ContactedPeers < r.SuperMajority {
+		t.Fatalf("Node should be in contact with %d validators, not %d", r.SuperMajority, r.ContactedPeers)
+	}
+	if r.LastConsensusRound == nil {
+		t.Fatalf("LastConsensusRound should be set")
+	}
+
+	//Consensus is stale when no round is decided within ReadyMaxRoundAge
+	//while there is pending work
+	nodes[0].conf.ReadyMaxRoundAge = time.Nanosecond
+	nodes[0].addTransaction([]byte("pending"))
+	if r := nodes[0].GetReadiness(); r.Ready {
+		t.Fatalf("Node should not be ready with stale consensus: %+v", r)
+	}
+
+	nodes[0].Shutdown()
+
+	if nodes[0].IsAlive() {
+		t.Fatalf("Node should not be alive after Shutdown")
+	}
+	if r := nodes[0].GetReadiness(); r.Ready {
+		t.Fatalf("Node should not be ready after Shutdown: %+v", r)
+	}
+}
+
+func TestReadinessIdle(t *testing.T) {
+	logger := common.NewTestLogger(t)
+	keys, peers := initPeers(t, 4)
+
+	genesisPeerSet := clonePeerSet(t, peers.Peers)
+
+	nodes := initNodes(keys, peers, genesisPeerSet, 1000, 400, 5, false, "inmem", 10*time.Millisecond, logger, t)
+	defer shutdownNodes(nodes)
+
+	for _, n := range nodes {
+		n.conf.ReadyMaxRoundAge = 500 * time.Millisecond
+		n.conf.ReadyMaxPeerSilence = 5 * time.Second
+	}
+
+	err := gossip(nodes, 5, false, 6*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := waitIdle(nodes, 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	//An idle network decides no rounds, but stays ready
+	time.Sleep(2 * nodes[0].conf.ReadyMaxRoundAge)
+
+	if r := nodes[0].GetReadiness(); !r.Ready {
+		t.Fatalf("Idle node should be ready after ReadyMaxRoundAge: %+v", r)
+	}
+}
+
+//waitIdle waits until none of the nodes has pending work
+func waitIdle(nodes []*Node, timeout time.Duration) error {
+	stopper := time.After(timeout)
+	for {
+		idle := true
+		for _, n := range nodes {
+			n.coreLock.Lock()
+			pending := n.core.HasPendingWork()
+			n.coreLock.Unlock()
+
+			if pending {
+				idle = false
+				break
+			}
+		}
+		if idle {
+			return nil
+		}
+
+		select {
+		case <-stopper:
+			return fmt.Errorf("TIMEOUT waiting for the nodes to be idle")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package node
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	gossips = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "huron",
+		Subsystem: "node",
+		Name:      "gossip_total",
+		Help:      "Number of gossip routines initiated with other peers.",
+	})
+
+	syncRequests = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "huron",
+		Subsystem: "node",
+		Name:      "sync_requests_total",
+		Help:      "Number of SyncRequests sent to other peers.",
+	})
+
+	syncErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "huron",
+		Subsystem: "node",
+		Name:      "sync_errors_total",
+		Help:      "Number of SyncRequests that failed or whose response could not be processed.",
+	})
+
+	fanOutPushes = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "huron",
+		Subsystem: "node",
+		Name:      "fan_out_pushes_total",
+		Help:      "Number of EagerSyncRequests sent by fan-out.",
+	})
+
+	fanOutEvents = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "huron",
+		Subsystem: "node",
+		Name:      "fan_out_events_total",
+		Help:      "Number of events successfully pushed by fan-out.",
+	})
+
+	fanOutFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "huron",
+		Subsystem: "node",
+		Name:      "fan_out_failures_total",
+		Help:      "Number of fan-out pushes which failed or were rejected by the peer.",
+	})
+
+	blockCommitDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "huron",
+		Subsystem: "node",
+		Name:      "block_commit_duration_seconds",
+		Help:      "Time taken by the application to commit a block.",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 14),
+	})
+)
+
+func init() {
+	prometheus.MustRegister(
+		gossips,
+		syncRequests,
+		syncErrors,
+		fanOutPushes,
+		fanOutEvents,
+		fanOutFailures,
+		blockCommitDuration,
+	)
+}
+
+var (
+	stateDesc = prometheus.NewDesc("huron_node_state",
+		"State of the node (0: Babbling, 1: CatchingUp, 2: Joining, 3: Leaving, 4: Shutdown).",
+		nil, nil)
+	undeterminedEventsDesc = prometheus.NewDesc("huron_node_undetermined_events",
+		"Number of Events that have not reached consensus yet.",
+		nil, nil)
+	transactionPoolDesc = prometheus.NewDesc("huron_node_transaction_pool",
+		"Number of transactions waiting to be included in an Event.",
+		nil, nil)
+	internalTransactionPoolDesc = prometheus.NewDesc("huron_node_internal_transaction_pool",
+		"Number of internal transactions waiting to be included in an Event.",
+		nil, nil)
+	signaturePoolDesc = prometheus.NewDesc("huron_node_signature_pool",
+		"Number of block signatures waiting to be included in an Event.",
+		nil, nil)
+	lastBlockDesc = prometheus.NewDesc("huron_node_last_block_index",
+		"Index of the last committed block.",
+		nil, nil)
+	lastRoundDesc = prometheus.NewDesc("huron_node_last_consensus_round",
+		"Index of the last consensus round.",
+		nil, nil)
+	peersDesc = prometheus.NewDesc("huron_node_peers",
+		"Number of peers the node gossips with.",
+		nil, nil)
+	cacheHitsDesc = prometheus.NewDesc("huron_cache_hits_total",
+		"Number of lookups found in an LRU cache since it was created.",
+		[]string{"cache"}, nil)
+	cacheMissesDesc = prometheus.NewDesc("huron_cache_misses_total",
+		"Number of lookups not found in an LRU cache since it was created.",
+		[]string{"cache"}, nil)
+	cacheEvictionsDesc = prometheus.NewDesc("huron_cache_evictions_total",
+		"Number of items evicted from an LRU cache to fit its bounds since it was created.",
+		[]string{"cache"}, nil)
+	cacheItemsDesc = prometheus.NewDesc("huron_cache_items",
+		"Number of items in an LRU cache.",
+		[]string{"cache"}, nil)
+	cacheBytesDesc = prometheus.NewDesc("huron_cache_bytes",
+		"Estimated size of the items in an LRU cache, when caches are sized by memory.",
+		[]string{"cache"}, nil)
+	cacheBudgetDesc = prometheus.NewDesc("huron_cache_budget_bytes",
+		"Share of the memory budget allocated to an LRU cache (0 when caches are sized by items).",
+		[]string{"cache"}, nil)
+)
+
+// MetricsCollector is a prometheus.Collector which reports the current values
+// of a node's pools and counters.
+type MetricsCollector struct {
+	node *Node
+}
+
+// NewMetricsCollector returns a MetricsCollector for a node
+func NewMetricsCollector(n *Node) *MetricsCollector {
+	return &MetricsCollector{node: n}
+}
+
+// Describe implements prometheus.Collector
+func (mc *MetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- stateDesc
+	ch <- undeterminedEventsDesc
+	ch <- transactionPoolDesc
+	ch <- internalTransactionPoolDesc
+	ch <- signaturePoolDesc
+	ch <- lastBlockDesc
+	ch <- lastRoundDesc
+	ch <- peersDesc
+	ch <- cacheHitsDesc
+	ch <- cacheMissesDesc
+	ch <- cacheEvictionsDesc
+	ch <- cacheItemsDesc
+	ch <- cacheBytesDesc
+	ch <- cacheBudgetDesc
+}
+
+// Collect implements prometheus.Collector
+func (mc *MetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	n := mc.node
+
+	n.coreLock.Lock()
+	undetermined := len(n.core.GetUndeterminedEvents())
+	txPool := len(n.core.transactionPool)
+	itxPool := len(n.core.internalTransactionPool)
+	sigPool := n.core.selfBlockSignatures.Len()
+	lastBlock := n.core.GetLastBlockIndex()
+	lastRound := -1
+	if r := n.core.GetLastConsensusRoundIndex(); r != nil {
+		lastRound = *r
+	}
+	peers := n.core.peers.Len()
+	cacheStats := n.core.hg.Store.Caches().Stats()
+	n.coreLock.Unlock()
+
+	gauge := func(desc *prometheus.Desc, v int) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(v))
+	}
+
+	gauge(stateDesc, int(n.getState()))
+	gauge(undeterminedEventsDesc, undetermined)
+	gauge(transactionPoolDesc, txPool)
+	gauge(internalTransactionPoolDesc, itxPool)
+	gauge(signaturePoolDesc, sigPool)
+	gauge(lastBlockDesc, lastBlock)
+	gauge(lastRoundDesc, lastRound)
+	gauge(peersDesc, peers)
+
+	for name, stats := range cacheStats {
+		metric := func(desc *prometheus.Desc, t prometheus.ValueType, v float64) {
+			ch <- prometheus.MustNewConstMetric(desc, t, v, name)
+		}
+
+		metric(cacheHitsDesc, prometheus.CounterValue, float64(stats.Hits))
+		metric(cacheMissesDesc, prometheus.CounterValue, float64(stats.Misses))
+		metric(cacheEvictionsDesc, prometheus.CounterValue, float64(stats.Evictions))
+		metric(cacheItemsDesc, prometheus.GaugeValue, float64(stats.Len))
+		metric(cacheBytesDesc, prometheus.GaugeValue, float64(stats.Bytes))
+		metric(cacheBudgetDesc, prometheus.GaugeValue, float64(stats.MaxBytes))
+	}
+}
@@ -0,0 +1,40 @@
+package node
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abassian/huron/src/common"
+	hg "github.com/abassian/huron/src/hashgraph"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsCollector(t *testing.T) {
+	logger := common.NewTestLogger(t)
+	keys, peers := initPeers(t, 1)
+
+	genesisPeerSet := clonePeerSet(t, peers.Peers)
+
+	node := newNode(peers.Peers[0], keys[0], peers, genesisPeerSet, 1000, 400, 5, false, "inmem", 10*time.Millisecond, logger, t)
+	defer node.Shutdown()
+
+	node.addTransaction([]byte("tx"))
+
+	collector := NewMetricsCollector(node)
+
+	//8 node metrics, and 6 metrics per cache
+	expectedCount := 8 + 6*len(hg.CacheNames())
+	if count := testutil.CollectAndCount(collector); count != expectedCount {
+		t.Fatalf("Collector should report %d metrics, not %d", expectedCount, count)
+	}
+
+	expected := `
+# HELP huron_node_transaction_pool Number of transactions waiting to be included in an Event.
+# TYPE huron_node_transaction_pool gauge
+huron_node_transaction_pool 1
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected), "huron_node_transaction_pool"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,12 +1,10 @@
 package node
 
 import (
+	"context"
 	"fmt"
-	"os"
-	"os/signal"
 	"strconv"
 	"sync"
-	"syscall"
 	"time"
 
 	hg "github.com/abassian/huron/src/hashgraph"
@@ -42,22 +40,44 @@ type Node struct {
 	// transactions from the applications to Huron.
 	proxy proxy.AppProxy
 
+	// snapshots periodically records application snapshots which are used to
+	// answer FastForwardRequests.
+	snapshots *SnapshotManager
+
 	// submitCh is where the node listens for incoming transactions to be
 	// submitted to Huron
 	submitCh chan []byte
 
-	// sigintCh is where the node listens for signals to politely leave the
-	// Huron network.
-	sigintCh chan os.Signal
+	// notifier relays Notifications about the node's state and consensus to
+	// subscribers.
+	notifier *notifier
 
 	// shutdownCh is where the node listens for commands to cleanly shutdown.
-	shutdownCh chan struct{}
+	shutdownCh   chan struct{}
+	shutdownLock sync.Mutex
+
+	// doneCh is closed once the node has completed its shutdown.
+	doneCh chan struct{}
 
 	// The node runs the controlTimer in the background to periodically receive
 	// signals to initiate gossip routines. It is paused, reset, etc., based on
 	// the node's current state
 	controlTimer *ControlTimer
 
+	// contacts records the last successful communication with each peer, to
+	// assess readiness.
+	contacts *peerContacts
+
+	// gossipPaused is set atomically by PauseGossip and ResumeGossip
+	gossipPaused uint32
+
+	// knowledge records the events known by peers, to deduplicate the events
+	// pushed by fan-out.
+	knowledge *peerKnowledge
+
+	// fanningOut is set atomically while a fan-out is in progress
+	fanningOut uint32
+
 	start        time.Time
 	syncRequests int
 	syncErrors   int
@@ -72,23 +92,39 @@ func NewNode(conf *Config,
 	trans net.Transport,
 	proxy proxy.AppProxy,
 ) *Node {
-	//Prepare sigintCh to relay SIGINT system calls
-	sigintCh := make(chan os.Signal)
-	signal.Notify(sigintCh, os.Interrupt, syscall.SIGINT)
+	logger := conf.Logger.WithField("this_id", validator.ID())
+
+	snapshots := NewSnapshotManager(conf.SnapshotDir,
+		conf.SnapshotInterval,
+		conf.SnapshotRetention,
+		conf.EncryptionKey,
+		proxy.GetSnapshot,
+		logger)
 
 	node := Node{
 		conf:         conf,
-		logger:       conf.Logger.WithField("this_id", validator.ID()),
-		core:         NewCore(validator, peers, genesisPeers, store, proxy.CommitBlock, conf.Logger),
+		logger:       logger,
+		core:         NewCore(validator, peers, genesisPeers, store, snapshots.CommitCallback(proxy.CommitBlock), conf.Logger),
 		trans:        trans,
 		netCh:        trans.Consumer(),
 		proxy:        proxy,
+		snapshots:    snapshots,
 		submitCh:     proxy.SubmitCh(),
-		sigintCh:     sigintCh,
+		notifier:     newNotifier(logger),
 		shutdownCh:   make(chan struct{}),
+		doneCh:       make(chan struct{}),
 		controlTimer: NewRandomControlTimer(),
+		contacts:     newPeerContacts(),
+		knowledge:    newPeerKnowledge(),
+		start:        time.Now(),
 	}
 
+	node.core.observer = conf.Observer
+	node.core.notifier = node.notifier
+
+	node.core.selectorKind = conf.PeerSelector
+	node.core.SetPeers(node.core.peers)
+
 	return &node
 }
 
@@ -100,18 +136,32 @@ Public Methods
 // boostrap process which loads the hashgraph from an existing database (if
 // bootstrap option is set in config). It also decides what state the node will
 // start in (Babbling, CatchingUp, or Joining) based on the current
-// validator-set and the value of the fast-sync option.
+// validator-set and the value of the fast-sync option. Observers never join the
+// validator-set.
 func (n *Node) Init() error {
+	if err := n.conf.Validate(); err != nil {
+		return err
+	}
+
+	//Only keep the existing snapshots if the hashgraph is loaded from the same
+	//database
+	if err := n.snapshots.Init(n.conf.Bootstrap); err != nil {
+		return err
+	}
+
 	if n.conf.Bootstrap {
 		n.logger.Debug("Bootstrap")
 
-		if err := n.core.Bootstrap(); err != nil {
+		if err := n.bootstrap(); err != nil {
 			return err
 		}
 	}
 
 	_, ok := n.core.peers.ByID[n.core.validator.ID()]
-	if ok {
+	if n.conf.Observer {
+		n.logger.Debug("Node is an Observer")
+		n.setBabblingOrCatchingUpState()
+	} else if ok {
 		n.logger.Debug("Node belongs to PeerSet")
 		n.setBabblingOrCatchingUpState()
 	} else {
@@ -123,8 +173,22 @@ func (n *Node) Init() error {
 }
 
 // Run invokes the main loop of the node. The gossip parameter controls whether
-// to actively participate in gossip or not.
-func (n *Node) Run(gossip bool) {
+// to actively participate in gossip or not. Run blocks until the node is shut
+// down, either by a call to Shutdown or Leave, or by the cancellation of ctx,
+// in which case it returns the context's error.
+func (n *Node) Run(ctx context.Context, gossip bool) error {
+	// Shutdown the node when the context is cancelled
+	go func() {
+		select {
+		case <-ctx.Done():
+			n.logger.WithError(ctx.Err()).Debug("Context done")
+			if err := n.Shutdown(); err != nil {
+				n.logger.WithError(err).Error("Shutdown")
+			}
+		case <-n.shutdownCh:
+		}
+	}()
+
 	// The ControlTimer allows the background routines to control the heartbeat
 	// timer when the node is in the Babbling state. The timer should only be
 	// running when there are uncommitted transactions in the system.
@@ -133,6 +197,12 @@ func (n *Node) Run(gossip bool) {
 	// Execute some background work regardless of the state of the node.
 	go n.doBackgroundWork()
 
+	// Watch for consensus stalls
+	go n.watchdog()
+
+	// Prune the Store periodically
+	go n.pruner()
+
 	//Execute Node State Machine
 	for {
 		//Run different routines depending on node state
@@ -148,56 +218,118 @@ func (n *Node) Run(gossip bool) {
 		case Joining:
 			n.join()
 		case Shutdown:
-			return
+			//wait for the shutdown to complete before returning
+			<-n.doneCh
+			return ctx.Err()
 		}
 	}
 }
 
-// RunAsync runs the node in a separate goroutine
+// RunAsync runs the node in a separate goroutine, without any context
+// cancellation.
 func (n *Node) RunAsync(gossip bool) {
 	n.logger.WithField("gossip", gossip).Debug("runasync")
-	go n.Run(gossip)
+	go func() {
+		if err := n.Run(context.Background(), gossip); err != nil {
+			n.logger.WithError(err).Error("Run")
+		}
+	}()
 }
 
 // Leave causes the node to politely leave the network via a LeaveRequest and
-// wait for the node to be removed from the validator-list via consensus.
-func (n *Node) Leave() error {
+// wait for the node to be removed from the validator-list via consensus. The
+// node is shut down when Leave returns, whether it succeeded or not.
+func (n *Node) Leave(ctx context.Context) error {
 	n.logger.Debug("LEAVING")
 
-	defer n.Shutdown()
-
-	err := n.core.Leave(n.conf.JoinTimeout)
+	err := n.core.Leave(ctx, n.conf.JoinTimeout)
 	if err != nil {
 		n.logger.WithError(err).Error("Leaving")
-		return err
 	}
 
-	return nil
+	notif := Notification{Type: LeaveCompleted}
+	if err != nil {
+		notif.Error = err.Error()
+	}
+	n.notifier.publish(notif)
+
+	if serr := n.Shutdown(); err == nil {
+		err = serr
+	}
+
+	return err
 }
 
 // Shutdown attempts to cleanly shutdown the node by waiting for pending work to
-// be finished, stopping the control-timer, and closing the transport.
-func (n *Node) Shutdown() {
-	if n.getState() != Shutdown {
-		n.logger.Debug("Shutdown")
+// be finished, stopping the control-timer, and closing the transport and the
+// store. It is safe to call Shutdown more than once.
+func (n *Node) Shutdown() error {
+	n.shutdownLock.Lock()
+	defer n.shutdownLock.Unlock()
+
+	select {
+	case <-n.doneCh:
+		return nil
+	default:
+	}
+
+	n.logger.Debug("Shutdown")
+
+	//Exit any non-shutdown state immediately
+	n.setState(Shutdown)
+
+	//Stop and wait for concurrent operations
+	close(n.shutdownCh)
+
+	n.waitRoutines()
 
-		//Exit any non-shutdown state immediately
-		n.setState(Shutdown)
+	//For some reason this needs to be called after closing the shutdownCh
+	//Not entirely sure why...
+	n.controlTimer.Shutdown()
 
-		//Stop and wait for concurrent operations
-		close(n.shutdownCh)
+	//transport and store should only be closed once all concurrent operations
+	//are finished otherwise they will panic trying to use close objects
+	terr := n.trans.Close()
+	serr := n.core.hg.Store.Close()
 
-		n.waitRoutines()
+	n.notifier.close()
+
+	close(n.doneCh)
+
+	if terr != nil {
+		return terr
+	}
+
+	return serr
+}
+
+// Done returns a channel that is closed once the node has shut down.
+func (n *Node) Done() <-chan struct{} {
+	return n.doneCh
+}
 
-		//For some reason this needs to be called after closing the shutdownCh
-		//Not entirely sure why...
-		n.controlTimer.Shutdown()
+// Subscribe returns a Subscription which receives Notifications about state
+// transitions, consensus rounds, committed blocks, validator-set changes, and
+// join/leave outcomes. bufferSize Notifications are buffered before they start
+// being dropped. If types are given, only Notifications of those types are
+// delivered, so that others do not fill the buffer. The Subscription is closed
+// when the node shuts down.
+func (n *Node) Subscribe(bufferSize int, types ...NotificationType) *Subscription {
+	return n.notifier.subscribe(bufferSize, types...)
+}
 
-		//transport and store should only be closed once all concurrent operations
-		//are finished otherwise they will panic trying to use close objects
-		n.trans.Close()
+// SubmitTx adds a transaction to the node's transaction-pool, as if it had
+// been submitted by the application.
+func (n *Node) SubmitTx(tx []byte) error {
+	if n.conf.Observer {
+		return fmt.Errorf("Observers do not accept transactions")
+	}
 
-		n.core.hg.Store.Close()
+	select {
+	case n.submitCh <- tx:
+		return nil
+	case <-n.shutdownCh:
+		return fmt.Errorf("Node is shut down")
 	}
 }
 
@@ -245,6 +377,8 @@ func (n *Node) GetStats() map[string]string {
 		"id":                     fmt.Sprint(n.core.validator.ID()),
 		"state":                  n.getState().String(),
 		"moniker":                n.core.validator.Moniker,
+		"observer":               strconv.FormatBool(n.conf.Observer),
+		"gossip_paused":          strconv.FormatBool(n.IsGossipPaused()),
 	}
 	return s
 }
@@ -254,6 +388,51 @@ func (n *Node) GetBlock(blockIndex int) (*hg.Block, error) {
 	return n.core.hg.Store.GetBlock(blockIndex)
 }
 
+// GetLastBlockIndex returns the index of the last block in the Store. Blocks
+// are stored before they are committed to the application; see
+// GetLastCommittedBlockIndex.
+func (n *Node) GetLastBlockIndex() int {
+	n.coreLock.Lock()
+	defer n.coreLock.Unlock()
+
+	return n.core.GetLastBlockIndex()
+}
+
+// GetLastCommittedBlockIndex returns the index of the last block committed to
+// the application, with its StateHash. It is -1 until then.
+func (n *Node) GetLastCommittedBlockIndex() int {
+	n.coreLock.Lock()
+	defer n.coreLock.Unlock()
+
+	return n.core.GetLastCommittedBlockIndex()
+}
+
+// GetEvent returns an event
+func (n *Node) GetEvent(hash string) (*hg.Event, error) {
+	return n.core.hg.Store.GetEvent(hash)
+}
+
+// GetRound returns the information about a round: its events, witnesses and
+// their fame
+func (n *Node) GetRound(roundIndex int) (*hg.RoundInfo, error) {
+	return n.core.hg.Store.GetRound(roundIndex)
+}
+
+// GetPeerSet returns the validator-set effective at a given round
+func (n *Node) GetPeerSet(round int) ([]*peers.Peer, error) {
+	peerSet, err := n.core.hg.Store.GetPeerSet(round)
+	if err != nil {
+		return nil, err
+	}
+	return peerSet.Peers, nil
+}
+
+// GetAllPeerSets returns all the validator-sets, indexed by the round from
+// which they are effective
+func (n *Node) GetAllPeerSets() (map[int][]*peers.Peer, error) {
+	return n.core.hg.Store.GetAllPeerSets()
+}
+
 // GetPeers returns the current peers
 func (n *Node) GetPeers() []*peers.Peer {
 	return n.core.peers.Peers
@@ -268,8 +447,8 @@ func (n *Node) GetGenesisPeers() []*peers.Peer {
 Background
 *******************************************************************************/
 
-// doBackgroundWork coninuously listens to incoming RPC commands, incoming
-// transactions, and the sigint signal, regardless of the node's state.
+// doBackgroundWork coninuously listens to incoming RPC commands and incoming
+// transactions, regardless of the node's state.
 func (n *Node) doBackgroundWork() {
 	for {
 		select {
@@ -285,10 +464,6 @@ func (n *Node) doBackgroundWork() {
 			n.resetTimer()
 		case <-n.shutdownCh:
 			return
-		case <-n.sigintCh:
-			n.logger.Debug("Reacting to SIGINT - LEAVE")
-			n.Leave()
-			os.Exit(0)
 		}
 	}
 }
@@ -299,6 +474,8 @@ func (n *Node) resetTimer() {
 	n.coreLock.Lock()
 	defer n.coreLock.Unlock()
 
+	n.core.trackPendingWork()
+
 	if !n.controlTimer.set {
 		ts := n.conf.HeartbeatTimeout
 
@@ -323,11 +500,16 @@ func (n *Node) huron(gossip bool) {
 	for {
 		select {
 		case <-n.controlTimer.tickCh:
-			if gossip {
-				peer := n.core.peerSelector.Next()
+			//Exit when the state was changed, for example by ForceFastSync
+			if n.getState() != Babbling {
+				return
+			}
+
+			if gossip && !n.IsGossipPaused() {
+				peer := n.nextPeer()
 				if peer != nil {
 					n.goFunc(func() { n.gossip(peer) })
-				} else {
+				} else if n.isAlone() {
 					n.monologue()
 				}
 			}
@@ -338,13 +520,32 @@ func (n *Node) huron(gossip bool) {
 	}
 }
 
+// nextPeer returns the next peer to talk to, or nil if all peers are backing
+// off after failures.
+func (n *Node) nextPeer() *peers.Peer {
+	n.core.selectorLock.Lock()
+	defer n.core.selectorLock.Unlock()
+
+	return n.core.peerSelector.Next()
+}
+
+// isAlone returns true if the node does not know any other peer
+func (n *Node) isAlone() bool {
+	n.core.selectorLock.Lock()
+	defer n.core.selectorLock.Unlock()
+
+	_, others := peers.ExcludePeer(n.core.peerSelector.Peers().Peers, n.GetID())
+
+	return len(others) == 0
+}
+
 // monologue is called when the node is alone in the network but wants to record
 // some events anyway.
 func (n *Node) monologue() error {
 	n.coreLock.Lock()
 	defer n.coreLock.Unlock()
 
-	if n.core.Busy() {
+	if n.core.Busy() && !n.conf.Observer {
 		err := n.core.AddSelfEvent("")
 		if err != nil {
 			n.logger.WithError(err).Error("monologue, AddSelfEvent()")
@@ -363,6 +564,8 @@ func (n *Node) monologue() error {
 
 // gossip performs a pull-push gossip operation with the selected peer.
 func (n *Node) gossip(peer *peers.Peer) error {
+	gossips.Inc()
+
 	//pull
 	otherKnownEvents, err := n.pull(peer)
 	if err != nil {
@@ -370,11 +573,15 @@ func (n *Node) gossip(peer *peers.Peer) error {
 		return err
 	}
 
-	//push
-	err = n.push(peer, otherKnownEvents)
-	if err != nil {
-		n.logger.WithError(err).Error("gossip push")
-		return err
+	//push. Observers only push to other observers, because validators do
+	//not need to hear about their own events from a node that does not create
+	//any.
+	if !n.conf.Observer || !n.core.IsValidator(peer.ID()) {
+		err = n.push(peer, otherKnownEvents)
+		if err != nil {
+			n.logger.WithError(err).Error("gossip push")
+			return err
+		}
 	}
 
 	//update peer selector
@@ -400,11 +607,18 @@ func (n *Node) pull(peer *peers.Peer) (otherKnownEvents map[uint32]int, err erro
 	elapsed := time.Since(start)
 	n.logger.WithField("duration", elapsed.Nanoseconds()).Debug("requestSync()")
 
+	syncRequests.Inc()
+
 	if err != nil {
+		syncErrors.Inc()
 		n.logger.WithField("error", err).Error("requestSync()")
+		n.reportGossip(peer.ID(), GossipResult{Err: err})
 		return nil, err
 	}
 
+	n.contacts.record(peer.ID())
+	n.knowledge.update(peer.ID(), resp.Known)
+
 	n.logger.WithFields(logrus.Fields{
 		"from_id": resp.FromID,
 		"events":  len(resp.Events),
@@ -413,10 +627,28 @@ func (n *Node) pull(peer *peers.Peer) (otherKnownEvents map[uint32]int, err erro
 
 	//Add Events to Hashgraph and create new Head if necessary
 	n.coreLock.Lock()
+	seq := n.core.Seq
 	err = n.sync(peer.ID(), resp.Events)
+	created := n.core.Seq > seq
+	ownKnown := n.core.KnownEvents()
 	n.coreLock.Unlock()
 
+	//Report the known-events after the sync, so that the events just pulled
+	//from the peer no longer count as unknown
+	n.reportGossip(peer.ID(), GossipResult{
+		RTT:      elapsed,
+		Known:    resp.Known,
+		OwnKnown: ownKnown,
+	})
+
+	//Fan out the new self-event. Events created upon EagerSyncRequests are not
+	//fanned out, lest every push triggers others in cascade.
+	if created {
+		n.triggerFanOut(peer.ID())
+	}
+
 	if err != nil {
+		syncErrors.Inc()
 		n.logger.WithField("error", err).Error("sync()")
 		return nil, err
 	}
@@ -462,8 +694,11 @@ func (n *Node) push(peer *peers.Peer, knownEvents map[uint32]int) error {
 		n.logger.WithField("duration", elapsed.Nanoseconds()).Debug("requestEagerSync()")
 		if err != nil {
 			n.logger.WithField("error", err).Error("requestEagerSync()")
+			n.reportGossip(peer.ID(), GossipResult{Err: err})
 			return err
 		}
+		n.contacts.record(peer.ID())
+		n.knowledge.updateFromEvents(peer.ID(), wireEvents)
 		n.logger.WithFields(logrus.Fields{
 			"from_id": resp2.FromID,
 			"success": resp2.Success,
@@ -473,6 +708,13 @@ func (n *Node) push(peer *peers.Peer, knownEvents map[uint32]int) error {
 	return nil
 }
 
+// reportGossip passes the outcome of a gossip exchange to the peer-selector
+func (n *Node) reportGossip(peer uint32, result GossipResult) {
+	n.core.selectorLock.Lock()
+	n.core.peerSelector.Report(peer, result)
+	n.core.selectorLock.Unlock()
+}
+
 // sync attempts to insert a list of events into the hashgraph, record a new
 // sync event, and process the signature pool.
 func (n *Node) sync(fromID uint32, events []hg.WireEvent) error {
@@ -556,13 +798,42 @@ func (n *Node) getBestFastForwardResponse() *net.FastForwardResponse {
 	var bestResponse *net.FastForwardResponse
 	maxBlock := 0
 
-	for _, p := range n.core.peerSelector.Peers().Peers {
-		start := time.Now()
-		resp, err := n.requestFastForward(p.NetAddr)
-		elapsed := time.Since(start)
-		n.logger.WithField("duration", elapsed.Nanoseconds()).Debug("requestFastForward()")
-		if err != nil {
-			n.logger.WithField("error", err).Error("requestFastForward()")
+	n.core.selectorLock.Lock()
+	peerSet := n.core.peerSelector.Peers()
+	n.core.selectorLock.Unlock()
+
+	//Request all the available peers in parallel, so that dead peers do not
+	//delay each other.
+	responses := make(chan *net.FastForwardResponse)
+	requests := 0
+
+	for _, p := range peerSet.Peers {
+		if p.ID() == n.GetID() || !n.core.peerHealth.acquire(p.ID()) {
+			continue
+		}
+
+		requests++
+
+		go func(p *peers.Peer) {
+			start := time.Now()
+			resp, err := n.requestFastForward(p.NetAddr)
+			elapsed := time.Since(start)
+			n.logger.WithField("duration", elapsed.Nanoseconds()).Debug("requestFastForward()")
+			if err != nil {
+				n.logger.WithField("error", err).Error("requestFastForward()")
+				n.core.peerHealth.failure(p.ID())
+				responses <- nil
+				return
+			}
+
+			n.core.peerHealth.success(p.ID(), elapsed)
+			responses <- &resp
+		}(p)
+	}
+
+	for i := 0; i < requests; i++ {
+		resp := <-responses
+		if resp == nil {
 			continue
 		}
 
@@ -577,7 +848,7 @@ func (n *Node) getBestFastForwardResponse() *net.FastForwardResponse {
 		}).Debug("FastForwardResponse")
 
 		if resp.Block.Index() > maxBlock {
-			bestResponse = &resp
+			bestResponse = resp
 			maxBlock = resp.Block.Index()
 		}
 	}
@@ -594,7 +865,15 @@ Joining
 func (n *Node) join() error {
 	n.logger.Debug("JOINING")
 
-	peer := n.core.peerSelector.Next()
+	peer := n.nextPeer()
+	if peer == nil {
+		//All peers are backing off. Wait before trying again.
+		select {
+		case <-time.After(minPeerBackoff):
+		case <-n.shutdownCh:
+		}
+		return fmt.Errorf("No peer available to join")
+	}
 
 	start := time.Now()
 	resp, err := n.requestJoin(peer.NetAddr)
@@ -603,9 +882,12 @@ func (n *Node) join() error {
 
 	if err != nil {
 		n.logger.Error("Cannot join:", peer.NetAddr, err)
+		n.core.peerHealth.failure(peer.ID())
 		return err
 	}
 
+	n.core.peerHealth.success(peer.ID(), elapsed)
+
 	n.logger.WithFields(logrus.Fields{
 		"from_id":        resp.FromID,
 		"accepted":       resp.Accepted,
@@ -614,11 +896,19 @@ func (n *Node) join() error {
 	}).Debug("JoinResponse")
 
 	if resp.Accepted {
+		n.notifier.publish(Notification{
+			Type:  JoinAccepted,
+			Round: resp.AcceptedRound,
+			Peers: resp.Peers,
+		})
+
 		n.core.AcceptedRound = resp.AcceptedRound
 		n.setBabblingOrCatchingUpState()
 	} else {
 		// Then JoinRequest was explicitly refused by the curren peer-set. This
 		// is not an error.
+		n.notifier.publish(Notification{Type: JoinRefused})
+
 		n.logger.Debug("JoinRequest refused. Shutting down.")
 		n.Shutdown()
 	}
@@ -630,6 +920,21 @@ func (n *Node) join() error {
 Utils
 *******************************************************************************/
 
+// setState overrides the embedded state's setState to notify subscribers of
+// state transitions.
+func (n *Node) setState(s State) {
+	prev := n.getState()
+
+	n.state.setState(s)
+
+	if prev != s {
+		n.notifier.publish(Notification{
+			Type:  StateChanged,
+			State: s.String(),
+		})
+	}
+}
+
 // setBabblingOrCatchingUpState sets the node's state to CatchingUp if fast-sync
 // is enabled, or to Babbling if fast-sync is not enabled.
 func (n *Node) setBabblingOrCatchingUpState() {
@@ -648,6 +953,11 @@ func (n *Node) setBabblingOrCatchingUpState() {
 // addTransaction is a thread-safe function to add and incoming transaction to
 // the core's transaction-pool.
 func (n *Node) addTransaction(tx []byte) {
+	if n.conf.Observer {
+		n.logger.Warning("Observers do not accept transactions. Dropping transaction")
+		return
+	}
+
 	n.coreLock.Lock()
 	defer n.coreLock.Unlock()
 
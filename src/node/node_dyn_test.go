@@ -1,6 +1,7 @@
 package node
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"testing"
@@ -98,7 +99,7 @@ func TestLeaveRequest(t *testing.T) {
 
 	leavingNode := nodes[3]
 
-	err = leavingNode.Leave()
+	err = leavingNode.Leave(context.Background())
 	if err != nil {
 		t.Fatalf("Fatal Error: %v", err)
 	}
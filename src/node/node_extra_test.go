@@ -3,6 +3,7 @@
 package node
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"fmt"
 	"testing"
@@ -98,7 +99,7 @@ func TestSuccessiveLeaveRequestExtra(t *testing.T) {
 
 		leavingNode := nodes[n-1]
 
-		err = leavingNode.Leave()
+		err = leavingNode.Leave(context.Background())
 		if err != nil {
 			t.Error("Fatal Error 2", err)
 			t.Fatal(err)
@@ -148,13 +149,13 @@ func TestSimultaneousLeaveRequestExtra(t *testing.T) {
 	leavingNode := nodes[3]
 	leavingNode2 := nodes[2]
 
-	err = leavingNode.Leave()
+	err = leavingNode.Leave(context.Background())
 	if err != nil {
 		t.Error("Fatal Error 2", err)
 		t.Fatal(err)
 	}
 
-	err = leavingNode2.Leave()
+	err = leavingNode2.Leave(context.Background())
 	if err != nil {
 		t.Error("Fatal Error 3", err)
 		t.Fatal(err)
@@ -191,7 +192,7 @@ func TestJoinLeaveRequestExtra(t *testing.T) {
 
 	leavingNode := nodes[3]
 
-	err = leavingNode.Leave()
+	err = leavingNode.Leave(context.Background())
 	if err != nil {
 		t.Error("Fatal Error 2", err)
 		t.Fatal(err)
@@ -398,7 +399,7 @@ func gossipAndCheck(nodes []*Node, targetBlockInc int, timeOutSeconds time.Durat
 
 func leaveNode(msg string, node *Node, t *testing.T) {
 	t.Log(msg)
-	err := node.Leave()
+	err := node.Leave(context.Background())
 	if err != nil {
 		t.Log("Fatal Error "+msg, err)
 		t.Fatal(msg+" Leave", err)
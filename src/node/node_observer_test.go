@@ -0,0 +1,122 @@
+package node
+
+import (
+	"crypto/ecdsa"
+	"testing"
+	"time"
+
+	"github.com/abassian/huron/src/common"
+	hg "github.com/abassian/huron/src/hashgraph"
+	"github.com/abassian/huron/src/net"
+	"github.com/abassian/huron/src/peers"
+	dummy "github.com/abassian/huron/src/proxy/dummy"
+	"github.com/sirupsen/logrus"
+)
+
+/*
+
+Tests for nodes running in observer mode. An observer follows consensus and
+commits blocks without belonging to the validator-set.
+
+*/
+
+func TestObserver(t *testing.T) {
+	logger := common.NewTestLogger(t)
+
+	keys, allPeers := initPeers(t, 4)
+
+	//The first 3 peers are validators, the last one is an observer which knows
+	//about everyone.
+	validators := peers.NewPeerSet(allPeers.Peers[:3])
+	genesisPeerSet := clonePeerSet(t, validators.Peers)
+
+	nodes := initNodes(keys[:3], validators, genesisPeerSet, 1000, 400, 5, false, "inmem", 10*time.Millisecond, logger, t)
+	defer shutdownNodes(nodes)
+
+	observer := newObserver(allPeers.Peers[3], keys[3], allPeers, genesisPeerSet, logger, t)
+	defer observer.Shutdown()
+
+	observer.RunAsync(true)
+
+	target := 20
+	err := gossip(nodes, target, false, 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//wait for the observer to catch up with the validators
+	timeout := time.After(10 * time.Second)
+	for observer.core.GetLastBlockIndex() < target {
+		select {
+		case <-timeout:
+			t.Fatalf("Timeout waiting for observer to reach block %d, currently %d",
+				target, observer.core.GetLastBlockIndex())
+		default:
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	checkGossip(append(nodes, observer), 0, t)
+
+	if state := observer.getState(); state != Babbling {
+		t.Fatalf("Observer should be Babbling, not %s", state)
+	}
+
+	//The observer must not have created any Event
+	if _, ok := observer.core.hg.Store.RepertoireByID()[observer.core.validator.ID()]; ok {
+		t.Fatalf("Observer should not be in the repertoire")
+	}
+	if observer.core.Seq != -1 {
+		t.Fatalf("Observer Seq should be -1, not %d", observer.core.Seq)
+	}
+
+	if err := observer.SubmitTx([]byte("observer tx")); err == nil {
+		t.Fatalf("Observer should refuse transactions")
+	}
+
+	//The observer must not have joined the validator-set
+	for i, n := range nodes {
+		if n.core.IsValidator(observer.core.validator.ID()) {
+			t.Fatalf("Observer should not be a validator for node %d", i)
+		}
+	}
+}
+
+func newObserver(peer *peers.Peer,
+	k *ecdsa.PrivateKey,
+	peerSet *peers.PeerSet,
+	genesisPeerSet *peers.PeerSet,
+	logger *logrus.Logger,
+	t *testing.T) *Node {
+
+	conf := NewConfig(
+		10*time.Millisecond,
+		time.Second,
+		5*time.Second,
+		1000,
+		400,
+		false,
+		logger,
+	)
+	conf.Observer = true
+
+	trans, err := net.NewTCPTransport(peer.NetAddr,
+		nil, 2, conf.TCPTimeout, conf.JoinTimeout, logger)
+	if err != nil {
+		t.Fatalf("Fatal failed to create transport for observer: %s", err)
+	}
+
+	node := NewNode(conf,
+		NewValidator(k, peer.Moniker),
+		peerSet,
+		genesisPeerSet,
+		hg.NewInmemStore(conf.CacheSize),
+		trans,
+		dummy.NewInmemDummyClient(logger))
+
+	if err := node.Init(); err != nil {
+		t.Fatalf("Fatal failed to initialize observer: %s", err)
+	}
+
+	return node
+}
@@ -89,6 +89,10 @@ func (n *Node) processRPC(rpc net.RPC) {
 	case *net.FastForwardRequest:
 		n.processFastForwardRequest(rpc, cmd)
 	case *net.JoinRequest:
+		if n.conf.Observer {
+			rpc.Respond(nil, fmt.Errorf("Observers do not process JoinRequests"))
+			return
+		}
 		n.processJoinRequest(rpc, cmd)
 	default:
 		n.logger.WithField("cmd", rpc.Command).Error("Unexpected RPC command")
@@ -103,6 +107,9 @@ func (n *Node) processSyncRequest(rpc net.RPC, cmd *net.SyncRequest) {
 		"known":      cmd.Known,
 	}).Debug("process SyncRequest")
 
+	n.contacts.record(cmd.FromID)
+	n.knowledge.update(cmd.FromID, cmd.Known)
+
 	resp := &net.SyncResponse{
 		FromID: n.core.validator.ID(),
 	}
@@ -177,6 +184,9 @@ func (n *Node) processEagerSyncRequest(rpc net.RPC, cmd *net.EagerSyncRequest) {
 		"events":  len(cmd.Events),
 	}).Debug("EagerSyncRequest")
 
+	n.contacts.record(cmd.FromID)
+	n.knowledge.updateFromEvents(cmd.FromID, cmd.Events)
+
 	success := true
 
 	n.coreLock.Lock()
@@ -207,27 +217,16 @@ func (n *Node) processFastForwardRequest(rpc net.RPC, cmd *net.FastForwardReques
 
 	var respErr error
 
-	//Get latest Frame
-	n.coreLock.Lock()
-	block, frame, err := n.core.GetAnchorBlockWithFrame()
-	n.coreLock.Unlock()
+	//Get the base Block, Frame, and Snapshot
+	block, frame, snapshot, err := n.getFastForwardBase()
 
 	if err != nil {
-		n.logger.WithError(err).Error("Getting Frame")
+		n.logger.WithError(err).Error("Getting FastForward base")
 		respErr = err
 	} else {
 		resp.Block = *block
 		resp.Frame = *frame
-
-		//Get snapshot
-		snapshot, err := n.proxy.GetSnapshot(block.Index())
-
-		if err != nil {
-			n.logger.WithField("error", err).Error("Getting Snapshot")
-			respErr = err
-		} else {
-			resp.Snapshot = snapshot
-		}
+		resp.Snapshot = snapshot
 	}
 
 	n.logger.WithFields(logrus.Fields{
@@ -240,6 +239,46 @@ func (n *Node) processFastForwardRequest(rpc net.RPC, cmd *net.FastForwardReques
 	rpc.Respond(resp, respErr)
 }
 
+// getFastForwardBase returns the Block, Frame and Snapshot used to answer a
+// FastForwardRequest. It prefers the newest stored snapshot whose Block has
+// collected enough signatures, and falls back to the AnchorBlock with a
+// snapshot requested from the App on demand.
+func (n *Node) getFastForwardBase() (*hg.Block, *hg.Frame, []byte, error) {
+	for _, index := range n.snapshots.Indexes() {
+		n.coreLock.Lock()
+		block, frame, err := n.core.GetBlockWithFrame(index)
+		n.coreLock.Unlock()
+
+		if err != nil {
+			n.logger.WithError(err).Debugf("Snapshot %d is not a suitable base", index)
+			continue
+		}
+
+		snapshot, err := n.snapshots.Get(index)
+		if err != nil {
+			n.logger.WithError(err).Errorf("Reading Snapshot %d", index)
+			continue
+		}
+
+		return block, frame, snapshot, nil
+	}
+
+	n.coreLock.Lock()
+	block, frame, err := n.core.GetAnchorBlockWithFrame()
+	n.coreLock.Unlock()
+
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	snapshot, err := n.proxy.GetSnapshot(block.Index())
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return block, frame, snapshot, nil
+}
+
 func (n *Node) processJoinRequest(rpc net.RPC, cmd *net.JoinRequest) {
 	n.logger.WithFields(logrus.Fields{
 		"peer": cmd.InternalTransaction.Body.Peer,
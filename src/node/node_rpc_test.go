@@ -1,6 +1,8 @@
 package node
 
 import (
+	"io/ioutil"
+	"os"
 	"reflect"
 	"testing"
 	"time"
@@ -9,6 +11,7 @@ import (
 	hg "github.com/abassian/huron/src/hashgraph"
 	"github.com/abassian/huron/src/net"
 	dummy "github.com/abassian/huron/src/proxy/dummy"
+	"github.com/sirupsen/logrus"
 )
 
 func TestProcessSync(t *testing.T) {
@@ -263,3 +266,92 @@ func TestProcessFastForward(t *testing.T) {
 	node0.Shutdown()
 	node1.Shutdown()
 }
+
+func TestGetFastForwardBase(t *testing.T) {
+	//The nodes do not log to the test, because their goroutines may still log
+	//after the test completes
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+
+	keys, peers := initPeers(t, 4)
+	genesisPeerSet := clonePeerSet(t, peers.Peers)
+
+	nodes := initNodes(keys, peers, genesisPeerSet, 1000, 1000, 5, false, "inmem", 10*time.Millisecond, logger, t)
+	if err := gossip(nodes, 10, true, 6*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	node := nodes[0]
+
+	//Find three Blocks with enough signatures to be a base
+	signed := []int{}
+	for i := 0; i <= node.core.GetLastBlockIndex() && len(signed) < 3; i++ {
+		if _, _, err := node.core.GetBlockWithFrame(i); err == nil {
+			signed = append(signed, i)
+		}
+	}
+	if len(signed) < 3 {
+		t.Fatalf("There should be 3 signed Blocks, not %d", len(signed))
+	}
+
+	//The newest of them loses its signatures
+	under := signed[2]
+	block, err := node.core.hg.Store.GetBlock(under)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned := *block
+	unsigned.Signatures = make(map[string]string)
+	if err := node.core.hg.Store.SetBlock(&unsigned); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "ffbase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	node.snapshots = NewSnapshotManager(dir, 1000, 0, nil, node.proxy.GetSnapshot, nil)
+	if err := node.snapshots.Init(false); err != nil {
+		t.Fatal(err)
+	}
+	for _, index := range signed {
+		if err := node.snapshots.Take(index); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//The under-signed snapshot Block is skipped for the newest signed one
+	base, frame, snapshot, err := node.getFastForwardBase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base.Index() != signed[1] {
+		t.Fatalf("The base should be Block %d, not %d", signed[1], base.Index())
+	}
+	if frame.Round != base.RoundReceived() {
+		t.Fatalf("The Frame should be that of round %d, not %d", base.RoundReceived(), frame.Round)
+	}
+	if stored, _ := node.snapshots.Get(signed[1]); !reflect.DeepEqual(snapshot, stored) {
+		t.Fatalf("The snapshot should be the stored one")
+	}
+
+	//Without snapshots, the AnchorBlock is used with a snapshot from the App
+	node.snapshots = NewSnapshotManager("", 0, 0, nil, node.proxy.GetSnapshot, nil)
+
+	base, _, snapshot, err = node.getFastForwardBase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if anchor := node.core.hg.AnchorBlock; anchor == nil || base.Index() != *anchor {
+		t.Fatalf("The base should be the AnchorBlock, not %d", base.Index())
+	}
+	appSnapshot, err := node.proxy.GetSnapshot(base.Index())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(snapshot, appSnapshot) {
+		t.Fatalf("The snapshot should be requested from the App")
+	}
+}
@@ -1,12 +1,14 @@
 package node
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"testing"
@@ -120,6 +122,51 @@ func TestAddTransaction(t *testing.T) {
 	node1.Shutdown()
 }
 
+func TestRunContext(t *testing.T) {
+	logger := common.NewTestLogger(t)
+	keys, peers := initPeers(t, 2)
+
+	genesisPeerSet := clonePeerSet(t, peers.Peers)
+
+	nodes := initNodes(keys, peers, genesisPeerSet, 1000, 400, 5, false, "inmem", 10*time.Millisecond, logger, t)
+	defer shutdownNodes(nodes)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	errCh := make(chan error, len(nodes))
+	for _, n := range nodes {
+		node := n
+		go func() {
+			errCh <- node.Run(ctx, true)
+		}()
+	}
+
+	cancel()
+
+	for range nodes {
+		select {
+		case err := <-errCh:
+			if err != context.Canceled {
+				t.Fatalf("Fatal Run should return context.Canceled, not %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Fatal Timeout waiting for Run to return")
+		}
+	}
+
+	for i, n := range nodes {
+		select {
+		case <-n.Done():
+		default:
+			t.Fatalf("Fatal node %d should be done", i)
+		}
+
+		if state := n.getState(); state != Shutdown {
+			t.Fatalf("Fatal node %d should be in Shutdown state, not %s", i, state)
+		}
+	}
+}
+
 func TestGossip(t *testing.T) {
 	logger := common.NewTestLogger(t)
 	keys, peers := initPeers(t, 4)
@@ -217,16 +264,24 @@ func TestShutdown(t *testing.T) {
 }
 
 func TestBootstrapAllNodes(t *testing.T) {
+	for _, storeType := range []string{"badger", "bolt"} {
+		t.Run(storeType, func(t *testing.T) {
+			testBootstrapAllNodes(storeType, t)
+		})
+	}
+}
+
+func testBootstrapAllNodes(storeType string, t *testing.T) {
 	os.RemoveAll("test_data")
 	os.Mkdir("test_data", os.ModeDir|0777)
 
-	//create a first network with BadgerStore and wait till it reaches 10 blocks
-	//before shutting it down
+	//create a first network with a persistent Store and wait till it reaches 10
+	//blocks before shutting it down
 	logger := common.NewTestLogger(t)
 	keys, peers := initPeers(t, 4)
 	genesisPeerSet := clonePeerSet(t, peers.Peers)
 
-	nodes := initNodes(keys, peers, genesisPeerSet, 1000, 1000, 10, false, "badger", 6*time.Millisecond, logger, t)
+	nodes := initNodes(keys, peers, genesisPeerSet, 1000, 1000, 10, false, storeType, 6*time.Millisecond, logger, t)
 
 	err := gossip(nodes, 10, true, 3*time.Second)
 	if err != nil {
@@ -338,6 +393,18 @@ func newNode(peer *peers.Peer,
 		if err != nil {
 			t.Fatalf("Fatal failed to create BadgerStore for peer %d: %s", peer.ID(), err)
 		}
+	case "bolt":
+		dir, _ := ioutil.TempDir("test_data", "bolt")
+		store, err = hg.NewBoltStore(conf.CacheSize, filepath.Join(dir, "bolt.db"))
+		if err != nil {
+			t.Fatalf("Fatal failed to create BoltStore for peer %d: %s", peer.ID(), err)
+		}
+	case "hybrid":
+		dir, _ := ioutil.TempDir("test_data", "hybrid")
+		store, err = hg.NewHybridStore(conf.CacheSize, filepath.Join(dir, "spill.seg"))
+		if err != nil {
+			t.Fatalf("Fatal failed to create HybridStore for peer %d: %s", peer.ID(), err)
+		}
 	case "inmem":
 		store = hg.NewInmemStore(conf.CacheSize)
 	}
@@ -418,13 +485,26 @@ func recycleNode(oldNode *Node, logger *logrus.Logger, t *testing.T) *Node {
 
 	var store hg.Store
 	var err error
-	if _, ok := oldNode.core.hg.Store.(*hg.BadgerStore); ok {
+	switch oldNode.core.hg.Store.(type) {
+	case *hg.BadgerStore:
 		store, err = hg.NewBadgerStore(conf.CacheSize, oldNode.core.hg.Store.StorePath())
 		if err != nil {
 			t.Error("Fatal Error recyleNode", err)
 			t.Fatal(err)
 		}
-	} else {
+	case *hg.BoltStore:
+		store, err = hg.NewBoltStore(conf.CacheSize, oldNode.core.hg.Store.StorePath())
+		if err != nil {
+			t.Error("Fatal Error recyleNode", err)
+			t.Fatal(err)
+		}
+	case *hg.HybridStore:
+		store, err = hg.NewHybridStore(conf.CacheSize, oldNode.core.hg.Store.StorePath())
+		if err != nil {
+			t.Error("Fatal Error recyleNode", err)
+			t.Fatal(err)
+		}
+	default:
 		store = hg.NewInmemStore(conf.CacheSize)
 	}
 
@@ -453,7 +533,7 @@ func runNodes(nodes []*Node, gossip bool) {
 	for _, n := range nodes {
 		node := n
 		go func() {
-			node.Run(gossip)
+			node.Run(context.Background(), gossip)
 		}()
 	}
 }
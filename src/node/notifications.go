@@ -0,0 +1,214 @@
+package node
+
+import (
+	"sync"
+
+	hg "github.com/abassian/huron/src/hashgraph"
+	"github.com/abassian/huron/src/peers"
+	"github.com/sirupsen/logrus"
+)
+
+// NotificationType identifies the kind of event described by a Notification
+type NotificationType uint32
+
+const (
+	//StateChanged is emitted when the node changes state
+	StateChanged NotificationType = iota
+	//RoundDecided is emitted when a new consensus round is reached
+	RoundDecided
+	//BlockCommitted is emitted when a block has been committed to the app
+	BlockCommitted
+	//ValidatorsChanged is emitted when a new validator-set is recorded
+	ValidatorsChanged
+	//JoinAccepted is emitted when the node's JoinRequest is accepted
+	JoinAccepted
+	//JoinRefused is emitted when the node's JoinRequest is refused
+	JoinRefused
+	//LeaveCompleted is emitted when the node has left the network, or failed
+	//to do so, in which case Error is set
+	LeaveCompleted
+)
+
+// String ...
+func (t NotificationType) String() string {
+	switch t {
+	case StateChanged:
+		return "StateChanged"
+	case RoundDecided:
+		return "RoundDecided"
+	case BlockCommitted:
+		return "BlockCommitted"
+	case ValidatorsChanged:
+		return "ValidatorsChanged"
+	case JoinAccepted:
+		return "JoinAccepted"
+	case JoinRefused:
+		return "JoinRefused"
+	case LeaveCompleted:
+		return "LeaveCompleted"
+	default:
+		return "Unknown"
+	}
+}
+
+// MarshalText ...
+func (t NotificationType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// Notification is a typed message describing something that happened in the
+// node. Only the fields relevant to the Type are set.
+type Notification struct {
+	Type NotificationType
+
+	// State is the new state of the node (StateChanged)
+	State string `json:",omitempty"`
+
+	// Round is the new consensus round (RoundDecided), the round from which
+	// the new validator-set is effective (ValidatorsChanged), or the accepted
+	// round (JoinAccepted).
+	Round int `json:",omitempty"`
+
+	// Block is the committed block (BlockCommitted)
+	Block *hg.Block `json:",omitempty"`
+
+	// Peers is the new validator-set (ValidatorsChanged, JoinAccepted)
+	Peers []*peers.Peer `json:",omitempty"`
+
+	// Error describes a failure (LeaveCompleted)
+	Error string `json:",omitempty"`
+}
+
+// Subscription receives the Notifications published by a node. Notifications
+// are dropped, rather than blocking the node, when the subscriber does not
+// keep up.
+type Subscription struct {
+	ch      chan Notification
+	dropped int
+	closed  bool
+
+	// types are the NotificationTypes delivered to the subscriber. All types
+	// are delivered when it is empty.
+	types map[NotificationType]bool
+
+	notifier *notifier
+}
+
+// Notifications returns the channel on which Notifications are delivered. It
+// is closed when the subscription is closed or the node shuts down.
+func (s *Subscription) Notifications() <-chan Notification {
+	return s.ch
+}
+
+// wants returns true if Notifications of type t are delivered to the
+// subscriber
+func (s *Subscription) wants(t NotificationType) bool {
+	return len(s.types) == 0 || s.types[t]
+}
+
+// Dropped returns the number of Notifications that could not be delivered
+// because the subscriber was too slow. Notifications of other types than those
+// subscribed to are not counted.
+func (s *Subscription) Dropped() int {
+	s.notifier.lock.Lock()
+	defer s.notifier.lock.Unlock()
+
+	return s.dropped
+}
+
+// Close stops the delivery of Notifications and closes the channel
+func (s *Subscription) Close() {
+	s.notifier.unsubscribe(s)
+}
+
+// notifier dispatches Notifications to subscribers. A nil notifier silently
+// discards everything, which lets Core run without a Node.
+type notifier struct {
+	subs   map[*Subscription]struct{}
+	closed bool
+	lock   sync.Mutex
+
+	logger *logrus.Entry
+}
+
+func newNotifier(logger *logrus.Entry) *notifier {
+	return &notifier{
+		subs:   make(map[*Subscription]struct{}),
+		logger: logger,
+	}
+}
+
+func (n *notifier) subscribe(bufferSize int, types ...NotificationType) *Subscription {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	s := &Subscription{
+		ch:       make(chan Notification, bufferSize),
+		types:    make(map[NotificationType]bool),
+		notifier: n,
+	}
+
+	for _, t := range types {
+		s.types[t] = true
+	}
+
+	if n.closed {
+		s.closed = true
+		close(s.ch)
+		return s
+	}
+
+	n.subs[s] = struct{}{}
+
+	return s
+}
+
+func (n *notifier) unsubscribe(s *Subscription) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if s.closed {
+		return
+	}
+
+	delete(n.subs, s)
+	s.closed = true
+	close(s.ch)
+}
+
+func (n *notifier) publish(notif Notification) {
+	if n == nil {
+		return
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	for s := range n.subs {
+		if !s.wants(notif.Type) {
+			continue
+		}
+
+		select {
+		case s.ch <- notif:
+		default:
+			s.dropped++
+			n.logger.WithField("type", notif.Type.String()).Debug("Dropping Notification for slow subscriber")
+		}
+	}
+}
+
+// close closes all the subscriptions. Subsequent subscriptions are closed
+// immediately.
+func (n *notifier) close() {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	for s := range n.subs {
+		s.closed = true
+		close(s.ch)
+	}
+
+	n.subs = make(map[*Subscription]struct{})
+	n.closed = true
+}
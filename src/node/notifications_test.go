@@ -0,0 +1,127 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/abassian/huron/src/common"
+)
+
+func TestNotifier(t *testing.T) {
+	n := newNotifier(common.NewTestLogger(t).WithField("id", "test"))
+
+	sub := n.subscribe(2)
+	slow := n.subscribe(1)
+
+	n.publish(Notification{Type: RoundDecided, Round: 1})
+	n.publish(Notification{Type: RoundDecided, Round: 2})
+
+	for i := 1; i <= 2; i++ {
+		notif := <-sub.Notifications()
+		if notif.Type != RoundDecided || notif.Round != i {
+			t.Fatalf("Notification %d should be RoundDecided %d, not %s %d", i, i, notif.Type, notif.Round)
+		}
+	}
+
+	//The slow subscriber only had room for one Notification
+	if d := slow.Dropped(); d != 1 {
+		t.Fatalf("Slow subscriber should have dropped 1 Notification, not %d", d)
+	}
+
+	sub.Close()
+	if _, ok := <-sub.Notifications(); ok {
+		t.Fatalf("Closed subscription should not deliver Notifications")
+	}
+
+	//Closing twice is harmless
+	sub.Close()
+
+	n.close()
+
+	<-slow.Notifications()
+	if _, ok := <-slow.Notifications(); ok {
+		t.Fatalf("Subscriptions should be closed with the notifier")
+	}
+
+	if _, ok := <-n.subscribe(1).Notifications(); ok {
+		t.Fatalf("Subscribing to a closed notifier should return a closed subscription")
+	}
+
+	//A filtered subscription only receives, and only drops, its types
+	n = newNotifier(common.NewTestLogger(t).WithField("id", "test"))
+	blocks := n.subscribe(1, BlockCommitted)
+
+	n.publish(Notification{Type: RoundDecided, Round: 1})
+	n.publish(Notification{Type: BlockCommitted, Round: 2})
+	n.publish(Notification{Type: StateChanged})
+
+	if notif := <-blocks.Notifications(); notif.Type != BlockCommitted || notif.Round != 2 {
+		t.Fatalf("Filtered subscription should receive BlockCommitted 2, not %s %d", notif.Type, notif.Round)
+	}
+
+	if d := blocks.Dropped(); d != 0 {
+		t.Fatalf("Filtered subscription should not have dropped Notifications, not %d", d)
+	}
+
+	//A nil notifier discards Notifications
+	var nilNotifier *notifier
+	nilNotifier.publish(Notification{Type: StateChanged})
+}
+
+func TestNodeNotifications(t *testing.T) {
+	logger := common.NewTestLogger(t)
+	keys, peers := initPeers(t, 4)
+
+	genesisPeerSet := clonePeerSet(t, peers.Peers)
+
+	nodes := initNodes(keys, peers, genesisPeerSet, 1000, 400, 5, false, "inmem", 10*time.Millisecond, logger, t)
+	defer shutdownNodes(nodes)
+
+	sub := nodes[0].Subscribe(1000)
+
+	target := 10
+	err := gossip(nodes, target, false, 6*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nodes[0].Shutdown()
+
+	lastBlock := -1
+	lastRound := -1
+	shutdown := false
+	for notif := range sub.Notifications() {
+		switch notif.Type {
+		case BlockCommitted:
+			if notif.Block.Index() != lastBlock+1 {
+				t.Fatalf("Block %d should follow block %d", notif.Block.Index(), lastBlock)
+			}
+			lastBlock = notif.Block.Index()
+		case RoundDecided:
+			if notif.Round <= lastRound {
+				t.Fatalf("Round %d should be greater than %d", notif.Round, lastRound)
+			}
+			lastRound = notif.Round
+		case StateChanged:
+			if notif.State == Shutdown.String() {
+				shutdown = true
+			}
+		}
+	}
+
+	if lastBlock < target {
+		t.Fatalf("Should have been notified of block %d, last was %d", target, lastBlock)
+	}
+
+	if lastRound < 0 {
+		t.Fatalf("Should have been notified of consensus rounds")
+	}
+
+	if !shutdown {
+		t.Fatalf("Should have been notified of the Shutdown state")
+	}
+
+	if d := sub.Dropped(); d != 0 {
+		t.Fatalf("No Notification should have been dropped, got %d", d)
+	}
+}
@@ -0,0 +1,202 @@
+package node
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// minPeerBackoff is how long a peer is avoided after its first failure.
+	// The delay doubles with every consecutive failure, up to maxPeerBackoff.
+	minPeerBackoff = time.Second
+	maxPeerBackoff = 30 * time.Second
+
+	// circuitThreshold is the number of consecutive failures after which the
+	// circuit to a peer is open: it is then only probed by a single request
+	// at a time, every maxPeerBackoff at most.
+	circuitThreshold = 5
+)
+
+// PeerHealth describes the recent outcome of the requests made to a peer
+type PeerHealth struct {
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastSuccess         *time.Time `json:"last_success,omitempty"`
+	LastFailure         *time.Time `json:"last_failure,omitempty"`
+	RTT                 string     `json:"rtt,omitempty"`
+	CircuitOpen         bool       `json:"circuit_open"`
+	RetryIn             string     `json:"retry_in,omitempty"`
+}
+
+type peerHealthEntry struct {
+	failures    int
+	lastSuccess time.Time
+	lastFailure time.Time
+	rtt         time.Duration
+
+	// probeStart is when the probe of an open circuit was sent. A probe
+	// which is not resolved within maxPeerBackoff is considered lost.
+	probeStart time.Time
+}
+
+// backoff returns how long to wait after the last failure before trying
+// again.
+func (e *peerHealthEntry) backoff() time.Duration {
+	if e.failures == 0 {
+		return 0
+	}
+
+	backoff := minPeerBackoff
+	for i := 1; i < e.failures && backoff < maxPeerBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxPeerBackoff {
+		backoff = maxPeerBackoff
+	}
+
+	return backoff
+}
+
+func (e *peerHealthEntry) circuitOpen() bool {
+	return e.failures >= circuitThreshold
+}
+
+func (e *peerHealthEntry) probing() bool {
+	return !e.probeStart.IsZero() && time.Since(e.probeStart) < maxPeerBackoff
+}
+
+// ready returns true if the peer is not backing off and, if its circuit is
+// open, no other probe is in flight.
+func (e *peerHealthEntry) ready() bool {
+	if time.Since(e.lastFailure) < e.backoff() {
+		return false
+	}
+
+	return !(e.circuitOpen() && e.probing())
+}
+
+// peerHealth tracks the health of peers, to apply exponential backoff and
+// circuit breaking to the requests made to them.
+type peerHealth struct {
+	peers map[uint32]*peerHealthEntry
+	lock  sync.Mutex
+}
+
+func newPeerHealth() *peerHealth {
+	return &peerHealth{
+		peers: make(map[uint32]*peerHealthEntry),
+	}
+}
+
+func (ph *peerHealth) entry(id uint32) *peerHealthEntry {
+	e, ok := ph.peers[id]
+	if !ok {
+		e = &peerHealthEntry{}
+		ph.peers[id] = e
+	}
+	return e
+}
+
+// success records a successful request. rtt is ignored if not positive.
+func (ph *peerHealth) success(id uint32, rtt time.Duration) {
+	ph.lock.Lock()
+	defer ph.lock.Unlock()
+
+	e := ph.entry(id)
+	e.failures = 0
+	e.probeStart = time.Time{}
+	e.lastSuccess = time.Now()
+
+	if rtt > 0 {
+		if e.rtt == 0 {
+			e.rtt = rtt
+		} else {
+			e.rtt = time.Duration(latencyAlpha*float64(rtt) + (1-latencyAlpha)*float64(e.rtt))
+		}
+	}
+}
+
+// failure records a failed request
+func (ph *peerHealth) failure(id uint32) {
+	ph.lock.Lock()
+	defer ph.lock.Unlock()
+
+	e := ph.entry(id)
+	e.failures++
+	e.probeStart = time.Time{}
+	e.lastFailure = time.Now()
+}
+
+// available returns true if a peer can be tried now, ie. it is not backing off
+// and, if its circuit is open, no other probe is in flight.
+func (ph *peerHealth) available(id uint32) bool {
+	ph.lock.Lock()
+	defer ph.lock.Unlock()
+
+	e, ok := ph.peers[id]
+
+	return !ok || e.ready()
+}
+
+// acquire is like available but, if the circuit to the peer is open, it also
+// reserves the single probe allowed until the next success or failure.
+func (ph *peerHealth) acquire(id uint32) bool {
+	ph.lock.Lock()
+	defer ph.lock.Unlock()
+
+	e, ok := ph.peers[id]
+	if !ok {
+		return true
+	}
+
+	if !e.ready() {
+		return false
+	}
+
+	if e.circuitOpen() {
+		e.probeStart = time.Now()
+	}
+
+	return true
+}
+
+// GetPeerHealth returns the health of a peer, or nil if the node never made a
+// request to it.
+func (n *Node) GetPeerHealth(id uint32) *PeerHealth {
+	return n.core.peerHealth.get(id)
+}
+
+// get returns the health of a peer, or nil if no request was ever made to it
+func (ph *peerHealth) get(id uint32) *PeerHealth {
+	ph.lock.Lock()
+	defer ph.lock.Unlock()
+
+	e, ok := ph.peers[id]
+	if !ok {
+		return nil
+	}
+
+	h := &PeerHealth{
+		ConsecutiveFailures: e.failures,
+		CircuitOpen:         e.circuitOpen(),
+	}
+
+	if !e.lastSuccess.IsZero() {
+		t := e.lastSuccess
+		h.LastSuccess = &t
+	}
+
+	if !e.lastFailure.IsZero() {
+		t := e.lastFailure
+		h.LastFailure = &t
+	}
+
+	if e.rtt > 0 {
+		h.RTT = e.rtt.String()
+	}
+
+	if retryIn := e.backoff() - time.Since(e.lastFailure); e.failures > 0 && retryIn > 0 {
+		h.RetryIn = retryIn.Round(time.Millisecond).String()
+	}
+
+	return h
+}
@@ -0,0 +1,104 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/abassian/huron/src/common"
+	"github.com/abassian/huron/src/net"
+)
+
+func TestPeerHealthBackoff(t *testing.T) {
+	ph := newPeerHealth()
+
+	if !ph.available(1) || ph.get(1) != nil {
+		t.Fatalf("Unknown peers should be available and without health")
+	}
+
+	expected := []time.Duration{
+		time.Second,
+		2 * time.Second,
+		4 * time.Second,
+		8 * time.Second,
+		16 * time.Second,
+		30 * time.Second,
+		30 * time.Second,
+	}
+
+	for i, backoff := range expected {
+		ph.failure(1)
+
+		if b := ph.peers[1].backoff(); b != backoff {
+			t.Fatalf("Backoff after %d failures should be %s, not %s", i+1, backoff, b)
+		}
+
+		if ph.available(1) {
+			t.Fatalf("Peer should not be available right after a failure")
+		}
+	}
+
+	h := ph.get(1)
+	if h.ConsecutiveFailures != len(expected) || !h.CircuitOpen || h.LastFailure == nil || h.RetryIn == "" {
+		t.Fatalf("Unexpected health %+v", h)
+	}
+
+	ph.success(1, 10*time.Millisecond)
+
+	h = ph.get(1)
+	if h.ConsecutiveFailures != 0 || h.CircuitOpen || h.LastSuccess == nil || h.RTT != "10ms" {
+		t.Fatalf("Unexpected health after success %+v", h)
+	}
+
+	if !ph.available(1) {
+		t.Fatalf("Peer should be available after a success")
+	}
+}
+
+func TestPeerHealthCircuitBreaker(t *testing.T) {
+	ph := newPeerHealth()
+
+	for i := 0; i < circuitThreshold; i++ {
+		ph.failure(1)
+	}
+
+	//Pretend the backoff has expired
+	ph.peers[1].lastFailure = time.Now().Add(-maxPeerBackoff)
+
+	if !ph.acquire(1) {
+		t.Fatalf("A probe should be allowed once the backoff has expired")
+	}
+
+	if ph.available(1) || ph.acquire(1) {
+		t.Fatalf("Only one probe should be allowed while the circuit is open")
+	}
+
+	ph.failure(1)
+
+	if ph.available(1) {
+		t.Fatalf("A failed probe should reopen the circuit")
+	}
+}
+
+func TestPeerHealthIgnoresInboundRequests(t *testing.T) {
+	keys, peerSet := initPeers(t, 2)
+	node := newNode(peerSet.Peers[0], keys[0], peerSet, peerSet, 100, 100, 5, false, "inmem", 5*time.Millisecond, common.NewTestLogger(t), t)
+	defer node.Shutdown()
+
+	remote := peerSet.Peers[1].ID()
+	for i := 0; i < circuitThreshold; i++ {
+		node.core.peerHealth.failure(remote)
+	}
+
+	//A peer which we cannot reach may still reach us, which says nothing
+	//about its own address
+	respCh := make(chan net.RPCResponse, 1)
+	node.processSyncRequest(
+		net.RPC{RespChan: respCh},
+		&net.SyncRequest{FromID: remote, Known: map[uint32]int{}, SyncLimit: 10},
+	)
+	<-respCh
+
+	if h := node.GetPeerHealth(remote); h == nil || !h.CircuitOpen {
+		t.Fatalf("An inbound request should not close the circuit to a peer, got %+v", h)
+	}
+}
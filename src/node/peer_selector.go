@@ -1,66 +1,368 @@
 package node
 
 import (
+	"fmt"
+	"math"
 	"math/rand"
+	"time"
 
 	"github.com/abassian/huron/src/peers"
 )
 
+// Names of the PeerSelectors, as used in Config.PeerSelector
+const (
+	RandomSelector        = "random"
+	LatencySelector       = "latency"
+	UnknownEventsSelector = "unknown-events"
+	RoundRobinSelector    = "round-robin"
+)
+
+// latencyAlpha is the smoothing factor of the moving average of RTTs kept by
+// the LatencyPeerSelector
+const latencyAlpha = 0.3
+
 //PeerSelector defines and interface for Peer Selectors
 type PeerSelector interface {
 	Peers() *peers.PeerSet
 	UpdateLast(peer uint32)
+	Report(peer uint32, result GossipResult)
 	Next() *peers.Peer
 }
 
+// GossipResult is the outcome of a gossip exchange with a peer. Known is the
+// peer's known-events when it answered a SyncRequest, and OwnKnown is this
+// node's known-events after processing the SyncResponse.
+type GossipResult struct {
+	Err      error
+	RTT      time.Duration
+	Known    map[uint32]int
+	OwnKnown map[uint32]int
+}
+
+// validPeerSelector returns true if kind is the name of a PeerSelector. The
+// empty name stands for the RandomPeerSelector.
+func validPeerSelector(kind string) bool {
+	switch kind {
+	case RandomSelector, LatencySelector, UnknownEventsSelector, RoundRobinSelector, "":
+		return true
+	default:
+		return false
+	}
+}
+
+// NewPeerSelector returns a PeerSelector of the given kind
+func NewPeerSelector(kind string, peerSet *peers.PeerSet, selfID uint32) (PeerSelector, error) {
+	switch kind {
+	case RandomSelector, "":
+		return NewRandomPeerSelector(peerSet, selfID), nil
+	case LatencySelector:
+		return NewLatencyPeerSelector(peerSet, selfID), nil
+	case UnknownEventsSelector:
+		return NewUnknownEventsPeerSelector(peerSet, selfID), nil
+	case RoundRobinSelector:
+		return NewRoundRobinPeerSelector(peerSet, selfID), nil
+	default:
+		return nil, fmt.Errorf("Unknown peer selector %s", kind)
+	}
+}
+
 //+++++++++++++++++++++++++++++++++++++++
-//RANDOM
+//BASE
 
-// RandomPeerSelector defines a struct which controls the random selection of
-// peers
-type RandomPeerSelector struct {
+// selectorBase implements the parts that are common to all PeerSelectors:
+// excluding this node, avoiding the last selected peer, and avoiding peers
+// which are backing off after failures, according to their health.
+type selectorBase struct {
 	peers           *peers.PeerSet
 	selfID          uint32
 	selectablePeers []*peers.Peer
 	last            uint32
+	health          *peerHealth
 }
 
-// NewRandomPeerSelector is a factory method that returns a new instance of
-// RandomPeerSelector
-func NewRandomPeerSelector(peerSet *peers.PeerSet, selfID uint32) *RandomPeerSelector {
+func newSelectorBase(peerSet *peers.PeerSet, selfID uint32) selectorBase {
 	_, selectablePeers := peers.ExcludePeer(peerSet.Peers, selfID)
-	return &RandomPeerSelector{
+	return selectorBase{
 		peers:           peerSet,
 		selfID:          selfID,
 		selectablePeers: selectablePeers,
+		health:          newPeerHealth(),
 	}
 }
 
 //Peers returns a set of peers
-func (ps *RandomPeerSelector) Peers() *peers.PeerSet {
+func (ps *selectorBase) Peers() *peers.PeerSet {
 	return ps.peers
 }
 
 //UpdateLast sets the last peer
-func (ps *RandomPeerSelector) UpdateLast(peer uint32) {
+func (ps *selectorBase) UpdateLast(peer uint32) {
 	ps.last = peer
 }
 
+//Report records the outcome of the gossip with a peer in its health
+func (ps *selectorBase) Report(peer uint32, result GossipResult) {
+	if result.Err != nil {
+		ps.health.failure(peer)
+	} else {
+		ps.health.success(peer, result.RTT)
+	}
+}
+
+// setHealth makes the selector share the health of peers with the node
+func (ps *selectorBase) setHealth(health *peerHealth) {
+	ps.health = health
+}
+
+// candidates returns the selectable peers, other than the last one, which
+// are available according to their health. It returns an empty list while
+// all of them are backing off.
+func (ps *selectorBase) candidates() []*peers.Peer {
+	selectablePeers := ps.selectablePeers
+
+	if len(selectablePeers) > 1 {
+		_, selectablePeers = peers.ExcludePeer(selectablePeers, ps.last)
+	}
+
+	healthy := make([]*peers.Peer, 0, len(selectablePeers))
+	for _, p := range selectablePeers {
+		if ps.health.available(p.ID()) {
+			healthy = append(healthy, p)
+		}
+	}
+
+	return healthy
+}
+
+// selected reserves the probe of a peer whose circuit is open
+func (ps *selectorBase) selected(peer *peers.Peer) *peers.Peer {
+	ps.health.acquire(peer.ID())
+	return peer
+}
+
+//+++++++++++++++++++++++++++++++++++++++
+//RANDOM
+
+// RandomPeerSelector defines a struct which controls the random selection of
+// peers
+type RandomPeerSelector struct {
+	selectorBase
+}
+
+// NewRandomPeerSelector is a factory method that returns a new instance of
+// RandomPeerSelector
+func NewRandomPeerSelector(peerSet *peers.PeerSet, selfID uint32) *RandomPeerSelector {
+	return &RandomPeerSelector{
+		selectorBase: newSelectorBase(peerSet, selfID),
+	}
+}
+
 //Next returns the next peer
 func (ps *RandomPeerSelector) Next() *peers.Peer {
-	selectablePeers := ps.selectablePeers
+	candidates := ps.candidates()
 
-	if len(selectablePeers) == 0 {
+	if len(candidates) == 0 {
 		return nil
 	}
 
-	if len(selectablePeers) > 1 {
-		_, selectablePeers = peers.ExcludePeer(selectablePeers, ps.last)
+	i := rand.Intn(len(candidates))
+
+	peer := candidates[i]
+
+	return ps.selected(peer)
+}
+
+//+++++++++++++++++++++++++++++++++++++++
+//LATENCY
+
+// LatencyPeerSelector selects peers randomly, with a probability inversely
+// proportional to their average round-trip time. Peers whose RTT is not known
+// yet are given the weight of the fastest peer, so that they get tried.
+type LatencyPeerSelector struct {
+	selectorBase
+	rtts map[uint32]time.Duration
+}
+
+// NewLatencyPeerSelector ...
+func NewLatencyPeerSelector(peerSet *peers.PeerSet, selfID uint32) *LatencyPeerSelector {
+	return &LatencyPeerSelector{
+		selectorBase: newSelectorBase(peerSet, selfID),
+		rtts:         make(map[uint32]time.Duration),
 	}
+}
 
-	i := rand.Intn(len(selectablePeers))
+//Report updates the moving average of the peer's RTT
+func (ps *LatencyPeerSelector) Report(peer uint32, result GossipResult) {
+	ps.selectorBase.Report(peer, result)
 
-	peer := selectablePeers[i]
+	if result.Err != nil || result.RTT <= 0 {
+		return
+	}
 
-	return peer
+	if rtt, ok := ps.rtts[peer]; ok {
+		ps.rtts[peer] = time.Duration(latencyAlpha*float64(result.RTT) + (1-latencyAlpha)*float64(rtt))
+	} else {
+		ps.rtts[peer] = result.RTT
+	}
+}
+
+//Next returns the next peer
+func (ps *LatencyPeerSelector) Next() *peers.Peer {
+	candidates := ps.candidates()
+
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	var fastest time.Duration
+	for _, p := range candidates {
+		if rtt, ok := ps.rtts[p.ID()]; ok && (fastest == 0 || rtt < fastest) {
+			fastest = rtt
+		}
+	}
+
+	weights := make([]float64, len(candidates))
+	total := 0.0
+	for i, p := range candidates {
+		rtt, ok := ps.rtts[p.ID()]
+		if !ok {
+			rtt = fastest
+		}
+
+		if rtt <= 0 {
+			weights[i] = 1
+		} else {
+			weights[i] = 1 / rtt.Seconds()
+		}
+		total += weights[i]
+	}
+
+	r := rand.Float64() * total
+	for i, w := range weights {
+		r -= w
+		if r < 0 {
+			return ps.selected(candidates[i])
+		}
+	}
+
+	return ps.selected(candidates[len(candidates)-1])
+}
+
+//+++++++++++++++++++++++++++++++++++++++
+//UNKNOWN EVENTS
+
+// UnknownEventsPeerSelector selects the peer which knows the most events that
+// this node does not know, according to the known-events returned by the
+// peers' SyncResponses. Peers never synced with are selected first.
+type UnknownEventsPeerSelector struct {
+	selectorBase
+	known    map[uint32]map[uint32]int
+	ownKnown map[uint32]int
+}
+
+// NewUnknownEventsPeerSelector ...
+func NewUnknownEventsPeerSelector(peerSet *peers.PeerSet, selfID uint32) *UnknownEventsPeerSelector {
+	return &UnknownEventsPeerSelector{
+		selectorBase: newSelectorBase(peerSet, selfID),
+		known:        make(map[uint32]map[uint32]int),
+		ownKnown:     make(map[uint32]int),
+	}
+}
+
+//Report records the known-events of the peer and of this node
+func (ps *UnknownEventsPeerSelector) Report(peer uint32, result GossipResult) {
+	ps.selectorBase.Report(peer, result)
+
+	if result.Known != nil {
+		ps.known[peer] = result.Known
+	}
+
+	//Known-events only increase
+	for id, index := range result.OwnKnown {
+		if index > ps.ownKnown[id] {
+			ps.ownKnown[id] = index
+		}
+	}
+}
+
+// unknownEvents returns the number of events known by a peer and not by this
+// node, or MaxInt32 if the peer's known-events are not known.
+func (ps *UnknownEventsPeerSelector) unknownEvents(peer uint32) int {
+	known, ok := ps.known[peer]
+	if !ok {
+		return math.MaxInt32
+	}
+
+	count := 0
+	for id, index := range known {
+		own, ok := ps.ownKnown[id]
+		if !ok {
+			own = -1
+		}
+		if index > own {
+			count += index - own
+		}
+	}
+
+	return count
+}
+
+//Next returns the next peer
+func (ps *UnknownEventsPeerSelector) Next() *peers.Peer {
+	candidates := ps.candidates()
+
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	best := []*peers.Peer{}
+	max := -1
+	for _, p := range candidates {
+		count := ps.unknownEvents(p.ID())
+		if count > max {
+			best = []*peers.Peer{p}
+			max = count
+		} else if count == max {
+			best = append(best, p)
+		}
+	}
+
+	return ps.selected(best[rand.Intn(len(best))])
+}
+
+//+++++++++++++++++++++++++++++++++++++++
+//ROUND ROBIN
+
+// RoundRobinPeerSelector selects peers in turn, so that every peer is
+// selected equally often. Peers which are backing off after failures are
+// skipped.
+type RoundRobinPeerSelector struct {
+	selectorBase
+	next int
+}
+
+// NewRoundRobinPeerSelector ...
+func NewRoundRobinPeerSelector(peerSet *peers.PeerSet, selfID uint32) *RoundRobinPeerSelector {
+	return &RoundRobinPeerSelector{
+		selectorBase: newSelectorBase(peerSet, selfID),
+	}
+}
+
+//Next returns the next peer
+func (ps *RoundRobinPeerSelector) Next() *peers.Peer {
+	candidates := make(map[uint32]bool)
+	for _, p := range ps.candidates() {
+		candidates[p.ID()] = true
+	}
+
+	n := len(ps.selectablePeers)
+	for i := 0; i < n; i++ {
+		p := ps.selectablePeers[(ps.next+i)%n]
+		if candidates[p.ID()] {
+			ps.next = (ps.next + i + 1) % n
+			return ps.selected(p)
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,200 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/abassian/huron/src/common"
+	"github.com/abassian/huron/src/peers"
+)
+
+func testPeerSet(t *testing.T, n int) *peers.PeerSet {
+	_, peerSet := initPeers(t, n)
+	return peerSet
+}
+
+func TestNewPeerSelector(t *testing.T) {
+	peerSet := testPeerSet(t, 3)
+
+	for _, kind := range []string{RandomSelector, LatencySelector, UnknownEventsSelector, RoundRobinSelector, ""} {
+		if _, err := NewPeerSelector(kind, peerSet, peerSet.Peers[0].ID()); err != nil {
+			t.Fatalf("%s: %v", kind, err)
+		}
+		if err := (&Config{PeerSelector: kind}).Validate(); err != nil {
+			t.Fatalf("%s: %v", kind, err)
+		}
+	}
+
+	if _, err := NewPeerSelector("nonsense", peerSet, peerSet.Peers[0].ID()); err == nil {
+		t.Fatalf("Unknown peer selectors should be refused")
+	}
+	if err := (&Config{PeerSelector: "nonsense"}).Validate(); err == nil {
+		t.Fatalf("A Config with an unknown peer selector should be refused")
+	}
+}
+
+func TestPeerSelectorsExcludeFailingPeers(t *testing.T) {
+	peerSet := testPeerSet(t, 4)
+	self := peerSet.Peers[0].ID()
+	failing := peerSet.Peers[1].ID()
+
+	for _, kind := range []string{RandomSelector, LatencySelector, UnknownEventsSelector, RoundRobinSelector} {
+		ps, _ := NewPeerSelector(kind, peerSet, self)
+
+		ps.Report(failing, GossipResult{Err: fmt.Errorf("timeout")})
+
+		for i := 0; i < 50; i++ {
+			p := ps.Next()
+			if p.ID() == self || p.ID() == failing {
+				t.Fatalf("%s selector should not select %s", kind, p.Moniker)
+			}
+			ps.UpdateLast(p.ID())
+		}
+
+		//No peer is selected while they are all backing off
+		ps.Report(peerSet.Peers[2].ID(), GossipResult{Err: fmt.Errorf("timeout")})
+		ps.Report(peerSet.Peers[3].ID(), GossipResult{Err: fmt.Errorf("timeout")})
+		if p := ps.Next(); p != nil {
+			t.Fatalf("%s selector should not select %s while it backs off", kind, p.Moniker)
+		}
+	}
+}
+
+func TestRoundRobinPeerSelector(t *testing.T) {
+	peerSet := testPeerSet(t, 4)
+	ps := NewRoundRobinPeerSelector(peerSet, peerSet.Peers[0].ID())
+
+	counts := make(map[uint32]int)
+	for i := 0; i < 30; i++ {
+		p := ps.Next()
+		ps.UpdateLast(p.ID())
+		counts[p.ID()]++
+	}
+
+	for _, p := range peerSet.Peers[1:] {
+		if counts[p.ID()] != 10 {
+			t.Fatalf("%s should have been selected 10 times, not %d", p.Moniker, counts[p.ID()])
+		}
+	}
+}
+
+func TestLatencyPeerSelector(t *testing.T) {
+	peerSet := testPeerSet(t, 3)
+	fast := peerSet.Peers[1].ID()
+	slow := peerSet.Peers[2].ID()
+
+	ps := NewLatencyPeerSelector(peerSet, peerSet.Peers[0].ID())
+	ps.Report(fast, GossipResult{RTT: time.Millisecond})
+	ps.Report(slow, GossipResult{RTT: 100 * time.Millisecond})
+
+	counts := make(map[uint32]int)
+	for i := 0; i < 1000; i++ {
+		counts[ps.Next().ID()]++
+	}
+
+	if counts[fast] <= 10*counts[slow] {
+		t.Fatalf("The fast peer should be selected far more often: %d vs %d", counts[fast], counts[slow])
+	}
+}
+
+func TestUnknownEventsPeerSelector(t *testing.T) {
+	peerSet := testPeerSet(t, 4)
+	self := peerSet.Peers[0].ID()
+	a := peerSet.Peers[1].ID()
+	b := peerSet.Peers[2].ID()
+	c := peerSet.Peers[3].ID()
+
+	ps := NewUnknownEventsPeerSelector(peerSet, self)
+
+	own := map[uint32]int{self: 5, a: 5, b: 5, c: 5}
+	ps.Report(a, GossipResult{Known: map[uint32]int{self: 5, a: 8, b: 5, c: 5}, OwnKnown: own})
+	ps.Report(b, GossipResult{Known: map[uint32]int{self: 5, a: 5, b: 6, c: 5}, OwnKnown: own})
+
+	//c was never synced with, so it is tried first
+	if p := ps.Next(); p.ID() != c {
+		t.Fatalf("Peer never synced with should be selected first, got %s", p.Moniker)
+	}
+
+	ps.Report(c, GossipResult{Known: own, OwnKnown: own})
+
+	if p := ps.Next(); p.ID() != a {
+		t.Fatalf("Peer with the most unknown events should be selected, got %s", p.Moniker)
+	}
+}
+
+func TestUnknownEventsAfterSync(t *testing.T) {
+	logger := common.NewTestLogger(t)
+	keys, peers := initPeers(t, 3)
+
+	genesisPeerSet := clonePeerSet(t, peers.Peers)
+
+	nodes := initNodes(keys, peers, genesisPeerSet, 1000, 400, 5, false, "inmem", 10*time.Millisecond, logger, t)
+
+	//node1 answers SyncRequests. Wait for it to stop running before the test
+	//completes, because it logs to the test.
+	done := make(chan struct{})
+	go func() {
+		nodes[1].Run(context.Background(), false)
+		close(done)
+	}()
+	defer func() {
+		shutdownNodes(nodes)
+		<-done
+	}()
+
+	node0 := nodes[0]
+	node0.core.selectorKind = UnknownEventsSelector
+	node0.core.SetPeers(node0.core.peers)
+
+	//node1 has events unknown to node0
+	nodes[1].coreLock.Lock()
+	for i := 0; i < 3; i++ {
+		if err := nodes[1].core.AddSelfEvent(""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	nodes[1].coreLock.Unlock()
+
+	peer1 := node0.core.peers.ByID[nodes[1].GetID()]
+	if _, err := node0.pull(peer1); err != nil {
+		t.Fatal(err)
+	}
+
+	//After a full sync, node1 knows nothing more than node0, so node0 moves on
+	//to node2, which it never synced with
+	selector := node0.core.peerSelector.(*UnknownEventsPeerSelector)
+	if unknown := selector.unknownEvents(nodes[1].GetID()); unknown != 0 {
+		t.Fatalf("Events pulled from node1 should be known, not %d unknown", unknown)
+	}
+	if p := selector.Next(); p.ID() != nodes[2].GetID() {
+		t.Fatalf("node2 should be selected after a full sync with node1, not %s", p.Moniker)
+	}
+}
+
+func TestGossipWithPeerSelectors(t *testing.T) {
+	for _, kind := range []string{LatencySelector, UnknownEventsSelector, RoundRobinSelector} {
+		t.Run(kind, func(t *testing.T) {
+			logger := common.NewTestLogger(t)
+			keys, peers := initPeers(t, 4)
+
+			genesisPeerSet := clonePeerSet(t, peers.Peers)
+
+			nodes := initNodes(keys, peers, genesisPeerSet, 1000, 400, 5, false, "inmem", 10*time.Millisecond, logger, t)
+			defer shutdownNodes(nodes)
+
+			for _, n := range nodes {
+				n.core.selectorKind = kind
+				n.core.SetPeers(n.core.peers)
+			}
+
+			err := gossip(nodes, 10, false, 6*time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			checkGossip(nodes, 0, t)
+		})
+	}
+}
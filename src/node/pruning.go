@@ -0,0 +1,85 @@
+package node
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/abassian/huron/src/common"
+	hg "github.com/abassian/huron/src/hashgraph"
+)
+
+/*******************************************************************************
+Pruning
+
+When Config.PruneDepth is positive, the node periodically deletes the events
+and rounds which lie more than PruneDepth rounds behind the base used to answer
+FastForwardRequests. The base Block, its Frame and the corresponding snapshot
+are kept, so that the node can still be bootstrapped, and still serve
+fast-forwards, after pruning.
+*******************************************************************************/
+
+// Prune deletes the history which lies more than PruneDepth rounds behind the
+// FastForward base, if the node's Store supports pruning. It returns an error
+// if pruning is disabled or if the node is not Babbling.
+func (n *Node) Prune() error {
+	if n.conf.PruneDepth <= 0 {
+		return fmt.Errorf("Pruning is disabled: prune depth is %d", n.conf.PruneDepth)
+	}
+
+	if state := n.getState(); state != Babbling {
+		return fmt.Errorf("Cannot prune in %s state", state)
+	}
+
+	block, _, snapshot, err := n.getFastForwardBase()
+	if err != nil {
+		return err
+	}
+
+	n.coreLock.Lock()
+	defer n.coreLock.Unlock()
+
+	return n.core.hg.Prune(block, n.conf.PruneDepth, snapshot)
+}
+
+// pruner runs Prune every PruneInterval while the node is Babbling
+func (n *Node) pruner() {
+	if n.conf.PruneDepth <= 0 || n.conf.PruneInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(n.conf.PruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if n.getState() != Babbling {
+				continue
+			}
+
+			if err := n.Prune(); err != nil {
+				n.logger.WithError(err).Error("Pruning")
+			}
+		case <-n.shutdownCh:
+			return
+		}
+	}
+}
+
+// getPruneBase returns the PruneBase of the Store, or nil if it was not pruned
+func (n *Node) getPruneBase() (*hg.PruneBase, error) {
+	pruner, ok := n.core.hg.Store.(hg.Pruner)
+	if !ok {
+		return nil, nil
+	}
+
+	base, err := pruner.GetPruneBase()
+	if err != nil {
+		if common.Is(err, common.KeyNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return base, nil
+}
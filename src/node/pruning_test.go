@@ -0,0 +1,80 @@
+package node
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/abassian/huron/src/common"
+	hg "github.com/abassian/huron/src/hashgraph"
+)
+
+func TestPruneAndBootstrap(t *testing.T) {
+	os.RemoveAll("test_data")
+	os.Mkdir("test_data", os.ModeDir|0777)
+
+	logger := common.NewTestLogger(t)
+	keys, peers := initPeers(t, 4)
+	genesisPeerSet := clonePeerSet(t, peers.Peers)
+
+	nodes := initNodes(keys, peers, genesisPeerSet, 1000, 1000, 10, false, "badger", 6*time.Millisecond, logger, t)
+
+	for _, n := range nodes {
+		n.conf.PruneDepth = 5
+	}
+
+	runNodes(nodes, true)
+
+	if err := bombardAndWait(nodes, 20, 10*time.Second); err != nil {
+		shutdownNodes(nodes)
+		t.Fatal(err)
+	}
+
+	for _, n := range nodes {
+		if err := n.Prune(); err != nil {
+			shutdownNodes(nodes)
+			t.Fatal(err)
+		}
+	}
+
+	//Consensus carries on after pruning
+	if err := bombardAndWait(nodes, 30, 10*time.Second); err != nil {
+		shutdownNodes(nodes)
+		t.Fatal(err)
+	}
+
+	shutdownNodes(nodes)
+	checkGossip(nodes, 0, t)
+
+	base, err := nodes[0].core.hg.Store.(hg.Pruner).GetPruneBase()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//Recreate the network from the pruned databases and advance it
+	newNodes := recycleNodes(nodes, logger, t)
+
+	if err := gossip(newNodes, 40, true, 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	checkGossip(newNodes, base.Block, t)
+	checkGossip([]*Node{nodes[0], newNodes[0]}, base.Block, t)
+}
+
+func TestPruneChecks(t *testing.T) {
+	keys, peers := initPeers(t, 1)
+	node := newNode(peers.Peers[0], keys[0], peers, peers, 100, 100, 5, false, "inmem", 5*time.Millisecond, common.NewTestLogger(t), t)
+	defer node.Shutdown()
+
+	if err := node.Prune(); err == nil {
+		t.Fatalf("Prune should fail when pruning is disabled")
+	}
+
+	node.conf.PruneDepth = 5
+	node.setState(CatchingUp)
+
+	if err := node.Prune(); err == nil {
+		t.Fatalf("Prune should fail when the node is not Babbling")
+	}
+}
@@ -0,0 +1,326 @@
+package node
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/abassian/huron/src/crypto/encryption"
+	hg "github.com/abassian/huron/src/hashgraph"
+	"github.com/abassian/huron/src/proxy"
+	"github.com/sirupsen/logrus"
+)
+
+const snapshotPrefix = "snapshot"
+
+// SnapshotManager periodically asks the application for a snapshot of its
+// state and keeps the most recent ones in a directory. The stored snapshots are
+// used to answer FastForwardRequests without asking the application to produce
+// a new snapshot for every fast-syncing peer.
+type SnapshotManager struct {
+	dir       string
+	interval  int
+	retention int
+
+	// key encrypts the snapshots on disk. They are stored in plaintext if it
+	// is nil.
+	key *encryption.Key
+
+	// getSnapshot is the function used to retrieve a snapshot from the
+	// application. It is usually the AppProxy's GetSnapshot method.
+	getSnapshot func(blockIndex int) ([]byte, error)
+
+	// indexes is the sorted list of block indexes for which a snapshot is
+	// stored in dir.
+	indexes []int
+	lock    sync.Mutex
+
+	logger *logrus.Entry
+}
+
+// NewSnapshotManager is a factory method that returns a SnapshotManager. A
+// snapshot is taken every interval blocks and at most retention snapshots are
+// kept in dir, encrypted with key unless it is nil. An interval of 0 disables
+// periodic snapshots.
+func NewSnapshotManager(dir string,
+	interval int,
+	retention int,
+	key *encryption.Key,
+	getSnapshot func(blockIndex int) ([]byte, error),
+	logger *logrus.Entry) *SnapshotManager {
+
+	if logger == nil {
+		log := logrus.New()
+		log.Level = logrus.DebugLevel
+		logger = logrus.NewEntry(log)
+	}
+
+	return &SnapshotManager{
+		dir:         dir,
+		interval:    interval,
+		retention:   retention,
+		key:         key,
+		getSnapshot: getSnapshot,
+		indexes:     []int{},
+		logger:      logger,
+	}
+}
+
+// Enabled returns true if the SnapshotManager is configured to take periodic
+// snapshots.
+func (sm *SnapshotManager) Enabled() bool {
+	return sm.interval > 0 && sm.dir != ""
+}
+
+// Init prepares the snapshot directory. When load is true, the snapshots
+// already present in the directory are indexed so that they can be served;
+// otherwise they are deleted because they might belong to a different history.
+func (sm *SnapshotManager) Init(load bool) error {
+	if !sm.Enabled() {
+		return nil
+	}
+
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+
+	if err := os.MkdirAll(sm.dir, 0700); err != nil {
+		return err
+	}
+
+	indexes, err := sm.readIndexes()
+	if err != nil {
+		return err
+	}
+
+	if !load {
+		for _, i := range indexes {
+			if err := os.Remove(sm.path(i)); err != nil {
+				return err
+			}
+		}
+		indexes = []int{}
+	}
+
+	sm.indexes = indexes
+
+	sm.logger.WithFields(logrus.Fields{
+		"dir":       sm.dir,
+		"snapshots": len(sm.indexes),
+	}).Debug("SnapshotManager Init")
+
+	return nil
+}
+
+// CommitCallback wraps a proxy CommitCallback so that a snapshot is taken right
+// after every interval-th block is committed to the application.
+func (sm *SnapshotManager) CommitCallback(commit proxy.CommitCallback) proxy.CommitCallback {
+	return func(block hg.Block) (proxy.CommitResponse, error) {
+		resp, err := commit(block)
+		if err != nil {
+			return resp, err
+		}
+
+		if sm.Enabled() && block.Index()%sm.interval == 0 {
+			if err := sm.Take(block.Index()); err != nil {
+				sm.logger.WithError(err).Errorf("Taking snapshot %d", block.Index())
+			}
+		}
+
+		return resp, nil
+	}
+}
+
+// Take retrieves the snapshot corresponding to blockIndex from the application
+// and writes it to disk. Older snapshots are deleted when there are more than
+// retention snapshots.
+func (sm *SnapshotManager) Take(blockIndex int) error {
+	snapshot, err := sm.getSnapshot(blockIndex)
+	if err != nil {
+		return err
+	}
+
+	size := len(snapshot)
+
+	if sm.key != nil {
+		if snapshot, err = sm.key.Seal(snapshot); err != nil {
+			return err
+		}
+	}
+
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+
+	if err := writeSnapshotFile(sm.path(blockIndex), snapshot); err != nil {
+		return err
+	}
+
+	sm.insertIndex(blockIndex)
+
+	sm.logger.WithFields(logrus.Fields{
+		"block": blockIndex,
+		"size":  size,
+	}).Debug("Snapshot taken")
+
+	return sm.prune()
+}
+
+// Get returns the stored snapshot corresponding to blockIndex.
+func (sm *SnapshotManager) Get(blockIndex int) ([]byte, error) {
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+
+	snapshot, err := ioutil.ReadFile(sm.path(blockIndex))
+	if err != nil || sm.key == nil {
+		return snapshot, err
+	}
+
+	return sm.key.Open(snapshot)
+}
+
+// Indexes returns the block indexes of the stored snapshots, newest first.
+func (sm *SnapshotManager) Indexes() []int {
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+
+	res := make([]int, len(sm.indexes))
+	for i, idx := range sm.indexes {
+		res[len(sm.indexes)-1-i] = idx
+	}
+
+	return res
+}
+
+// prune deletes the oldest snapshots until no more than retention snapshots
+// remain. A retention of 0 means that all snapshots are kept.
+func (sm *SnapshotManager) prune() error {
+	if sm.retention <= 0 {
+		return nil
+	}
+
+	for len(sm.indexes) > sm.retention {
+		oldest := sm.indexes[0]
+
+		if err := os.Remove(sm.path(oldest)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		sm.indexes = sm.indexes[1:]
+
+		sm.logger.WithField("block", oldest).Debug("Snapshot deleted")
+	}
+
+	return nil
+}
+
+func (sm *SnapshotManager) insertIndex(blockIndex int) {
+	i := sort.SearchInts(sm.indexes, blockIndex)
+	if i < len(sm.indexes) && sm.indexes[i] == blockIndex {
+		return
+	}
+
+	sm.indexes = append(sm.indexes, 0)
+	copy(sm.indexes[i+1:], sm.indexes[i:])
+	sm.indexes[i] = blockIndex
+}
+
+func (sm *SnapshotManager) readIndexes() ([]int, error) {
+	files, err := ioutil.ReadDir(sm.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := []int{}
+	for _, f := range files {
+		name := f.Name()
+
+		if f.IsDir() || !strings.HasPrefix(name, snapshotPrefix+"_") {
+			continue
+		}
+
+		index, err := strconv.Atoi(strings.TrimPrefix(name, snapshotPrefix+"_"))
+		if err != nil {
+			//ignore temporary files and other garbage
+			continue
+		}
+
+		indexes = append(indexes, index)
+	}
+
+	sort.Ints(indexes)
+
+	return indexes, nil
+}
+
+func (sm *SnapshotManager) path(blockIndex int) string {
+	return filepath.Join(sm.dir, fmt.Sprintf("%s_%09d", snapshotPrefix, blockIndex))
+}
+
+// writeSnapshotFile writes to a temporary file first so that a crash does not
+// leave a truncated snapshot behind
+func writeSnapshotFile(path string, snapshot []byte) error {
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, snapshot, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// RekeySnapshots re-encrypts the snapshots stored in dir with newKey. oldKey is
+// their current key, or nil if they are not encrypted. A nil newKey decrypts
+// them. Snapshots already encrypted with newKey, or already decrypted, are
+// skipped, so an interrupted rekey can be resumed. The node must not be
+// running. It returns the number of snapshots which were re-encrypted.
+func RekeySnapshots(dir string, oldKey, newKey *encryption.Key) (int, error) {
+	sm := &SnapshotManager{dir: dir}
+
+	indexes, err := sm.readIndexes()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	count := 0
+	for _, i := range indexes {
+		snapshot, err := ioutil.ReadFile(sm.path(i))
+		if err != nil {
+			return count, err
+		}
+
+		//skip the snapshots which were re-encrypted, or decrypted, by an
+		//interrupted rekey
+		sealedBy, sealed := encryption.SealedBy(snapshot)
+		if newKey != nil && sealed && sealedBy == newKey.ID() || newKey == nil && !sealed {
+			continue
+		}
+
+		if sealed {
+			if oldKey == nil {
+				return count, fmt.Errorf("Snapshot %d is encrypted with key %s", i, sealedBy)
+			}
+			if snapshot, err = oldKey.Open(snapshot); err != nil {
+				return count, fmt.Errorf("Decrypting snapshot %d: %v", i, err)
+			}
+		}
+
+		if newKey != nil {
+			if snapshot, err = newKey.Seal(snapshot); err != nil {
+				return count, err
+			}
+		}
+
+		if err := writeSnapshotFile(sm.path(i), snapshot); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
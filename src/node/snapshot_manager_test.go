@@ -0,0 +1,166 @@
+package node
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/abassian/huron/src/common"
+	"github.com/abassian/huron/src/crypto/encryption"
+	hg "github.com/abassian/huron/src/hashgraph"
+	"github.com/abassian/huron/src/proxy"
+)
+
+func dummySnapshot(blockIndex int) ([]byte, error) {
+	return []byte(fmt.Sprintf("snapshot %d", blockIndex)), nil
+}
+
+func TestSnapshotManager(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	logger := common.NewTestLogger(t).WithField("id", "test")
+
+	sm := NewSnapshotManager(dir, 2, 3, nil, dummySnapshot, logger)
+	if err := sm.Init(false); err != nil {
+		t.Fatal(err)
+	}
+
+	commit := sm.CommitCallback(proxy.DummyCommitCallback)
+
+	for i := 0; i < 10; i++ {
+		block := hg.NewBlock(i, i, []byte{}, nil, [][]byte{}, []hg.InternalTransaction{})
+		if _, err := commit(*block); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expectedIndexes := []int{8, 6, 4}
+	if indexes := sm.Indexes(); !reflect.DeepEqual(indexes, expectedIndexes) {
+		t.Fatalf("Indexes should be %v, not %v", expectedIndexes, indexes)
+	}
+
+	snapshot, err := sm.Get(6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(snapshot) != "snapshot 6" {
+		t.Fatalf("Snapshot 6 should be 'snapshot 6', not '%s'", snapshot)
+	}
+
+	if _, err := sm.Get(2); err == nil {
+		t.Fatalf("Snapshot 2 should have been pruned")
+	}
+
+	//A new SnapshotManager should load the existing snapshots
+	sm2 := NewSnapshotManager(dir, 2, 3, nil, dummySnapshot, logger)
+	if err := sm2.Init(true); err != nil {
+		t.Fatal(err)
+	}
+
+	if indexes := sm2.Indexes(); !reflect.DeepEqual(indexes, expectedIndexes) {
+		t.Fatalf("Loaded indexes should be %v, not %v", expectedIndexes, indexes)
+	}
+
+	//Or delete them when not bootstrapping
+	sm3 := NewSnapshotManager(dir, 2, 3, nil, dummySnapshot, logger)
+	if err := sm3.Init(false); err != nil {
+		t.Fatal(err)
+	}
+
+	if indexes := sm3.Indexes(); len(indexes) != 0 {
+		t.Fatalf("Snapshots should have been deleted, got %v", indexes)
+	}
+}
+
+func TestEncryptedSnapshots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	logger := common.NewTestLogger(t).WithField("id", "test")
+
+	newKey := func() *encryption.Key {
+		secret, _ := encryption.GenerateSecret()
+		key, err := encryption.NewKey(secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+
+	key := newKey()
+
+	sm := NewSnapshotManager(dir, 2, 3, key, dummySnapshot, logger)
+	if err := sm.Init(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.Take(4); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := ioutil.ReadFile(sm.path(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), "snapshot 4") {
+		t.Fatalf("Snapshot file should be encrypted")
+	}
+
+	snapshot, err := sm.Get(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(snapshot) != "snapshot 4" {
+		t.Fatalf("Snapshot 4 should be 'snapshot 4', not '%s'", snapshot)
+	}
+
+	//Rotate the key
+	rotatedKey := newKey()
+	if count, err := RekeySnapshots(dir, key, rotatedKey); err != nil || count != 1 {
+		t.Fatalf("RekeySnapshots should re-encrypt 1 snapshot, got %d, %v", count, err)
+	}
+
+	if _, err := sm.Get(4); err == nil {
+		t.Fatalf("Snapshot should not be readable with the old key")
+	}
+
+	sm2 := NewSnapshotManager(dir, 2, 3, rotatedKey, dummySnapshot, logger)
+	if err := sm2.Init(true); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err = sm2.Get(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(snapshot) != "snapshot 4" {
+		t.Fatalf("Rekeyed snapshot 4 should be 'snapshot 4', not '%s'", snapshot)
+	}
+
+	//Resuming the rotation skips the snapshot
+	if count, err := RekeySnapshots(dir, key, rotatedKey); err != nil || count != 0 {
+		t.Fatalf("Resumed RekeySnapshots should skip the snapshot, got %d, %v", count, err)
+	}
+
+	//Decrypt
+	if _, err := RekeySnapshots(dir, rotatedKey, nil); err != nil {
+		t.Fatal(err)
+	}
+	raw, _ = ioutil.ReadFile(sm.path(4))
+	if string(raw) != "snapshot 4" {
+		t.Fatalf("Decrypted snapshot 4 should be 'snapshot 4', not '%s'", raw)
+	}
+
+	//Resuming the decryption skips the unsealed snapshot
+	if count, err := RekeySnapshots(dir, rotatedKey, nil); err != nil || count != 0 {
+		t.Fatalf("Resumed decryption should skip the snapshot, got %d, %v", count, err)
+	}
+}
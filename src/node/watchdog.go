@@ -0,0 +1,220 @@
+package node
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/abassian/huron/src/common"
+	"github.com/abassian/huron/src/peers"
+	"github.com/sirupsen/logrus"
+)
+
+// maxReportedRounds is the max number of pending rounds, starting from the
+// oldest, described in a ConsensusReport
+const maxReportedRounds = 10
+
+// ConsensusReport is a diagnostic of the progress of consensus, meant to
+// explain why LastConsensusRound is not advancing.
+type ConsensusReport struct {
+	Time               time.Time `json:"time"`
+	Stalled            bool      `json:"stalled"`
+	LastConsensusRound *int      `json:"last_consensus_round"`
+	LastRoundAge       string    `json:"last_round_age"`
+
+	// PendingRounds are the oldest rounds which have not been processed yet
+	PendingRounds []PendingRoundReport `json:"pending_rounds"`
+
+	// Peers lists the last successful sync with every known peer
+	Peers []PeerContact `json:"peers"`
+}
+
+// PendingRoundReport describes a round in the hashgraph's PendingRounds
+type PendingRoundReport struct {
+	Index   int  `json:"index"`
+	Decided bool `json:"decided"`
+
+	// UndecidedWitnesses are the witnesses whose fame is still Undefined
+	UndecidedWitnesses []string `json:"undecided_witnesses,omitempty"`
+
+	// MissingPeers are the validators which have no event in the round
+	MissingPeers []string `json:"missing_peers,omitempty"`
+}
+
+// PeerContact is the last time the node successfully synced with a peer. It
+// is nil if the node never did.
+type PeerContact struct {
+	ID          uint32     `json:"id"`
+	Moniker     string     `json:"moniker"`
+	Validator   bool       `json:"validator"`
+	LastContact *time.Time `json:"last_contact"`
+}
+
+// GetConsensusReport builds a diagnostic of consensus progress. Stalled is set
+// if the node has pending work and no round was decided within the configured
+// StallTimeout. An idle network decides no rounds, and is not stalled.
+func (n *Node) GetConsensusReport() ConsensusReport {
+	n.coreLock.Lock()
+
+	lastRound := n.core.GetLastConsensusRoundIndex()
+	if lastRound != nil {
+		r := *lastRound
+		lastRound = &r
+	}
+
+	roundAge := n.core.lastRoundAge(n.start)
+	consensusAge, pendingWork := n.core.consensusAge(n.start)
+
+	pendingRounds := []PendingRoundReport{}
+	for _, pr := range n.core.hg.PendingRounds.GetOrderedPendingRounds() {
+		if len(pendingRounds) == maxReportedRounds {
+			break
+		}
+		pendingRounds = append(pendingRounds, n.core.pendingRoundReport(pr.Index, pr.Decided))
+	}
+
+	validators := n.core.validators
+	knownPeers := n.core.peerSelector.Peers()
+
+	n.coreLock.Unlock()
+
+	contacts := n.contacts.get()
+
+	reportPeers := []PeerContact{}
+	addPeer := func(p *peers.Peer) {
+		if p.ID() == n.GetID() {
+			return
+		}
+
+		pc := PeerContact{
+			ID:      p.ID(),
+			Moniker: p.Moniker,
+		}
+		_, pc.Validator = validators.ByID[p.ID()]
+		if t, ok := contacts[p.ID()]; ok {
+			pc.LastContact = &t
+		}
+
+		reportPeers = append(reportPeers, pc)
+	}
+
+	for _, p := range validators.Peers {
+		addPeer(p)
+	}
+	for _, p := range knownPeers.Peers {
+		if _, ok := validators.ByID[p.ID()]; !ok {
+			addPeer(p)
+		}
+	}
+
+	return ConsensusReport{
+		Time:               time.Now(),
+		Stalled:            n.conf.StallTimeout > 0 && pendingWork && consensusAge > n.conf.StallTimeout,
+		LastConsensusRound: lastRound,
+		LastRoundAge:       roundAge.Round(time.Millisecond).String(),
+		PendingRounds:      pendingRounds,
+		Peers:              reportPeers,
+	}
+}
+
+// pendingRoundReport describes a pending round, using the validator-set of
+// that round to determine which peers are missing.
+func (c *Core) pendingRoundReport(index int, decided bool) PendingRoundReport {
+	report := PendingRoundReport{
+		Index:   index,
+		Decided: decided,
+	}
+
+	round, err := c.hg.Store.GetRound(index)
+	if err != nil {
+		c.logger.WithError(err).WithField("round", index).Debug("pendingRoundReport GetRound")
+		return report
+	}
+
+	peerSet, err := c.hg.Store.GetPeerSet(index)
+	if err != nil {
+		peerSet = c.validators
+	}
+
+	creators := make(map[string]bool)
+	for hash, re := range round.CreatedEvents {
+		if re.Witness && re.Famous == common.Undefined {
+			report.UndecidedWitnesses = append(report.UndecidedWitnesses, hash)
+		}
+
+		if ev, err := c.hg.Store.GetEvent(hash); err == nil {
+			creators[ev.Creator()] = true
+		}
+	}
+
+	for _, p := range peerSet.Peers {
+		if !creators[p.PubKeyString()] {
+			report.MissingPeers = append(report.MissingPeers, p.Moniker)
+		}
+	}
+
+	return report
+}
+
+// watchdog periodically checks that consensus is progressing, and logs a
+// ConsensusReport when it is not. It returns when the node shuts down.
+func (n *Node) watchdog() {
+	if n.conf.StallTimeout <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(n.conf.StallTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if n.getState() != Babbling {
+				continue
+			}
+
+			report := n.GetConsensusReport()
+			if report.Stalled {
+				n.logConsensusReport(report)
+			}
+		case <-n.shutdownCh:
+			return
+		}
+	}
+}
+
+// logConsensusReport logs a ConsensusReport as a series of warnings
+func (n *Node) logConsensusReport(report ConsensusReport) {
+	lastRound := "nil"
+	if report.LastConsensusRound != nil {
+		lastRound = strconv.Itoa(*report.LastConsensusRound)
+	}
+
+	n.logger.WithFields(logrus.Fields{
+		"last_consensus_round": lastRound,
+		"last_round_age":       report.LastRoundAge,
+		"pending_rounds":       len(report.PendingRounds),
+	}).Warn("Consensus stalled")
+
+	for _, pr := range report.PendingRounds {
+		n.logger.WithFields(logrus.Fields{
+			"round":               pr.Index,
+			"decided":             pr.Decided,
+			"undecided_witnesses": pr.UndecidedWitnesses,
+			"missing_peers":       pr.MissingPeers,
+		}).Warn("Consensus stalled: pending round")
+	}
+
+	for _, p := range report.Peers {
+		lastContact := "never"
+		if p.LastContact != nil {
+			lastContact = time.Since(*p.LastContact).Round(time.Millisecond).String() + " ago"
+		}
+
+		n.logger.WithFields(logrus.Fields{
+			"peer":         p.Moniker,
+			"peer_id":      p.ID,
+			"validator":    p.Validator,
+			"last_contact": lastContact,
+		}).Warn("Consensus stalled: peer")
+	}
+}
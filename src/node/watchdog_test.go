@@ -0,0 +1,94 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/abassian/huron/src/common"
+)
+
+func TestConsensusReport(t *testing.T) {
+	logger := common.NewTestLogger(t)
+	keys, peers := initPeers(t, 4)
+
+	genesisPeerSet := clonePeerSet(t, peers.Peers)
+
+	nodes := initNodes(keys, peers, genesisPeerSet, 1000, 400, 5, false, "inmem", 10*time.Millisecond, logger, t)
+	defer shutdownNodes(nodes)
+
+	for _, n := range nodes {
+		n.conf.StallTimeout = 500 * time.Millisecond
+	}
+
+	err := gossip(nodes, 5, false, 6*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r := nodes[0].GetConsensusReport(); r.Stalled {
+		t.Fatalf("Consensus should not be stalled: %+v", r)
+	}
+
+	//Without a supermajority, consensus stalls on pending work
+	nodes[2].Shutdown()
+	nodes[3].Shutdown()
+	nodes[0].addTransaction([]byte("pending"))
+
+	time.Sleep(time.Second)
+
+	r := nodes[0].GetConsensusReport()
+
+	if !r.Stalled {
+		t.Fatalf("Consensus should be stalled: %+v", r)
+	}
+
+	if len(r.PendingRounds) == 0 {
+		t.Fatalf("Stalled consensus should have pending rounds")
+	}
+
+	if len(r.Peers) != 3 {
+		t.Fatalf("Report should describe 3 peers, not %d", len(r.Peers))
+	}
+
+	for _, p := range r.Peers {
+		if !p.Validator || p.LastContact == nil {
+			t.Fatalf("Peer %s should be a validator with a last contact", p.Moniker)
+		}
+	}
+}
+
+func TestConsensusReportIdle(t *testing.T) {
+	logger := common.NewTestLogger(t)
+	keys, peers := initPeers(t, 4)
+
+	genesisPeerSet := clonePeerSet(t, peers.Peers)
+
+	nodes := initNodes(keys, peers, genesisPeerSet, 1000, 400, 5, false, "inmem", 10*time.Millisecond, logger, t)
+	defer shutdownNodes(nodes)
+
+	for _, n := range nodes {
+		n.conf.StallTimeout = 500 * time.Millisecond
+	}
+
+	err := gossip(nodes, 5, false, 6*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := waitIdle(nodes, 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	//An idle network decides no rounds, but is not stalled
+	time.Sleep(2 * nodes[0].conf.StallTimeout)
+
+	if r := nodes[0].GetConsensusReport(); r.Stalled {
+		t.Fatalf("Idle consensus should not be stalled: %+v", r)
+	}
+
+	//Pending work restarts the clock rather than stalling immediately
+	nodes[0].addTransaction([]byte("pending"))
+	if r := nodes[0].GetConsensusReport(); r.Stalled {
+		t.Fatalf("Consensus should not be stalled as soon as work arrives: %+v", r)
+	}
+}
@@ -11,3 +11,10 @@ type AppProxy interface {
 	GetSnapshot(blockIndex int) ([]byte, error)
 	Restore(snapshot []byte) error
 }
+
+// Pinger is implemented by AppProxies which can check whether the application
+// is reachable. AppProxies which do not implement it are assumed to always be
+// reachable.
+type Pinger interface {
+	Ping() error
+}
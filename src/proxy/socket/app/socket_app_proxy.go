@@ -69,3 +69,8 @@ func (p *SocketAppProxy) GetSnapshot(blockIndex int) ([]byte, error) {
 func (p *SocketAppProxy) Restore(snapshot []byte) error {
 	return p.client.Restore(snapshot)
 }
+
+// Ping implements proxy.Pinger
+func (p *SocketAppProxy) Ping() error {
+	return p.client.Ping()
+}
@@ -42,6 +42,17 @@ func (p *SocketAppProxyClient) getConnection() error {
 	return nil
 }
 
+// Ping checks that the client accepts connections. It uses a separate
+// connection so as not to interfere with ongoing calls.
+func (p *SocketAppProxyClient) Ping() error {
+	conn, err := net.DialTimeout("tcp", p.clientAddr, p.timeout)
+	if err != nil {
+		return err
+	}
+
+	return conn.Close()
+}
+
 // CommitBlock ...
 func (p *SocketAppProxyClient) CommitBlock(block hashgraph.Block) (proxy.CommitResponse, error) {
 	if err := p.getConnection(); err != nil {
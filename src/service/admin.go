@@ -0,0 +1,192 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// The admin endpoints control the node at runtime. They are registered under
+// /admin and require the admin role. They are disabled if no admin credentials
+// are configured.
+
+// PostLogLevel changes the log level to the value of the level parameter
+func (s *Service) PostLogLevel(w http.ResponseWriter, r *http.Request) {
+	level := r.URL.Query().Get("level")
+
+	if err := s.node.SetLogLevel(level); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PostPauseGossip ...
+func (s *Service) PostPauseGossip(w http.ResponseWriter, r *http.Request) {
+	s.node.PauseGossip()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PostResumeGossip ...
+func (s *Service) PostResumeGossip(w http.ResponseWriter, r *http.Request) {
+	s.node.ResumeGossip()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PostLeave starts a graceful Leave and responds immediately, because the
+// node, and this service, shut down at the end of it. The outcome is
+// published as a LeaveCompleted notification on /events.
+func (s *Service) PostLeave(w http.ResponseWriter, r *http.Request) {
+	go func() {
+		if err := s.node.Leave(context.Background()); err != nil {
+			s.logger.WithError(err).Error("Leave requested by admin")
+		}
+	}()
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// PostFastSync ...
+func (s *Service) PostFastSync(w http.ResponseWriter, r *http.Request) {
+	if err := s.node.ForceFastSync(); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// GetProfile dumps a runtime profile, such as goroutine or heap. Without the
+// debug parameter, the profile is in the binary format read by go tool pprof.
+func (s *Service) GetProfile(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	debug, err := intParam(r, "debug", 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := s.node.WriteProfile(name, debug, &buf); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	if debug > 0 {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	}
+
+	buf.WriteTo(w)
+}
+
+// PostCompact compacts the node's Store
+func (s *Service) PostCompact(w http.ResponseWriter, r *http.Request) {
+	if err := s.node.CompactStore(); err != nil {
+		s.logger.WithError(err).Error("Compacting Store")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Trailers of the /admin/backup response, which mark a complete backup. The
+// status is "ok" once the whole backup was sent, or the error which interrupted
+// it. The checksum is the hex-encoded SHA256 of the body.
+const (
+	BackupStatusTrailer   = "Huron-Backup-Status"
+	BackupChecksumTrailer = "Huron-Backup-Sha256"
+)
+
+// PostBackup streams a backup of the node's Store, which can be restored with
+// the db restore command. Errors which occur after the first bytes were sent
+// can no longer change the response status; they are reported in the
+// BackupStatusTrailer instead, which ReadBackup checks.
+func (s *Service) PostBackup(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Trailer", BackupStatusTrailer+", "+BackupChecksumTrailer)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="huron.backup"`)
+
+	hash := sha256.New()
+	body := &countingWriter{w: io.MultiWriter(w, hash)}
+
+	err := s.node.BackupStore(body)
+	if err != nil {
+		s.logger.WithError(err).Error("Backing up Store")
+
+		if body.n == 0 {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	status := "ok"
+	if err != nil {
+		status = err.Error()
+	}
+
+	w.Header().Set(BackupStatusTrailer, status)
+	w.Header().Set(BackupChecksumTrailer, hex.EncodeToString(hash.Sum(nil)))
+}
+
+// ReadBackup copies the body of a successful /admin/backup response to w, and
+// returns an error unless the trailers mark a complete backup with a matching
+// checksum.
+func ReadBackup(resp *http.Response, w io.Writer) error {
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(w, hash), resp.Body); err != nil {
+		return err
+	}
+
+	status := resp.Trailer.Get(BackupStatusTrailer)
+	switch status {
+	case "ok":
+	case "":
+		return fmt.Errorf("Incomplete backup: no %s trailer", BackupStatusTrailer)
+	default:
+		return fmt.Errorf("Backup failed: %s", status)
+	}
+
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	if expected := resp.Trailer.Get(BackupChecksumTrailer); checksum != expected {
+		return fmt.Errorf("Backup checksum mismatch: %s != %s", checksum, expected)
+	}
+
+	return nil
+}
+
+// countingWriter counts the bytes written to w
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// PostPrune prunes the node's Store
+func (s *Service) PostPrune(w http.ResponseWriter, r *http.Request) {
+	if err := s.node.Prune(); err != nil {
+		s.logger.WithError(err).Error("Pruning Store")
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
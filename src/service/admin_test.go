@@ -0,0 +1,116 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/abassian/huron/src/common"
+	hg "github.com/abassian/huron/src/hashgraph"
+	"github.com/abassian/huron/src/node"
+)
+
+//backupStore is an InmemStore whose Backup writes data, and then fails with
+//err if it is not nil
+type backupStore struct {
+	*hg.InmemStore
+	data []byte
+	err  error
+}
+
+func (s *backupStore) Backup(w io.Writer) error {
+	if _, err := w.Write(s.data); err != nil {
+		return err
+	}
+	return s.err
+}
+
+//fetchTestBackup backs up the Store through /admin/backup and ReadBackup
+func fetchTestBackup(store hg.Store, t *testing.T) ([]byte, error) {
+	s, err := NewService("127.0.0.1:0", Config{AdminToken: testAdminToken}, newTestNodeWithStore(store, t), common.NewTestLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.node.Shutdown()
+
+	ts := httptest.NewServer(s.server.Handler)
+	defer ts.Close()
+
+	req, err := http.NewRequest("POST", ts.URL+"/admin/backup", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Backup should return 200, not %d", resp.StatusCode)
+	}
+
+	var buf bytes.Buffer
+	err = ReadBackup(resp, &buf)
+	return buf.Bytes(), err
+}
+
+func TestBackupIntegrity(t *testing.T) {
+	data := bytes.Repeat([]byte("backup"), 10000)
+
+	backup, err := fetchTestBackup(&backupStore{
+		InmemStore: hg.NewInmemStore(node.DefaultConfig().CacheSize),
+		data:       data,
+	}, t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(backup, data) {
+		t.Fatalf("Backup should have %d bytes, not %d", len(data), len(backup))
+	}
+
+	//A backup which fails after the first bytes were sent still returns 200,
+	//but is not marked complete
+	_, err = fetchTestBackup(&backupStore{
+		InmemStore: hg.NewInmemStore(node.DefaultConfig().CacheSize),
+		data:       data,
+		err:        errors.New("disk failure"),
+	}, t)
+	if err == nil || !strings.Contains(err.Error(), "disk failure") {
+		t.Fatalf("A backup which failed partway should be refused, not %v", err)
+	}
+}
+
+func TestReadBackupTrailers(t *testing.T) {
+	cases := []struct {
+		status   string
+		checksum string
+		err      string
+	}{
+		{"", "", "Incomplete backup"},
+		{"ok", "0000", "checksum mismatch"},
+	}
+
+	for _, c := range cases {
+		resp := &http.Response{
+			Body:    ioutil.NopCloser(strings.NewReader("backup")),
+			Trailer: http.Header{},
+		}
+		if c.status != "" {
+			resp.Trailer.Set(BackupStatusTrailer, c.status)
+			resp.Trailer.Set(BackupChecksumTrailer, c.checksum)
+		}
+
+		var buf bytes.Buffer
+		if err := ReadBackup(resp, &buf); err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Fatalf("Trailers %q %q should fail with %q, not %v", c.status, c.checksum, c.err, err)
+		}
+	}
+}
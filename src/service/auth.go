@@ -0,0 +1,227 @@
+package service
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Config contains the security settings of the HTTP service. The zero value
+// serves the read endpoints over plain HTTP to anyone, without cross-origin
+// access, and disables the admin endpoints.
+type Config struct {
+	// CertFile and KeyFile are the PEM-encoded certificate and private key
+	// used to serve HTTPS. The service uses plain HTTP if they are empty.
+	CertFile string
+	KeyFile  string
+
+	// ClientCAFile is a PEM-encoded bundle of the CAs used to verify client
+	// certificates. Clients presenting a certificate signed by one of these
+	// CAs are granted the read-only role, or the admin role if the
+	// certificate's common name is listed in AdminClients. It requires TLS.
+	ClientCAFile string
+	AdminClients []string
+
+	// ReadToken and AdminToken are the bearer tokens granting the read-only
+	// and admin roles.
+	ReadToken  string
+	AdminToken string
+
+	// AllowedOrigins are the origins allowed to make cross-origin requests.
+	// "*" allows any origin.
+	AllowedOrigins []string
+}
+
+// Role is the level of access granted to a request
+type Role int
+
+const (
+	// Anonymous requests did not present any valid credentials
+	Anonymous Role = iota
+	// ReadOnly requests can read the state of the node
+	ReadOnly
+	// Admin requests can also submit transactions and control the node
+	Admin
+)
+
+// String ...
+func (r Role) String() string {
+	switch r {
+	case Anonymous:
+		return "Anonymous"
+	case ReadOnly:
+		return "ReadOnly"
+	case Admin:
+		return "Admin"
+	default:
+		return "Unknown"
+	}
+}
+
+// tlsConfig returns the TLS configuration of the service, or nil if TLS is
+// not enabled.
+func (c *Config) tlsConfig() (*tls.Config, error) {
+	if c.CertFile == "" && c.KeyFile == "" {
+		if c.ClientCAFile != "" {
+			return nil, fmt.Errorf("Client certificates require TLS")
+		}
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("Loading TLS certificate: %v", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if c.ClientCAFile != "" {
+		pem, err := ioutil.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Reading client CA file: %v", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificate found in client CA file %s", c.ClientCAFile)
+		}
+
+		//Clients may still authenticate with a bearer token instead of a
+		//certificate
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return tlsConfig, nil
+}
+
+// role returns the highest role granted by the credentials of a request
+func (c *Config) role(r *http.Request) Role {
+	role := Anonymous
+
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		role = ReadOnly
+
+		name := r.TLS.VerifiedChains[0][0].Subject.CommonName
+		for _, admin := range c.AdminClients {
+			if name == admin {
+				return Admin
+			}
+		}
+	}
+
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token := []byte(strings.TrimPrefix(auth, "Bearer "))
+
+		if c.AdminToken != "" && subtle.ConstantTimeCompare(token, []byte(c.AdminToken)) == 1 {
+			return Admin
+		}
+
+		if c.ReadToken != "" && subtle.ConstantTimeCompare(token, []byte(c.ReadToken)) == 1 {
+			role = ReadOnly
+		}
+	}
+
+	return role
+}
+
+// adminEnabled returns true if credentials granting the admin role are
+// configured. Admin endpoints are disabled otherwise.
+func (c *Config) adminEnabled() bool {
+	return c.AdminToken != "" || len(c.AdminClients) > 0
+}
+
+// required returns the role needed to access endpoints of the given level.
+// Read endpoints without any configured credentials are open to anyone, so
+// that a service without authentication keeps working as before. Admin
+// endpoints always require the admin role.
+func (c *Config) required(level Role) Role {
+	if level == ReadOnly && c.ReadToken == "" && c.ClientCAFile == "" {
+		return Anonymous
+	}
+
+	return level
+}
+
+// authorize wraps a handler so that it is only served to requests granted at
+// least the given role.
+func (s *Service) authorize(level Role, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.config.role(r) < s.config.required(level) {
+			s.logger.WithFields(logrus.Fields{
+				"path":   r.URL.Path,
+				"remote": r.RemoteAddr,
+			}).Debug("Unauthorized request")
+
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// adminGate wraps a handler so that it is refused to every request when no
+// admin credentials are configured. It is applied to the whole admin
+// subrouter, so that no admin endpoint is ever served to anonymous clients.
+func (s *Service) adminGate(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.config.adminEnabled() {
+			http.Error(w, "Admin endpoints are disabled", http.StatusForbidden)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// CORSServer applies the cross-origin policy of the service before handing
+// requests over to the router
+type CORSServer struct {
+	r              http.Handler
+	allowedOrigins []string
+}
+
+// allowed returns true if requests from the given origin are allowed
+func (s *CORSServer) allowed(origin string) bool {
+	for _, o := range s.allowedOrigins {
+		if o == "*" || o == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// ServeHTTP ...
+func (s *CORSServer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if origin := req.Header.Get("Origin"); origin != "" {
+		rw.Header().Add("Vary", "Origin")
+
+		if s.allowed(origin) {
+			rw.Header().Set("Access-Control-Allow-Origin", origin)
+			rw.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
+			rw.Header().Set("Access-Control-Allow-Headers",
+				"Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+		}
+
+		// Stop here if its Preflighted OPTIONS request
+		if req.Method == "OPTIONS" && req.Header.Get("Access-Control-Request-Method") != "" {
+			if !s.allowed(origin) {
+				rw.WriteHeader(http.StatusForbidden)
+			}
+			return
+		}
+	}
+	// Lets Gorilla work
+	s.r.ServeHTTP(rw, req)
+}
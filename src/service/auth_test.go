@@ -0,0 +1,461 @@
+package service
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const (
+	testReadToken  = "read-token"
+	testAdminToken = "admin-token"
+)
+
+//routeProbes are requests to one endpoint of each group, which do not change
+//the state of the node. Authorized requests to the admin endpoints fail with
+//400 because of their bad parameters.
+var routeProbes = map[string]struct {
+	method string
+	path   string
+	level  Role
+}{
+	"probe":    {"GET", "/health", Anonymous},
+	"read":     {"GET", "/stats", ReadOnly},
+	"tx":       {"POST", "/tx", Admin},
+	"admin":    {"POST", "/admin/loglevel?level=bogus", Admin},
+	"profiles": {"GET", "/admin/profile/bogus?debug=x", Admin},
+}
+
+//serve sends a request to the Service's handler and returns the status code
+func serve(s *Service, req *http.Request) int {
+	rec := httptest.NewRecorder()
+	s.server.Handler.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+//bearer returns a request presenting the given token, if any
+func bearer(method string, path string, token string) *http.Request {
+	req := httptest.NewRequest(method, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+func TestRoles(t *testing.T) {
+	configs := map[string]Config{
+		"none":  {},
+		"read":  {ReadToken: testReadToken},
+		"admin": {AdminToken: testAdminToken},
+		"both":  {ReadToken: testReadToken, AdminToken: testAdminToken},
+	}
+
+	tokens := map[string]string{
+		"anonymous": "",
+		"wrong":     "wrong-token",
+		"read":      testReadToken,
+		"admin":     testAdminToken,
+	}
+
+	//expected returns the status of a request of the given role to an
+	//endpoint of the given level
+	expected := func(config Config, role Role, level Role) int {
+		switch {
+		case level == Admin && !config.adminEnabled():
+			return http.StatusForbidden
+		case role < config.required(level):
+			return http.StatusUnauthorized
+		case level == Admin:
+			return http.StatusBadRequest
+		default:
+			return http.StatusOK
+		}
+	}
+
+	for cname, config := range configs {
+		s := newTestService(config, t)
+		defer s.node.Shutdown()
+
+		for tname, token := range tokens {
+			role := s.config.role(bearer("GET", "/", token))
+
+			want := Anonymous
+			switch {
+			case tname == "read" && config.ReadToken != "":
+				want = ReadOnly
+			case tname == "admin" && config.AdminToken != "":
+				want = Admin
+			}
+			if role != want {
+				t.Fatalf("%s token should be granted %v with %s config, not %v", tname, want, cname, role)
+			}
+
+			for pname, probe := range routeProbes {
+				code := serve(s, bearer(probe.method, probe.path, token))
+				if want := expected(config, role, probe.level); code != want {
+					t.Fatalf("%s request to %s with %s config should return %d, not %d",
+						tname, pname, cname, want, code)
+				}
+			}
+		}
+	}
+}
+
+func TestCORS(t *testing.T) {
+	s := newTestService(Config{AllowedOrigins: []string{"https://allowed.example"}}, t)
+	defer s.node.Shutdown()
+
+	for origin, allowed := range map[string]bool{
+		"https://allowed.example": true,
+		"https://evil.example":    false,
+	} {
+		//Preflight
+		req := httptest.NewRequest("OPTIONS", "/stats", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", "GET")
+		rec := httptest.NewRecorder()
+		s.server.Handler.ServeHTTP(rec, req)
+
+		if allowed && (rec.Code != http.StatusOK || rec.Header().Get("Access-Control-Allow-Origin") != origin) {
+			t.Fatalf("Preflight from %s should be allowed, got %d %v", origin, rec.Code, rec.Header())
+		}
+		if !allowed && (rec.Code != http.StatusForbidden || rec.Header().Get("Access-Control-Allow-Origin") != "") {
+			t.Fatalf("Preflight from %s should be refused, got %d %v", origin, rec.Code, rec.Header())
+		}
+
+		//Simple request
+		req = httptest.NewRequest("GET", "/stats", nil)
+		req.Header.Set("Origin", origin)
+		rec = httptest.NewRecorder()
+		s.server.Handler.ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("Access-Control-Allow-Origin") != ""; got != allowed {
+			t.Fatalf("Response to %s should allow cross-origin access: %v", origin, allowed)
+		}
+	}
+}
+
+//testPKI holds the files of a CA, a server certificate and client
+//certificates signed by the CA
+type testPKI struct {
+	dir        string
+	caFile     string
+	certFile   string
+	keyFile    string
+	caCert     *x509.Certificate
+	caKey      *ecdsa.PrivateKey
+	serverPool *x509.CertPool
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	dir, err := ioutil.TempDir("", "huron-service")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pki := &testPKI{
+		dir:      dir,
+		caFile:   filepath.Join(dir, "ca.pem"),
+		certFile: filepath.Join(dir, "cert.pem"),
+		keyFile:  filepath.Join(dir, "key.pem"),
+	}
+
+	caKey, caDER := pki.sign(&x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}, t)
+	pki.caKey = caKey
+	pki.caCert, err = x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(pki.caFile, "CERTIFICATE", caDER, t)
+
+	pki.serverPool = x509.NewCertPool()
+	pki.serverPool.AddCert(pki.caCert)
+
+	key, der := pki.sign(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, t)
+	writePEM(pki.certFile, "CERTIFICATE", der, t)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePEM(pki.keyFile, "EC PRIVATE KEY", keyDER, t)
+
+	return pki
+}
+
+//sign creates a key and a certificate from the template, signed by the CA,
+//or self-signed if there is no CA yet
+func (pki *testPKI) sign(template *x509.Certificate, t *testing.T) (*ecdsa.PrivateKey, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template.SerialNumber = serial
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+
+	parent, parentKey := template, key
+	if pki.caCert != nil {
+		parent, parentKey = pki.caCert, pki.caKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key, der
+}
+
+//clientCert returns a client certificate with the given common name
+func (pki *testPKI) clientCert(name string, t *testing.T) tls.Certificate {
+	key, der := pki.sign(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: name},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, t)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func writePEM(path string, blockType string, der []byte, t *testing.T) {
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	pki := newTestPKI(t)
+	defer os.RemoveAll(pki.dir)
+
+	if c, err := (&Config{}).tlsConfig(); c != nil || err != nil {
+		t.Fatalf("TLS should be disabled without a certificate, got %v %v", c, err)
+	}
+
+	if _, err := (&Config{ClientCAFile: pki.caFile}).tlsConfig(); err == nil {
+		t.Fatal("Client certificates should require TLS")
+	}
+
+	if _, err := (&Config{CertFile: pki.certFile, KeyFile: pki.caFile}).tlsConfig(); err == nil {
+		t.Fatal("A certificate without its key should be refused")
+	}
+
+	if _, err := (&Config{CertFile: pki.certFile, KeyFile: pki.keyFile, ClientCAFile: pki.keyFile}).tlsConfig(); err == nil {
+		t.Fatal("A client CA file without certificates should be refused")
+	}
+
+	c, err := (&Config{CertFile: pki.certFile, KeyFile: pki.keyFile}).tlsConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.MinVersion != tls.VersionTLS12 || c.ClientAuth != tls.NoClientCert {
+		t.Fatalf("TLS config should require TLS 1.2 and no client certificate, got %#v", c)
+	}
+
+	c, err = (&Config{CertFile: pki.certFile, KeyFile: pki.keyFile, ClientCAFile: pki.caFile}).tlsConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ClientAuth != tls.VerifyClientCertIfGiven || c.ClientCAs == nil {
+		t.Fatalf("TLS config should verify client certificates if given, got %#v", c)
+	}
+}
+
+func TestClientCertificates(t *testing.T) {
+	pki := newTestPKI(t)
+	defer os.RemoveAll(pki.dir)
+
+	s := newTestService(Config{
+		CertFile:     pki.certFile,
+		KeyFile:      pki.keyFile,
+		ClientCAFile: pki.caFile,
+		AdminClients: []string{"operator"},
+		AdminToken:   testAdminToken,
+	}, t)
+	defer s.node.Shutdown()
+
+	ts := httptest.NewUnstartedServer(s.server.Handler)
+	ts.TLS = s.tlsConfig
+	ts.StartTLS()
+	defer ts.Close()
+
+	cases := []struct {
+		name  string
+		certs []tls.Certificate
+		token string
+		role  Role
+	}{
+		{"no certificate", nil, "", Anonymous},
+		{"reader", []tls.Certificate{pki.clientCert("reader", t)}, "", ReadOnly},
+		{"operator", []tls.Certificate{pki.clientCert("operator", t)}, "", Admin},
+		{"no certificate with admin token", nil, testAdminToken, Admin},
+		{"reader with admin token", []tls.Certificate{pki.clientCert("reader", t)}, testAdminToken, Admin},
+	}
+
+	for _, c := range cases {
+		client := &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					RootCAs:      pki.serverPool,
+					Certificates: c.certs,
+				},
+			},
+		}
+
+		for pname, probe := range routeProbes {
+			req, err := http.NewRequest(probe.method, ts.URL+probe.path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.token != "" {
+				req.Header.Set("Authorization", "Bearer "+c.token)
+			}
+
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			want := http.StatusOK
+			switch {
+			case c.role < probe.level:
+				want = http.StatusUnauthorized
+			case probe.level == Admin:
+				want = http.StatusBadRequest
+			}
+			if resp.StatusCode != want {
+				t.Fatalf("%s request to %s should return %d, not %d", c.name, pname, want, resp.StatusCode)
+			}
+		}
+	}
+
+	//A self-signed certificate, even with an admin common name, is either
+	//refused during the handshake or not sent at all
+	otherKey, otherDER := (&testPKI{}).sign(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "operator"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, t)
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				RootCAs: pki.serverPool,
+				Certificates: []tls.Certificate{{
+					Certificate: [][]byte{otherDER},
+					PrivateKey:  otherKey,
+				}},
+			},
+		},
+	}
+	if resp, err := client.Get(ts.URL + "/stats"); err == nil {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("A certificate from another CA should not grant any role, got %s", resp.Status)
+		}
+	}
+}
+
+func TestAdminDisabled(t *testing.T) {
+	adminRoutes := []struct {
+		method string
+		path   string
+	}{
+		{"POST", "/tx"},
+		{"POST", "/txs"},
+		{"POST", "/admin/loglevel?level=debug"},
+		{"POST", "/admin/gossip/pause"},
+		{"POST", "/admin/gossip/resume"},
+		{"POST", "/admin/leave"},
+		{"POST", "/admin/fastsync"},
+		{"GET", "/admin/profile/goroutine"},
+		{"POST", "/admin/compact"},
+		{"POST", "/admin/prune"},
+		{"POST", "/admin/backup"},
+	}
+
+	//Neither the default config nor read credentials open the admin
+	//endpoints
+	for _, config := range []Config{{}, {ReadToken: testReadToken}} {
+		s := newTestService(config, t)
+		defer s.node.Shutdown()
+
+		for _, token := range []string{"", testReadToken} {
+			for _, route := range adminRoutes {
+				if code := serve(s, bearer(route.method, route.path, token)); code != http.StatusForbidden {
+					t.Fatalf("%s %s should be forbidden without admin credentials, not %d",
+						route.method, route.path, code)
+				}
+			}
+		}
+
+		if s.node.IsGossipPaused() || !s.node.IsAlive() {
+			t.Fatalf("Refused admin requests should not change the node")
+		}
+	}
+}
+
+func TestBackupRequiresAdmin(t *testing.T) {
+	s := newTestService(Config{ReadToken: testReadToken, AdminToken: testAdminToken}, t)
+	defer s.node.Shutdown()
+
+	ts := httptest.NewServer(s.server.Handler)
+	defer ts.Close()
+
+	//The test node's InmemStore does not support backups, so an authorized
+	//request gets past the authorization and fails with 500
+	cases := []struct {
+		method string
+		token  string
+		status int
+	}{
+		{"POST", "", http.StatusUnauthorized},
+		{"POST", "wrong-token", http.StatusUnauthorized},
+		{"POST", testReadToken, http.StatusUnauthorized},
+		{"GET", testAdminToken, http.StatusMethodNotAllowed},
+		{"POST", testAdminToken, http.StatusInternalServerError},
+	}
+
+	for _, c := range cases {
+		req, err := http.NewRequest(c.method, ts.URL+"/admin/backup", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != c.status {
+			t.Fatalf("%s /admin/backup with token %q should return %d, not %d",
+				c.method, c.token, c.status, resp.StatusCode)
+		}
+	}
+}
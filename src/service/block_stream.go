@@ -0,0 +1,136 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/abassian/huron/src/node"
+)
+
+const (
+	// blockStreamBufferSize is the number of Notifications buffered for each
+	// client of the block stream. Notifications are only used to wake the
+	// stream up, so dropping some does not lose any block.
+	blockStreamBufferSize = 10
+
+	// keepAliveInterval is the time between two keep-alive comments sent to
+	// idle stream clients
+	keepAliveInterval = 15 * time.Second
+)
+
+// GetBlockStream streams committed blocks as server-sent events, starting at
+// the block index given by the from parameter, or following the Last-Event-ID
+// header when a client reconnects. Historical blocks are replayed from the
+// Store, then new blocks are sent as they are committed. Only blocks which were
+// committed to the App, with their StateHash, are streamed; the stream does not
+// go past a block which is stored but failed to commit.
+//
+// Blocks are always read from the Store, and the stream only advances as fast
+// as the client reads, so a slow client lags behind without slowing the node
+// down or missing blocks.
+func (s *Service) GetBlockStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	cursor, err := streamStart(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	//Subscribe before reading the Store so that no commit is missed
+	sub := s.node.Subscribe(blockStreamBufferSize)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		for last := s.node.GetLastCommittedBlockIndex(); cursor <= last; cursor++ {
+			block, err := s.node.GetBlock(cursor)
+			if err != nil {
+				s.logger.WithError(err).Errorf("Streaming block %d", cursor)
+				fmt.Fprintf(w, "event: error\ndata: %q\n\n", err.Error())
+				flusher.Flush()
+				return
+			}
+
+			data, err := json.Marshal(block)
+			if err != nil {
+				s.logger.WithError(err).Errorf("Marshalling block %d", cursor)
+				return
+			}
+
+			if _, err := fmt.Fprintf(w, "id: %d\nevent: block\ndata: %s\n\n", cursor, data); err != nil {
+				return
+			}
+
+			flusher.Flush()
+
+			if r.Context().Err() != nil {
+				return
+			}
+		}
+
+		select {
+		case _, ok := <-sub.Notifications():
+			if !ok || !drain(sub.Notifications()) {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// streamStart returns the index of the first block to stream
+func streamStart(r *http.Request) (int, error) {
+	if from := r.URL.Query().Get("from"); from != "" {
+		index, err := strconv.Atoi(from)
+		if err != nil || index < 0 {
+			return 0, fmt.Errorf("Invalid from parameter %s", from)
+		}
+		return index, nil
+	}
+
+	if lastID := r.Header.Get("Last-Event-ID"); lastID != "" {
+		index, err := strconv.Atoi(lastID)
+		if err != nil {
+			return 0, fmt.Errorf("Invalid Last-Event-ID %s", lastID)
+		}
+		return index + 1, nil
+	}
+
+	return 0, nil
+}
+
+// drain empties a channel without blocking. It returns false if the channel
+// is closed.
+func drain(ch <-chan node.Notification) bool {
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return false
+			}
+		default:
+			return true
+		}
+	}
+}
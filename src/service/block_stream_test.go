@@ -0,0 +1,191 @@
+package service
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abassian/huron/src/common"
+	hg "github.com/abassian/huron/src/hashgraph"
+	"github.com/abassian/huron/src/proxy"
+	dummy "github.com/abassian/huron/src/proxy/dummy"
+	"github.com/sirupsen/logrus"
+)
+
+//failingApp is a dummy App which fails to commit any Block
+type failingApp struct {
+	*dummy.InmemDummyClient
+}
+
+func (a *failingApp) CommitBlock(block hg.Block) (proxy.CommitResponse, error) {
+	return proxy.CommitResponse{}, fmt.Errorf("Failed to commit Block %d", block.Index())
+}
+
+//readBlockEvents reads n block events from a server-sent events stream and
+//returns their ids
+func readBlockEvents(r *bufio.Reader, n int, t *testing.T) []int {
+	ids := []int{}
+	id := -1
+
+	for len(ids) < n {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id, err = strconv.Atoi(strings.TrimPrefix(line, "id: "))
+			if err != nil {
+				t.Fatal(err)
+			}
+		case strings.HasPrefix(line, "data: "):
+			var block hg.Block
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &block); err != nil {
+				t.Fatal(err)
+			}
+			if block.Index() != id {
+				t.Fatalf("Event %d should carry Block %d, not %d", id, id, block.Index())
+			}
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+func TestBlockStream(t *testing.T) {
+	s, ts := runTestService(Config{AdminToken: testAdminToken}, t)
+	defer s.node.Shutdown()
+	defer ts.Close()
+
+	commitBlocks(ts, s, 3, t)
+	last := s.node.GetLastBlockIndex()
+
+	//Replay from Block 1, then follow live Blocks
+	resp, err := http.Get(ts.URL + "/blocks/stream?from=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type should be text/event-stream, not %s", ct)
+	}
+
+	stream := bufio.NewReader(resp.Body)
+
+	replayed := readBlockEvents(stream, last, t)
+	for i, id := range replayed {
+		if id != i+1 {
+			t.Fatalf("Replayed Blocks should be 1 to %d, not %v", last, replayed)
+		}
+	}
+
+	done := make(chan []int)
+	go func() {
+		done <- readBlockEvents(stream, 1, t)
+	}()
+
+	postTx(ts, "live", "?wait=true&timeout=5s", t)
+
+	select {
+	case live := <-done:
+		if live[0] != last+1 {
+			t.Fatalf("The first live Block should be %d, not %d", last+1, live[0])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for a live Block")
+	}
+
+	//A client reconnecting with Last-Event-ID resumes after that Block
+	req, _ := http.NewRequest("GET", ts.URL+"/blocks/stream", nil)
+	req.Header.Set("Last-Event-ID", strconv.Itoa(last))
+	resp2, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp2.Body.Close()
+
+	if resumed := readBlockEvents(bufio.NewReader(resp2.Body), 1, t); resumed[0] != last+1 {
+		t.Fatalf("The stream should resume at Block %d, not %d", last+1, resumed[0])
+	}
+
+	//A bad start is refused
+	resp3, err := http.Get(ts.URL + "/blocks/stream?from=-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp3.Body.Close()
+	if resp3.StatusCode != http.StatusBadRequest {
+		t.Fatalf("A negative from should return 400, not %d", resp3.StatusCode)
+	}
+}
+
+func TestBlockStreamUncommitted(t *testing.T) {
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+
+	app := &failingApp{dummy.NewInmemDummyClient(logger)}
+
+	n := newTestNodeWith(hg.NewInmemStore(100), app, t)
+	s, err := NewService("127.0.0.1:0", Config{}, n, common.NewTestLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.node.RunAsync(true)
+	defer s.node.Shutdown()
+
+	ts := httptest.NewServer(s.server.Handler)
+	defer ts.Close()
+
+	//The Block is stored, but the App fails to commit it
+	app.SubmitTx([]byte("uncommitted"))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for s.node.GetLastBlockIndex() < 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timeout waiting for a Block")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if last := s.node.GetLastCommittedBlockIndex(); last != -1 {
+		t.Fatalf("The last committed Block should be -1, not %d", last)
+	}
+
+	resp, err := http.Get(ts.URL + "/blocks/stream?from=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	ids := make(chan string, 1)
+	go func() {
+		stream := bufio.NewReader(resp.Body)
+		for {
+			line, err := stream.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if strings.HasPrefix(line, "id: ") {
+				ids <- line
+				return
+			}
+		}
+	}()
+
+	select {
+	case id := <-ids:
+		t.Fatalf("An uncommitted Block should not be streamed, got %s", id)
+	case <-time.After(500 * time.Millisecond):
+	}
+}
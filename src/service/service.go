@@ -1,76 +1,196 @@
 package service
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
 
-	"github.com/gorilla/mux"
+	"github.com/abassian/huron/src/common"
+	hg "github.com/abassian/huron/src/hashgraph"
 	"github.com/abassian/huron/src/node"
 	"github.com/abassian/huron/src/peers"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
 )
 
+// maxBlocksPage is the maximum number of blocks returned by a single /blocks
+// request
+const maxBlocksPage = 100
+
+// eventsBufferSize is the number of Notifications buffered for each client of
+// the /events endpoint
+const eventsBufferSize = 100
+
 // Service ...
 type Service struct {
 	bindAddress string
 	node        *node.Node
 	graph       *node.Graph
+	server      *http.Server
+	config      Config
+	tlsConfig   *tls.Config
+	registry    *prometheus.Registry
 	logger      *logrus.Logger
 }
 
 // NewService ...
-func NewService(bindAddress string, n *node.Node, logger *logrus.Logger) *Service {
+// It returns an error if the TLS settings of the Config cannot be loaded.
+func NewService(bindAddress string, config Config, n *node.Node, logger *logrus.Logger) (*Service, error) {
+	tlsConfig, err := config.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
 	service := Service{
 		bindAddress: bindAddress,
 		node:        n,
 		graph:       node.NewGraph(n),
+		config:      config,
+		tlsConfig:   tlsConfig,
+		registry:    prometheus.NewRegistry(),
 		logger:      logger,
 	}
 
-	return &service
-}
+	service.registry.MustRegister(node.NewMetricsCollector(n))
 
-//Serve defines endpoints and starts ListenAndServe
-func (s *Service) Serve() {
-	s.logger.WithField("bind_address", s.bindAddress).Debug("Huron Service serving")
+	if !config.adminEnabled() {
+		logger.Warn("No admin token or admin client configured: transaction submission and admin endpoints of the HTTP service are disabled")
+	}
 
+	//The server is created here, rather than in Serve, so that Shutdown can
+	//be called concurrently with Serve
+	service.server = &http.Server{
+		Addr:      bindAddress,
+		Handler:   service.handler(),
+		TLSConfig: tlsConfig,
+	}
+
+	return &service, nil
+}
+
+//handler defines the endpoints of the service
+func (s *Service) handler() http.Handler {
 	serverMuxHuron := http.NewServeMux()
 	r := mux.NewRouter()
-	r.HandleFunc("/stats", s.GetStats)
-	r.HandleFunc("/block/{index}", s.GetBlock)
-	r.HandleFunc("/graph", s.GetGraph)
-	r.HandleFunc("/peers", s.GetPeers)
-	r.HandleFunc("/genesispeers", s.GetGenesisPeers)
 
-	serverMuxHuron.Handle("/", &CORSServer{r})
+	//Liveness and readiness probes are not authenticated
+	r.HandleFunc("/health", s.GetHealth)
+	r.HandleFunc("/ready", s.GetReady)
+
+	read := r.NewRoute().Subrouter()
+	read.Use(func(h http.Handler) http.Handler { return s.authorize(ReadOnly, h) })
+	read.HandleFunc("/stats", s.GetStats)
+	read.HandleFunc("/block/{index}", s.GetBlock)
+	read.HandleFunc("/blocks", s.GetBlocks)
+	read.HandleFunc("/blocks/stream", s.GetBlockStream)
+	read.HandleFunc("/event/{hash}", s.GetEvent)
+	read.HandleFunc("/round/{index}", s.GetRound)
+	read.HandleFunc("/graph", s.GetGraph)
+	read.HandleFunc("/peers", s.GetPeers)
+	read.HandleFunc("/peers/{round}", s.GetPeerSet)
+	read.HandleFunc("/peersets", s.GetAllPeerSets)
+	read.HandleFunc("/genesispeers", s.GetGenesisPeers)
+	read.HandleFunc("/events", s.GetEvents)
+	read.HandleFunc("/consensus", s.GetConsensusReport)
+	read.Handle("/metrics", s.metricsHandler())
+
+	//Admin endpoints, including transaction submission, are disabled unless
+	//admin credentials are configured
+	admin := r.NewRoute().Subrouter()
+	admin.Use(s.adminGate)
+	admin.Use(func(h http.Handler) http.Handler { return s.authorize(Admin, h) })
+	admin.HandleFunc("/tx", s.PostTx).Methods("POST")
+	admin.HandleFunc("/txs", s.PostTxs).Methods("POST")
+	admin.HandleFunc("/admin/loglevel", s.PostLogLevel).Methods("POST")
+	admin.HandleFunc("/admin/gossip/pause", s.PostPauseGossip).Methods("POST")
+	admin.HandleFunc("/admin/gossip/resume", s.PostResumeGossip).Methods("POST")
+	admin.HandleFunc("/admin/leave", s.PostLeave).Methods("POST")
+	admin.HandleFunc("/admin/fastsync", s.PostFastSync).Methods("POST")
+	admin.HandleFunc("/admin/profile/{name}", s.GetProfile).Methods("GET")
+	admin.HandleFunc("/admin/compact", s.PostCompact).Methods("POST")
+	admin.HandleFunc("/admin/prune", s.PostPrune).Methods("POST")
+	admin.HandleFunc("/admin/backup", s.PostBackup).Methods("POST")
+
+	serverMuxHuron.Handle("/", &CORSServer{r, s.config.AllowedOrigins})
+
+	return serverMuxHuron
+}
 
-	err := http.ListenAndServe(s.bindAddress, serverMuxHuron)
+//Serve starts serving the endpoints. It always returns a non-nil error, which
+//is http.ErrServerClosed after Shutdown.
+func (s *Service) Serve() error {
+	s.logger.WithFields(logrus.Fields{
+		"bind_address": s.bindAddress,
+		"tls":          s.tlsConfig != nil,
+	}).Debug("Huron Service serving")
+
+	var err error
+	if s.tlsConfig != nil {
+		//The certificate is already loaded in the TLSConfig
+		err = s.server.ListenAndServeTLS("", "")
+	} else {
+		err = s.server.ListenAndServe()
+	}
 
-	if err != nil {
+	if err != http.ErrServerClosed {
 		s.logger.WithField("error", err).Error("Service failed")
 	}
+
+	return err
 }
 
-// CORSServer ...
-type CORSServer struct {
-	r *mux.Router
+//Shutdown gracefully stops the HTTP server
+func (s *Service) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
 }
 
-// ServeHTTP ...
-func (s *CORSServer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
-	if origin := req.Header.Get("Origin"); origin != "" {
-		rw.Header().Set("Access-Control-Allow-Origin", origin)
-		rw.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		rw.Header().Set("Access-Control-Allow-Headers",
-			"Accept, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
-	}
-	// Stop here if its Preflighted OPTIONS request
-	if req.Method == "OPTIONS" {
+// metricsHandler serves the process-wide metrics, registered by the node,
+// hashgraph and net packages, along with the metrics of this service's node,
+// in the Prometheus text format.
+func (s *Service) metricsHandler() http.Handler {
+	return promhttp.HandlerFor(
+		prometheus.Gatherers{prometheus.DefaultGatherer, s.registry},
+		promhttp.HandlerOpts{},
+	)
+}
+
+// GetHealth answers 200 as long as the node is alive, and 503 once it has shut
+// down.
+func (s *Service) GetHealth(w http.ResponseWriter, r *http.Request) {
+	if !s.node.IsAlive() {
+		http.Error(w, "Shutdown", http.StatusServiceUnavailable)
 		return
 	}
-	// Lets Gorilla work
-	s.r.ServeHTTP(rw, req)
+
+	w.Write([]byte("OK"))
+}
+
+// GetReady answers 200 if the node is ready and 503 otherwise. The body
+// describes the checks performed.
+func (s *Service) GetReady(w http.ResponseWriter, r *http.Request) {
+	readiness := s.node.GetReadiness()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if !readiness.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	json.NewEncoder(w).Encode(readiness)
+}
+
+// GetConsensusReport returns a diagnostic of consensus progress
+func (s *Service) GetConsensusReport(w http.ResponseWriter, r *http.Request) {
+	report := s.node.GetConsensusReport()
+
+	w.Header().Set("Content-Type", "application/json")
+
+	json.NewEncoder(w).Encode(report)
 }
 
 // GetStats ...
@@ -91,7 +211,7 @@ func (s *Service) GetBlock(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		s.logger.WithError(err).Errorf("Parsing block_index parameter %s", param)
 
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 
 		return
 	}
@@ -101,7 +221,7 @@ func (s *Service) GetBlock(w http.ResponseWriter, r *http.Request) {
 	if err != nil {
 		s.logger.WithError(err).Errorf("Retrieving block %d", blockIndex)
 
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), storeErrorStatus(err))
 
 		return
 	}
@@ -111,6 +231,182 @@ func (s *Service) GetBlock(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(block)
 }
 
+// GetBlocks returns the blocks between the from and to parameters, both
+// included. At most maxBlocksPage blocks are returned; clients fetch the next
+// page by starting from the index following the last returned block.
+func (s *Service) GetBlocks(w http.ResponseWriter, r *http.Request) {
+	lastBlockIndex := s.node.GetLastBlockIndex()
+
+	from, err := intParam(r, "from", 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	to, err := intParam(r, "to", lastBlockIndex)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if from < 0 {
+		http.Error(w, fmt.Sprintf("Invalid range [%d, %d]", from, to), http.StatusBadRequest)
+		return
+	}
+
+	if from > lastBlockIndex {
+		http.Error(w, fmt.Sprintf("Block %d not found", from), http.StatusNotFound)
+		return
+	}
+
+	if to < from {
+		http.Error(w, fmt.Sprintf("Invalid range [%d, %d]", from, to), http.StatusBadRequest)
+		return
+	}
+
+	if to > lastBlockIndex {
+		to = lastBlockIndex
+	}
+
+	if to-from+1 > maxBlocksPage {
+		to = from + maxBlocksPage - 1
+	}
+
+	blocks := []*hg.Block{}
+	for i := from; i <= to; i++ {
+		block, err := s.node.GetBlock(i)
+		if err != nil {
+			s.logger.WithError(err).Errorf("Retrieving block %d", i)
+
+			http.Error(w, err.Error(), storeErrorStatus(err))
+
+			return
+		}
+		blocks = append(blocks, block)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	json.NewEncoder(w).Encode(blocks)
+}
+
+// GetEvent ...
+func (s *Service) GetEvent(w http.ResponseWriter, r *http.Request) {
+	hash := mux.Vars(r)["hash"]
+
+	event, err := s.node.GetEvent(hash)
+
+	if err != nil {
+		s.logger.WithError(err).Errorf("Retrieving event %s", hash)
+
+		http.Error(w, err.Error(), storeErrorStatus(err))
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	json.NewEncoder(w).Encode(event)
+}
+
+// RoundEvent describes an event created in a round
+type RoundEvent struct {
+	Witness bool
+	Famous  string
+}
+
+// Round describes a round, its witnesses and their fame
+type Round struct {
+	Index          int
+	CreatedEvents  map[string]RoundEvent
+	ReceivedEvents []string
+	Witnesses      []string
+}
+
+// GetRound ...
+func (s *Service) GetRound(w http.ResponseWriter, r *http.Request) {
+	param := mux.Vars(r)["index"]
+
+	roundIndex, err := strconv.Atoi(param)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	roundInfo, err := s.node.GetRound(roundIndex)
+
+	if err != nil {
+		s.logger.WithError(err).Errorf("Retrieving round %d", roundIndex)
+
+		http.Error(w, err.Error(), storeErrorStatus(err))
+
+		return
+	}
+
+	round := Round{
+		Index:          roundIndex,
+		CreatedEvents:  make(map[string]RoundEvent),
+		ReceivedEvents: roundInfo.ReceivedEvents,
+		Witnesses:      roundInfo.Witnesses(),
+	}
+
+	for h, e := range roundInfo.CreatedEvents {
+		round.CreatedEvents[h] = RoundEvent{
+			Witness: e.Witness,
+			Famous:  e.Famous.String(),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	json.NewEncoder(w).Encode(round)
+}
+
+// GetPeerSet returns the validator-set effective at a given round
+func (s *Service) GetPeerSet(w http.ResponseWriter, r *http.Request) {
+	param := mux.Vars(r)["round"]
+
+	round, err := strconv.Atoi(param)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	peers, err := s.node.GetPeerSet(round)
+
+	if err != nil {
+		s.logger.WithError(err).Errorf("Retrieving peer-set %d", round)
+
+		http.Error(w, err.Error(), storeErrorStatus(err))
+
+		return
+	}
+
+	returnPeerSet(w, r, peers)
+}
+
+// GetAllPeerSets returns the history of validator-sets, indexed by the round
+// from which they are effective
+func (s *Service) GetAllPeerSets(w http.ResponseWriter, r *http.Request) {
+	peerSets, err := s.node.GetAllPeerSets()
+
+	if err != nil {
+		s.logger.WithError(err).Error("Retrieving peer-sets")
+
+		http.Error(w, err.Error(), storeErrorStatus(err))
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	json.NewEncoder(w).Encode(peerSets)
+}
+
 // GetGraph ...
 func (s *Service) GetGraph(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
@@ -122,9 +418,26 @@ func (s *Service) GetGraph(w http.ResponseWriter, r *http.Request) {
 	encoder.Encode(res)
 }
 
-// GetPeers ...
+// GetPeers returns the current peers along with their health
 func (s *Service) GetPeers(w http.ResponseWriter, r *http.Request) {
-	returnPeerSet(w, r, s.node.GetPeers())
+	statuses := []PeerStatus{}
+	for _, p := range s.node.GetPeers() {
+		statuses = append(statuses, PeerStatus{
+			Peer:   p,
+			Health: s.node.GetPeerHealth(p.ID()),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	json.NewEncoder(w).Encode(statuses)
+}
+
+// PeerStatus is a peer along with its health, as seen by this node. Health is
+// omitted for peers which were never contacted.
+type PeerStatus struct {
+	*peers.Peer
+	Health *node.PeerHealth `json:",omitempty"`
 }
 
 // GetGenesisPeers ...
@@ -132,6 +445,74 @@ func (s *Service) GetGenesisPeers(w http.ResponseWriter, r *http.Request) {
 	returnPeerSet(w, r, s.node.GetGenesisPeers())
 }
 
+// GetEvents streams the node's Notifications as server-sent events until the
+// client disconnects or the node shuts down.
+func (s *Service) GetEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	sub := s.node.Subscribe(eventsBufferSize)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	flusher.Flush()
+
+	for {
+		select {
+		case notif, ok := <-sub.Notifications():
+			if !ok {
+				return
+			}
+
+			data, err := json.Marshal(notif)
+			if err != nil {
+				s.logger.WithError(err).Error("Marshalling Notification")
+				return
+			}
+
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", notif.Type, data); err != nil {
+				return
+			}
+
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// intParam parses an optional integer query parameter
+func intParam(r *http.Request, name string, defaultValue int) (int, error) {
+	param := r.URL.Query().Get(name)
+	if param == "" {
+		return defaultValue, nil
+	}
+
+	value, err := strconv.Atoi(param)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %s parameter %s", name, param)
+	}
+
+	return value, nil
+}
+
+// storeErrorStatus returns the HTTP status code corresponding to an error
+// returned by the Store
+func storeErrorStatus(err error) int {
+	if common.Is(err, common.KeyNotFound) ||
+		common.Is(err, common.TooLate) ||
+		common.Is(err, common.NoPeerSet) {
+		return http.StatusNotFound
+	}
+
+	return http.StatusInternalServerError
+}
+
 func returnPeerSet(w http.ResponseWriter, r *http.Request, peers []*peers.Peer) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abassian/huron/src/common"
+	bkeys "github.com/abassian/huron/src/crypto/keys"
+	hg "github.com/abassian/huron/src/hashgraph"
+	"github.com/abassian/huron/src/net"
+	"github.com/abassian/huron/src/node"
+	"github.com/abassian/huron/src/peers"
+	"github.com/abassian/huron/src/proxy"
+	dummy "github.com/abassian/huron/src/proxy/dummy"
+	"github.com/sirupsen/logrus"
+)
+
+//newTestNode creates a lone validator, over an in-memory transport, which
+//commits Blocks on its own once it runs. The node does not log to the test,
+//because its goroutines may still log after the test completes.
+func newTestNode(t *testing.T) *node.Node {
+	return newTestNodeWithStore(hg.NewInmemStore(node.DefaultConfig().CacheSize), t)
+}
+
+//newTestNodeWithStore creates a test node on the given Store
+func newTestNodeWithStore(store hg.Store, t *testing.T) *node.Node {
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+
+	return newTestNodeWith(store, dummy.NewInmemDummyClient(logger), t)
+}
+
+//newTestNodeWith creates a test node on the given Store, in front of the given
+//App
+func newTestNodeWith(store hg.Store, app proxy.AppProxy, t *testing.T) *node.Node {
+	key, err := bkeys.GenerateECDSAKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr, trans := net.NewInmemTransport("")
+	peer := peers.NewPeer(bkeys.PublicKeyHex(&key.PublicKey), addr, "node0")
+	peerSet := peers.NewPeerSet([]*peers.Peer{peer})
+
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+
+	conf := node.DefaultConfig()
+	conf.Logger = logger
+	conf.HeartbeatTimeout = 5 * time.Millisecond
+
+	n := node.NewNode(conf,
+		node.NewValidator(key, peer.Moniker),
+		peerSet,
+		peerSet,
+		store,
+		trans,
+		app)
+
+	if err := n.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	return n
+}
+
+//newTestService creates a Service with the given Config in front of a new
+//test node. The node is not running.
+func newTestService(config Config, t *testing.T) *Service {
+	s, err := NewService("127.0.0.1:0", config, newTestNode(t), common.NewTestLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func TestServeShutdown(t *testing.T) {
+	s := newTestService(Config{}, t)
+	defer s.node.Shutdown()
+
+	served := make(chan error)
+	go func() {
+		served <- s.Serve()
+	}()
+
+	//Shutdown may be called before or after Serve starts listening
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-served:
+		if err != http.ErrServerClosed {
+			t.Fatalf("Serve should return http.ErrServerClosed, not %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after Shutdown")
+	}
+}
+
+//runTestService starts a test node and serves a Service, with the given
+//Config, in front of it
+func runTestService(config Config, t *testing.T) (*Service, *httptest.Server) {
+	s := newTestService(config, t)
+	s.node.RunAsync(true)
+
+	return s, httptest.NewServer(s.server.Handler)
+}
+
+//commitBlocks submits transactions through the /tx endpoint, waiting for each
+//to be committed, until the node has committed n Blocks
+func commitBlocks(ts *httptest.Server, s *Service, n int, t *testing.T) {
+	for i := 0; s.node.GetLastBlockIndex() < n-1; i++ {
+		resp := postTx(ts, fmt.Sprintf("commit%d", i), "?wait=true&timeout=5s", t)
+		if resp.BlockIndex == nil {
+			t.Fatalf("Transaction %d should be committed", i)
+		}
+	}
+}
+
+func TestGetBlocks(t *testing.T) {
+	s, ts := runTestService(Config{AdminToken: testAdminToken}, t)
+	defer s.node.Shutdown()
+	defer ts.Close()
+
+	commitBlocks(ts, s, 3, t)
+	last := s.node.GetLastBlockIndex()
+
+	cases := []struct {
+		query   string
+		status  int
+		indexes []int
+	}{
+		{"", http.StatusOK, nil},
+		{"?from=1&to=1", http.StatusOK, []int{1}},
+		{fmt.Sprintf("?from=1&to=%d", last+10), http.StatusOK, nil},
+		{"?from=x", http.StatusBadRequest, nil},
+		{"?from=2&to=1", http.StatusBadRequest, nil},
+		{"?from=-1", http.StatusBadRequest, nil},
+		{fmt.Sprintf("?from=%d", last+1), http.StatusNotFound, nil},
+	}
+
+	for _, c := range cases {
+		resp, err := http.Get(ts.URL + "/blocks" + c.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != c.status {
+			resp.Body.Close()
+			t.Fatalf("/blocks%s should return %d, not %d", c.query, c.status, resp.StatusCode)
+		}
+
+		if c.status == http.StatusOK {
+			blocks := []*hg.Block{}
+			if err := json.NewDecoder(resp.Body).Decode(&blocks); err != nil {
+				t.Fatal(err)
+			}
+
+			//Without a range, or past the last Block, the page ends at
+			//the last Block
+			if c.indexes == nil {
+				from := 0
+				if strings.Contains(c.query, "from=1") {
+					from = 1
+				}
+				for i := from; i <= last; i++ {
+					c.indexes = append(c.indexes, i)
+				}
+			}
+
+			indexes := []int{}
+			for _, b := range blocks {
+				indexes = append(indexes, b.Index())
+			}
+			if !reflect.DeepEqual(indexes, c.indexes) {
+				t.Fatalf("/blocks%s should return Blocks %v, not %v", c.query, c.indexes, indexes)
+			}
+		}
+
+		resp.Body.Close()
+	}
+
+	resp, err := http.Get(fmt.Sprintf("%s/block/%d", ts.URL, last+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("A missing Block should return 404, not %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/abassian/huron/src/common"
+	"github.com/abassian/huron/src/crypto"
+	hg "github.com/abassian/huron/src/hashgraph"
+	"github.com/abassian/huron/src/node"
+)
+
+const (
+	// maxTxRequestSize is the maximum size of the body of a /tx or /txs
+	// request
+	maxTxRequestSize = 10 << 20
+
+	// defaultTxWaitTimeout is how long a request waits for its transactions to
+	// be committed, unless a timeout parameter is specified
+	defaultTxWaitTimeout = 30 * time.Second
+
+	// txWaitBufferSize is the number of Notifications buffered while waiting
+	// for transactions to be committed
+	txWaitBufferSize = 1000
+)
+
+// TxResponse is returned for every submitted transaction. BlockIndex is only
+// set when the request waited for the transaction to be committed.
+type TxResponse struct {
+	Hash       string `json:"hash"`
+	BlockIndex *int   `json:"block_index,omitempty"`
+}
+
+// txHash returns the hex-encoded SHA256 of a transaction
+func txHash(tx []byte) string {
+	return common.EncodeToString(crypto.SHA256(tx))
+}
+
+// PostTx submits the body of the request as a single transaction. With the
+// wait parameter, it only responds once the transaction is committed.
+func (s *Service) PostTx(w http.ResponseWriter, r *http.Request) {
+	wait, timeout, err := parseWait(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxTxRequestSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if len(tx) == 0 {
+		http.Error(w, "Empty transaction", http.StatusBadRequest)
+		return
+	}
+
+	resps, status := s.submitTxs(r, [][]byte{tx}, wait, timeout)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	json.NewEncoder(w).Encode(resps[0])
+}
+
+// PostTxs submits a batch of transactions, provided as a JSON array of
+// base64-encoded transactions. With the wait parameter, it only responds once
+// all the transactions are committed.
+func (s *Service) PostTxs(w http.ResponseWriter, r *http.Request) {
+	wait, timeout, err := parseWait(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var txs [][]byte
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxTxRequestSize))
+	if err := dec.Decode(&txs); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if len(txs) == 0 {
+		http.Error(w, "Empty batch", http.StatusBadRequest)
+		return
+	}
+
+	for i, tx := range txs {
+		if len(tx) == 0 {
+			http.Error(w, fmt.Sprintf("Empty transaction %d", i), http.StatusBadRequest)
+			return
+		}
+	}
+
+	resps, status := s.submitTxs(r, txs, wait, timeout)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	json.NewEncoder(w).Encode(resps)
+}
+
+// parseWait reads the wait and timeout parameters of a request
+func parseWait(r *http.Request) (bool, time.Duration, error) {
+	wait := r.URL.Query().Get("wait") == "true"
+
+	timeout := defaultTxWaitTimeout
+	if t := r.URL.Query().Get("timeout"); t != "" {
+		d, err := time.ParseDuration(t)
+		if err != nil {
+			return false, 0, fmt.Errorf("Parsing timeout parameter: %s", err)
+		}
+		timeout = d
+	}
+
+	return wait, timeout, nil
+}
+
+// submitTxs submits the transactions to the node and, if wait is set, waits
+// for them to be committed. It returns the responses and the HTTP status code.
+func (s *Service) submitTxs(r *http.Request, txs [][]byte, wait bool, timeout time.Duration) ([]*TxResponse, int) {
+	resps := make([]*TxResponse, len(txs))
+	pending := make(map[string][]*TxResponse)
+	for i, tx := range txs {
+		resps[i] = &TxResponse{Hash: txHash(tx)}
+		pending[resps[i].Hash] = append(pending[resps[i].Hash], resps[i])
+	}
+
+	//Subscribe before submitting so that no block is missed, and start
+	//looking for the transactions after the last committed block
+	var sub *node.Subscription
+	var next int
+	if wait {
+		sub = s.node.Subscribe(txWaitBufferSize, node.BlockCommitted)
+		defer sub.Close()
+
+		next = s.node.GetLastCommittedBlockIndex() + 1
+	}
+
+	for _, tx := range txs {
+		if err := s.node.SubmitTx(tx); err != nil {
+			s.logger.WithError(err).Error("Submitting transaction")
+			return resps, http.StatusServiceUnavailable
+		}
+	}
+
+	if !wait {
+		return resps, http.StatusAccepted
+	}
+
+	return resps, s.waitTxs(r, sub, pending, next, timeout)
+}
+
+// waitTxs waits until the pending transactions are found in blocks committed
+// from index next, setting the BlockIndex of their responses. It returns the
+// HTTP status code. Blocks are taken from the BlockCommitted Notifications of
+// sub; if some Notifications were dropped, the committed blocks are read back
+// from the Store instead.
+func (s *Service) waitTxs(r *http.Request, sub *node.Subscription, pending map[string][]*TxResponse, next int, timeout time.Duration) int {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	dropped := 0
+
+	for len(pending) > 0 {
+		select {
+		case notif, ok := <-sub.Notifications():
+			if !ok {
+				return http.StatusServiceUnavailable
+			}
+
+			if d := sub.Dropped(); d > dropped {
+				dropped = d
+
+				for last := s.node.GetLastCommittedBlockIndex(); next <= last; next++ {
+					block, err := s.node.GetBlock(next)
+					if err != nil {
+						s.logger.WithError(err).Errorf("Reading block %d", next)
+						continue
+					}
+					matchTxs(block, pending)
+				}
+
+				continue
+			}
+
+			//Blocks already read from the Store are skipped
+			if index := notif.Block.Index(); index >= next {
+				matchTxs(notif.Block, pending)
+				next = index + 1
+			}
+		case <-timer.C:
+			return http.StatusGatewayTimeout
+		case <-r.Context().Done():
+			return http.StatusGatewayTimeout
+		}
+	}
+
+	return http.StatusOK
+}
+
+// matchTxs sets the BlockIndex of the pending responses whose transactions are
+// in the block, and removes them from pending
+func matchTxs(block *hg.Block, pending map[string][]*TxResponse) {
+	index := block.Index()
+	for _, tx := range block.Transactions() {
+		h := txHash(tx)
+		if rs, ok := pending[h]; ok {
+			rs[0].BlockIndex = &index
+			if len(rs) == 1 {
+				delete(pending, h)
+			} else {
+				pending[h] = rs[1:]
+			}
+		}
+	}
+}
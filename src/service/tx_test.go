@@ -0,0 +1,194 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/abassian/huron/src/node"
+)
+
+//postTx submits a transaction to the /tx endpoint with the admin token
+func postTx(ts *httptest.Server, tx string, query string, t *testing.T) *TxResponse {
+	req, err := http.NewRequest("POST", ts.URL+"/tx"+query, bytes.NewBufferString(tx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	want := http.StatusAccepted
+	if query != "" {
+		want = http.StatusOK
+	}
+	if resp.StatusCode != want {
+		t.Fatalf("Submitting %s should return %d, not %d", tx, want, resp.StatusCode)
+	}
+
+	var txResp TxResponse
+	if err := json.NewDecoder(resp.Body).Decode(&txResp); err != nil {
+		t.Fatal(err)
+	}
+
+	if txResp.Hash != txHash([]byte(tx)) {
+		t.Fatalf("Hash of %s should be %s, not %s", tx, txHash([]byte(tx)), txResp.Hash)
+	}
+
+	return &txResp
+}
+
+func TestPostTx(t *testing.T) {
+	s, ts := runTestService(Config{AdminToken: testAdminToken}, t)
+	defer s.node.Shutdown()
+	defer ts.Close()
+
+	//Without wait, the transaction is only accepted
+	if resp := postTx(ts, "async", "", t); resp.BlockIndex != nil {
+		t.Fatalf("An accepted transaction should not have a block index")
+	}
+
+	//With wait, the response gives the Block which contains the transaction
+	resp := postTx(ts, "sync", "?wait=true&timeout=5s", t)
+	if resp.BlockIndex == nil {
+		t.Fatalf("A committed transaction should have a block index")
+	}
+
+	block, err := s.node.GetBlock(*resp.BlockIndex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, tx := range block.Transactions() {
+		if string(tx) == "sync" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("Block %d should contain the transaction", *resp.BlockIndex)
+	}
+}
+
+func TestPostTxs(t *testing.T) {
+	s, ts := runTestService(Config{AdminToken: testAdminToken}, t)
+	defer s.node.Shutdown()
+	defer ts.Close()
+
+	//The same transaction twice is waited for twice
+	txs := [][]byte{[]byte("a"), []byte("b"), []byte("a")}
+	body, _ := json.Marshal(txs)
+
+	req, _ := http.NewRequest("POST", ts.URL+"/txs?wait=true&timeout=5s", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Submitting a batch should return 200, not %d", resp.StatusCode)
+	}
+
+	var txResps []*TxResponse
+	if err := json.NewDecoder(resp.Body).Decode(&txResps); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(txResps) != len(txs) {
+		t.Fatalf("There should be %d responses, not %d", len(txs), len(txResps))
+	}
+	for i, r := range txResps {
+		if r.Hash != txHash(txs[i]) || r.BlockIndex == nil {
+			t.Fatalf("Transaction %d should be committed, got %#v", i, r)
+		}
+	}
+}
+
+func TestPostTxErrors(t *testing.T) {
+	s := newTestService(Config{AdminToken: testAdminToken}, t)
+	defer s.node.Shutdown()
+
+	cases := []struct {
+		path string
+		body string
+	}{
+		{"/tx", ""},
+		{"/tx?timeout=x", "tx"},
+		{"/txs", "not json"},
+		{"/txs", "[]"},
+		{"/txs", `["dHg=", ""]`},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest("POST", c.path, bytes.NewBufferString(c.body))
+		req.Header.Set("Authorization", "Bearer "+testAdminToken)
+
+		if code := serve(s, req); code != http.StatusBadRequest {
+			t.Fatalf("POST %s %q should return 400, not %d", c.path, c.body, code)
+		}
+	}
+}
+
+func TestPostTxTimeout(t *testing.T) {
+	//Gossip is paused, so the transaction is never committed
+	s, ts := runTestService(Config{AdminToken: testAdminToken}, t)
+	defer s.node.Shutdown()
+	defer ts.Close()
+	s.node.PauseGossip()
+
+	req, _ := http.NewRequest("POST", ts.URL+"/tx?wait=true&timeout=100ms", bytes.NewBufferString("late"))
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusGatewayTimeout {
+		t.Fatalf("A transaction which is not committed in time should return 504, not %d", resp.StatusCode)
+	}
+}
+
+func TestWaitTxsDropped(t *testing.T) {
+	s, ts := runTestService(Config{AdminToken: testAdminToken}, t)
+	defer s.node.Shutdown()
+	defer ts.Close()
+
+	//The subscription only has room for the first of three Blocks
+	sub := s.node.Subscribe(1, node.BlockCommitted)
+	defer sub.Close()
+	next := s.node.GetLastCommittedBlockIndex() + 1
+
+	var last *TxResponse
+	for _, tx := range []string{"dropped0", "dropped1", "dropped2"} {
+		last = postTx(ts, tx, "?wait=true&timeout=5s", t)
+	}
+
+	if d := sub.Dropped(); d == 0 {
+		t.Fatalf("The subscription should have dropped Notifications")
+	}
+
+	//The Block of the last transaction is read back from the Store
+	resp := &TxResponse{Hash: last.Hash}
+	pending := map[string][]*TxResponse{last.Hash: {resp}}
+
+	status := s.waitTxs(httptest.NewRequest("POST", "/tx", nil), sub, pending, next, time.Second)
+	if status != http.StatusOK {
+		t.Fatalf("Waiting for a dropped Block should return 200, not %d", status)
+	}
+
+	if resp.BlockIndex == nil || *resp.BlockIndex != *last.BlockIndex {
+		t.Fatalf("The transaction should be found in Block %d", *last.BlockIndex)
+	}
+}